@@ -45,11 +45,18 @@ var helpText = func(colors logger.Colors) string {
   --watch               Watch mode: rebuild on file system changes
 
 ` + colors.Bold + `Advanced options:` + colors.Reset + `
+  --alias:P=R               Rewrite imports of package P to the path R before
+                            resolving (also applies inside node_modules)
   --asset-names=...         Path template to use for "file" loader files
                             (default "[name]-[hash]")
   --banner:T=...            Text to be prepended to each output file of type T
                             where T is one of: css | js
+  --cache-dir=...           Store parse results in this directory and reuse
+                            them in later builds of unchanged files
+  --cache-max-size=...      Size limit in bytes for --cache-dir (default 512mb)
   --charset=utf8            Do not escape UTF-8 code points
+  --chrome-trace=...        Write the time spent in each phase of the build to
+                            this file as Chrome trace events
   --chunk-names=...         Path template to use for code splitting chunks
                             (default "[name]-[hash]")
   --color=...               Force use of color terminal escapes (true | false)
@@ -655,6 +655,8 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 					kind = "import-rule"
 				case api.ResolveCSSURLToken:
 					kind = "url-token"
+				case api.ResolveCSSComposesFrom:
+					kind = "composes-from"
 
 				default:
 					panic("Internal error")
@@ -670,6 +672,7 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 					"resolveDir": args.ResolveDir,
 					"kind":       kind,
 					"pluginData": args.PluginData,
+					"assertions": encodeStringMap(args.Assertions),
 				}).(map[string]interface{})
 
 				if value, ok := response["id"]; ok {
@@ -696,6 +699,9 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 				if value, ok := response["external"]; ok {
 					result.External = value.(bool)
 				}
+				if value, ok := response["suffix"]; ok {
+					result.Suffix = value.(string)
+				}
 				if value, ok := response["pluginData"]; ok {
 					result.PluginData = value.(int)
 				}
@@ -735,7 +741,9 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 					"ids":        ids,
 					"path":       args.Path,
 					"namespace":  args.Namespace,
+					"suffix":     args.Suffix,
 					"pluginData": args.PluginData,
+					"assertions": encodeStringMap(args.Assertions),
 				}).(map[string]interface{})
 
 				if value, ok := response["id"]; ok {
@@ -896,6 +904,14 @@ func decodeStringArray(values []interface{}) []string {
 	return strings
 }
 
+func encodeStringMap(strings map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(strings))
+	for key, value := range strings {
+		values[key] = value
+	}
+	return values
+}
+
 func encodeOutputFiles(outputFiles []api.OutputFile) []interface{} {
 	values := make([]interface{}, len(outputFiles))
 	for i, outputFile := range outputFiles {
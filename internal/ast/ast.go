@@ -32,6 +32,9 @@ const (
 
 	// A CSS "url(...)" token
 	ImportURL
+
+	// A CSS "composes" declaration with a "from" clause in a CSS module
+	ImportComposesFrom
 )
 
 func (kind ImportKind) StringForMetafile() string {
@@ -48,6 +51,8 @@ func (kind ImportKind) StringForMetafile() string {
 		return "import-rule"
 	case ImportURL:
 		return "url-token"
+	case ImportComposesFrom:
+		return "composes-from"
 	case ImportEntryPoint:
 		return "entry-point"
 	default:
@@ -56,13 +61,17 @@ func (kind ImportKind) StringForMetafile() string {
 }
 
 func (kind ImportKind) IsFromCSS() bool {
-	return kind == ImportAt || kind == ImportURL
+	return kind == ImportAt || kind == ImportURL || kind == ImportComposesFrom
 }
 
 type ImportRecord struct {
 	Range logger.Range
 	Path  logger.Path
 
+	// These are the entries in an "assert { type: 'json' }" clause after the
+	// import path, or nil if there was no such clause
+	Assertions *[]AssertEntry
+
 	// The resolved source index for an internal import (within the bundle) or
 	// nil for an external import (not included in the bundle)
 	SourceIndex Index32
@@ -98,6 +107,14 @@ type ImportRecord struct {
 	Kind ImportKind
 }
 
+type AssertEntry struct {
+	Key             []uint16 // An identifier or a string
+	Value           []uint16 // Always a string
+	KeyLoc          logger.Loc
+	ValueLoc        logger.Loc
+	PreferQuotedKey bool
+}
+
 // This stores a 32-bit index where the zero value is an invalid index. This is
 // a better alternative to storing the index as a pointer since that has the
 // same properties but takes up more space and costs an extra pointer traversal.
@@ -166,6 +166,7 @@ type parseArgs struct {
 	warnIfUnusedData *resolver.IgnoreIfUnusedData
 	importPathRange  logger.Range
 	pluginData       interface{}
+	importAssertions map[string]string
 	options          config.Options
 	results          chan parseResult
 	inject           chan config.InjectedFile
@@ -208,6 +209,7 @@ func parseFile(args parseArgs) {
 		}
 		absResolveDir = args.options.Stdin.AbsResolveDir
 	} else {
+		span := args.options.Tracer.Begin("Load", source.PrettyPath)
 		result, ok := runOnLoadPlugins(
 			args.options.Plugins,
 			args.res,
@@ -218,8 +220,10 @@ func parseFile(args parseArgs) {
 			args.importSource,
 			args.importPathRange,
 			args.pluginData,
+			args.importAssertions,
 			args.options.WatchMode,
 		)
+		span.End()
 		if !ok {
 			if args.inject != nil {
 				args.inject <- config.InjectedFile{
@@ -255,6 +259,7 @@ func parseFile(args parseArgs) {
 		},
 	}
 
+	span := args.options.Tracer.Begin("Parse", source.PrettyPath)
 	switch loader {
 	case config.LoaderJS:
 		ast, ok := args.caches.JSCache.Parse(args.log, source, js_parser.OptionsFromConfig(&args.options))
@@ -280,11 +285,13 @@ func parseFile(args parseArgs) {
 		result.file.repr = &reprJS{ast: ast}
 		result.ok = ok
 
-	case config.LoaderCSS:
+	case config.LoaderCSS, config.LoaderLocalCSS:
 		ast := args.caches.CSSCache.Parse(args.log, source, css_parser.Options{
 			MangleSyntax:           args.options.MangleSyntax,
 			RemoveWhitespace:       args.options.RemoveWhitespace,
 			UnsupportedCSSFeatures: args.options.UnsupportedCSSFeatures,
+			LocalCSS:               loader == config.LoaderLocalCSS,
+			CSSPrefixData:          args.options.CSSPrefixData,
 		})
 		result.file.repr = &reprCSS{ast: ast}
 		result.ok = true
@@ -399,6 +406,7 @@ func parseFile(args parseArgs) {
 		}
 		args.log.AddRangeError(args.importSource, args.importPathRange, message)
 	}
+	span.End()
 
 	// This must come before we send on the "results" channel to avoid deadlock
 	if args.inject != nil {
@@ -433,6 +441,7 @@ func parseFile(args parseArgs) {
 		result.resolveResults = make([]*resolver.ResolveResult, len(records))
 
 		if len(records) > 0 {
+			span := args.options.Tracer.Begin("Resolve", source.PrettyPath)
 			resolverCache := make(map[ast.ImportKind]map[string]*resolver.ResolveResult)
 			resolveMap := make(map[string]string)
 			result.resolveMap = &resolveMap
@@ -498,11 +507,12 @@ func parseFile(args parseArgs) {
 						&args.caches.FSCache,
 						&source,
 						record.Range,
-						source.KeyPath.Namespace,
+						source.KeyPath,
 						record.Path.Text,
 						record.Kind,
 						absResolveDir,
 						pluginData,
+						importAssertionsToMap(record.Assertions),
 					)
 				}
 				cache[record.Path.Text] = resolveResult
@@ -557,6 +567,7 @@ func parseFile(args parseArgs) {
 
 				result.resolveResults[importRecordIndex] = resolveResult
 			}
+			span.End()
 		}
 	}
 
@@ -739,6 +750,17 @@ func logPluginMessages(
 	return didLogError
 }
 
+func importAssertionsToMap(assertions *[]ast.AssertEntry) map[string]string {
+	if assertions == nil {
+		return nil
+	}
+	result := make(map[string]string, len(*assertions))
+	for _, entry := range *assertions {
+		result[js_lexer.UTF16ToString(entry.Key)] = js_lexer.UTF16ToString(entry.Value)
+	}
+	return result
+}
+
 type ResolveKeyVal struct {
 	key string
 	val string
@@ -752,26 +774,24 @@ func runOnResolvePlugins(
 	fsCache *cache.FSCache,
 	importSource *logger.Source,
 	importPathRange logger.Range,
-	importNamespace string,
+	importer logger.Path,
 	path string,
 	kind ast.ImportKind,
 	absResolveDir string,
 	pluginData interface{},
+	importAssertions map[string]string,
 ) (*resolver.ResolveResult, bool, resolver.DebugMeta, *ResolveKeyVal) {
 	resolverArgs := config.OnResolveArgs{
 		Path:       path,
+		Importer:   importer,
 		ResolveDir: absResolveDir,
 		Kind:       kind,
 		PluginData: pluginData,
+		Assertions: importAssertions,
 	}
 	applyPath := logger.Path{
 		Text:      path,
-		Namespace: importNamespace,
-	}
-	if importSource != nil {
-		resolverArgs.Importer = importSource.KeyPath
-	} else {
-		resolverArgs.Importer.Namespace = importNamespace
+		Namespace: importer.Namespace,
 	}
 
 	// Apply resolver plugins in order until one succeeds
@@ -875,6 +895,39 @@ func runOnResolvePlugins(
 	}
 }
 
+// This lets plugins resolve a path the same way an import statement would be
+// resolved, including running the "onResolve" callbacks of the given plugins.
+// Messages are logged without a location since there is no import statement.
+func RunOnResolvePlugins(
+	plugins []config.Plugin,
+	res resolver.Resolver,
+	log logger.Log,
+	fs fs.FS,
+	fsCache *cache.FSCache,
+	importer logger.Path,
+	path string,
+	kind ast.ImportKind,
+	absResolveDir string,
+	pluginData interface{},
+) (*resolver.ResolveResult, bool, resolver.DebugMeta) {
+	result, didLogError, debug, _ := runOnResolvePlugins(
+		plugins,
+		res,
+		log,
+		fs,
+		fsCache,
+		nil,
+		logger.Range{},
+		importer,
+		path,
+		kind,
+		absResolveDir,
+		pluginData,
+		nil,
+	)
+	return result, didLogError, debug
+}
+
 type loaderPluginResult struct {
 	loader        config.Loader
 	absResolveDir string
@@ -892,11 +945,13 @@ func runOnLoadPlugins(
 	importSource *logger.Source,
 	importPathRange logger.Range,
 	pluginData interface{},
+	importAssertions map[string]string,
 	isWatchMode bool,
 ) (loaderPluginResult, bool) {
 	loaderArgs := config.OnLoadArgs{
 		Path:       source.KeyPath,
 		PluginData: pluginData,
+		Assertions: importAssertions,
 	}
 
 	// Apply loader plugins in order until one succeeds
@@ -1061,6 +1116,36 @@ type EntryPoint struct {
 	IsFile     bool
 }
 
+// Runs all "onStart" plugin callbacks. This happens before scanning, even if
+// the options turned out to be invalid, since plugins use these to reset state
+// that their "onResolve", "onLoad" and "onEnd" callbacks depend on.
+func RunOnStartCallbacks(log logger.Log, res resolver.Resolver, options config.Options) {
+	defer options.Tracer.Begin("OnStart", "").End()
+	var onStarts []config.OnStart
+	for _, plugin := range options.Plugins {
+		onStarts = append(onStarts, plugin.OnStart...)
+	}
+	if len(onStarts) == 0 {
+		return
+	}
+
+	// The callbacks run in parallel but their messages are logged in order
+	results := make([]config.OnStartResult, len(onStarts))
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(len(onStarts))
+	for i, onStart := range onStarts {
+		go func(i int, onStart config.OnStart) {
+			results[i] = onStart.Callback()
+			waitGroup.Done()
+		}(i, onStart)
+	}
+	waitGroup.Wait()
+
+	for i, result := range results {
+		logPluginMessages(res, log, onStarts[i].Name, result.Msgs, result.ThrownError, nil, logger.Range{})
+	}
+}
+
 func ScanBundle(
 	log logger.Log,
 	fs fs.FS,
@@ -1075,6 +1160,7 @@ func ScanBundle(
 	}
 
 	applyOptionDefaults(&options)
+	defer options.Tracer.Begin("ScanBundle", "").End()
 
 	s := scanner{
 		log:           log,
@@ -1091,14 +1177,22 @@ func ScanBundle(
 	s.results = append(s.results, parseResult{})
 	s.remaining++
 	go func() {
+		span := options.Tracer.Begin("Parse", "<runtime>")
 		source, ast, ok := globalRuntimeCache.parseRuntime(&options)
+		span.End()
 		s.resultChannel <- parseResult{file: file{source: source, repr: &reprJS{ast: ast}}, ok: ok}
 	}()
 
 	s.preprocessInjectedFiles()
+	span := options.Tracer.Begin("Resolve entry points", "")
 	entryPointMeta := s.addEntryPoints(entryPoints)
+	span.End()
+	span = options.Tracer.Begin("Scan dependencies", "")
 	s.scanAllDependencies()
+	span.End()
+	span = options.Tracer.Begin("Process scanned files", "")
 	files := s.processScannedFiles()
+	span.End()
 
 	if log.Debug {
 		log.AddDebug(nil, logger.Loc{}, fmt.Sprintf("Ended the scan phase (%dms)", time.Since(start).Milliseconds()))
@@ -1138,6 +1232,7 @@ func (s *scanner) maybeParseFile(
 	importSource *logger.Source,
 	importPathRange logger.Range,
 	pluginData interface{},
+	importAssertions map[string]string,
 	kind inputKind,
 	inject chan config.InjectedFile,
 ) uint32 {
@@ -1224,6 +1319,7 @@ func (s *scanner) maybeParseFile(
 		warnIfUnusedData: resolveResult.IgnorePrimaryIfUnused,
 		importPathRange:  importPathRange,
 		pluginData:       pluginData,
+		importAssertions: importAssertions,
 		options:          optionsClone,
 		results:          s.resultChannel,
 		inject:           inject,
@@ -1319,7 +1415,7 @@ func (s *scanner) preprocessInjectedFiles() {
 		}
 
 		channel := make(chan config.InjectedFile)
-		s.maybeParseFile(*resolveResult, prettyPath, nil, logger.Range{}, nil, inputKindNormal, channel)
+		s.maybeParseFile(*resolveResult, prettyPath, nil, logger.Range{}, nil, nil, inputKindNormal, channel)
 
 		// Wait for the results in parallel. The results slice is large enough so
 		// it is not reallocated during the computations.
@@ -1360,7 +1456,7 @@ func (s *scanner) addEntryPoints(entryPoints []EntryPoint) []entryMeta {
 			}
 		}
 		resolveResult := resolver.ResolveResult{PathPair: resolver.PathPair{Primary: stdinPath}}
-		sourceIndex := s.maybeParseFile(resolveResult, s.res.PrettyPath(stdinPath), nil, logger.Range{}, nil, inputKindStdin, nil)
+		sourceIndex := s.maybeParseFile(resolveResult, s.res.PrettyPath(stdinPath), nil, logger.Range{}, nil, nil, inputKindStdin, nil)
 		entryMetas = append(entryMetas, entryMeta{
 			outputPath:  "stdin",
 			sourceIndex: sourceIndex,
@@ -1424,11 +1520,12 @@ func (s *scanner) addEntryPoints(entryPoints []EntryPoint) []entryMeta {
 				&s.caches.FSCache,
 				nil,
 				logger.Range{},
-				namespace,
+				logger.Path{Namespace: namespace},
 				entryPoint.InputPath,
 				ast.ImportEntryPoint,
 				entryPointAbsResolveDir,
 				nil,
+				nil,
 			)
 			if resolveResult != nil {
 				if resolveResult.IsExternal {
@@ -1456,7 +1553,7 @@ func (s *scanner) addEntryPoints(entryPoints []EntryPoint) []entryMeta {
 	for i, resolveResult := range entryPointResolveResults {
 		if resolveResult != nil {
 			prettyPath := s.res.PrettyPath(resolveResult.PathPair.Primary)
-			sourceIndex := s.maybeParseFile(*resolveResult, prettyPath, nil, logger.Range{}, resolveResult.PluginData, inputKindEntryPoint, nil)
+			sourceIndex := s.maybeParseFile(*resolveResult, prettyPath, nil, logger.Range{}, resolveResult.PluginData, nil, inputKindEntryPoint, nil)
 			outputPath := entryPoints[i].OutputPath
 			outputPathWasAutoGenerated := false
 
@@ -1616,9 +1713,12 @@ func (s *scanner) scanAllDependencies() {
 
 				path := resolveResult.PathPair.Primary
 				if !resolveResult.IsExternal && !pathIsAlwaysExternal(s.options, path) {
-					// Handle a path within the bundle
+					// Handle a path within the bundle. Note that if this file is imported
+					// more than once, only the assertions of the first import are passed
+					// to "onLoad" plugins since each file is only loaded once.
 					sourceIndex := s.maybeParseFile(*resolveResult, s.res.PrettyPath(path),
-						&result.file.source, record.Range, resolveResult.PluginData, inputKindNormal, nil)
+						&result.file.source, record.Range, resolveResult.PluginData,
+						importAssertionsToMap(record.Assertions), inputKindNormal, nil)
 					record.SourceIndex = ast.MakeIndex32(sourceIndex)
 				} else {
 					// If the path to the external module is relative to the source
@@ -1645,6 +1745,74 @@ func (s *scanner) scanAllDependencies() {
 	}
 }
 
+// The JavaScript stub for a CSS module exports an object that maps each local
+// name to its renamed version. Other names that it composes are included too,
+// separated by spaces. This object is empty for regular CSS files.
+func (s *scanner) cssModuleExports(sourceIndex uint32) js_ast.Expr {
+	repr := s.results[sourceIndex].file.repr.(*reprCSS)
+	properties := make([]js_ast.Property, 0, len(repr.ast.LocalNames))
+	for i := range repr.ast.LocalNames {
+		localName := &repr.ast.LocalNames[i]
+		names := s.appendComposedNames(nil, sourceIndex, localName, make(map[*css_ast.LocalName]bool))
+		properties = append(properties, js_ast.Property{
+			Key:   js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(localName.Original)}},
+			Value: &js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(strings.Join(names, " "))}},
+		})
+	}
+	return js_ast.Expr{Data: &js_ast.EObject{Properties: properties}}
+}
+
+func (s *scanner) appendComposedNames(
+	names []string,
+	sourceIndex uint32,
+	localName *css_ast.LocalName,
+	visited map[*css_ast.LocalName]bool,
+) []string {
+	// Guard against cycles and avoid including the same name twice
+	if visited[localName] {
+		return names
+	}
+	visited[localName] = true
+	names = append(names, localName.Local)
+
+	file := &s.results[sourceIndex].file
+	repr := file.repr.(*reprCSS)
+	for _, composes := range localName.Composes {
+		if composes.IsGlobal {
+			names = append(names, composes.Name)
+			continue
+		}
+
+		// Find the file that the composed name comes from
+		otherSourceIndex := sourceIndex
+		if composes.ImportRecordIndex.IsValid() {
+			record := &repr.ast.ImportRecords[composes.ImportRecordIndex.GetIndex()]
+			if !record.SourceIndex.IsValid() {
+				continue
+			}
+			otherSourceIndex = record.SourceIndex.GetIndex()
+		}
+		otherFile := &s.results[otherSourceIndex].file
+
+		// Look up the name in that file
+		found := false
+		if otherRepr, ok := otherFile.repr.(*reprCSS); ok {
+			for i := range otherRepr.ast.LocalNames {
+				if other := &otherRepr.ast.LocalNames[i]; other.Original == composes.Name {
+					names = s.appendComposedNames(names, otherSourceIndex, other, visited)
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			s.log.AddRangeWarning(&file.source, composes.Range,
+				fmt.Sprintf("The name %q is never declared in %q", composes.Name, otherFile.source.PrettyPath))
+		}
+	}
+	return names
+}
+
 func (s *scanner) processScannedFiles() []file {
 	// Now that all files have been scanned, process the final file import records
 	for i, result := range s.results {
@@ -1705,9 +1873,29 @@ func (s *scanner) processScannedFiles() []file {
 						js_printer.QuoteForJSON(record.Kind.StringForMetafile(), s.options.ASCIIOnly)))
 				}
 
+				// Validate that imports with "assert { type: 'json' }" were imported
+				// with the JSON loader. This matches the behavior of a real JavaScript
+				// runtime, which fails to load the module otherwise.
+				if record.Assertions != nil {
+					for _, entry := range *record.Assertions {
+						if js_lexer.UTF16EqualsString(entry.Key, "type") && js_lexer.UTF16EqualsString(entry.Value, "json") {
+							otherFile := &s.results[record.SourceIndex.GetIndex()].file
+							if otherFile.loader != config.LoaderJSON {
+								valueRange := result.file.source.RangeOfString(entry.ValueLoc)
+								assertRange := logger.Range{Loc: entry.KeyLoc, Len: valueRange.End() - entry.KeyLoc.Start}
+								s.log.AddRangeErrorWithNotes(&result.file.source, record.Range,
+									fmt.Sprintf("The file %q was loaded with the %q loader", otherFile.source.PrettyPath, config.LoaderToString[otherFile.loader]),
+									[]logger.MsgData{logger.RangeData(&result.file.source, assertRange,
+										"This import assertion requires the loader to be \"json\" instead:")})
+							}
+							break
+						}
+					}
+				}
+
 				switch record.Kind {
-				case ast.ImportAt, ast.ImportAtConditional:
-					// Using a JavaScript file with CSS "@import" is not allowed
+				case ast.ImportAt, ast.ImportAtConditional, ast.ImportComposesFrom:
+					// Using a JavaScript file with CSS "@import" or "composes" is not allowed
 					otherFile := &s.results[record.SourceIndex.GetIndex()].file
 					if _, ok := otherFile.repr.(*reprJS); ok {
 						s.log.AddRangeError(&result.file.source, record.Range,
@@ -1756,7 +1944,7 @@ func (s *scanner) processScannedFiles() []file {
 								file: file{
 									repr: &reprJS{
 										ast: js_parser.LazyExportAST(s.log, source,
-											js_parser.OptionsFromConfig(&s.options), js_ast.Expr{Data: &js_ast.EObject{}}, ""),
+											js_parser.OptionsFromConfig(&s.options), s.cssModuleExports(record.SourceIndex.GetIndex()), ""),
 										cssSourceIndex: ast.MakeIndex32(record.SourceIndex.GetIndex()),
 									},
 									source: source,
@@ -1920,6 +2108,9 @@ func DefaultExtensionToLoaderMap() map[string]config.Loader {
 		".css":  config.LoaderCSS,
 		".json": config.LoaderJSON,
 		".txt":  config.LoaderText,
+
+		// CSS modules
+		".module.css": config.LoaderLocalCSS,
 	}
 }
 
@@ -1974,6 +2165,7 @@ func (b *Bundle) Compile(log logger.Log, options config.Options, printAST PrintA
 	}
 
 	applyOptionDefaults(&options)
+	defer options.Tracer.Begin("Compile", "").End()
 
 	// The format can't be "preserve" while bundling
 	if options.Mode == config.ModeBundle && options.OutputFormat == config.FormatPreserve {
@@ -2000,8 +2192,10 @@ func (b *Bundle) Compile(log logger.Log, options config.Options, printAST PrintA
 			go func(i int, entryPoint entryMeta) {
 				entryPoints := []entryMeta{entryPoint}
 				reachableFiles := findReachableFiles(b.files, entryPoints)
+				span := options.Tracer.Begin("Link entry point", b.files[entryPoint.sourceIndex].source.PrettyPath)
 				c := newLinkerContext(&options, printAST, log, b.fs, b.res, b.files, entryPoints, reachableFiles, dataForSourceMaps)
 				resultGroups[i] = c.link()
+				span.End()
 				waitGroup.Done()
 			}(i, entryPoint)
 		}
@@ -2017,7 +2211,9 @@ func (b *Bundle) Compile(log logger.Log, options config.Options, printAST PrintA
 	// Also generate the metadata file if necessary
 	var metafileJSON string
 	if options.NeedsMetafile {
+		span := options.Tracer.Begin("Generate metafile", "")
 		metafileJSON = b.generateMetadataJSON(outputFiles, allReachableFiles, options.ASCIIOnly)
+		span.End()
 	}
 
 	if !options.WriteToStdout {
@@ -2144,41 +2340,50 @@ func (b *Bundle) computeDataForSourceMapsInParallel(options *config.Options, rea
 
 	for _, sourceIndex := range reachableFiles {
 		if f := &b.files[sourceIndex]; f.loader.CanHaveSourceMap() {
-			if repr, ok := f.repr.(*reprJS); ok {
-				waitGroup.Add(1)
-				go func(sourceIndex uint32, f *file, repr *reprJS) {
-					result := &results[sourceIndex]
-					result.lineOffsetTables = js_printer.GenerateLineOffsetTables(f.source.Contents, repr.ast.ApproximateLineCount)
-					sm := f.sourceMap
-					if !options.ExcludeSourcesContent {
-						if sm == nil {
-							// Simple case: no nested source map
-							result.quotedContents = [][]byte{js_printer.QuoteForJSON(f.source.Contents, options.ASCIIOnly)}
-						} else {
-							// Complex case: nested source map
-							result.quotedContents = make([][]byte, len(sm.Sources))
-							nullContents := []byte("null")
-							for i := range sm.Sources {
-								// Missing contents become a "null" literal
-								quotedContents := nullContents
-								if i < len(sm.SourcesContent) {
-									if value := sm.SourcesContent[i]; value.Quoted != "" {
-										if options.ASCIIOnly && !isASCIIOnly(value.Quoted) {
-											// Re-quote non-ASCII values if output is ASCII-only
-											quotedContents = js_printer.QuoteForJSON(js_lexer.UTF16ToString(value.Value), options.ASCIIOnly)
-										} else {
-											// Otherwise just use the value directly from the input file
-											quotedContents = []byte(value.Quoted)
-										}
+			var approximateLineCount int32
+			switch repr := f.repr.(type) {
+			case *reprJS:
+				approximateLineCount = repr.ast.ApproximateLineCount
+			case *reprCSS:
+				approximateLineCount = repr.ast.ApproximateLineCount
+			default:
+				continue
+			}
+			waitGroup.Add(1)
+			go func(sourceIndex uint32, f *file, approximateLineCount int32) {
+				span := options.Tracer.Begin("Source map data", f.source.PrettyPath)
+				result := &results[sourceIndex]
+				result.lineOffsetTables = js_printer.GenerateLineOffsetTables(f.source.Contents, approximateLineCount)
+				sm := f.sourceMap
+				if !options.ExcludeSourcesContent {
+					if sm == nil {
+						// Simple case: no nested source map
+						result.quotedContents = [][]byte{js_printer.QuoteForJSON(f.source.Contents, options.ASCIIOnly)}
+					} else {
+						// Complex case: nested source map
+						result.quotedContents = make([][]byte, len(sm.Sources))
+						nullContents := []byte("null")
+						for i := range sm.Sources {
+							// Missing contents become a "null" literal
+							quotedContents := nullContents
+							if i < len(sm.SourcesContent) {
+								if value := sm.SourcesContent[i]; value.Quoted != "" {
+									if options.ASCIIOnly && !isASCIIOnly(value.Quoted) {
+										// Re-quote non-ASCII values if output is ASCII-only
+										quotedContents = js_printer.QuoteForJSON(js_lexer.UTF16ToString(value.Value), options.ASCIIOnly)
+									} else {
+										// Otherwise just use the value directly from the input file
+										quotedContents = []byte(value.Quoted)
 									}
 								}
-								result.quotedContents[i] = quotedContents
 							}
+							result.quotedContents[i] = quotedContents
 						}
 					}
-					waitGroup.Done()
-				}(sourceIndex, f, repr)
-			}
+				}
+				span.End()
+				waitGroup.Done()
+			}(sourceIndex, f, approximateLineCount)
 		}
 	}
 
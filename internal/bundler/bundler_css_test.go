@@ -564,6 +564,109 @@ func TestCSSAtImportConditionsBundle(t *testing.T) {
 	})
 }
 
+func TestCSSAtImportLayerBundle(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@layer base, theme;
+				@import "./theme.css" layer(theme);
+				@import "./base.css" layer(base);
+				@import "./reset.css" layer;
+				@import "https://example.com/external.css" layer(external);
+				.entry { color: red }
+			`,
+			"/theme.css": `
+				@import "./colors.css" layer(colors);
+				.theme { color: blue }
+			`,
+			"/colors.css": `
+				.colors { color: green }
+			`,
+			"/base.css": `
+				.base { color: black }
+			`,
+			"/reset.css": `
+				* { margin: 0 }
+			`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+		},
+	})
+}
+
+func TestCSSAtImportLayerStatementOrder(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@import "./first.css";
+				@import "./second.css";
+			`,
+			"/first.css": `
+				@layer b { .first { color: red } }
+			`,
+			"/second.css": `
+				@layer a, b;
+				@import "./nested.css";
+				.second { color: blue }
+			`,
+			"/nested.css": `
+				@layer a { .nested { color: green } }
+			`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+		},
+	})
+}
+
+func TestCSSSourceMap(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.css": `
+				@import "./shared.css";
+				.entry { color: red }
+			`,
+			"/Users/user/project/src/shared.css": `
+				@media screen {
+					.shared { color: blue }
+				}
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			SourceMap:     config.SourceMapLinkedWithComment,
+			AbsOutputFile: "/Users/user/project/out.css",
+		},
+	})
+}
+
+func TestCSSSourceMapInline(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@import "./shared.css";
+				.entry { color: red }
+			`,
+			"/shared.css": `
+				.shared { color: blue }
+			`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			SourceMap:        config.SourceMapInline,
+			RemoveWhitespace: true,
+			AbsOutputFile:    "/out.css",
+		},
+	})
+}
+
 // This test mainly just makes sure that this scenario doesn't crash
 func TestCSSAndJavaScriptCodeSplittingIssue1064(t *testing.T) {
 	css_suite.expectBundled(t, bundled{
@@ -605,3 +708,71 @@ func TestCSSAndJavaScriptCodeSplittingIssue1064(t *testing.T) {
 		},
 	})
 }
+
+func TestCSSModules(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import styles from "./button.module.css"
+				console.log(styles.button, styles.primary)
+			`,
+			"/button.module.css": `
+				.base { color: black }
+				.button { composes: base; composes: reset from "./reset.module.css"; composes: clearfix from global }
+				.button:hover, :global(.app) .primary { animation: fade 1s }
+				@keyframes fade { from { opacity: 0 } }
+			`,
+			"/reset.module.css": `
+				.reset { margin: 0 }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestCSSModulesMissingComposes(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import styles from "./entry.module.css"
+				console.log(styles)
+			`,
+			"/entry.module.css": `
+				.entry { composes: missing from "./other.module.css" }
+			`,
+			"/other.module.css": `
+				.other { color: red }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `entry.module.css: warning: The name "missing" is never declared in "other.module.css"
+`,
+	})
+}
+
+func TestCSSModulesLoaderForPlainCSS(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				.a { color: red }
+				:global(.b) .c { color: blue }
+			`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+			ExtensionToLoader: map[string]config.Loader{
+				".css": config.LoaderLocalCSS,
+			},
+		},
+	})
+}
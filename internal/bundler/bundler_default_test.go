@@ -2,6 +2,7 @@ package bundler
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -4369,3 +4370,56 @@ func TestImportNamespaceThisValue(t *testing.T) {
 		},
 	})
 }
+
+func TestPluginPathSuffix(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import inline from './icon.svg?inline'
+				import url from './icon.svg'
+				import hashed from './icon.svg#hash'
+				import worker from 'worker:./worker.js'
+				console.log(inline, url, hashed, worker)
+			`,
+			"/icon.svg":  `<svg></svg>`,
+			"/worker.js": `self.onmessage = () => {}`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".svg": config.LoaderFile,
+			},
+			Plugins: []config.Plugin{{
+				Name: "suffix",
+				OnResolve: []config.OnResolve{{
+					Filter: regexp.MustCompile("^worker:"),
+					Callback: func(args config.OnResolveArgs) config.OnResolveResult {
+						return config.OnResolveResult{Path: logger.Path{
+							Text:          "/" + strings.TrimPrefix(args.Path, "worker:./"),
+							Namespace:     "file",
+							IgnoredSuffix: "?worker",
+						}}
+					},
+				}},
+				OnLoad: []config.OnLoad{{
+					Filter: regexp.MustCompile("."),
+					Callback: func(args config.OnLoadArgs) config.OnLoadResult {
+						// Pick a loader based on the suffix
+						switch args.Path.IgnoredSuffix {
+						case "?inline":
+							contents := "<svg>inline</svg>"
+							return config.OnLoadResult{Contents: &contents, Loader: config.LoaderText}
+						case "?worker":
+							contents := "export default " + strconv.Quote(args.Path.Text+args.Path.IgnoredSuffix)
+							return config.OnLoadResult{Contents: &contents, Loader: config.LoaderJS}
+						}
+						return config.OnLoadResult{}
+					},
+				}},
+			}},
+		},
+	})
+}
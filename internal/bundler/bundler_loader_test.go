@@ -402,6 +402,70 @@ func TestLoaderJSONSharedWithMultipleEntriesIssue413(t *testing.T) {
 	})
 }
 
+func TestLoaderJSONImportAssertion(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from './data.json' assert { type: 'json' }
+				import * as b from './data.json' assert { type: 'json' }
+				console.log(a, b, import('./data.json', { assert: { type: 'json' } }))
+			`,
+			"/data.json": `{"works": true}`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestLoaderTextImportAssertionJSON(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from './data.txt' assert { type: 'json' }
+				import b from './data.txt' assert { type: 'text' }
+				console.log(a, b, import('./data.txt', { assert: { type: 'json' } }))
+			`,
+			"/data.txt": `{"works": true}`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `entry.js: error: The file "data.txt" was loaded with the "text" loader
+entry.js: note: This import assertion requires the loader to be "json" instead:
+entry.js: error: The file "data.txt" was loaded with the "text" loader
+entry.js: note: This import assertion requires the loader to be "json" instead:
+`,
+	})
+}
+
+func TestLoaderJSONImportAssertionExternal(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from 'pkg/data.json' assert { type: 'json' }
+				export * from 'pkg/other.json' assert { type: 'json' }
+				console.log(a, import('pkg/data.json', { assert: { type: 'json' } }))
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			OutputFormat:  config.FormatESModule,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"pkg": true,
+				},
+			},
+		},
+	})
+}
+
 func TestLoaderFileWithQueryParameter(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
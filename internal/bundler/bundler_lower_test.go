@@ -900,18 +900,193 @@ func TestLowerAsyncES5(t *testing.T) {
 			UnsupportedJSFeatures: es(5),
 			AbsOutputFile:         "/out.js",
 		},
-		expectedScanLog: `arrow-1.js: error: Transforming async functions to the configured target environment is not supported yet
-arrow-2.js: error: Transforming async functions to the configured target environment is not supported yet
-export-def-1.js: error: Transforming async functions to the configured target environment is not supported yet
-export-def-2.js: error: Transforming async functions to the configured target environment is not supported yet
-fn-expr.js: error: Transforming async functions to the configured target environment is not supported yet
-fn-stmt.js: error: Transforming async functions to the configured target environment is not supported yet
-obj-method.js: error: Transforming async functions to the configured target environment is not supported yet
-obj-method.js: error: Transforming object literal extensions to the configured target environment is not supported yet
+		expectedScanLog: `obj-method.js: error: Transforming object literal extensions to the configured target environment is not supported yet
 `,
 	})
 }
 
+func TestLowerGeneratorES5(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				function* fnStmt(x) {
+					try {
+						var y = yield x
+					} finally {
+						cleanup(y)
+					}
+				}
+				export let fnExpr = function* () {
+					for (var k in obj) {
+						if (k === 'skip') continue
+						yield* k
+					}
+				}
+				export async function asyncFn() {
+					return (await a()) + (await b())
+				}
+				export { fnStmt }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(5),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerAsyncGeneratorES2017(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export async function* gen() {
+					yield await a()
+					yield* b()
+				}
+				export async function loop() {
+					for await (let x of gen()) console.log(x)
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(2017),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerLetConstES5(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './block'
+				import './loop'
+				import './loop-jumps'
+			`,
+			"/block.js": `
+				let x = 1
+				{
+					let x = 2
+					const y = 3
+					console.log(x, y)
+				}
+				console.log(x)
+			`,
+			"/loop.js": `
+				let fns = []
+				for (let i = 0; i < 3; i++) fns.push(() => i)
+				for (const key in obj) setTimeout(function() { console.log(key, this) })
+				while (fns.length) { let fn = fns.pop(); let y; setTimeout(() => fn(y)) }
+			`,
+			"/loop-jumps.js": `
+				export function find(items) {
+					outer: for (let i = 0; i < items.length; i++) {
+						for (let j = 0; j < i; j++) {
+							if (items[j] === items[i]) continue outer
+						}
+						if (items[i] === null) break
+						if (items[i] === true) return i
+						var last = i
+						fns.push(() => i, this)
+					}
+					return last
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(5),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerDestructuringES5(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				let [a, {b, c = 1, ...d}] = e
+				var {x} = y, [z, , ...w] = v, {['k' + 1]: k, 'not-id': n} = obj;
+				[a, b] = [b, a]
+				console.log({p, q: [r = 2, , ...s]} = t)
+				function f({a, b}, [c, d]) {}
+				try {} catch ({message}) { console.log(message) }
+				for (const [key, value] in pairs) fns.push(() => key + value)
+				for ({a} in list) ;
+				export {a, b, c, d, f, x, z, w, k, n}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(5),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerClassES5(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './stmt'
+				import './expr'
+				import './export-def-1'
+				import './export-def-2'
+			`,
+			"/stmt.js": `
+				class Foo extends Bar {
+					constructor(x) { super(x); this.y = 1 }
+					foo() { return super.foo(1) + super['b' + 1] }
+					get bar() { return 1 }
+					set bar(v) {}
+					static s() { return () => super.s() }
+					[computed]() {}
+				}
+				class Baz extends Foo { x = 1 }
+				if (cond) { class Foo {} new Foo }
+				console.log(Foo, Baz)
+			`,
+			"/expr.js": `
+				let C = class { m() { return C } }
+				new (class extends C {})
+			`,
+			"/export-def-1.js": `export default class Foo { static f = Foo }`,
+			"/export-def-2.js": `export default class extends Object {}`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(5),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerTemplateES5(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				console.log(tag` + "`a${b}c\\n`" + `)
+				export function f() {
+					return tag` + "`a${b}c`" + `
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			UnsupportedJSFeatures: es(5),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
 func TestLowerAsyncSuperES2016NoBundle(t *testing.T) {
 	lower_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -968,6 +1143,53 @@ func TestLowerClassField2020NoBundle(t *testing.T) {
 	})
 }
 
+func TestLowerClassStaticBlock2020NoBundle(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				class Foo {
+					#foo = 123
+					static bar = 1
+					static {
+						this.baz = this.bar + 1
+					}
+					static isFoo(x) {
+						return #foo in x
+					}
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			UnsupportedJSFeatures: es(2020),
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
+func TestLowerClassStaticBlockNextNoBundle(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				class Foo {
+					#foo = 123
+					static bar = 1
+					static {
+						this.baz = this.bar + 1
+					}
+					static isFoo(x) {
+						return #foo in x
+					}
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
 func TestLowerClassFieldNextNoBundle(t *testing.T) {
 	lower_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -1890,3 +1890,192 @@ Users/user/project/src/entry.js: note: Consider using an "import" statement to i
 `,
 	})
 }
+
+func TestPackageJsonMainEntryRedirect(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import fn from 'demo-pkg'
+				import other from 'other-pkg'
+				console.log(fn(), other())
+			`,
+			"/Users/user/project/node_modules/demo-pkg/package.json": `
+				{
+					"name": "demo-pkg",
+					"main": "./guardian.js"
+				}
+			`,
+			"/Users/user/project/node_modules/demo-pkg/guardian.js": `
+				module.exports = require(process.version > 'v12' ? './es6' : './es5')
+			`,
+			"/Users/user/project/node_modules/demo-pkg/es5/index.js": `
+				module.exports = function() {
+					return 123
+				}
+			`,
+			"/Users/user/project/node_modules/other-pkg/package.json": `
+				{
+					"name": "other-pkg",
+					"main": "./guardian.js"
+				}
+			`,
+			"/Users/user/project/node_modules/other-pkg/guardian.js": `
+				module.exports = function() {
+					return 234
+				}
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			PackageEntryRedirects: []config.PackageEntryRedirect{
+				{PackageName: "demo-pkg", Main: "guardian.js", Redirect: "es5"},
+				{PackageName: "other-pkg", Main: "other.js", Redirect: "es5"},
+			},
+		},
+	})
+}
+
+func TestPackageJsonImports(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import '#top-level'
+				import '#nested/path.js'
+				import '#star/c.js'
+				import '#slash/d.js'
+				import '#conditions'
+				import 'pkg1'
+			`,
+			"/Users/user/project/package.json": `
+				{
+					"imports": {
+						"#top-level": "./a.js",
+						"#nested/path.js": "./b.js",
+						"#star/*": "./some-star/*",
+						"#slash/": "./some-slash/",
+						"#conditions": {
+							"require": "./require.js",
+							"import": "./import.js"
+						}
+					}
+				}
+			`,
+			"/Users/user/project/a.js":            `console.log('a.js')`,
+			"/Users/user/project/b.js":            `console.log('b.js')`,
+			"/Users/user/project/some-star/c.js":  `console.log('c.js')`,
+			"/Users/user/project/some-slash/d.js": `console.log('d.js')`,
+			"/Users/user/project/require.js":      `console.log('require.js')`,
+			"/Users/user/project/import.js":       `console.log('import.js')`,
+			"/Users/user/project/node_modules/pkg1/package.json": `
+				{
+					"main": "./index.js",
+					"imports": {
+						"#internal": "./lib/internal.js",
+						"#dep": "pkg2"
+					}
+				}
+			`,
+			"/Users/user/project/node_modules/pkg1/index.js": `
+				require('#internal')
+				require('#dep')
+			`,
+			"/Users/user/project/node_modules/pkg1/lib/internal.js": `console.log('pkg1 internal.js')`,
+			"/Users/user/project/node_modules/pkg2/index.js":        `console.log('pkg2 index.js')`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+	})
+}
+
+func TestPackageJsonImportsErrors(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import '#missing'
+				import '#/invalid'
+				import '#conditions'
+				import 'pkg1'
+			`,
+			"/Users/user/project/package.json": `
+				{
+					"imports": {
+						"#conditions": {
+							"what": "./what.js"
+						}
+					}
+				}
+			`,
+			"/Users/user/project/what.js": `console.log('FAILURE')`,
+			"/Users/user/project/node_modules/pkg1/package.json": `
+				{ "main": "./index.js" }
+			`,
+			"/Users/user/project/node_modules/pkg1/index.js": `
+				require('#no-imports-map')
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+		expectedScanLog: `Users/user/project/node_modules/pkg1/index.js: error: Could not resolve "#no-imports-map" (mark it as external to exclude it from the bundle)
+Users/user/project/src/entry.js: error: Could not resolve "#missing" (mark it as external to exclude it from the bundle)
+Users/user/project/package.json: note: The package import "#missing" is not defined in this "imports" map
+Users/user/project/src/entry.js: error: Could not resolve "#/invalid" (mark it as external to exclude it from the bundle)
+Users/user/project/package.json: note: The module specifier "#/invalid" is invalid
+Users/user/project/src/entry.js: error: Could not resolve "#conditions" (mark it as external to exclude it from the bundle)
+Users/user/project/package.json: note: The package import "#conditions" is not currently defined in this "imports" map
+Users/user/project/package.json: note: None of the conditions provided ("what") match any of the currently active conditions ("browser", "default", "import")
+`,
+	})
+}
+
+func TestPackageAlias(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import "pkg1"
+				import "pkg2"
+				import "@scope/pkg3/baz"
+				import "pkg4"
+				import "pkg4/sub"
+				import "pkg5"
+				import "pkg10"
+				import "other"
+			`,
+			"/Users/user/project/package.json": `
+				{ "browser": { "alias2": "./shims/alias2.js" } }
+			`,
+			"/Users/user/project/shims/alias2.js":             `console.log('alias2 shim')`,
+			"/Users/user/project/shims/pkg5.js":               `console.log('pkg5 shim')`,
+			"/Users/user/project/node_modules/pkg10/index.js": `console.log('pkg10')`,
+			"/Users/user/project/node_modules/alias1/package.json": `
+				{ "exports": { ".": "./exported.js" } }
+			`,
+			"/Users/user/project/node_modules/alias1/exported.js":  `console.log('alias1')`,
+			"/Users/user/project/node_modules/alias2/index.js":     `console.log('alias2')`,
+			"/Users/user/project/node_modules/alias3/baz.js":       `console.log('alias3/baz')`,
+			"/Users/user/project/node_modules/alias4/index.js":     `console.log('alias4')`,
+			"/Users/user/project/node_modules/alias4-sub/index.js": `console.log('alias4-sub')`,
+			"/Users/user/project/node_modules/other/index.js":      `import "pkg1"`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			Alias: map[string]string{
+				"pkg1":        "alias1",
+				"pkg2":        "alias2",
+				"@scope/pkg3": "alias3",
+				"pkg4":        "alias4",
+				"pkg4/sub":    "alias4-sub",
+				"pkg5":        "/Users/user/project/shims/pkg5.js",
+			},
+		},
+	})
+}
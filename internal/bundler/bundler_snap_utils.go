@@ -69,6 +69,13 @@ func requireDefinitionStmt(
 	return requireDefinition(commonJSRef, request, &value)
 }
 
+// Returns the path of the source relative to the snapshot base dir with forward slashes, i.e.
+// "./node_modules/foo/index.js". This is also the key under which the module is registered.
+func snapshotRelPath(options *config.Options, source *logger.Source) string {
+	relPath, _ := filepath.Rel(options.SnapshotAbsBaseDir, source.KeyPath.Text)
+	return fmt.Sprintf("./%s", filepath.ToSlash(relPath))
+}
+
 func pathIsAlwaysExternal(options config.Options, path logger.Path) bool {
 	if options.CreateSnapshot {
 		return filepath.Ext(path.Text) == ".node"
@@ -70,6 +70,9 @@ type bundled struct {
 	expectedScanLog    string
 	expectedCompileLog string
 	options            config.Options
+
+	// Reads files inside of ".zip" archives (i.e. Yarn's cache) as if they were directories
+	zipFS bool
 }
 
 type suite struct {
@@ -85,7 +88,11 @@ func (s *suite) expectBundled(t *testing.T, args bundled) {
 	testName := t.Name()
 	t.Run("", func(t *testing.T) {
 		t.Helper()
-		fs := fs.MockFS(args.files)
+		mockFS := fs.MockFS
+		if args.zipFS {
+			mockFS = func(files map[string]string) fs.FS { return fs.WrapWithZip(fs.MockFS(files)) }
+		}
+		fs := mockFS(args.files)
 		if args.options.ExtensionOrder == nil {
 			args.options.ExtensionOrder = []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
 		}
@@ -0,0 +1,189 @@
+package bundler
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/config"
+)
+
+var yarnpnp_suite = suite{
+	name: "yarnpnp",
+}
+
+const yarnPnPDataForTests = `{
+	"enableTopLevelFallback": true,
+	"fallbackExclusionList": [["strict-pkg", ["npm:1.0.0"]]],
+	"fallbackPool": [],
+	"ignorePatternData": null,
+	"packageRegistryData": [
+		[null, [[null, {
+			"packageLocation": "./",
+			"packageDependencies": [
+				["left-pad", "npm:1.3.0"],
+				["pad", ["left-pad", "npm:1.3.0"]],
+				["strict-pkg", "npm:1.0.0"],
+				["zipped", "npm:2.0.0"]
+			],
+			"linkType": "SOFT"
+		}]]],
+		["left-pad", [["npm:1.3.0", {
+			"packageLocation": "./.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/",
+			"packageDependencies": [["left-pad", "npm:1.3.0"]],
+			"linkType": "HARD"
+		}]]],
+		["strict-pkg", [["npm:1.0.0", {
+			"packageLocation": "./.yarn/unplugged/strict-pkg-npm-1.0.0/node_modules/strict-pkg/",
+			"packageDependencies": [["strict-pkg", "npm:1.0.0"], ["peer", null]],
+			"linkType": "HARD"
+		}]]],
+		["zipped", [["npm:2.0.0", {
+			"packageLocation": "./.yarn/cache/zipped-npm-2.0.0-abc.zip/node_modules/zipped/",
+			"packageDependencies": [["zipped", "npm:2.0.0"], ["left-pad", "npm:1.3.0"]],
+			"linkType": "HARD"
+		}]]]
+	]
+}`
+
+func makeZipForTest(t *testing.T, files map[string]string) string {
+	t.Helper()
+	buffer := bytes.Buffer{}
+	writer := zip.NewWriter(&buffer)
+	for name, contents := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestYarnPnPDirectoryPackages(t *testing.T) {
+	yarnpnp_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import leftPad from 'left-pad'
+				import pad from 'pad'
+				console.log(leftPad(), pad())
+			`,
+			"/Users/user/project/.pnp.data.json": yarnPnPDataForTests,
+			"/Users/user/project/.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/package.json": `
+				{ "main": "./lib/index.js" }
+			`,
+			"/Users/user/project/.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/lib/index.js": `
+				module.exports = function() { return 'left-pad' }
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+	})
+}
+
+func TestYarnPnPZipArchive(t *testing.T) {
+	yarnpnp_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import zipped from 'zipped'
+				import helper from 'zipped/helper'
+				console.log(zipped, helper)
+			`,
+			"/Users/user/project/.pnp.data.json": yarnPnPDataForTests,
+			"/Users/user/project/.yarn/cache/zipped-npm-2.0.0-abc.zip": makeZipForTest(t, map[string]string{
+				"node_modules/zipped/package.json": `{
+					"exports": {
+						".": "./main.js",
+						"./helper": "./lib/helper.js"
+					}
+				}`,
+				"node_modules/zipped/main.js":       `import leftPad from 'left-pad'; export default leftPad()`,
+				"node_modules/zipped/lib/helper.js": `export default 'helper'`,
+			}),
+			"/Users/user/project/.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/index.js": `
+				module.exports = function() { return 'left-pad' }
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+		zipFS: true,
+	})
+}
+
+func TestYarnPnPInlinedManifest(t *testing.T) {
+	yarnpnp_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import leftPad from 'left-pad'
+				console.log(leftPad())
+			`,
+			"/Users/user/project/.pnp.cjs": `#!/usr/bin/env node
+				/* eslint-disable */
+				"use strict";
+
+				const RAW_RUNTIME_STATE =
+				'{\
+					"enableTopLevelFallback": false,\
+					"packageRegistryData": [\
+						[null, [[null, {"packageLocation": "./", "packageDependencies": [["left-pad", "npm:1.3.0"]]}]]],\
+						["left-pad", [["npm:1.3.0", {"packageLocation": "./.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/", "packageDependencies": []}]]]\
+					]\
+				}';
+
+				function $$SETUP_STATE(hydrateRuntimeState, basePath) {
+					return hydrateRuntimeState(JSON.parse(RAW_RUNTIME_STATE), {basePath: basePath || __dirname});
+				}
+			`,
+			"/Users/user/project/.yarn/unplugged/left-pad-npm-1.3.0/node_modules/left-pad/index.js": `
+				module.exports = function() { return 'left-pad' }
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+	})
+}
+
+func TestYarnPnPUndeclaredDependency(t *testing.T) {
+	yarnpnp_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import 'strict-pkg'
+				import 'undeclared'
+			`,
+			"/Users/user/project/.pnp.data.json": yarnPnPDataForTests,
+			"/Users/user/project/.yarn/unplugged/strict-pkg-npm-1.0.0/node_modules/strict-pkg/index.js": `
+				import 'peer'
+				import 'left-pad'
+			`,
+			"/Users/user/project/node_modules/undeclared/index.js": `
+				console.log('this should not be found')
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+		expectedScanLog: `Users/user/project/.yarn/unplugged/strict-pkg-npm-1.0.0/node_modules/strict-pkg/index.js: error: Could not resolve "peer" (mark it as external to exclude it from the bundle)
+note: The package "peer" is a peer dependency of "strict-pkg" (npm:1.0.0) but isn't provided by any of its ancestors
+Users/user/project/.yarn/unplugged/strict-pkg-npm-1.0.0/node_modules/strict-pkg/index.js: error: Could not resolve "left-pad" (mark it as external to exclude it from the bundle)
+note: The Yarn Plug'n'Play manifest doesn't list "left-pad" as a dependency of "strict-pkg" (npm:1.0.0)
+Users/user/project/src/entry.js: error: Could not resolve "undeclared" (mark it as external to exclude it from the bundle)
+note: The Yarn Plug'n'Play manifest doesn't list "undeclared" as a dependency of the top-level package
+`,
+	})
+}
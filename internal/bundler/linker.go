@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"hash"
 	"math/rand"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -582,14 +581,18 @@ func (c *linkerContext) link() []OutputFile {
 	if !c.generateUniqueKeyPrefix() {
 		return nil
 	}
+	span := c.options.Tracer.Begin("Scan imports and exports", "")
 	c.scanImportsAndExports()
+	span.End()
 
 	// Stop now if there were errors
 	if c.log.HasErrors() {
 		return []OutputFile{}
 	}
 
+	span = c.options.Tracer.Begin("Tree shaking", "")
 	c.markPartsReachableFromEntryPoints()
+	span.End()
 
 	if c.options.Mode == config.ModePassThrough {
 		for _, entryPoint := range c.entryPoints {
@@ -597,8 +600,10 @@ func (c *linkerContext) link() []OutputFile {
 		}
 	}
 
+	span = c.options.Tracer.Begin("Compute chunks", "")
 	chunks := c.computeChunks()
 	c.computeCrossChunkDependencies(chunks)
+	span.End()
 
 	// Make sure calls to "js_ast.FollowSymbols()" in parallel goroutines after this
 	// won't hit concurrent map mutation hazards
@@ -639,6 +644,8 @@ func (c *linkerContext) enforceNoCyclicChunkImports(chunks []chunkInfo) {
 }
 
 func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []OutputFile {
+	defer c.options.Tracer.Begin("Generate chunks", "").End()
+
 	// Generate each chunk on a separate goroutine
 	generateWaitGroup := sync.WaitGroup{}
 	generateWaitGroup.Add(len(chunks))
@@ -684,6 +691,7 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []OutputFil
 	resultsWaitGroup.Add(len(chunks))
 	for chunkIndex, chunk := range chunks {
 		go func(chunkIndex int, chunk chunkInfo) {
+			span := c.options.Tracer.Begin("Join chunk", chunk.finalRelPath)
 			var outputFiles []OutputFile
 
 			// Each file may optionally contain additional files to be copied to the
@@ -703,20 +711,32 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []OutputFil
 				outputSourceMap := chunk.outputSourceMap.Finalize(outputSourceMapShifts)
 				finalRelPathForSourceMap := chunk.finalRelPath + ".map"
 
+				// CSS doesn't have single-line comments
+				commentPrefix := "//"
+				commentSuffix := ""
+				if _, ok := chunk.chunkRepr.(*chunkReprCSS); ok {
+					commentPrefix = "/*"
+					commentSuffix = " */"
+				}
+
 				// Potentially write a trailing source map comment
 				switch c.options.SourceMap {
 				case config.SourceMapLinkedWithComment:
 					importPath := c.pathBetweenChunks(finalRelDir, finalRelPathForSourceMap)
 					importPath = strings.TrimPrefix(importPath, "./")
 					outputContentsJoiner.EnsureNewlineAtEnd()
-					outputContentsJoiner.AddString("//# sourceMappingURL=")
+					outputContentsJoiner.AddString(commentPrefix)
+					outputContentsJoiner.AddString("# sourceMappingURL=")
 					outputContentsJoiner.AddString(importPath)
+					outputContentsJoiner.AddString(commentSuffix)
 					outputContentsJoiner.AddString("\n")
 
 				case config.SourceMapInline, config.SourceMapInlineAndExternal:
 					outputContentsJoiner.EnsureNewlineAtEnd()
-					outputContentsJoiner.AddString("//# sourceMappingURL=data:application/json;base64,")
+					outputContentsJoiner.AddString(commentPrefix)
+					outputContentsJoiner.AddString("# sourceMappingURL=data:application/json;base64,")
 					outputContentsJoiner.AddString(base64.StdEncoding.EncodeToString(outputSourceMap))
+					outputContentsJoiner.AddString(commentSuffix)
 					outputContentsJoiner.AddString("\n")
 				}
 
@@ -753,6 +773,7 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []OutputFil
 				IsExecutable:      chunk.isExecutable,
 			})
 
+			span.End()
 			results[chunkIndex] = outputFiles
 			resultsWaitGroup.Done()
 		}(chunkIndex, chunk)
@@ -1369,8 +1390,7 @@ func (c *linkerContext) scanImportsAndExports() {
 			// point, additionally we want to normalize paths on Windows to use forward slashes
 			if c.options.CreateSnapshot && file.source.Index != runtime.SourceIndex {
 				repr.meta.wrap = wrapCJS
-				relPath, _ := filepath.Rel(c.options.SnapshotAbsBaseDir, file.source.KeyPath.Text)
-				c.symbols.Get(repr.ast.WrapperRef).OriginalName = fmt.Sprintf("./%s", filepath.ToSlash(relPath))
+				c.symbols.Get(repr.ast.WrapperRef).OriginalName = snapshotRelPath(c.options, &file.source)
 			}
 		}
 	}
@@ -3540,6 +3560,15 @@ type compileResultJS struct {
 	generatedOffset sourcemap.LineColumnOffset
 }
 
+// This is the subset of a compile result that's needed to join its source map
+// chunk into the source map for the whole output file. It's shared between the
+// JavaScript and CSS code paths.
+type compileResultForSourceMap struct {
+	sourceMapChunk  js_printer.SourceMapChunk
+	generatedOffset sourcemap.LineColumnOffset
+	sourceIndex     uint32
+}
+
 func (c *linkerContext) requireOrImportMetaForSource(sourceIndex uint32) (meta js_printer.RequireOrImportMeta) {
 	repr := c.files[sourceIndex].repr.(*reprJS)
 	meta.WrapperRef = repr.ast.WrapperRef
@@ -3765,13 +3794,22 @@ func (c *linkerContext) generateCodeForFileInChunkJS(
 		IsRuntime:                    partRange.sourceIndex == runtime.SourceIndex,
 		FilePath:                     file.source.PrettyPath,
 	}
+	if c.options.CreateSnapshot {
+		printOptions.SnapshotRelPath = snapshotRelPath(c.options, &file.source)
+	}
 	tree := repr.ast
 	tree.Directive = "" // This is handled elsewhere
 	tree.Parts = []js_ast.Part{{Stmts: stmts}}
+	spanName := "Print"
+	if needsWrapper {
+		spanName = "Print snapshot"
+	}
+	span := c.options.Tracer.Begin(spanName, file.source.PrettyPath)
 	*result = compileResultJS{
 		PrintResult: c.print(tree, c.symbols, r, printOptions),
 		sourceIndex: partRange.sourceIndex,
 	}
+	span.End()
 
 	waitGroup.Done()
 }
@@ -4259,8 +4297,17 @@ func (c *linkerContext) renameSymbolsInChunk(chunk *chunkInfo, filesInOrder []ui
 	return r
 }
 
+// The chunk's path is only computed when tracing since it's not free
+func (c *linkerContext) beginChunkSpan(chunk *chunkInfo) helpers.TraceSpan {
+	if c.options.Tracer == nil {
+		return helpers.TraceSpan{}
+	}
+	return c.options.Tracer.Begin("Generate chunk", c.fs.Join(config.TemplateToString(chunk.finalTemplate)))
+}
+
 func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chunkWaitGroup *sync.WaitGroup) {
 	chunk := &chunks[chunkIndex]
+	defer c.beginChunkSpan(chunk).End()
 	chunkRepr := chunk.chunkRepr.(*chunkReprJS)
 	compileResults := make([]compileResultJS, 0, len(chunk.partsInChunkInOrder))
 	runtimeMembers := c.files[runtime.SourceIndex].repr.(*reprJS).ast.ModuleScope.Members
@@ -4478,7 +4525,7 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	}
 
 	// Concatenate the generated JavaScript chunks together
-	var compileResultsForSourceMap []compileResultJS
+	var compileResultsForSourceMap []compileResultForSourceMap
 	var commentList []string
 	var metaOrder []uint32
 	var metaByteCount map[string]int
@@ -4537,7 +4584,11 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 
 				// Include this file in the source map
 				if c.options.SourceMap != config.SourceMapNone {
-					compileResultsForSourceMap = append(compileResultsForSourceMap, compileResult)
+					compileResultsForSourceMap = append(compileResultsForSourceMap, compileResultForSourceMap{
+						sourceMapChunk:  compileResult.SourceMapChunk,
+						generatedOffset: compileResult.generatedOffset,
+						sourceIndex:     compileResult.sourceIndex,
+					})
 				}
 			}
 
@@ -4672,10 +4723,15 @@ func (c *linkerContext) generateGlobalNamePrefix() string {
 }
 
 type compileResultCSS struct {
-	printedCSS      string
+	css_printer.PrintResult
+
 	sourceIndex     uint32
 	hasCharset      bool
 	externalImports []externalImportCSS
+
+	// This is the line and column offset since the previous CSS string or the
+	// start of the file if this is the first CSS string.
+	generatedOffset sourcemap.LineColumnOffset
 }
 
 type externalImportCSS struct {
@@ -4683,29 +4739,115 @@ type externalImportCSS struct {
 	conditions []css_ast.Token
 }
 
+// Returns the set of CSS files transitively imported by this file using
+// "@import" rules, not including the file itself
+func (c *linkerContext) findImportedCSSFiles(sourceIndex uint32) map[uint32]bool {
+	imported := make(map[uint32]bool)
+	var visit func(uint32)
+	visit = func(sourceIndex uint32) {
+		for _, record := range c.files[sourceIndex].repr.(*reprCSS).ast.ImportRecords {
+			if record.SourceIndex.IsValid() && record.Kind == ast.ImportAt {
+				if other := record.SourceIndex.GetIndex(); !imported[other] {
+					imported[other] = true
+					visit(other)
+				}
+			}
+		}
+	}
+	visit(sourceIndex)
+	return imported
+}
+
 func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chunkWaitGroup *sync.WaitGroup) {
 	chunk := &chunks[chunkIndex]
+	defer c.beginChunkSpan(chunk).End()
 	compileResults := make([]compileResultCSS, 0, len(chunk.filesInChunkInOrder))
+	dataForSourceMaps := c.dataForSourceMaps()
+
+	// Files imported using "@import ... layer(...)" are wrapped in "@layer"
+	// rules. Importers always come after the files they import, so iterate in
+	// reverse to propagate layers from importers to the files they import.
+	layers := make(map[uint32][]*css_ast.RAtImport)
+	for i := len(chunk.filesInChunkInOrder) - 1; i >= 0; i-- {
+		sourceIndex := chunk.filesInChunkInOrder[i]
+		ast := c.files[sourceIndex].repr.(*reprCSS).ast
+		for _, rule := range ast.Rules {
+			if r, ok := rule.Data.(*css_ast.RAtImport); ok && r.HasLayer {
+				if record := ast.ImportRecords[r.ImportRecordIndex]; record.SourceIndex.IsValid() {
+					otherSourceIndex := record.SourceIndex.GetIndex()
+					if _, ok := layers[otherSourceIndex]; !ok {
+						outer := layers[sourceIndex]
+						layers[otherSourceIndex] = append(outer[:len(outer):len(outer)], r)
+					}
+				}
+			}
+		}
+	}
+
+	// "@layer" statements that come before "@import" rules must still come
+	// before the imported files after bundling, since the order in which layer
+	// names first appear determines the order of the layers. They are moved in
+	// front of the imported files, or to the front of the chunk if the imported
+	// files start the chunk (since external "@import" rules are moved there too).
+	var prefixLayerStatements []css_ast.Rule
+	layerStatementsBefore := make(map[int][]css_ast.Rule)
+	hasMovedLayerStatements := make(map[uint32]bool)
+	for i, sourceIndex := range chunk.filesInChunkInOrder {
+		if len(layers[sourceIndex]) > 0 {
+			// Layer statements inside another layer can't be moved
+			continue
+		}
+		var statements []css_ast.Rule
+		for _, rule := range c.files[sourceIndex].repr.(*reprCSS).ast.Rules {
+			if r, ok := rule.Data.(*css_ast.RAtLayer); ok && r.Rules == nil {
+				statements = append(statements, css_ast.Rule{Data: r})
+			} else if _, ok := rule.Data.(*css_ast.RAtCharset); !ok {
+				break
+			}
+		}
+		if len(statements) == 0 {
+			continue
+		}
+		hasMovedLayerStatements[sourceIndex] = true
+		imported := c.findImportedCSSFiles(sourceIndex)
+		start := i
+		for start > 0 && imported[chunk.filesInChunkInOrder[start-1]] {
+			start--
+		}
+		if start == 0 {
+			prefixLayerStatements = append(prefixLayerStatements, statements...)
+		} else {
+			layerStatementsBefore[start] = append(layerStatementsBefore[start], statements...)
+		}
+	}
 
 	// Generate CSS for each file in parallel
 	waitGroup := sync.WaitGroup{}
-	for _, sourceIndex := range chunk.filesInChunkInOrder {
+	for i, sourceIndex := range chunk.filesInChunkInOrder {
 		// Create a goroutine for this file
 		compileResults = append(compileResults, compileResultCSS{})
 		compileResult := &compileResults[len(compileResults)-1]
 		waitGroup.Add(1)
-		go func(sourceIndex uint32, compileResult *compileResultCSS) {
+		go func(sourceIndex uint32, compileResult *compileResultCSS, layerStatements []css_ast.Rule) {
 			file := &c.files[sourceIndex]
 			ast := file.repr.(*reprCSS).ast
 
 			// Filter out "@import" rules
-			rules := make([]css_ast.R, 0, len(ast.Rules))
+			rules := make([]css_ast.Rule, 0, len(ast.Rules))
+			isBeforeOtherRules := true
 			for _, rule := range ast.Rules {
-				switch r := rule.(type) {
+				switch r := rule.Data.(type) {
 				case *css_ast.RAtCharset:
 					compileResult.hasCharset = true
 					continue
+				case *css_ast.RAtLayer:
+					// Skip layer statements that were moved elsewhere
+					if isBeforeOtherRules && r.Rules == nil && hasMovedLayerStatements[sourceIndex] {
+						continue
+					}
+					isBeforeOtherRules = false
 				case *css_ast.RAtImport:
+					isBeforeOtherRules = false
 					if record := ast.ImportRecords[r.ImportRecordIndex]; !record.SourceIndex.IsValid() {
 						compileResult.externalImports = append(compileResult.externalImports, externalImportCSS{
 							record:     record,
@@ -4713,26 +4855,51 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 						})
 					}
 					continue
+				default:
+					isBeforeOtherRules = false
 				}
 				rules = append(rules, rule)
 			}
+
+			// Wrap the file in "@layer" rules if it was imported into a layer
+			importLayers := layers[sourceIndex]
+			for i := len(importLayers) - 1; i >= 0; i-- {
+				var names [][]string
+				if importLayers[i].LayerName != nil {
+					names = [][]string{importLayers[i].LayerName}
+				}
+				rules = []css_ast.Rule{{Data: &css_ast.RAtLayer{Names: names, Rules: rules}}}
+			}
+			if len(layerStatements) > 0 {
+				rules = append(layerStatements[:len(layerStatements):len(layerStatements)], rules...)
+			}
 			ast.Rules = rules
 
-			compileResult.printedCSS = css_printer.Print(ast, css_printer.Options{
+			cssOptions := css_printer.Options{
 				RemoveWhitespace: c.options.RemoveWhitespace,
 				ASCIIOnly:        c.options.ASCIIOnly,
-			})
+			}
+			if c.options.SourceMap != config.SourceMapNone {
+				cssOptions.AddSourceMappings = true
+				cssOptions.LineOffsetTables = dataForSourceMaps[sourceIndex].lineOffsetTables
+			}
+			span := c.options.Tracer.Begin("Print", file.source.PrettyPath)
+			compileResult.PrintResult = css_printer.Print(ast, cssOptions)
+			span.End()
 			compileResult.sourceIndex = sourceIndex
 			waitGroup.Done()
-		}(sourceIndex, compileResult)
+		}(sourceIndex, compileResult, layerStatementsBefore[i])
 	}
 
 	waitGroup.Wait()
 	j := helpers.Joiner{}
+	prevOffset := sourcemap.LineColumnOffset{}
 	newlineBeforeComment := false
 
 	if len(c.options.CSSBanner) > 0 {
+		prevOffset.AdvanceString(c.options.CSSBanner)
 		j.AddString(c.options.CSSBanner)
+		prevOffset.AdvanceString("\n")
 		j.AddString("\n")
 	}
 
@@ -4743,19 +4910,23 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 		// "@charset" is the only thing that comes before "@import"
 		for _, compileResult := range compileResults {
 			if compileResult.hasCharset {
-				ast.Rules = append(ast.Rules, &css_ast.RAtCharset{Encoding: "UTF-8"})
+				ast.Rules = append(ast.Rules, css_ast.Rule{Data: &css_ast.RAtCharset{Encoding: "UTF-8"}})
 				break
 			}
 		}
 
+		// "@layer" statements are allowed before "@import" rules and may be needed
+		// to determine the order of the layers used by those "@import" rules
+		ast.Rules = append(ast.Rules, prefixLayerStatements...)
+
 		// Insert all external "@import" rules at the front. In CSS, all "@import"
 		// rules must come first or the browser will just ignore them.
 		for _, compileResult := range compileResults {
 			for _, external := range compileResult.externalImports {
-				ast.Rules = append(ast.Rules, &css_ast.RAtImport{
+				ast.Rules = append(ast.Rules, css_ast.Rule{Data: &css_ast.RAtImport{
 					ImportRecordIndex: uint32(len(ast.ImportRecords)),
 					ImportConditions:  external.conditions,
-				})
+				}})
 				ast.ImportRecords = append(ast.ImportRecords, external.record)
 			}
 		}
@@ -4763,8 +4934,9 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 		if len(ast.Rules) > 0 {
 			css := css_printer.Print(ast, css_printer.Options{
 				RemoveWhitespace: c.options.RemoveWhitespace,
-			})
+			}).CSS
 			if len(css) > 0 {
+				prevOffset.AdvanceString(css)
 				j.AddString(css)
 				newlineBeforeComment = true
 			}
@@ -4808,17 +4980,40 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 	isFirstMeta := true
 
 	// Concatenate the generated CSS chunks together
+	var compileResultsForSourceMap []compileResultForSourceMap
 	for _, compileResult := range compileResults {
 		if c.options.Mode == config.ModeBundle && !c.options.RemoveWhitespace {
 			if newlineBeforeComment {
+				prevOffset.AdvanceString("\n")
 				j.AddString("\n")
 			}
-			j.AddString(fmt.Sprintf("/* %s */\n", c.files[compileResult.sourceIndex].source.PrettyPath))
+			text := fmt.Sprintf("/* %s */\n", c.files[compileResult.sourceIndex].source.PrettyPath)
+			prevOffset.AdvanceString(text)
+			j.AddString(text)
 		}
-		if len(compileResult.printedCSS) > 0 {
+		if len(compileResult.CSS) > 0 {
 			newlineBeforeComment = true
 		}
-		j.AddString(compileResult.printedCSS)
+
+		// Save the offset to the start of the stored CSS
+		compileResult.generatedOffset = prevOffset
+		j.AddString(compileResult.CSS)
+
+		// Ignore empty source map chunks
+		if compileResult.SourceMapChunk.ShouldIgnore {
+			prevOffset.AdvanceString(compileResult.CSS)
+		} else {
+			prevOffset = sourcemap.LineColumnOffset{}
+
+			// Include this file in the source map
+			if c.options.SourceMap != config.SourceMapNone {
+				compileResultsForSourceMap = append(compileResultsForSourceMap, compileResultForSourceMap{
+					sourceMapChunk:  compileResult.SourceMapChunk,
+					generatedOffset: compileResult.generatedOffset,
+					sourceIndex:     compileResult.sourceIndex,
+				})
+			}
+		}
 
 		// Include this file in the metadata
 		if c.options.NeedsMetafile {
@@ -4829,7 +5024,7 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 			}
 			jMeta.AddString(fmt.Sprintf("\n        %s: {\n          \"bytesInOutput\": %d\n        }",
 				js_printer.QuoteForJSON(c.files[compileResult.sourceIndex].source.PrettyPath, c.options.ASCIIOnly),
-				len(compileResult.printedCSS)))
+				len(compileResult.CSS)))
 		}
 	}
 
@@ -4841,6 +5036,12 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 		j.AddString("\n")
 	}
 
+	if c.options.SourceMap != config.SourceMapNone {
+		// See the comment in generateChunkJS for why this is computed this way
+		chunkAbsDir := c.fs.Dir(c.fs.Join(c.options.AbsOutputDir, config.TemplateToString(chunk.finalTemplate)))
+		chunk.outputSourceMap = c.generateSourceMapForChunk(compileResultsForSourceMap, chunkAbsDir, dataForSourceMaps)
+	}
+
 	// The CSS contents are done now that the source map comment is in
 	cssContents := j.Done()
 
@@ -5123,7 +5324,7 @@ func (c *linkerContext) preventExportsFromBeingRenamed(sourceIndex uint32) {
 }
 
 func (c *linkerContext) generateSourceMapForChunk(
-	results []compileResultJS,
+	results []compileResultForSourceMap,
 	chunkAbsDir string,
 	dataForSourceMaps []dataForSourceMap,
 ) (pieces sourcemap.SourceMapPieces) {
@@ -5235,7 +5436,7 @@ func (c *linkerContext) generateSourceMapForChunk(
 	prevEndState := js_printer.SourceMapState{}
 	prevColumnOffset := 0
 	for _, result := range results {
-		chunk := result.SourceMapChunk
+		chunk := result.sourceMapChunk
 		offset := result.generatedOffset
 		sourcesIndex := sourceIndexToSourcesIndex[result.sourceIndex]
 
@@ -60,6 +60,13 @@ func MakeCacheSet() *CacheSet {
 	}
 }
 
+// The persistent cache is shared by all of the caches for parse results
+func (c *CacheSet) SetDiskCache(disk *DiskCache) {
+	c.CSSCache.disk = disk
+	c.JSONCache.disk = disk
+	c.JSCache.disk = disk
+}
+
 type SourceIndexCache struct {
 	mutex           sync.Mutex
 	entries         map[sourceIndexKey]uint32
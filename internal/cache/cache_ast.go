@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/evanw/esbuild/internal/css_ast"
@@ -22,6 +23,10 @@ import (
 // be the same pointer, which makes the comparison trivial. Also we want to
 // cache the AST for plugins in the common case that the plugin output stays
 // the same.
+//
+// If there is a persistent cache, it's checked when this cache misses. It can't
+// compare the parser options directly so it uses a string representation of
+// them as part of the key instead.
 
 ////////////////////////////////////////////////////////////////////////////////
 // CSS
@@ -29,6 +34,7 @@ import (
 type CSSCache struct {
 	mutex   sync.Mutex
 	entries map[logger.Path]*cssCacheEntry
+	disk    *DiskCache
 }
 
 type cssCacheEntry struct {
@@ -47,18 +53,30 @@ func (c *CSSCache) Parse(log logger.Log, source logger.Source, options css_parse
 	}()
 
 	// Cache hit
-	if entry != nil && entry.source == source && entry.options == options {
+	if entry != nil && entry.source == source && entry.options.Equal(&options) {
 		for _, msg := range entry.msgs {
 			log.AddMsg(msg)
 		}
 		return entry.ast
 	}
 
-	// Cache miss
-	tempLog := logger.NewDeferLog()
-	ast := css_parser.Parse(tempLog, source, options)
-	msgs := tempLog.Done()
-	for _, msg := range msgs {
+	// Cache miss, so check the persistent cache before parsing
+	var diskKey diskCacheKey
+	var result cssParseResult
+	found := false
+	if c.disk != nil {
+		diskKey = c.disk.key("css", source, fmt.Sprintf("%+v", options))
+		found = c.disk.loadValue(diskKey, &result, source.Index)
+	}
+	if !found {
+		tempLog := logger.NewDeferLog()
+		result = cssParseResult{AST: css_parser.Parse(tempLog, source, options)}
+		result.Msgs = tempLog.Done()
+		if c.disk != nil {
+			c.disk.storeValue(diskKey, &result, source.Index)
+		}
+	}
+	for _, msg := range result.Msgs {
 		log.AddMsg(msg)
 	}
 
@@ -66,15 +84,21 @@ func (c *CSSCache) Parse(log logger.Log, source logger.Source, options css_parse
 	entry = &cssCacheEntry{
 		source:  source,
 		options: options,
-		ast:     ast,
-		msgs:    msgs,
+		ast:     result.AST,
+		msgs:    result.Msgs,
 	}
 
 	// Save for next time
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.entries[source.KeyPath] = entry
-	return ast
+	return result.AST
+}
+
+// This is what's stored in the persistent cache
+type cssParseResult struct {
+	AST  css_ast.AST
+	Msgs []logger.Msg
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -83,6 +107,7 @@ func (c *CSSCache) Parse(log logger.Log, source logger.Source, options css_parse
 type JSONCache struct {
 	mutex   sync.Mutex
 	entries map[logger.Path]*jsonCacheEntry
+	disk    *DiskCache
 }
 
 type jsonCacheEntry struct {
@@ -109,11 +134,23 @@ func (c *JSONCache) Parse(log logger.Log, source logger.Source, options js_parse
 		return entry.expr, entry.ok
 	}
 
-	// Cache miss
-	tempLog := logger.NewDeferLog()
-	expr, ok := js_parser.ParseJSON(tempLog, source, options)
-	msgs := tempLog.Done()
-	for _, msg := range msgs {
+	// Cache miss, so check the persistent cache before parsing
+	var diskKey diskCacheKey
+	var result jsonParseResult
+	found := false
+	if c.disk != nil {
+		diskKey = c.disk.key("json", source, fmt.Sprintf("%+v", options))
+		found = c.disk.loadValue(diskKey, &result, source.Index)
+	}
+	if !found {
+		tempLog := logger.NewDeferLog()
+		expr, ok := js_parser.ParseJSON(tempLog, source, options)
+		result = jsonParseResult{Expr: expr, OK: ok, Msgs: tempLog.Done()}
+		if c.disk != nil {
+			c.disk.storeValue(diskKey, &result, source.Index)
+		}
+	}
+	for _, msg := range result.Msgs {
 		log.AddMsg(msg)
 	}
 
@@ -121,16 +158,23 @@ func (c *JSONCache) Parse(log logger.Log, source logger.Source, options js_parse
 	entry = &jsonCacheEntry{
 		source:  source,
 		options: options,
-		expr:    expr,
-		ok:      ok,
-		msgs:    msgs,
+		expr:    result.Expr,
+		ok:      result.OK,
+		msgs:    result.Msgs,
 	}
 
 	// Save for next time
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.entries[source.KeyPath] = entry
-	return expr, ok
+	return result.Expr, result.OK
+}
+
+// This is what's stored in the persistent cache
+type jsonParseResult struct {
+	Expr js_ast.Expr
+	OK   bool
+	Msgs []logger.Msg
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -139,6 +183,7 @@ func (c *JSONCache) Parse(log logger.Log, source logger.Source, options js_parse
 type JSCache struct {
 	mutex   sync.Mutex
 	entries map[logger.Path]*jsCacheEntry
+	disk    *DiskCache
 }
 
 type jsCacheEntry struct {
@@ -165,11 +210,26 @@ func (c *JSCache) Parse(log logger.Log, source logger.Source, options js_parser.
 		return entry.ast, entry.ok
 	}
 
-	// Cache miss
-	tempLog := logger.NewDeferLog()
-	ast, ok := js_parser.Parse(tempLog, source, options)
-	msgs := tempLog.Done()
-	for _, msg := range msgs {
+	// Cache miss, so check the persistent cache before parsing. Some options
+	// refer to other files and can't be used with the persistent cache.
+	var diskKey diskCacheKey
+	var result jsParseResult
+	optionsKey, useDisk := options.PersistentCacheKey()
+	useDisk = useDisk && c.disk != nil
+	found := false
+	if useDisk {
+		diskKey = c.disk.key("js", source, optionsKey)
+		found = c.disk.loadValue(diskKey, &result, source.Index)
+	}
+	if !found {
+		tempLog := logger.NewDeferLog()
+		ast, ok := js_parser.Parse(tempLog, source, options)
+		result = jsParseResult{AST: ast, OK: ok, Msgs: tempLog.Done()}
+		if useDisk {
+			c.disk.storeValue(diskKey, &result, source.Index)
+		}
+	}
+	for _, msg := range result.Msgs {
 		log.AddMsg(msg)
 	}
 
@@ -177,14 +237,21 @@ func (c *JSCache) Parse(log logger.Log, source logger.Source, options js_parser.
 	entry = &jsCacheEntry{
 		source:  source,
 		options: options,
-		ast:     ast,
-		ok:      ok,
-		msgs:    msgs,
+		ast:     result.AST,
+		ok:      result.OK,
+		msgs:    result.Msgs,
 	}
 
 	// Save for next time
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.entries[source.KeyPath] = entry
-	return ast, ok
+	return result.AST, result.OK
+}
+
+// This is what's stored in the persistent cache
+type jsParseResult struct {
+	AST  js_ast.AST
+	OK   bool
+	Msgs []logger.Msg
 }
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This cache stores parse results in a directory on the file system so that
+// they can be reused by other processes. This is useful for cold builds (e.g.
+// in CI) where the in-memory cache is empty but most of the files, especially
+// the ones in "node_modules", haven't changed since the previous build.
+//
+// Entries are keyed on the contents of the file, the options for the parser,
+// and the executable itself. The executable is part of the key because the
+// serialized format depends on the layout of the AST in this particular build.
+// Each entry also contains a checksum of its contents so corrupt or truncated
+// entries are detected and deleted instead of being used.
+//
+// The directory is size-limited. Entries are touched when they are used and
+// the least recently used entries are deleted once the limit is exceeded.
+
+const DefaultDiskCacheMaxSize = 512 * 1024 * 1024
+
+// This must be changed if the layout of the entry file itself changes
+const diskCacheMagic = "esbuild-parse-cache-v1\n"
+
+type diskCacheKey [sha256.Size]byte
+
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	// This contains everything that affects parsing but that isn't part of the
+	// parser options for an individual file (the executable and the defines)
+	salt [sha256.Size]byte
+
+	mutex        sync.Mutex
+	bytesWritten int64
+}
+
+// This returns nil if the persistent cache can't be used, in which case files
+// will just be parsed as usual. The "fingerprint" should contain any build
+// options that affect parsing that aren't included in the parser options.
+func NewDiskCache(dir string, maxSize int64, fingerprint string) *DiskCache {
+	executable, ok := executableHash()
+	if !ok {
+		return nil
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultDiskCacheMaxSize
+	}
+	hash := sha256.New()
+	hash.Write(executable[:])
+	writeKeyPart(hash, fingerprint)
+	c := &DiskCache{
+		dir:     dir,
+		maxSize: maxSize,
+	}
+	copy(c.salt[:], hash.Sum(nil))
+	return c
+}
+
+var executableHashOnce sync.Once
+var executableHashValue [sha256.Size]byte
+var executableHashOK bool
+
+// Hashing the executable is done once per process since the executable can be
+// reasonably large
+func executableHash() ([sha256.Size]byte, bool) {
+	executableHashOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return
+		}
+		copy(executableHashValue[:], hash.Sum(nil))
+		executableHashOK = true
+	})
+	return executableHashValue, executableHashOK
+}
+
+// Each part is prefixed by its length so that different parts can't run into
+// each other and produce the same key
+func writeKeyPart(w io.Writer, part string) {
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(part)))
+	w.Write(length[:])
+	io.WriteString(w, part)
+}
+
+// The source index is not part of the key since it's different each time the
+// file is parsed. It's rewritten when the entry is loaded instead.
+func (c *DiskCache) key(kind string, source logger.Source, options string) (key diskCacheKey) {
+	hash := sha256.New()
+	hash.Write(c.salt[:])
+	writeKeyPart(hash, kind)
+	writeKeyPart(hash, options)
+	writeKeyPart(hash, source.KeyPath.Text)
+	writeKeyPart(hash, source.KeyPath.Namespace)
+	writeKeyPart(hash, source.KeyPath.IgnoredSuffix)
+	writeKeyPart(hash, source.PrettyPath)
+	writeKeyPart(hash, source.IdentifierName)
+	writeKeyPart(hash, source.Contents)
+	copy(key[:], hash.Sum(nil))
+	return
+}
+
+func (c *DiskCache) entryPath(key diskCacheKey) string {
+	name := hex.EncodeToString(key[:])
+	return filepath.Join(c.dir, name[:2], name[2:])
+}
+
+// The entry file format is the magic string, the key, a checksum of the
+// payload, and then the payload itself
+func (c *DiskCache) load(key diskCacheKey) ([]byte, bool) {
+	path := c.entryPath(key)
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	headerSize := len(diskCacheMagic) + len(key) + sha256.Size
+	if len(contents) < headerSize || string(contents[:len(diskCacheMagic)]) != diskCacheMagic ||
+		!bytes.Equal(contents[len(diskCacheMagic):len(diskCacheMagic)+len(key)], key[:]) {
+		os.Remove(path)
+		return nil, false
+	}
+	payload := contents[headerSize:]
+	checksum := sha256.Sum256(payload)
+	if !bytes.Equal(contents[headerSize-sha256.Size:headerSize], checksum[:]) {
+		os.Remove(path)
+		return nil, false
+	}
+
+	// Mark this entry as recently used so it's evicted last
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return payload, true
+}
+
+// Failures are ignored since the cache is only an optimization
+func (c *DiskCache) store(key diskCacheKey, payload []byte) {
+	checksum := sha256.Sum256(payload)
+	contents := make([]byte, 0, len(diskCacheMagic)+len(key)+len(checksum)+len(payload))
+	contents = append(contents, diskCacheMagic...)
+	contents = append(contents, key[:]...)
+	contents = append(contents, checksum[:]...)
+	contents = append(contents, payload...)
+	if int64(len(contents)) > c.maxSize {
+		return
+	}
+
+	// Write to a temporary file and then rename it into place so that other
+	// processes never observe a partially-written entry
+	path := c.entryPath(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(contents)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+
+	c.mutex.Lock()
+	c.bytesWritten += int64(len(contents))
+	c.mutex.Unlock()
+}
+
+// This deletes the least recently used entries if the cache directory is over
+// its size limit. It's meant to be called once at the end of each build.
+func (c *DiskCache) Evict() {
+	c.mutex.Lock()
+	bytesWritten := c.bytesWritten
+	c.bytesWritten = 0
+	c.mutex.Unlock()
+
+	// The cache can only have grown if something was written
+	if bytesWritten == 0 {
+		return
+	}
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var totalSize int64
+	staleTempFiles := time.Now().Add(-time.Hour)
+	filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		// Temporary files are left behind if a process is killed while writing
+		if strings.HasPrefix(info.Name(), "tmp-") {
+			if info.ModTime().Before(staleTempFiles) {
+				os.Remove(path)
+			}
+			return nil
+		}
+
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		totalSize += info.Size()
+		return nil
+	})
+	if totalSize <= c.maxSize {
+		return
+	}
+
+	// Evict down to a bit below the limit so that this doesn't need to happen
+	// again after every single build
+	targetSize := c.maxSize / 10 * 9
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, entry := range entries {
+		if totalSize <= targetSize {
+			break
+		}
+		if os.Remove(entry.path) == nil {
+			totalSize -= entry.size
+		}
+	}
+}
+
+// The payload starts with the source index that the value was parsed with so
+// that symbol references can be rewritten to use the new source index
+func (c *DiskCache) loadValue(key diskCacheKey, value interface{}, sourceIndex uint32) bool {
+	payload, ok := c.load(key)
+	if !ok {
+		return false
+	}
+	oldSourceIndex, width := binary.Uvarint(payload)
+	if width <= 0 {
+		return false
+	}
+	return decodeValue(payload[width:], value, uint32(oldSourceIndex), sourceIndex) == nil
+}
+
+func (c *DiskCache) storeValue(key diskCacheKey, value interface{}, sourceIndex uint32) {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return
+	}
+	var prefix [binary.MaxVarintLen32]byte
+	width := binary.PutUvarint(prefix[:], uint64(sourceIndex))
+	c.store(key, append(prefix[:width], encoded...))
+}
@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/css_parser"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_parser"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+const jsForTest = `
+	import def, { a as b } from './foo'
+	export * from './bar'
+	export let [x, { y = 1, ...z }] = [null, this, void 0, -1.5, 123n, /re/g]
+	label: for (const k in b) { if (k) continue label; else break label }
+	function* gen() { yield* arguments; debugger }
+	class Foo extends def { static #priv = new.target; method() { return super.method?.() } }
+	try { throw import.meta } catch { eval('x') } finally {}
+	switch (x) { case 1: ; default: y++ }
+	do ; while (false)
+	let fn = async (a, ...rest) => await ` + "`${a}`" + `
+	require('./baz'), import('./qux')
+`
+
+func parseJSForTest(t *testing.T, sourceIndex uint32) js_ast.AST {
+	t.Helper()
+	source := test.SourceForTest(jsForTest)
+	source.Index = sourceIndex
+	tree, ok := js_parser.Parse(logger.NewDeferLog(), source, js_parser.OptionsFromConfig(&config.Options{}))
+	if !ok {
+		t.Fatal("Parse error")
+	}
+	return tree
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	tree := parseJSForTest(t, 1)
+	encoded, err := encodeValue(&tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoding with the same source index should give back an identical AST
+	var decoded js_ast.AST
+	if err := decodeValue(encoded, &decoded, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree, decoded) {
+		t.Fatal("Decoded AST is different")
+	}
+
+	// Scopes are shared between the scope tree and the parts
+	inTree := make(map[*js_ast.Scope]bool)
+	var visit func(*js_ast.Scope)
+	visit = func(scope *js_ast.Scope) {
+		inTree[scope] = true
+		for _, child := range scope.Children {
+			if child.Parent != scope {
+				t.Fatal("Expected the parent pointer to be preserved")
+			}
+			visit(child)
+		}
+	}
+	visit(decoded.ModuleScope)
+	count := 0
+	for _, part := range decoded.Parts {
+		for _, scope := range part.Scopes {
+			if !inTree[scope] {
+				t.Fatal("Expected the scopes in each part to be in the scope tree")
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		t.Fatal("Expected some parts to have scopes")
+	}
+}
+
+func TestEncodingSourceIndex(t *testing.T) {
+	tree := parseJSForTest(t, 1)
+	encoded, err := encodeValue(&tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoding with a different source index should be the same as parsing with it
+	var decoded js_ast.AST
+	if err := decodeValue(encoded, &decoded, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parseJSForTest(t, 2), decoded) {
+		t.Fatal("Decoded AST is different")
+	}
+}
+
+func TestEncodingInvalid(t *testing.T) {
+	tree := parseJSForTest(t, 1)
+	encoded, err := encodeValue(&tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded js_ast.AST
+	if err := decodeValue(encoded[:len(encoded)/2], &decoded, 1, 1); err == nil {
+		t.Fatal("Expected an error for truncated data")
+	}
+
+	// Values that aren't part of an AST can't be encoded
+	value := struct{ Fn func() }{}
+	if _, err := encodeValue(&value); err != errNotEncodable {
+		t.Fatalf("Expected %v, got %v", errNotEncodable, err)
+	}
+}
+
+// Every type that implements one of the AST interfaces must be registered
+func TestEncodingInterfaceTypes(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, typ := range interfaceTypes {
+		registered[typ.Elem().String()] = true
+	}
+
+	markers := map[string]bool{
+		"isBinding":          true,
+		"isExpr":             true,
+		"isStmt":             true,
+		"isRule":             true,
+		"isSubclassSelector": true,
+	}
+	for pkg, path := range map[string]string{
+		"js_ast":  "../js_ast/js_ast.go",
+		"css_ast": "../css_ast/css_ast.go",
+	} {
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && markers[fn.Name.Name] {
+				name := pkg + "." + fn.Recv.List[0].Type.(*ast.StarExpr).X.(*ast.Ident).Name
+				if !registered[name] {
+					t.Errorf("The type %s is missing from \"interfaceTypes\"", name)
+				}
+			}
+		}
+	}
+}
+
+func makeDiskCacheForTest(t *testing.T, maxSize int64) (*DiskCache, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "esbuild-cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk := NewDiskCache(dir, maxSize, "")
+	if disk == nil {
+		t.Fatal("Expected a disk cache")
+	}
+	return disk, dir
+}
+
+func entriesForTest(t *testing.T, dir string) (paths []string) {
+	t.Helper()
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return
+}
+
+func TestDiskCache(t *testing.T) {
+	disk, dir := makeDiskCacheForTest(t, 0)
+	defer os.RemoveAll(dir)
+	source := test.SourceForTest("a { color: red }")
+	options := css_parser.Options{MangleSyntax: true}
+
+	// The first parse should populate the persistent cache
+	caches := MakeCacheSet()
+	caches.SetDiskCache(disk)
+	expected := caches.CSSCache.Parse(logger.NewDeferLog(), source, options)
+	entries := entriesForTest(t, dir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	// Another process should be able to use the entry
+	caches = MakeCacheSet()
+	caches.SetDiskCache(disk)
+	if ast := caches.CSSCache.Parse(logger.NewDeferLog(), source, options); !reflect.DeepEqual(ast, expected) {
+		t.Fatal("Cached AST is different")
+	}
+
+	// Different options must not use the same entry
+	caches = MakeCacheSet()
+	caches.SetDiskCache(disk)
+	caches.CSSCache.Parse(logger.NewDeferLog(), source, css_parser.Options{})
+	if entries := entriesForTest(t, dir); len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	// A corrupt entry should be detected and replaced
+	for _, path := range entriesForTest(t, dir) {
+		if err := ioutil.WriteFile(path, []byte(diskCacheMagic), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := disk.key("css", source, fmt.Sprintf("%+v", options))
+	if _, ok := disk.load(key); ok {
+		t.Fatal("Expected a cache miss")
+	}
+	caches = MakeCacheSet()
+	caches.SetDiskCache(disk)
+	if ast := caches.CSSCache.Parse(logger.NewDeferLog(), source, options); !reflect.DeepEqual(ast, expected) {
+		t.Fatal("Cached AST is different")
+	}
+	if _, ok := disk.load(key); !ok {
+		t.Fatal("Expected a cache hit")
+	}
+}
+
+func TestDiskCacheEvict(t *testing.T) {
+	disk, dir := makeDiskCacheForTest(t, 1024)
+	defer os.RemoveAll(dir)
+
+	caches := MakeCacheSet()
+	caches.SetDiskCache(disk)
+	for i := 0; i < 20; i++ {
+		source := test.SourceForTest("[" + string(rune('a'+i)) + "] { color: red }")
+		caches.CSSCache.Parse(logger.NewDeferLog(), source, css_parser.Options{})
+	}
+	disk.Evict()
+
+	var totalSize int64
+	entries := entriesForTest(t, dir)
+	for _, path := range entries {
+		if info, err := os.Stat(path); err == nil {
+			totalSize += info.Size()
+		}
+	}
+	if len(entries) == 0 || totalSize > 1024 {
+		t.Fatalf("Expected the cache to be evicted down to the limit, got %d entries with %d bytes", len(entries), totalSize)
+	}
+}
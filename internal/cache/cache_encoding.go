@@ -0,0 +1,558 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/js_ast"
+)
+
+// This is a binary encoding for parse results that is used by the persistent
+// cache. It uses reflection to derive the encoding instead of a hand-written
+// encoder for each AST node so that it doesn't need to be updated every time an
+// AST node changes. The persistent cache is keyed on the executable itself, so the
+// encoding never needs to be compatible with data from a different build.
+//
+// Unlike "encoding/gob", this preserves pointer identity (the scope tree has
+// cycles and scopes are shared between the tree and each part) and supports
+// structs without any fields (e.g. "js_ast.ENull"). Types that can't be
+// encoded cause the whole value to not be cached instead of being dropped.
+
+var errNotEncodable = errors.New("Value cannot be encoded")
+var errInvalidEncoding = errors.New("Invalid encoding")
+
+// These are all of the concrete types that can be stored inside an interface
+// in an AST. Each one must be listed here because Go has no way to enumerate
+// the types that implement an interface at run-time.
+var interfaceTypes = []reflect.Type{
+	// Bindings
+	reflect.TypeOf(&js_ast.BMissing{}),
+	reflect.TypeOf(&js_ast.BIdentifier{}),
+	reflect.TypeOf(&js_ast.BArray{}),
+	reflect.TypeOf(&js_ast.BObject{}),
+
+	// Expressions
+	reflect.TypeOf(&js_ast.EArray{}),
+	reflect.TypeOf(&js_ast.EUnary{}),
+	reflect.TypeOf(&js_ast.EBinary{}),
+	reflect.TypeOf(&js_ast.EBoolean{}),
+	reflect.TypeOf(&js_ast.ESuper{}),
+	reflect.TypeOf(&js_ast.ENull{}),
+	reflect.TypeOf(&js_ast.EUndefined{}),
+	reflect.TypeOf(&js_ast.EThis{}),
+	reflect.TypeOf(&js_ast.ENew{}),
+	reflect.TypeOf(&js_ast.ENewTarget{}),
+	reflect.TypeOf(&js_ast.EImportMeta{}),
+	reflect.TypeOf(&js_ast.ECall{}),
+	reflect.TypeOf(&js_ast.EDot{}),
+	reflect.TypeOf(&js_ast.EIndex{}),
+	reflect.TypeOf(&js_ast.EArrow{}),
+	reflect.TypeOf(&js_ast.EFunction{}),
+	reflect.TypeOf(&js_ast.EClass{}),
+	reflect.TypeOf(&js_ast.EIdentifier{}),
+	reflect.TypeOf(&js_ast.EImportIdentifier{}),
+	reflect.TypeOf(&js_ast.EPrivateIdentifier{}),
+	reflect.TypeOf(&js_ast.EJSXElement{}),
+	reflect.TypeOf(&js_ast.EMissing{}),
+	reflect.TypeOf(&js_ast.ENumber{}),
+	reflect.TypeOf(&js_ast.EBigInt{}),
+	reflect.TypeOf(&js_ast.EObject{}),
+	reflect.TypeOf(&js_ast.ESpread{}),
+	reflect.TypeOf(&js_ast.EString{}),
+	reflect.TypeOf(&js_ast.ETemplate{}),
+	reflect.TypeOf(&js_ast.ERegExp{}),
+	reflect.TypeOf(&js_ast.EAwait{}),
+	reflect.TypeOf(&js_ast.EYield{}),
+	reflect.TypeOf(&js_ast.EIf{}),
+	reflect.TypeOf(&js_ast.ERequire{}),
+	reflect.TypeOf(&js_ast.ERequireResolve{}),
+	reflect.TypeOf(&js_ast.EImport{}),
+
+	// Statements
+	reflect.TypeOf(&js_ast.SBlock{}),
+	reflect.TypeOf(&js_ast.SComment{}),
+	reflect.TypeOf(&js_ast.SDebugger{}),
+	reflect.TypeOf(&js_ast.SDirective{}),
+	reflect.TypeOf(&js_ast.SEmpty{}),
+	reflect.TypeOf(&js_ast.STypeScript{}),
+	reflect.TypeOf(&js_ast.SExportClause{}),
+	reflect.TypeOf(&js_ast.SExportFrom{}),
+	reflect.TypeOf(&js_ast.SExportDefault{}),
+	reflect.TypeOf(&js_ast.SExportStar{}),
+	reflect.TypeOf(&js_ast.SExportEquals{}),
+	reflect.TypeOf(&js_ast.SLazyExport{}),
+	reflect.TypeOf(&js_ast.SExpr{}),
+	reflect.TypeOf(&js_ast.SEnum{}),
+	reflect.TypeOf(&js_ast.SNamespace{}),
+	reflect.TypeOf(&js_ast.SFunction{}),
+	reflect.TypeOf(&js_ast.SClass{}),
+	reflect.TypeOf(&js_ast.SLabel{}),
+	reflect.TypeOf(&js_ast.SIf{}),
+	reflect.TypeOf(&js_ast.SFor{}),
+	reflect.TypeOf(&js_ast.SForIn{}),
+	reflect.TypeOf(&js_ast.SForOf{}),
+	reflect.TypeOf(&js_ast.SDoWhile{}),
+	reflect.TypeOf(&js_ast.SWhile{}),
+	reflect.TypeOf(&js_ast.SWith{}),
+	reflect.TypeOf(&js_ast.STry{}),
+	reflect.TypeOf(&js_ast.SSwitch{}),
+	reflect.TypeOf(&js_ast.SImport{}),
+	reflect.TypeOf(&js_ast.SReturn{}),
+	reflect.TypeOf(&js_ast.SThrow{}),
+	reflect.TypeOf(&js_ast.SLocal{}),
+	reflect.TypeOf(&js_ast.SBreak{}),
+	reflect.TypeOf(&js_ast.SContinue{}),
+
+	// CSS rules
+	reflect.TypeOf(&css_ast.RAtCharset{}),
+	reflect.TypeOf(&css_ast.RAtImport{}),
+	reflect.TypeOf(&css_ast.RAtKeyframes{}),
+	reflect.TypeOf(&css_ast.RKnownAt{}),
+	reflect.TypeOf(&css_ast.RAtLayer{}),
+	reflect.TypeOf(&css_ast.RUnknownAt{}),
+	reflect.TypeOf(&css_ast.RSelector{}),
+	reflect.TypeOf(&css_ast.RQualified{}),
+	reflect.TypeOf(&css_ast.RDeclaration{}),
+	reflect.TypeOf(&css_ast.RBadDeclaration{}),
+
+	// CSS subclass selectors
+	reflect.TypeOf(&css_ast.SSHash{}),
+	reflect.TypeOf(&css_ast.SSClass{}),
+	reflect.TypeOf(&css_ast.SSAttribute{}),
+	reflect.TypeOf(&css_ast.SSPseudoClass{}),
+}
+
+// Symbol references contain the source index of the file they were parsed
+// from. Source indices are assigned in the order files are discovered, so they
+// are different each time and must be rewritten when decoding.
+var typesWithSourceIndex = []reflect.Type{
+	reflect.TypeOf(js_ast.Ref{}),
+	reflect.TypeOf(js_ast.Dependency{}),
+}
+
+// Walking values using "reflect.Value" is too slow to be worth caching, since
+// decoding would take longer than parsing. Instead, a codec is compiled once
+// for each type that reads and writes memory directly at precomputed offsets.
+type codec struct {
+	encode func(e *encoder, p unsafe.Pointer)
+	decode func(d *decoder, p unsafe.Pointer)
+}
+
+var codecsMutex sync.Mutex
+var codecs = make(map[reflect.Type]*codec)
+var interfaceCodecs []*codec
+
+// This must be called with "codecsMutex" held. Codecs are added to the map
+// before they are compiled so that recursive types refer to themselves.
+func codecForType(t reflect.Type) *codec {
+	if c, ok := codecs[t]; ok {
+		return c
+	}
+	c := &codec{}
+	codecs[t] = c
+
+	switch t.Kind() {
+	case reflect.Bool:
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			if *(*bool)(p) {
+				e.bytes = append(e.bytes, 1)
+			} else {
+				e.bytes = append(e.bytes, 0)
+			}
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			*(*bool)(p) = d.byte() != 0
+		}
+
+	case reflect.Int:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.varint(int64(*(*int)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*int)(p) = int(d.varint()) }
+	case reflect.Int8:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.varint(int64(*(*int8)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*int8)(p) = int8(d.varint()) }
+	case reflect.Int16:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.varint(int64(*(*int16)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*int16)(p) = int16(d.varint()) }
+	case reflect.Int32:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.varint(int64(*(*int32)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*int32)(p) = int32(d.varint()) }
+	case reflect.Int64:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.varint(*(*int64)(p)) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*int64)(p) = d.varint() }
+
+	case reflect.Uint:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(uint64(*(*uint)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*uint)(p) = uint(d.uvarint()) }
+	case reflect.Uint8:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.bytes = append(e.bytes, *(*uint8)(p)) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*uint8)(p) = d.byte() }
+	case reflect.Uint16:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(uint64(*(*uint16)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*uint16)(p) = uint16(d.uvarint()) }
+	case reflect.Uint32:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(uint64(*(*uint32)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*uint32)(p) = uint32(d.uvarint()) }
+	case reflect.Uint64:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(*(*uint64)(p)) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*uint64)(p) = d.uvarint() }
+
+	case reflect.Float32:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(uint64(math.Float32bits(*(*float32)(p)))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*float32)(p) = math.Float32frombits(uint32(d.uvarint())) }
+	case reflect.Float64:
+		c.encode = func(e *encoder, p unsafe.Pointer) { e.uvarint(math.Float64bits(*(*float64)(p))) }
+		c.decode = func(d *decoder, p unsafe.Pointer) { *(*float64)(p) = math.Float64frombits(d.uvarint()) }
+
+	// Decoded strings are substrings of the data so they don't need to be copied
+	case reflect.String:
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			text := *(*string)(p)
+			e.uvarint(uint64(len(text)))
+			e.bytes = append(e.bytes, text...)
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			n := d.length()
+			*(*string)(p) = d.data[d.pos : d.pos+n]
+			d.pos += n
+		}
+
+	case reflect.Array:
+		elem := codecForType(t.Elem())
+		size := t.Elem().Size()
+		n := uintptr(t.Len())
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			for i := uintptr(0); i < n; i++ {
+				elem.encode(e, unsafe.Pointer(uintptr(p)+i*size))
+			}
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			for i := uintptr(0); i < n; i++ {
+				elem.decode(d, unsafe.Pointer(uintptr(p)+i*size))
+			}
+		}
+
+	// Slices and maps distinguish between nil and empty because some code
+	// checks for nil specifically
+	case reflect.Slice:
+		elem := codecForType(t.Elem())
+		size := t.Elem().Size()
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			slice := (*reflect.SliceHeader)(p)
+			if slice.Data == 0 {
+				e.uvarint(0)
+				return
+			}
+			e.uvarint(uint64(slice.Len) + 1)
+			data := unsafe.Pointer(slice.Data)
+			for i := 0; i < slice.Len; i++ {
+				elem.encode(e, unsafe.Pointer(uintptr(data)+uintptr(i)*size))
+			}
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			n := d.length()
+			if n == 0 {
+				return
+			}
+			n--
+			slice := reflect.MakeSlice(t, n, n)
+			reflect.NewAt(t, p).Elem().Set(slice)
+			data := unsafe.Pointer(slice.Pointer())
+			for i := 0; i < n; i++ {
+				elem.decode(d, unsafe.Pointer(uintptr(data)+uintptr(i)*size))
+			}
+		}
+
+	case reflect.Map:
+		keyType, valueType := t.Key(), t.Elem()
+		key, value := codecForType(keyType), codecForType(valueType)
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			m := reflect.NewAt(t, p).Elem()
+			if m.IsNil() {
+				e.uvarint(0)
+				return
+			}
+			e.uvarint(uint64(m.Len()) + 1)
+			k := reflect.New(keyType)
+			v := reflect.New(valueType)
+			iter := m.MapRange()
+			for iter.Next() {
+				k.Elem().Set(iter.Key())
+				v.Elem().Set(iter.Value())
+				key.encode(e, unsafe.Pointer(k.Pointer()))
+				value.encode(e, unsafe.Pointer(v.Pointer()))
+			}
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			n := d.length()
+			if n == 0 {
+				return
+			}
+			n--
+			m := reflect.MakeMapWithSize(t, n)
+			k := reflect.New(keyType).Elem()
+			v := reflect.New(valueType).Elem()
+			zeroKey := reflect.Zero(keyType)
+			zeroValue := reflect.Zero(valueType)
+			for i := 0; i < n; i++ {
+				// The map copies the key and value so the temporaries can be reused
+				k.Set(zeroKey)
+				v.Set(zeroValue)
+				key.decode(d, unsafe.Pointer(k.UnsafeAddr()))
+				value.decode(d, unsafe.Pointer(v.UnsafeAddr()))
+				m.SetMapIndex(k, v)
+			}
+			reflect.NewAt(t, p).Elem().Set(m)
+		}
+
+	// Pointers are encoded as 0 for nil, 1 for a new object followed by that
+	// object, or 2 + the index of an object that was already encoded
+	case reflect.Ptr:
+		elemType := t.Elem()
+		elem := codecForType(elemType)
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			ptr := *(*unsafe.Pointer)(p)
+			if ptr == nil {
+				e.uvarint(0)
+				return
+			}
+			key := pointerKey{address: ptr, codec: c}
+			if index, ok := e.pointers[key]; ok {
+				e.uvarint(index + 2)
+				return
+			}
+			e.pointers[key] = uint64(len(e.pointers))
+			e.uvarint(1)
+			elem.encode(e, ptr)
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			tag := d.uvarint()
+			switch {
+			case tag == 0:
+				*(*unsafe.Pointer)(p) = nil
+			case tag == 1:
+				// Register the pointer before decoding the object so that cycles work
+				ptr := reflect.New(elemType)
+				*(*unsafe.Pointer)(p) = unsafe.Pointer(ptr.Pointer())
+				d.pointers = append(d.pointers, pointerKey{address: *(*unsafe.Pointer)(p), codec: c})
+				elem.decode(d, *(*unsafe.Pointer)(p))
+			case tag-2 < uint64(len(d.pointers)) && d.pointers[tag-2].codec == c:
+				*(*unsafe.Pointer)(p) = d.pointers[tag-2].address
+			default:
+				panic(errInvalidEncoding)
+			}
+		}
+
+	// All concrete types stored in interfaces are pointers, so the value is
+	// encoded as the index of the type followed by the pointer. Interfaces are
+	// read and written directly as a type word followed by a data word instead
+	// of using reflection, which is much slower.
+	case reflect.Interface:
+		if interfaceCodecs == nil {
+			interfaceCodecs = make([]*codec, len(interfaceTypes))
+			for i, concrete := range interfaceTypes {
+				interfaceCodecs[i] = codecForType(concrete)
+			}
+		}
+		typeWords := make([]unsafe.Pointer, len(interfaceTypes))
+		typeWordIndices := make(map[unsafe.Pointer]uint64)
+		for i, concrete := range interfaceTypes {
+			if concrete.Implements(t) {
+				value := reflect.New(t)
+				value.Elem().Set(reflect.Zero(concrete))
+				typeWords[i] = (*[2]unsafe.Pointer)(unsafe.Pointer(value.Pointer()))[0]
+				typeWordIndices[typeWords[i]] = uint64(i)
+			}
+		}
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			words := (*[2]unsafe.Pointer)(p)
+			if words[0] == nil {
+				e.uvarint(0)
+				return
+			}
+			index, ok := typeWordIndices[words[0]]
+			if !ok {
+				panic(errNotEncodable)
+			}
+			e.uvarint(index + 1)
+			interfaceCodecs[index].encode(e, unsafe.Pointer(&words[1]))
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			tag := d.uvarint()
+			if tag == 0 {
+				return
+			}
+			if tag-1 >= uint64(len(typeWords)) || typeWords[tag-1] == nil {
+				panic(errInvalidEncoding)
+			}
+			words := (*[2]unsafe.Pointer)(p)
+			interfaceCodecs[tag-1].decode(d, unsafe.Pointer(&words[1]))
+			words[0] = typeWords[tag-1]
+		}
+
+	// Unexported fields (e.g. the one in "ast.Index32") are included too
+	case reflect.Struct:
+		type field struct {
+			offset uintptr
+			codec  *codec
+		}
+		fields := make([]field, t.NumField())
+		for i := range fields {
+			f := t.Field(i)
+			fields[i] = field{offset: f.Offset, codec: codecForType(f.Type)}
+		}
+		c.encode = func(e *encoder, p unsafe.Pointer) {
+			for _, f := range fields {
+				f.codec.encode(e, unsafe.Pointer(uintptr(p)+f.offset))
+			}
+		}
+		c.decode = func(d *decoder, p unsafe.Pointer) {
+			for _, f := range fields {
+				f.codec.decode(d, unsafe.Pointer(uintptr(p)+f.offset))
+			}
+		}
+		for _, other := range typesWithSourceIndex {
+			if t == other {
+				sourceIndex, _ := t.FieldByName("SourceIndex")
+				decodeFields := c.decode
+				c.decode = func(d *decoder, p unsafe.Pointer) {
+					decodeFields(d, p)
+					if ptr := (*uint32)(unsafe.Pointer(uintptr(p) + sourceIndex.Offset)); *ptr == d.oldSourceIndex {
+						*ptr = d.newSourceIndex
+					}
+				}
+			}
+		}
+
+	default:
+		c.encode = func(*encoder, unsafe.Pointer) { panic(errNotEncodable) }
+		c.decode = func(*decoder, unsafe.Pointer) { panic(errInvalidEncoding) }
+	}
+
+	return c
+}
+
+type pointerKey struct {
+	address unsafe.Pointer
+	codec   *codec
+}
+
+type encoder struct {
+	bytes    []byte
+	pointers map[pointerKey]uint64
+}
+
+// The value must be a pointer to the value to encode
+func encodeValue(value interface{}) (bytes []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok && e == errNotEncodable {
+				bytes, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	v := reflect.ValueOf(value)
+	codecsMutex.Lock()
+	c := codecForType(v.Type().Elem())
+	codecsMutex.Unlock()
+
+	e := encoder{pointers: make(map[pointerKey]uint64)}
+	c.encode(&e, unsafe.Pointer(v.Pointer()))
+
+	// The number of pointers goes first so the decoder can allocate its table
+	body := e.bytes
+	e.bytes = make([]byte, 0, len(body)+10)
+	e.uvarint(uint64(len(e.pointers)))
+	return append(e.bytes, body...), nil
+}
+
+func (e *encoder) uvarint(n uint64) {
+	for n >= 0x80 {
+		e.bytes = append(e.bytes, byte(n)|0x80)
+		n >>= 7
+	}
+	e.bytes = append(e.bytes, byte(n))
+}
+
+func (e *encoder) varint(n int64) {
+	e.uvarint(uint64(n<<1) ^ uint64(n>>63))
+}
+
+type decoder struct {
+	data           string
+	pos            int
+	pointers       []pointerKey
+	oldSourceIndex uint32
+	newSourceIndex uint32
+}
+
+// The value must be a pointer to a zero value of the type that was encoded
+func decodeValue(bytes []byte, value interface{}, oldSourceIndex uint32, newSourceIndex uint32) (err error) {
+	// The data should have already been validated with a checksum, but still
+	// don't crash if it's somehow malformed
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: %v", errInvalidEncoding, r)
+		}
+	}()
+
+	v := reflect.ValueOf(value)
+	codecsMutex.Lock()
+	c := codecForType(v.Type().Elem())
+	codecsMutex.Unlock()
+
+	d := decoder{
+		data:           string(bytes),
+		oldSourceIndex: oldSourceIndex,
+		newSourceIndex: newSourceIndex,
+	}
+	d.pointers = make([]pointerKey, 0, d.length())
+	c.decode(&d, unsafe.Pointer(v.Pointer()))
+	if d.pos != len(d.data) {
+		return errInvalidEncoding
+	}
+	return nil
+}
+
+func (d *decoder) byte() byte {
+	if d.pos >= len(d.data) {
+		panic(errInvalidEncoding)
+	}
+	c := d.data[d.pos]
+	d.pos++
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	var n uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		c := d.byte()
+		n |= uint64(c&0x7F) << shift
+		if c < 0x80 {
+			return n
+		}
+	}
+	panic(errInvalidEncoding)
+}
+
+func (d *decoder) varint() int64 {
+	n := d.uvarint()
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+// Every element takes up at least one byte, so a length that's longer than
+// the remaining data is invalid. This avoids huge allocations for bad data.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		panic(errInvalidEncoding)
+	}
+	return int(n)
+}
@@ -0,0 +1,179 @@
+package compat
+
+import (
+	"github.com/evanw/esbuild/internal/css_ast"
+)
+
+type CSSPrefix uint8
+
+const (
+	MozPrefix CSSPrefix = 1 << iota
+	MsPrefix
+	WebkitPrefix
+
+	NoPrefix CSSPrefix = 0
+)
+
+type prefixData struct {
+	engine        Engine
+	prefix        CSSPrefix
+	withoutPrefix []int // This is nil if the prefix is still required
+}
+
+// This is a pseudo-element selector such as "::placeholder" that needs a
+// prefix. These are keyed by name instead of by "css_ast.D".
+type CSSPseudoElement uint8
+
+const (
+	PseudoElementPlaceholder CSSPseudoElement = iota
+)
+
+// Prefixes for "position: sticky" are stored under "css_ast.DPosition". The
+// parser only uses them when the value is "sticky".
+var cssPrefixTable = map[css_ast.D][]prefixData{
+	// Data from: https://caniuse.com/css-appearance
+	css_ast.DAppearance: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{84}},
+		{engine: Edge, prefix: WebkitPrefix, withoutPrefix: []int{84}},
+		{engine: Firefox, prefix: MozPrefix, withoutPrefix: []int{80}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+	},
+
+	// Data from: https://caniuse.com/css-backdrop-filter
+	css_ast.DBackdropFilter: {
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{18}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{18}},
+	},
+
+	// Data from: https://caniuse.com/transforms3d
+	css_ast.DBackfaceVisibility: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{36}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+	},
+
+	// Data from: https://caniuse.com/css-boxdecorationbreak
+	css_ast.DBoxDecorationBreak: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{130}},
+		{engine: Edge, prefix: WebkitPrefix, withoutPrefix: []int{130}},
+		{engine: IOS, prefix: WebkitPrefix},
+		{engine: Safari, prefix: WebkitPrefix},
+	},
+
+	// Data from: https://caniuse.com/css-clip-path
+	css_ast.DClipPath: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{55}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{13}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{13, 1}},
+	},
+
+	// Data from: https://caniuse.com/css-hyphens
+	css_ast.DHyphens: {
+		{engine: Firefox, prefix: MozPrefix, withoutPrefix: []int{43}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{17}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{17}},
+	},
+
+	// Data from: https://caniuse.com/css-masks
+	css_ast.DMaskImage: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{120}},
+		{engine: Edge, prefix: WebkitPrefix, withoutPrefix: []int{120}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{15, 4}},
+	},
+
+	// Data from: https://caniuse.com/css-sticky
+	css_ast.DPosition: {
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{13}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{13}},
+	},
+
+	// Data from: https://caniuse.com/css3-tabsize
+	css_ast.DTabSize: {
+		{engine: Firefox, prefix: MozPrefix, withoutPrefix: []int{91}},
+	},
+
+	// Data from: https://caniuse.com/text-size-adjust
+	css_ast.DTextSizeAdjust: {
+		{engine: Edge, prefix: MsPrefix, withoutPrefix: []int{79}},
+		{engine: IOS, prefix: WebkitPrefix},
+	},
+
+	// Data from: https://caniuse.com/mdn-css_properties_user-select
+	css_ast.DUserSelect: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{54}},
+		{engine: Edge, prefix: MsPrefix, withoutPrefix: []int{79}},
+		{engine: Firefox, prefix: MozPrefix, withoutPrefix: []int{69}},
+		{engine: IOS, prefix: WebkitPrefix},
+		{engine: Safari, prefix: WebkitPrefix},
+	},
+}
+
+var cssPseudoElementPrefixTable = map[CSSPseudoElement][]prefixData{
+	// Data from: https://caniuse.com/css-placeholder
+	PseudoElementPlaceholder: {
+		{engine: Chrome, prefix: WebkitPrefix, withoutPrefix: []int{57}},
+		{engine: Edge, prefix: MsPrefix, withoutPrefix: []int{79}},
+		{engine: Firefox, prefix: MozPrefix, withoutPrefix: []int{51}},
+		{engine: IOS, prefix: WebkitPrefix, withoutPrefix: []int{10, 3}},
+		{engine: Safari, prefix: WebkitPrefix, withoutPrefix: []int{10, 1}},
+	},
+}
+
+// This is the set of prefixes that need to be added for each property and
+// pseudo-element for the configured target environment. Properties and
+// pseudo-elements that don't need any prefixes are omitted.
+type CSSPrefixData struct {
+	Properties     map[css_ast.D]CSSPrefix
+	PseudoElements map[CSSPseudoElement]CSSPrefix
+}
+
+func (a *CSSPrefixData) Equal(b *CSSPrefixData) bool {
+	if len(a.Properties) != len(b.Properties) || len(a.PseudoElements) != len(b.PseudoElements) {
+		return false
+	}
+	for key, prefixes := range a.Properties {
+		if other, ok := b.Properties[key]; !ok || other != prefixes {
+			return false
+		}
+	}
+	for key, prefixes := range a.PseudoElements {
+		if other, ok := b.PseudoElements[key]; !ok || other != prefixes {
+			return false
+		}
+	}
+	return true
+}
+
+// Return the prefixes that are needed by at least one environment
+func CSSPrefixDataForConstraints(constraints map[Engine][]int) (data CSSPrefixData) {
+	for key, items := range cssPrefixTable {
+		if prefixes := prefixesForConstraints(items, constraints); prefixes != NoPrefix {
+			if data.Properties == nil {
+				data.Properties = make(map[css_ast.D]CSSPrefix)
+			}
+			data.Properties[key] = prefixes
+		}
+	}
+	for key, items := range cssPseudoElementPrefixTable {
+		if prefixes := prefixesForConstraints(items, constraints); prefixes != NoPrefix {
+			if data.PseudoElements == nil {
+				data.PseudoElements = make(map[CSSPseudoElement]CSSPrefix)
+			}
+			data.PseudoElements[key] = prefixes
+		}
+	}
+	return
+}
+
+func prefixesForConstraints(items []prefixData, constraints map[Engine][]int) (prefixes CSSPrefix) {
+	for _, item := range items {
+		if version, ok := constraints[item.engine]; ok {
+			if item.withoutPrefix == nil || isVersionLessThan(version, item.withoutPrefix) {
+				prefixes |= item.prefix
+			}
+		}
+	}
+	return
+}
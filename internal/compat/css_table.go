@@ -13,6 +13,9 @@ const (
 	// - rgb() can accept alpha values
 	// - Space-separated functional color notations
 	Modern_RGB_HSL
+
+	// Nested style rules, both with and without an explicit "&"
+	Nesting
 )
 
 func (features CSSFeature) Has(feature CSSFeature) bool {
@@ -42,6 +45,15 @@ var cssTable = map[CSSFeature]map[Engine][]int{
 		IOS:     {12, 2},
 		Safari:  {12, 1},
 	},
+
+	// Data from: https://developer.mozilla.org/en-US/docs/Web/CSS/CSS_nesting
+	Nesting: {
+		Chrome:  {120},
+		Edge:    {120},
+		Firefox: {117},
+		IOS:     {17, 2},
+		Safari:  {17, 2},
+	},
 }
 
 // Return all features that are not available in at least one environment
@@ -45,11 +45,13 @@ const (
 	Class
 	ClassField
 	ClassPrivateAccessor
+	ClassPrivateBrandCheck
 	ClassPrivateField
 	ClassPrivateMethod
 	ClassPrivateStaticAccessor
 	ClassPrivateStaticField
 	ClassPrivateStaticMethod
+	ClassStaticBlocks
 	ClassStaticField
 	Const
 	DefaultArgument
@@ -61,6 +63,7 @@ const (
 	ForOf
 	Generator
 	Hashbang
+	ImportAssertions
 	ImportMeta
 	Let
 	LogicalAssignment
@@ -150,6 +153,15 @@ var jsTable = map[JSFeature]map[Engine][]int{
 		Edge:   {84},
 		Node:   {14, 6},
 	},
+	ClassPrivateBrandCheck: {
+		Chrome:  {91},
+		Edge:    {91},
+		ES:      {2022},
+		Firefox: {90},
+		IOS:     {15},
+		Node:    {16, 9},
+		Safari:  {15},
+	},
 	ClassPrivateField: {
 		Chrome: {84},
 		Edge:   {84},
@@ -177,6 +189,13 @@ var jsTable = map[JSFeature]map[Engine][]int{
 		Edge:   {84},
 		Node:   {14, 6},
 	},
+	ClassStaticBlocks: {
+		Chrome:  {91},
+		Edge:    {94},
+		ES:      {2022},
+		Firefox: {93},
+		Node:    {16, 11},
+	},
 	ClassStaticField: {
 		Chrome:  {73},
 		Edge:    {79},
@@ -271,6 +290,11 @@ var jsTable = map[JSFeature]map[Engine][]int{
 		Node:    {12, 0},
 		Safari:  {13, 1},
 	},
+	ImportAssertions: {
+		Chrome: {91},
+		Edge:   {91},
+		Node:   {16, 14},
+	},
 	ImportMeta: {
 		Chrome:  {64},
 		Edge:    {79},
@@ -8,6 +8,7 @@ import (
 
 	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/compat"
+	"github.com/evanw/esbuild/internal/helpers"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/logger"
 )
@@ -68,15 +69,37 @@ const (
 	LoaderFile
 	LoaderBinary
 	LoaderCSS
+	LoaderLocalCSS
 	LoaderDefault
 )
 
+var LoaderToString = []string{
+	"none",
+	"js",
+	"jsx",
+	"ts",
+	"tsx",
+	"json",
+	"text",
+	"base64",
+	"dataurl",
+	"file",
+	"binary",
+	"css",
+	"local-css",
+	"default",
+}
+
 func (loader Loader) IsTypeScript() bool {
 	return loader == LoaderTS || loader == LoaderTSX
 }
 
+func (loader Loader) IsCSS() bool {
+	return loader == LoaderCSS || loader == LoaderLocalCSS
+}
+
 func (loader Loader) CanHaveSourceMap() bool {
-	return loader == LoaderJS || loader == LoaderJSX || loader == LoaderTS || loader == LoaderTSX
+	return loader == LoaderJS || loader == LoaderJSX || loader == LoaderTS || loader == LoaderTSX || loader.IsCSS()
 }
 
 type Format uint8
@@ -207,6 +230,7 @@ type Options struct {
 
 	UnsupportedJSFeatures  compat.JSFeature
 	UnsupportedCSSFeatures compat.CSSFeature
+	CSSPrefixData          compat.CSSPrefixData
 
 	// This is the original information that was used to generate the
 	// unsupported feature sets above. It's used for error messages.
@@ -218,6 +242,21 @@ type Options struct {
 	AbsNodePaths    []string // The "NODE_PATH" variable from Node.js
 	ExternalModules ExternalModules
 
+	PackageEntryRedirects []PackageEntryRedirect
+
+	// This maps package names to replacement paths. An import path is rewritten
+	// if it's equal to a package name here or if it starts with the package
+	// name followed by a "/", with the longest package name winning. Aliases
+	// apply to every file in the build including files inside "node_modules"
+	// and don't depend on any "tsconfig.json" file.
+	//
+	// Aliases are applied before anything else, so the rewritten path is then
+	// subject to external checks, "tsconfig.json" paths, the "browser" map in
+	// "package.json", and the "exports" map of the package it now refers to.
+	// A relative replacement path is resolved relative to the working directory
+	// when the option is validated.
+	Alias map[string]string
+
 	AbsOutputFile      string
 	AbsOutputDir       string
 	AbsOutputBase      string
@@ -251,11 +290,24 @@ type Options struct {
 
 	Stdin *StdinInfo
 
+	// This is nil unless the time spent in each phase of the build is recorded
+	Tracer *helpers.Tracer
+
 	// Snapshot
 	CreateSnapshot     bool
 	SnapshotAbsBaseDir string
 }
 
+// Some packages pick the file that implements them at run-time, which can't
+// be bundled. This replaces the file that a "main" field of such a package
+// resolves to with one of the implementations. Both paths are relative to the
+// directory containing the "package.json" file.
+type PackageEntryRedirect struct {
+	PackageName string
+	Main        string
+	Redirect    string
+}
+
 type PathPlaceholder uint8
 
 const (
@@ -438,10 +490,21 @@ func PluginAppliesToPath(path logger.Path, filter *regexp.Regexp, namespace stri
 
 type Plugin struct {
 	Name      string
+	OnStart   []OnStart
 	OnResolve []OnResolve
 	OnLoad    []OnLoad
 }
 
+type OnStart struct {
+	Name     string
+	Callback func() OnStartResult
+}
+
+type OnStartResult struct {
+	Msgs        []logger.Msg
+	ThrownError error
+}
+
 type OnResolve struct {
 	Name      string
 	Filter    *regexp.Regexp
@@ -455,6 +518,7 @@ type OnResolveArgs struct {
 	ResolveDir string
 	Kind       ast.ImportKind
 	PluginData interface{}
+	Assertions map[string]string
 }
 
 type OnResolveResult struct {
@@ -481,6 +545,7 @@ type OnLoad struct {
 type OnLoadArgs struct {
 	Path       logger.Path
 	PluginData interface{}
+	Assertions map[string]string
 }
 
 type OnLoadResult struct {
@@ -21,8 +21,33 @@ import (
 // representation that helps provide good parsing and printing performance.
 
 type AST struct {
-	ImportRecords []ast.ImportRecord
-	Rules         []R
+	ImportRecords        []ast.ImportRecord
+	Rules                []Rule
+	ApproximateLineCount int32
+
+	// This is only used for CSS modules (i.e. the "local-css" loader). It maps
+	// each local class or keyframe name to its renamed version and is in the
+	// order that the names first appeared in the file.
+	LocalNames []LocalName
+}
+
+type LocalName struct {
+	Original string
+	Local    string
+
+	// These are the additional names from "composes" declarations that should
+	// be applied along with this name
+	Composes []ComposesName
+}
+
+type ComposesName struct {
+	// This is the original name. It refers to a local name in this file unless
+	// it's global or the import record index is valid, in which case it refers
+	// to a local name in the CSS module imported by that record.
+	Name              string
+	ImportRecordIndex ast.Index32
+	IsGlobal          bool
+	Range             logger.Range
 }
 
 // We create a lot of tokens, so make sure this layout is memory-efficient.
@@ -74,6 +99,29 @@ const (
 	WhitespaceAfter
 )
 
+func (a Token) Equal(b Token) bool {
+	if a.Kind == b.Kind && a.Text == b.Text && a.ImportRecordIndex == b.ImportRecordIndex &&
+		a.UnitOffset == b.UnitOffset && a.Whitespace == b.Whitespace {
+		if a.Children == nil || b.Children == nil {
+			return a.Children == nil && b.Children == nil
+		}
+		return TokensEqual(*a.Children, *b.Children)
+	}
+	return false
+}
+
+func TokensEqual(a []Token, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, c := range a {
+		if !c.Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (t Token) PercentValue() string {
 	return t.Text[:len(t.Text)-1]
 }
@@ -92,6 +140,11 @@ type R interface {
 	isRule()
 }
 
+type Rule struct {
+	Loc  logger.Loc
+	Data R
+}
+
 type RAtCharset struct {
 	Encoding string
 }
@@ -99,6 +152,12 @@ type RAtCharset struct {
 type RAtImport struct {
 	ImportRecordIndex uint32
 	ImportConditions  []Token
+
+	// This is set if the only import condition is "layer" or "layer(...)", in
+	// which case the imported file can be bundled inside a "@layer" rule. The
+	// layer name is empty for an anonymous layer.
+	HasLayer  bool
+	LayerName []string
 }
 
 type RAtKeyframes struct {
@@ -109,13 +168,21 @@ type RAtKeyframes struct {
 
 type KeyframeBlock struct {
 	Selectors []string
-	Rules     []R
+	Rules     []Rule
 }
 
 type RKnownAt struct {
 	AtToken string
 	Prelude []Token
-	Rules   []R
+	Rules   []Rule
+}
+
+// Each name is split on "." so "@layer a.b, c;" has the names [[a b] [c]]. A
+// rule without a block (i.e. a statement) has nil rules. Rules with a block
+// have at most one name and an anonymous layer has no names.
+type RAtLayer struct {
+	Names [][]string
+	Rules []Rule
 }
 
 type RUnknownAt struct {
@@ -126,12 +193,12 @@ type RUnknownAt struct {
 
 type RSelector struct {
 	Selectors []ComplexSelector
-	Rules     []R
+	Rules     []Rule
 }
 
 type RQualified struct {
 	Prelude []Token
-	Rules   []R
+	Rules   []Rule
 }
 
 type RDeclaration struct {
@@ -150,6 +217,7 @@ func (*RAtCharset) isRule()      {}
 func (*RAtImport) isRule()       {}
 func (*RAtKeyframes) isRule()    {}
 func (*RKnownAt) isRule()        {}
+func (*RAtLayer) isRule()        {}
 func (*RUnknownAt) isRule()      {}
 func (*RSelector) isRule()       {}
 func (*RQualified) isRule()      {}
@@ -160,6 +228,30 @@ type ComplexSelector struct {
 	Selectors []CompoundSelector
 }
 
+func (a ComplexSelector) Equal(b ComplexSelector) bool {
+	if len(a.Selectors) != len(b.Selectors) {
+		return false
+	}
+	for i, sel := range a.Selectors {
+		if !sel.Equal(b.Selectors[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func ComplexSelectorsEqual(a []ComplexSelector, b []ComplexSelector) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, sel := range a {
+		if !sel.Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 type CompoundSelector struct {
 	HasNestPrefix        bool   // "&"
 	Combinator           string // Optional, may be ""
@@ -168,6 +260,31 @@ type CompoundSelector struct {
 	PseudoClassSelectors []SSPseudoClass // If present, these follow a ":" character
 }
 
+func (a CompoundSelector) Equal(b CompoundSelector) bool {
+	if a.HasNestPrefix != b.HasNestPrefix || a.Combinator != b.Combinator ||
+		len(a.SubclassSelectors) != len(b.SubclassSelectors) || len(a.PseudoClassSelectors) != len(b.PseudoClassSelectors) {
+		return false
+	}
+	if a.TypeSelector == nil || b.TypeSelector == nil {
+		if a.TypeSelector != nil || b.TypeSelector != nil {
+			return false
+		}
+	} else if !a.TypeSelector.Equal(*b.TypeSelector) {
+		return false
+	}
+	for i, ss := range a.SubclassSelectors {
+		if !SubclassSelectorsEqual(ss, b.SubclassSelectors[i]) {
+			return false
+		}
+	}
+	for i, pseudo := range a.PseudoClassSelectors {
+		if !pseudo.Equal(b.PseudoClassSelectors[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 type NameToken struct {
 	Kind css_lexer.T
 	Text string
@@ -181,6 +298,17 @@ type NamespacedName struct {
 	Name NameToken
 }
 
+func (a NamespacedName) Equal(b NamespacedName) bool {
+	if a.NamespacePrefix == nil || b.NamespacePrefix == nil {
+		if a.NamespacePrefix != nil || b.NamespacePrefix != nil {
+			return false
+		}
+	} else if *a.NamespacePrefix != *b.NamespacePrefix {
+		return false
+	}
+	return a.Name == b.Name
+}
+
 // This interface is never called. Its purpose is to encode a variant type in
 // Go's type system.
 type SS interface {
@@ -207,6 +335,32 @@ type SSPseudoClass struct {
 	Args []Token
 }
 
+func (a SSPseudoClass) Equal(b SSPseudoClass) bool {
+	return a.Name == b.Name && TokensEqual(a.Args, b.Args)
+}
+
+func SubclassSelectorsEqual(a SS, b SS) bool {
+	switch a := a.(type) {
+	case *SSHash:
+		b, ok := b.(*SSHash)
+		return ok && a.Name == b.Name
+
+	case *SSClass:
+		b, ok := b.(*SSClass)
+		return ok && a.Name == b.Name
+
+	case *SSAttribute:
+		b, ok := b.(*SSAttribute)
+		return ok && a.NamespacedName.Equal(b.NamespacedName) && a.MatcherOp == b.MatcherOp &&
+			a.MatcherValue == b.MatcherValue && a.MatcherModifier == b.MatcherModifier
+
+	case *SSPseudoClass:
+		b, ok := b.(*SSPseudoClass)
+		return ok && a.Equal(*b)
+	}
+	return false
+}
+
 func (*SSHash) isSubclassSelector()        {}
 func (*SSClass) isSubclassSelector()       {}
 func (*SSAttribute) isSubclassSelector()   {}
@@ -18,6 +18,8 @@ const (
 	DAnimationName
 	DAnimationPlayState
 	DAnimationTimingFunction
+	DAppearance
+	DBackdropFilter
 	DBackfaceVisibility
 	DBackground
 	DBackgroundAttachment
@@ -82,6 +84,7 @@ const (
 	DBorderTopWidth
 	DBorderWidth
 	DBottom
+	DBoxDecorationBreak
 	DBoxShadow
 	DBoxSizing
 	DBreakAfter
@@ -291,6 +294,7 @@ const (
 	DTextOverflow
 	DTextRendering
 	DTextShadow
+	DTextSizeAdjust
 	DTextTransform
 	DTextUnderlinePosition
 	DTop
@@ -336,6 +340,8 @@ var KnownDeclarations = map[string]D{
 	"animation-name":              DAnimationName,
 	"animation-play-state":        DAnimationPlayState,
 	"animation-timing-function":   DAnimationTimingFunction,
+	"appearance":                  DAppearance,
+	"backdrop-filter":             DBackdropFilter,
 	"backface-visibility":         DBackfaceVisibility,
 	"background":                  DBackground,
 	"background-attachment":       DBackgroundAttachment,
@@ -400,6 +406,7 @@ var KnownDeclarations = map[string]D{
 	"border-top-width":            DBorderTopWidth,
 	"border-width":                DBorderWidth,
 	"bottom":                      DBottom,
+	"box-decoration-break":        DBoxDecorationBreak,
 	"box-shadow":                  DBoxShadow,
 	"box-sizing":                  DBoxSizing,
 	"break-after":                 DBreakAfter,
@@ -609,6 +616,7 @@ var KnownDeclarations = map[string]D{
 	"text-overflow":               DTextOverflow,
 	"text-rendering":              DTextRendering,
 	"text-shadow":                 DTextShadow,
+	"text-size-adjust":            DTextSizeAdjust,
 	"text-transform":              DTextTransform,
 	"text-underline-position":     DTextUnderlinePosition,
 	"top":                         DTop,
@@ -146,14 +146,20 @@ func (token Token) DecodedText(contents string) string {
 }
 
 type lexer struct {
-	log       logger.Log
-	source    logger.Source
-	current   int
-	codePoint rune
-	Token     Token
+	log                     logger.Log
+	source                  logger.Source
+	current                 int
+	codePoint               rune
+	Token                   Token
+	approximateNewlineCount int
 }
 
-func Tokenize(log logger.Log, source logger.Source) (tokens []Token) {
+type TokenizeResult struct {
+	Tokens               []Token
+	ApproximateLineCount int32
+}
+
+func Tokenize(log logger.Log, source logger.Source) TokenizeResult {
 	lexer := lexer{
 		log:    log,
 		source: source,
@@ -170,12 +176,18 @@ func Tokenize(log logger.Log, source logger.Source) (tokens []Token) {
 		lexer.step()
 	}
 
+	var tokens []Token
 	lexer.next()
 	for lexer.Token.Kind != TEndOfFile {
 		tokens = append(tokens, lexer.Token)
 		lexer.next()
 	}
-	return
+
+	// Account for the last line, which usually doesn't end in a newline
+	return TokenizeResult{
+		Tokens:               tokens,
+		ApproximateLineCount: int32(lexer.approximateNewlineCount) + 1,
+	}
 }
 
 func (lexer *lexer) step() {
@@ -186,6 +198,12 @@ func (lexer *lexer) step() {
 		codePoint = eof
 	}
 
+	// Track the approximate number of newlines so the line offset table for
+	// source maps can be preallocated (see the JavaScript lexer for details)
+	if codePoint == '\n' {
+		lexer.approximateNewlineCount++
+	}
+
 	lexer.codePoint = codePoint
 	lexer.Token.Range.Len = int32(lexer.current) - lexer.Token.Range.Loc.Start
 	lexer.current += width
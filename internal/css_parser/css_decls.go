@@ -668,9 +668,16 @@ func (p *parser) mangleColor(token css_ast.Token) css_ast.Token {
 	return token
 }
 
-func (p *parser) processDeclarations(rules []css_ast.R) {
-	for _, rule := range rules {
-		decl, ok := rule.(*css_ast.RDeclaration)
+func (p *parser) processDeclarations(rules []css_ast.Rule) []css_ast.Rule {
+	margin := boxTracker{key: css_ast.DMargin, keyText: "margin"}
+	padding := boxTracker{key: css_ast.DPadding, keyText: "padding"}
+	borderWidth := boxTracker{key: css_ast.DBorderWidth, keyText: "border-width"}
+	borderStyle := boxTracker{key: css_ast.DBorderStyle, keyText: "border-style"}
+	borderColor := boxTracker{key: css_ast.DBorderColor, keyText: "border-color"}
+	borderRadius := boxTracker{key: css_ast.DBorderRadius, keyText: "border-radius"}
+
+	for i, rule := range rules {
+		decl, ok := rule.Data.(*css_ast.RDeclaration)
 		if !ok {
 			continue
 		}
@@ -704,5 +711,136 @@ func (p *parser) processDeclarations(rules []css_ast.R) {
 				}
 			}
 		}
+
+		if !p.options.MangleSyntax {
+			continue
+		}
+
+		switch decl.Key {
+		case css_ast.DMargin:
+			margin.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DMarginTop:
+			margin.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DMarginRight:
+			margin.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DMarginBottom:
+			margin.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DMarginLeft:
+			margin.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		case css_ast.DPadding:
+			padding.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DPaddingTop:
+			padding.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DPaddingRight:
+			padding.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DPaddingBottom:
+			padding.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DPaddingLeft:
+			padding.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		case css_ast.DBorderWidth:
+			borderWidth.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DBorderTopWidth:
+			borderWidth.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DBorderRightWidth:
+			borderWidth.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DBorderBottomWidth:
+			borderWidth.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DBorderLeftWidth:
+			borderWidth.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		case css_ast.DBorderStyle:
+			borderStyle.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DBorderTopStyle:
+			borderStyle.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DBorderRightStyle:
+			borderStyle.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DBorderBottomStyle:
+			borderStyle.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DBorderLeftStyle:
+			borderStyle.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		case css_ast.DBorderColor:
+			borderColor.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DBorderTopColor:
+			borderColor.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DBorderRightColor:
+			borderColor.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DBorderBottomColor:
+			borderColor.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DBorderLeftColor:
+			borderColor.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		case css_ast.DBorderRadius:
+			borderRadius.mangleSides(rules, decl, i, p.options.RemoveWhitespace)
+		case css_ast.DBorderTopLeftRadius:
+			borderRadius.mangleSide(rules, decl, i, boxTop, p.options.RemoveWhitespace)
+		case css_ast.DBorderTopRightRadius:
+			borderRadius.mangleSide(rules, decl, i, boxRight, p.options.RemoveWhitespace)
+		case css_ast.DBorderBottomRightRadius:
+			borderRadius.mangleSide(rules, decl, i, boxBottom, p.options.RemoveWhitespace)
+		case css_ast.DBorderBottomLeftRadius:
+			borderRadius.mangleSide(rules, decl, i, boxLeft, p.options.RemoveWhitespace)
+
+		default:
+			// Other properties such as "border-top" and "margin-block-start" also
+			// set some of the tracked properties, so moving a tracked declaration
+			// past one of them could change which one takes effect
+			if keyText := strings.ToLower(decl.KeyText); strings.HasPrefix(keyText, "margin") {
+				margin.reset()
+			} else if strings.HasPrefix(keyText, "padding") {
+				padding.reset()
+			} else if strings.HasPrefix(keyText, "border") {
+				borderWidth.reset()
+				borderStyle.reset()
+				borderColor.reset()
+				borderRadius.reset()
+			}
+		}
+	}
+
+	if p.options.MangleSyntax {
+		rules = removeDuplicateDeclarations(rules)
+		rules = p.mangleFont(rules)
+	}
+	return rules
+}
+
+// Removes declarations that are immediately overridden by an identical later
+// declaration: "color: red; color: blue; color: red" => "color: blue; color:
+// red". Declarations with different values are always kept since they may be
+// fallbacks for browsers that don't support the later value. This also drops
+// the declarations that were removed while collapsing shorthands.
+func removeDuplicateDeclarations(rules []css_ast.Rule) []css_ast.Rule {
+	type key struct {
+		text      string
+		important bool
+	}
+	later := make(map[key][]*css_ast.RDeclaration)
+
+	end := len(rules)
+	for i := len(rules) - 1; i >= 0; i-- {
+		rule := rules[i]
+		if rule.Data == nil {
+			continue
+		}
+		if decl, ok := rule.Data.(*css_ast.RDeclaration); ok {
+			k := key{text: decl.KeyText, important: decl.Important}
+			isDuplicate := false
+			for _, other := range later[k] {
+				if css_ast.TokensEqual(decl.Value, other.Value) {
+					isDuplicate = true
+					break
+				}
+			}
+			if isDuplicate {
+				continue
+			}
+			later[k] = append(later[k], decl)
+		}
+		end--
+		rules[end] = rule
 	}
+	return rules[end:]
 }
@@ -0,0 +1,219 @@
+package css_parser
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+)
+
+const (
+	boxTop = iota
+	boxRight
+	boxBottom
+	boxLeft
+)
+
+type boxSide struct {
+	token     css_ast.Token
+	ruleIndex int
+	isSet     bool
+}
+
+// This tracks the four sides of a box-like shorthand property such as
+// "margin" while the declarations in a rule are processed in order. Once all
+// four sides are known, the longhand declarations are collapsed into a single
+// shorthand declaration: "margin-top: 0; margin-right: 0; margin-bottom: 0;
+// margin-left: 0" => "margin: 0".
+//
+// This also works for "border-radius" even though its sides are corners,
+// since its values are in the same order as the other box properties.
+type boxTracker struct {
+	key       css_ast.D
+	keyText   string
+	sides     [4]boxSide
+	important bool
+}
+
+func (box *boxTracker) reset() {
+	box.sides = [4]boxSide{}
+}
+
+func (box *boxTracker) hasAnySide() bool {
+	for _, side := range box.sides {
+		if side.isSet {
+			return true
+		}
+	}
+	return false
+}
+
+func (box *boxTracker) mangleSide(rules []css_ast.Rule, decl *css_ast.RDeclaration, ruleIndex int, side int, removeWhitespace bool) {
+	if len(decl.Value) != 1 || !isSafeBoxValue(decl.Value[0]) || (box.hasAnySide() && box.important != decl.Important) {
+		box.reset()
+		return
+	}
+
+	// A previous longhand declaration for this side is now overridden. A
+	// shorthand declaration can't be removed since it also sets other sides.
+	if prev := box.sides[side]; prev.isSet && prev.ruleIndex != ruleIndex && !box.isUsedByOtherSide(prev.ruleIndex, side) {
+		rules[prev.ruleIndex] = css_ast.Rule{}
+	}
+
+	box.important = decl.Important
+	box.sides[side] = boxSide{token: decl.Value[0], ruleIndex: ruleIndex, isSet: true}
+	box.compactRules(rules, decl, ruleIndex, removeWhitespace)
+}
+
+func (box *boxTracker) mangleSides(rules []css_ast.Rule, decl *css_ast.RDeclaration, ruleIndex int, removeWhitespace bool) {
+	n := len(decl.Value)
+	if n < 1 || n > 4 {
+		box.reset()
+		return
+	}
+	for _, t := range decl.Value {
+		if !isSafeBoxValue(t) {
+			box.reset()
+			return
+		}
+	}
+	if box.hasAnySide() && box.important != decl.Important {
+		box.reset()
+		return
+	}
+
+	// Every previous declaration is now overridden
+	for _, side := range box.sides {
+		if side.isSet && side.ruleIndex != ruleIndex {
+			rules[side.ruleIndex] = css_ast.Rule{}
+		}
+	}
+
+	// Expand "1px 2px" to "1px 2px 1px 2px"
+	tokens := [4]css_ast.Token{}
+	switch n {
+	case 1:
+		tokens = [4]css_ast.Token{decl.Value[0], decl.Value[0], decl.Value[0], decl.Value[0]}
+	case 2:
+		tokens = [4]css_ast.Token{decl.Value[0], decl.Value[1], decl.Value[0], decl.Value[1]}
+	case 3:
+		tokens = [4]css_ast.Token{decl.Value[0], decl.Value[1], decl.Value[2], decl.Value[1]}
+	case 4:
+		tokens = [4]css_ast.Token{decl.Value[0], decl.Value[1], decl.Value[2], decl.Value[3]}
+	}
+
+	box.important = decl.Important
+	for side, t := range tokens {
+		box.sides[side] = boxSide{token: t, ruleIndex: ruleIndex, isSet: true}
+	}
+	box.compactRules(rules, decl, ruleIndex, removeWhitespace)
+}
+
+func (box *boxTracker) isUsedByOtherSide(ruleIndex int, side int) bool {
+	for i, other := range box.sides {
+		if i != side && other.isSet && other.ruleIndex == ruleIndex {
+			return true
+		}
+	}
+	return false
+}
+
+func (box *boxTracker) compactRules(rules []css_ast.Rule, decl *css_ast.RDeclaration, ruleIndex int, removeWhitespace bool) {
+	for _, side := range box.sides {
+		if !side.isSet {
+			return
+		}
+	}
+
+	// All other declarations that contributed to this box are now redundant
+	for _, side := range box.sides {
+		if side.ruleIndex != ruleIndex {
+			rules[side.ruleIndex] = css_ast.Rule{}
+		}
+	}
+
+	// Use the shortest form: "1px 2px 1px 2px" => "1px 2px"
+	tokens := []css_ast.Token{box.sides[boxTop].token, box.sides[boxRight].token, box.sides[boxBottom].token, box.sides[boxLeft].token}
+	if tokensEquivalent(tokens[boxLeft], tokens[boxRight]) {
+		tokens = tokens[:3]
+		if tokensEquivalent(tokens[boxBottom], tokens[boxTop]) {
+			tokens = tokens[:2]
+			if tokensEquivalent(tokens[boxRight], tokens[boxTop]) {
+				tokens = tokens[:1]
+			}
+		}
+	}
+	for i := range tokens {
+		tokens[i].Whitespace = 0
+		if i+1 < len(tokens) {
+			tokens[i].Whitespace |= css_ast.WhitespaceAfter
+		}
+	}
+	if !removeWhitespace {
+		tokens[0].Whitespace |= css_ast.WhitespaceBefore
+	}
+
+	rules[ruleIndex].Data = &css_ast.RDeclaration{
+		Key:       box.key,
+		KeyText:   box.keyText,
+		Value:     tokens,
+		KeyRange:  decl.KeyRange,
+		Important: decl.Important,
+	}
+	for side := range box.sides {
+		box.sides[side].ruleIndex = ruleIndex
+	}
+}
+
+// Only values that definitely stand for a single side can be moved between
+// the shorthand and longhand forms. Vendor-prefixed values are often used as
+// fallbacks for other values so they are left alone, as are CSS variables
+// since they may expand to more than one value.
+func isSafeBoxValue(t css_ast.Token) bool {
+	switch t.Kind {
+	case css_lexer.TNumber, css_lexer.TDimension, css_lexer.TPercentage, css_lexer.THash:
+		return true
+
+	case css_lexer.TIdent:
+		return !strings.HasPrefix(t.Text, "-") && !isCSSWideKeyword(t.Text)
+
+	case css_lexer.TFunction:
+		return !strings.HasPrefix(t.Text, "-") && t.Text != "var" && t.Text != "env" && t.Children != nil && areSafeFunctionArgs(*t.Children)
+	}
+	return false
+}
+
+func areSafeFunctionArgs(tokens []css_ast.Token) bool {
+	for _, t := range tokens {
+		switch t.Kind {
+		case css_lexer.TIdent:
+			if strings.HasPrefix(t.Text, "-") {
+				return false
+			}
+
+		case css_lexer.TFunction, css_lexer.TOpenParen:
+			if t.Kind == css_lexer.TFunction && (strings.HasPrefix(t.Text, "-") || t.Text == "var" || t.Text == "env") {
+				return false
+			}
+			if t.Children != nil && !areSafeFunctionArgs(*t.Children) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isCSSWideKeyword(text string) bool {
+	switch strings.ToLower(text) {
+	case "inherit", "initial", "unset", "revert", "revert-layer":
+		return true
+	}
+	return false
+}
+
+// This is like "Equal" but ignores whitespace around the token
+func tokensEquivalent(a css_ast.Token, b css_ast.Token) bool {
+	a.Whitespace = 0
+	b.Whitespace = 0
+	return a.Equal(b)
+}
@@ -0,0 +1,187 @@
+package css_parser
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+)
+
+// These are the longhand properties that can be collapsed into the "font"
+// shorthand, in the order that they must appear in the shorthand
+var fontLonghands = []css_ast.D{
+	css_ast.DFontStyle,
+	css_ast.DFontVariant,
+	css_ast.DFontWeight,
+	css_ast.DFontStretch,
+	css_ast.DFontSize,
+	css_ast.DLineHeight,
+	css_ast.DFontFamily,
+}
+
+// This collapses "font-style: italic; font-variant: normal; font-weight: bold;
+// font-size: 12px; line-height: 1.5; font-family: serif" into "font: italic
+// bold 12px/1.5 serif". Every CSS 2.1 font property must be present since the
+// shorthand resets any property that it omits. "font-stretch" is optional
+// because it's reset to "normal" just like any other newer font property.
+//
+// This is only done if there are no other font-related declarations in the
+// same rule, since the shorthand would reset them depending on where it ends
+// up. This also isn't done for "!important" declarations.
+func (p *parser) mangleFont(rules []css_ast.Rule) []css_ast.Rule {
+	indices := make(map[css_ast.D]int)
+	first := -1
+
+	for i, rule := range rules {
+		decl, ok := rule.Data.(*css_ast.RDeclaration)
+		if !ok {
+			continue
+		}
+
+		isLonghand := false
+		for _, key := range fontLonghands {
+			if decl.Key == key {
+				isLonghand = true
+				break
+			}
+		}
+		if !isLonghand {
+			// Any other font-related declaration may interact with the shorthand
+			if decl.Key == css_ast.DFont || strings.HasPrefix(strings.ToLower(decl.KeyText), "font") {
+				return rules
+			}
+			continue
+		}
+
+		// Duplicate declarations may be fallbacks for each other
+		if _, ok := indices[decl.Key]; ok || decl.Important || !isSafeFontValue(decl.Key, decl.Value) {
+			return rules
+		}
+		indices[decl.Key] = i
+		if first == -1 {
+			first = i
+		}
+	}
+
+	for _, key := range fontLonghands {
+		if _, ok := indices[key]; !ok && key != css_ast.DFontStretch {
+			return rules
+		}
+	}
+
+	// Generate the shorthand, omitting values that are the default
+	var tokens []css_ast.Token
+	for _, key := range fontLonghands {
+		i, ok := indices[key]
+		if !ok {
+			continue
+		}
+		value := rules[i].Data.(*css_ast.RDeclaration).Value
+		if key != css_ast.DFontSize && key != css_ast.DFontFamily && strings.EqualFold(value[0].Text, "normal") {
+			continue
+		}
+
+		// "12px/1.5" has no whitespace while the other values are space-separated
+		if key == css_ast.DLineHeight {
+			tokens = append(tokens, css_ast.Token{Kind: css_lexer.TDelimSlash, Text: "/"})
+		} else if len(tokens) > 0 {
+			tokens[len(tokens)-1].Whitespace |= css_ast.WhitespaceAfter
+		}
+
+		if key == css_ast.DFontFamily {
+			start := len(tokens)
+			tokens = append(tokens, value...)
+			tokens[start].Whitespace &= ^css_ast.WhitespaceBefore
+		} else {
+			t := value[0]
+			t.Whitespace = 0
+			tokens = append(tokens, t)
+		}
+	}
+	if !p.options.RemoveWhitespace {
+		tokens[0].Whitespace |= css_ast.WhitespaceBefore
+	}
+
+	// Replace the first longhand with the shorthand and remove the others
+	results := make([]css_ast.Rule, 0, len(rules))
+	for i, rule := range rules {
+		if i == first {
+			decl := rule.Data.(*css_ast.RDeclaration)
+			results = append(results, css_ast.Rule{Loc: rule.Loc, Data: &css_ast.RDeclaration{
+				Key:      css_ast.DFont,
+				KeyText:  "font",
+				Value:    tokens,
+				KeyRange: decl.KeyRange,
+			}})
+			continue
+		}
+		if decl, ok := rule.Data.(*css_ast.RDeclaration); ok {
+			if _, ok := indices[decl.Key]; ok {
+				continue
+			}
+		}
+		results = append(results, rule)
+	}
+	return results
+}
+
+func isSafeFontValue(key css_ast.D, value []css_ast.Token) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	// Only "font-family" can have more than one token
+	if key != css_ast.DFontFamily {
+		if len(value) != 1 {
+			return false
+		}
+		t := value[0]
+		if t.Kind == css_lexer.TIdent && isCSSWideKeyword(t.Text) {
+			return false
+		}
+
+		switch key {
+		case css_ast.DFontStyle:
+			return t.Kind == css_lexer.TIdent && isOneOf(t.Text, "normal", "italic", "oblique")
+
+		case css_ast.DFontVariant:
+			// The shorthand only allows the values from CSS 2.1
+			return t.Kind == css_lexer.TIdent && isOneOf(t.Text, "normal", "small-caps")
+
+		case css_ast.DFontWeight:
+			return t.Kind == css_lexer.TNumber || (t.Kind == css_lexer.TIdent && isOneOf(t.Text, "normal", "bold", "bolder", "lighter"))
+
+		case css_ast.DFontStretch:
+			// The shorthand doesn't allow percentages
+			return t.Kind == css_lexer.TIdent && isOneOf(t.Text, "normal", "ultra-condensed", "extra-condensed", "condensed",
+				"semi-condensed", "semi-expanded", "expanded", "extra-expanded", "ultra-expanded")
+
+		case css_ast.DFontSize, css_ast.DLineHeight:
+			return isSafeBoxValue(t)
+		}
+		return false
+	}
+
+	// "font-family" is a comma-separated list of identifiers and strings
+	for _, t := range value {
+		switch t.Kind {
+		case css_lexer.TIdent:
+			if isCSSWideKeyword(t.Text) {
+				return false
+			}
+		case css_lexer.TString, css_lexer.TComma:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isOneOf(text string, values ...string) bool {
+	for _, value := range values {
+		if strings.EqualFold(text, value) {
+			return true
+		}
+	}
+	return false
+}
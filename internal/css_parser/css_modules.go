@@ -0,0 +1,203 @@
+package css_parser
+
+import (
+	"encoding/base32"
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/xxhash"
+)
+
+// This implements CSS modules (https://github.com/css-modules/css-modules).
+// Class names and keyframe names in files parsed with the "local-css" loader
+// are local to that file by default. They are renamed by appending a suffix
+// derived from a hash of the file path so that they don't collide with names
+// from other files. The suffix only depends on the path so the generated
+// names are stable across builds.
+
+func localNameSuffixForSource(source logger.Source) string {
+	h := xxhash.New()
+	h.Write([]byte(source.PrettyPath))
+	return "_" + strings.ToLower(base32.StdEncoding.EncodeToString(h.Sum(nil))[:8])
+}
+
+func (p *parser) localName(original string) string {
+	if i, ok := p.localNameIndex[original]; ok {
+		return p.localNames[i].Local
+	}
+	local := original + p.localNameSuffix
+	p.localNameIndex[original] = len(p.localNames)
+	p.localNames = append(p.localNames, css_ast.LocalName{Original: original, Local: local})
+	return local
+}
+
+// Returns the index of the local name that was renamed to this name, if any
+func (p *parser) localNameIndexForLocal(local string) (int, bool) {
+	if strings.HasSuffix(local, p.localNameSuffix) {
+		if i, ok := p.localNameIndex[local[:len(local)-len(p.localNameSuffix)]]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// CSS modules can switch the rest of a selector between local and global
+// names using ":local" and ":global" (e.g. ":global .foo .bar")
+func (p *parser) eatLocalOrGlobalPrefix() bool {
+	if !p.options.LocalCSS || !p.peek(css_lexer.TColon) || p.next().Kind != css_lexer.TIdent {
+		return false
+	}
+	switch p.next().DecodedText(p.source.Contents) {
+	case "local":
+		p.isLocalScope = true
+	case "global":
+		p.isLocalScope = false
+	default:
+		return false
+	}
+	p.advance()
+	p.advance()
+	p.eat(css_lexer.TWhitespace)
+	return true
+}
+
+// This handles the function form of ":local" and ":global", which only applies
+// to the compound selector inside the parentheses (e.g. ":global(.foo).bar")
+func (p *parser) parseLocalOrGlobalFunction() (sel css_ast.CompoundSelector, ok bool) {
+	oldIsLocalScope := p.isLocalScope
+	p.isLocalScope = p.next().DecodedText(p.source.Contents) == "local"
+	p.advance()
+	p.advance()
+	p.eat(css_lexer.TWhitespace)
+	sel, ok = p.parseCompoundSelector()
+	p.isLocalScope = oldIsLocalScope
+	if !ok {
+		return
+	}
+	p.eat(css_lexer.TWhitespace)
+	ok = p.expect(css_lexer.TCloseParen)
+	return
+}
+
+func (p *parser) isLocalOrGlobalFunction() bool {
+	if p.options.LocalCSS && p.peek(css_lexer.TColon) && p.next().Kind == css_lexer.TFunction {
+		text := p.next().DecodedText(p.source.Contents)
+		return text == "local" || text == "global"
+	}
+	return false
+}
+
+// "composes" declarations aren't real CSS. They are removed from the output
+// and instead cause the class names they reference to be exported along with
+// the class name of the rule they are in.
+func (p *parser) processComposes(selectors []css_ast.ComplexSelector, rules []css_ast.Rule) []css_ast.Rule {
+	end := 0
+	for _, rule := range rules {
+		if decl, ok := rule.Data.(*css_ast.RDeclaration); ok && decl.KeyText == "composes" {
+			p.processComposesDeclaration(selectors, decl)
+			continue
+		}
+		rules[end] = rule
+		end++
+	}
+	return rules[:end]
+}
+
+func (p *parser) processComposesDeclaration(selectors []css_ast.ComplexSelector, decl *css_ast.RDeclaration) {
+	// Only rules for a single local class name can compose other names
+	var targets []int
+	for _, complex := range selectors {
+		if len(complex.Selectors) == 1 {
+			if sel := complex.Selectors[0]; sel.TypeSelector == nil && len(sel.SubclassSelectors) == 1 && len(sel.PseudoClassSelectors) == 0 {
+				if class, ok := sel.SubclassSelectors[0].(*css_ast.SSClass); ok {
+					if i, ok := p.localNameIndexForLocal(class.Name); ok {
+						targets = append(targets, i)
+						continue
+					}
+				}
+			}
+		}
+		p.log.AddRangeWarning(&p.source, decl.KeyRange, "\"composes\" only works inside single local class selectors")
+		return
+	}
+
+	// Parse "<name>+ [from (<string> | global)]"
+	var names []string
+	importRecordIndex := ast.Index32{}
+	isGlobal := false
+	for i, t := range decl.Value {
+		if t.Kind == css_lexer.TIdent && t.Text == "from" && len(names) > 0 && i+2 == len(decl.Value) {
+			switch from := decl.Value[i+1]; {
+			case from.Kind == css_lexer.TString:
+				importRecordIndex = ast.MakeIndex32(uint32(len(p.importRecords)))
+				p.importRecords = append(p.importRecords, ast.ImportRecord{
+					Kind:  ast.ImportComposesFrom,
+					Path:  logger.Path{Text: from.Text},
+					Range: decl.KeyRange,
+				})
+
+			case from.Kind == css_lexer.TIdent && from.Text == "global":
+				isGlobal = true
+
+			default:
+				p.log.AddRangeWarning(&p.source, decl.KeyRange, fmt.Sprintf("Unexpected %q in \"composes\"", from.Text))
+				return
+			}
+			break
+		}
+		if t.Kind != css_lexer.TIdent {
+			p.log.AddRangeWarning(&p.source, decl.KeyRange, fmt.Sprintf("Unexpected %q in \"composes\"", t.Text))
+			return
+		}
+		names = append(names, t.Text)
+	}
+
+	for _, target := range targets {
+		for _, name := range names {
+			// Make sure names from this file are local even if they are never used
+			// as a class name in a selector
+			if !importRecordIndex.IsValid() && !isGlobal {
+				p.localName(name)
+			}
+			p.localNames[target].Composes = append(p.localNames[target].Composes, css_ast.ComposesName{
+				Name:              name,
+				ImportRecordIndex: importRecordIndex,
+				IsGlobal:          isGlobal,
+				Range:             decl.KeyRange,
+			})
+		}
+	}
+}
+
+// Keyframe names may be referenced before they are declared, so references to
+// them in "animation" and "animation-name" are renamed after parsing the file
+func (p *parser) renameLocalAnimationNames(rules []css_ast.Rule) {
+	for _, rule := range rules {
+		switch r := rule.Data.(type) {
+		case *css_ast.RDeclaration:
+			if r.Key == css_ast.DAnimation || r.Key == css_ast.DAnimationName {
+				for i, t := range r.Value {
+					if t.Kind == css_lexer.TIdent && p.localKeyframes[t.Text] {
+						r.Value[i].Text = p.localName(t.Text)
+					}
+				}
+			}
+
+		case *css_ast.RSelector:
+			p.renameLocalAnimationNames(r.Rules)
+
+		case *css_ast.RQualified:
+			p.renameLocalAnimationNames(r.Rules)
+
+		case *css_ast.RKnownAt:
+			p.renameLocalAnimationNames(r.Rules)
+
+		case *css_ast.RAtLayer:
+			p.renameLocalAnimationNames(r.Rules)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package css_parser
+
+import (
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This flattens nested style rules (https://drafts.csswg.org/css-nesting-1/)
+// into standard CSS for browsers that don't support nesting. For example:
+//
+//   .a { color: red; &:hover { color: blue } @media screen { .b { color: green } } }
+//
+// becomes:
+//
+//   .a { color: red }
+//   .a:hover { color: blue }
+//   @media screen { .a .b { color: green } }
+//
+// Note that declarations that come after a nested rule are moved before it.
+// This matches how the nesting specification treats them.
+func (p *parser) lowerNestingInRules(rules []css_ast.Rule) []css_ast.Rule {
+	results := make([]css_ast.Rule, 0, len(rules))
+	for _, rule := range rules {
+		switch r := rule.Data.(type) {
+		case *css_ast.RSelector:
+			results = p.lowerNestingInRule(rule.Loc, r, r.Selectors, results)
+			continue
+
+		case *css_ast.RKnownAt:
+			r.Rules = p.lowerNestingInRules(r.Rules)
+
+		case *css_ast.RAtLayer:
+			if r.Rules != nil {
+				r.Rules = p.lowerNestingInRules(r.Rules)
+			}
+		}
+		results = append(results, rule)
+	}
+	return results
+}
+
+// The selectors passed here are the fully-resolved selectors for this rule.
+// Any nested rules are appended to "results" after this rule.
+func (p *parser) lowerNestingInRule(loc logger.Loc, rule *css_ast.RSelector, selectors []css_ast.ComplexSelector, results []css_ast.Rule) []css_ast.Rule {
+	// Separate the declarations from the nested rules
+	var nested []css_ast.Rule
+	declarations := make([]css_ast.Rule, 0, len(rule.Rules))
+	for _, child := range rule.Rules {
+		switch c := child.Data.(type) {
+		case *css_ast.RSelector:
+			nested = append(nested, child)
+			continue
+
+		case *css_ast.RKnownAt:
+			if specialAtRules[c.AtToken] == atRuleInheritContext {
+				nested = append(nested, child)
+				continue
+			}
+
+		case *css_ast.RAtLayer:
+			if c.Rules != nil {
+				nested = append(nested, child)
+				continue
+			}
+		}
+		declarations = append(declarations, child)
+	}
+
+	// Avoid generating an empty rule for a parent that only contains nested rules
+	if len(declarations) > 0 || len(nested) == 0 {
+		rule.Selectors = selectors
+		rule.Rules = declarations
+		results = append(results, css_ast.Rule{Loc: loc, Data: rule})
+	}
+
+	for _, child := range nested {
+		switch c := child.Data.(type) {
+		case *css_ast.RSelector:
+			results = p.lowerNestingInRule(child.Loc, c, substituteNestPrefix(c.Selectors, selectors), results)
+
+		case *css_ast.RKnownAt:
+			// "@media" and similar rules are moved outside of the style rule and the
+			// style rule is moved inside of them: ".a { @media b { c: d } }" becomes
+			// "@media b { .a { c: d } }"
+			c.Rules = p.lowerNestingInRule(child.Loc, &css_ast.RSelector{Rules: c.Rules}, selectors, nil)
+			results = append(results, child)
+
+		case *css_ast.RAtLayer:
+			c.Rules = p.lowerNestingInRule(child.Loc, &css_ast.RSelector{Rules: c.Rules}, selectors, nil)
+			results = append(results, child)
+		}
+	}
+
+	return results
+}
+
+// This generates every combination of each child selector with each parent
+// selector: ".a, .b { .c, .d {} }" becomes ".a .c, .b .c, .a .d, .b .d". Each
+// "&" is substituted independently, so ".a, .b { & + & {} }" becomes
+// ".a + .a, .a + .b, .b + .a, .b + .b".
+func substituteNestPrefix(children []css_ast.ComplexSelector, parents []css_ast.ComplexSelector) []css_ast.ComplexSelector {
+	results := make([]css_ast.ComplexSelector, 0, len(children)*len(parents))
+	for _, child := range children {
+		results = append(results, substituteNestPrefixInSelector(child, parents)...)
+	}
+	return results
+}
+
+func substituteNestPrefixInSelector(child css_ast.ComplexSelector, parents []css_ast.ComplexSelector) []css_ast.ComplexSelector {
+	hasNestPrefix := false
+	for _, sel := range child.Selectors {
+		if sel.HasNestPrefix {
+			hasNestPrefix = true
+			break
+		}
+	}
+
+	// A selector without a "&" is relative to the parent as if it started with
+	// "& ". A leading combinator stays on the first compound selector, so
+	// "> .b" inside ".a" becomes ".a > .b".
+	if !hasNestPrefix {
+		results := make([]css_ast.ComplexSelector, 0, len(parents))
+		for _, parent := range parents {
+			selectors := make([]css_ast.CompoundSelector, 0, len(parent.Selectors)+len(child.Selectors))
+			selectors = append(selectors, parent.Selectors...)
+			selectors = append(selectors, child.Selectors...)
+			results = append(results, css_ast.ComplexSelector{Selectors: selectors})
+		}
+		return results
+	}
+
+	// Otherwise replace each "&" with each parent selector. Anything else in the
+	// compound selector containing the "&" is merged into the last compound
+	// selector of the parent, so "&.b" inside ".a" becomes ".a.b".
+	results := [][]css_ast.CompoundSelector{nil}
+	for _, sel := range child.Selectors {
+		if !sel.HasNestPrefix {
+			for i := range results {
+				results[i] = append(results[i], sel)
+			}
+			continue
+		}
+		expanded := make([][]css_ast.CompoundSelector, 0, len(results)*len(parents))
+		for _, prefix := range results {
+			for _, parent := range parents {
+				// Copy the prefix since it's shared between the expanded results
+				selectors := make([]css_ast.CompoundSelector, 0, len(prefix)+len(parent.Selectors))
+				selectors = append(selectors, prefix...)
+				start := len(selectors)
+				selectors = append(selectors, parent.Selectors...)
+				selectors[start].Combinator = sel.Combinator
+				last := &selectors[len(selectors)-1]
+				*last = mergeCompoundSelectors(*last, sel)
+				expanded = append(expanded, selectors)
+			}
+		}
+		results = expanded
+	}
+	list := make([]css_ast.ComplexSelector, len(results))
+	for i, selectors := range results {
+		list[i] = css_ast.ComplexSelector{Selectors: selectors}
+	}
+	return list
+}
+
+func mergeCompoundSelectors(target css_ast.CompoundSelector, source css_ast.CompoundSelector) css_ast.CompoundSelector {
+	if target.TypeSelector == nil {
+		target.TypeSelector = source.TypeSelector
+	}
+
+	// Always make copies since these slices may be shared with the parent rule
+	if len(source.SubclassSelectors) > 0 {
+		subclassSelectors := make([]css_ast.SS, 0, len(target.SubclassSelectors)+len(source.SubclassSelectors))
+		subclassSelectors = append(subclassSelectors, target.SubclassSelectors...)
+		target.SubclassSelectors = append(subclassSelectors, source.SubclassSelectors...)
+	}
+	if len(source.PseudoClassSelectors) > 0 {
+		pseudoClassSelectors := make([]css_ast.SSPseudoClass, 0, len(target.PseudoClassSelectors)+len(source.PseudoClassSelectors))
+		pseudoClassSelectors = append(pseudoClassSelectors, target.PseudoClassSelectors...)
+		target.PseudoClassSelectors = append(pseudoClassSelectors, source.PseudoClassSelectors...)
+	}
+	return target
+}
@@ -24,30 +24,73 @@ type parser struct {
 	end           int
 	prevError     logger.Loc
 	importRecords []ast.ImportRecord
+
+	// For CSS modules
+	localNameSuffix string
+	localNameIndex  map[string]int
+	localNames      []css_ast.LocalName
+	localKeyframes  map[string]bool
+	isLocalScope    bool
 }
 
 type Options struct {
 	UnsupportedCSSFeatures compat.CSSFeature
 	MangleSyntax           bool
 	RemoveWhitespace       bool
+
+	// If true, this file is a CSS module and class names and keyframe names are
+	// local to this file unless they are marked as global
+	LocalCSS bool
+
+	// Vendor prefixes that must be added for the target environment
+	CSSPrefixData compat.CSSPrefixData
+}
+
+func (a *Options) Equal(b *Options) bool {
+	return a.UnsupportedCSSFeatures == b.UnsupportedCSSFeatures &&
+		a.MangleSyntax == b.MangleSyntax &&
+		a.RemoveWhitespace == b.RemoveWhitespace &&
+		a.LocalCSS == b.LocalCSS &&
+		a.CSSPrefixData.Equal(&b.CSSPrefixData)
 }
 
 func Parse(log logger.Log, source logger.Source, options Options) css_ast.AST {
+	result := css_lexer.Tokenize(log, source)
 	p := parser{
 		log:       log,
 		source:    source,
 		options:   options,
-		tokens:    css_lexer.Tokenize(log, source),
+		tokens:    result.Tokens,
 		prevError: logger.Loc{Start: -1},
 	}
 	p.end = len(p.tokens)
+	if options.LocalCSS {
+		p.localNameSuffix = localNameSuffixForSource(source)
+		p.localNameIndex = make(map[string]int)
+		p.localKeyframes = make(map[string]bool)
+	}
 	tree := css_ast.AST{}
 	tree.Rules = p.parseListOfRules(ruleContext{
 		isTopLevel:     true,
 		parseSelectors: true,
 	})
-	tree.ImportRecords = p.importRecords
 	p.expect(css_lexer.TEndOfFile)
+	if options.LocalCSS {
+		p.renameLocalAnimationNames(tree.Rules)
+	}
+	tree.ImportRecords = p.importRecords
+	tree.ApproximateLineCount = result.ApproximateLineCount
+	tree.LocalNames = p.localNames
+
+	// Flatten nested rules if the target doesn't support nesting
+	if p.options.UnsupportedCSSFeatures.Has(compat.Nesting) {
+		tree.Rules = p.lowerNestingInRules(tree.Rules)
+	}
+
+	// Add vendor-prefixed copies of rules that need them. This happens after
+	// nesting is lowered since "&::placeholder" may only become a top-level
+	// selector then.
+	tree.Rules = p.insertPrefixedSelectorRules(tree.Rules)
 	return tree
 }
 
@@ -152,11 +195,10 @@ type ruleContext struct {
 	parseSelectors bool
 }
 
-func (p *parser) parseListOfRules(context ruleContext) []css_ast.R {
+func (p *parser) parseListOfRules(context ruleContext) []css_ast.Rule {
 	didWarnAboutCharset := false
 	didWarnAboutImport := false
-	rules := []css_ast.R{}
-	locs := []logger.Loc{}
+	rules := []css_ast.Rule{}
 
 loop:
 	for {
@@ -170,15 +212,15 @@ loop:
 
 		case css_lexer.TAtKeyword:
 			first := p.current().Range
-			rule := p.parseAtRule(atRuleContext{})
+			rule := css_ast.Rule{Loc: first.Loc, Data: p.parseAtRule(atRuleContext{})}
 
 			// Validate structure
 			if context.isTopLevel {
-				switch rule.(type) {
+				switch rule.Data.(type) {
 				case *css_ast.RAtCharset:
 					if !didWarnAboutCharset && len(rules) > 0 {
 						p.log.AddRangeWarningWithNotes(&p.source, first, "\"@charset\" must be the first rule in the file",
-							[]logger.MsgData{logger.RangeData(&p.source, logger.Range{Loc: locs[len(locs)-1]},
+							[]logger.MsgData{logger.RangeData(&p.source, logger.Range{Loc: rules[len(rules)-1].Loc},
 								"This rule cannot come before a \"@charset\" rule")})
 						didWarnAboutCharset = true
 					}
@@ -186,12 +228,21 @@ loop:
 				case *css_ast.RAtImport:
 					if !didWarnAboutImport {
 					importLoop:
-						for i, before := range rules {
-							switch before.(type) {
+						for _, before := range rules {
+							switch b := before.Data.(type) {
 							case *css_ast.RAtCharset, *css_ast.RAtImport:
+							case *css_ast.RAtLayer:
+								// "@layer" statements are allowed before "@import" rules
+								if b.Rules != nil {
+									p.log.AddRangeWarningWithNotes(&p.source, first, "All \"@import\" rules must come first",
+										[]logger.MsgData{logger.RangeData(&p.source, logger.Range{Loc: before.Loc},
+											"This rule cannot come before an \"@import\" rule")})
+									didWarnAboutImport = true
+									break importLoop
+								}
 							default:
 								p.log.AddRangeWarningWithNotes(&p.source, first, "All \"@import\" rules must come first",
-									[]logger.MsgData{logger.RangeData(&p.source, logger.Range{Loc: locs[i]},
+									[]logger.MsgData{logger.RangeData(&p.source, logger.Range{Loc: before.Loc},
 										"This rule cannot come before an \"@import\" rule")})
 								didWarnAboutImport = true
 								break importLoop
@@ -202,9 +253,6 @@ loop:
 			}
 
 			rules = append(rules, rule)
-			if context.isTopLevel {
-				locs = append(locs, first.Loc)
-			}
 			continue
 
 		case css_lexer.TCDO, css_lexer.TCDC:
@@ -214,54 +262,67 @@ loop:
 			}
 		}
 
-		if context.isTopLevel {
-			locs = append(locs, p.current().Range.Loc)
-		}
+		loc := p.current().Range.Loc
 		if context.parseSelectors {
-			rules = append(rules, p.parseSelectorRule())
+			rules = append(rules, css_ast.Rule{Loc: loc, Data: p.parseSelectorRule(false /* isNested */)})
 		} else {
-			rules = append(rules, p.parseQualifiedRuleFrom(p.index, false /* isAlreadyInvalid */))
+			rules = append(rules, css_ast.Rule{Loc: loc, Data: p.parseQualifiedRuleFrom(p.index, false /* isAlreadyInvalid */)})
 		}
 	}
 
 	if p.options.MangleSyntax {
 		rules = removeEmptyRules(rules)
+		rules = mergeAdjacentLayerStatements(rules)
+		rules = p.mergeAdjacentSelectorRules(rules)
 	}
 	return rules
 }
 
-func (p *parser) parseListOfDeclarations() (list []css_ast.R) {
+func (p *parser) parseListOfDeclarations() (list []css_ast.Rule) {
 	for {
 		switch p.current().Kind {
 		case css_lexer.TWhitespace, css_lexer.TSemicolon:
 			p.advance()
 
 		case css_lexer.TEndOfFile, css_lexer.TCloseBrace:
-			p.processDeclarations(list)
+			list = p.processDeclarations(list)
+			list = p.insertPrefixedDeclarations(list)
 			if p.options.MangleSyntax {
 				list = removeEmptyRules(list)
 			}
 			return
 
 		case css_lexer.TAtKeyword:
-			list = append(list, p.parseAtRule(atRuleContext{
+			loc := p.current().Range.Loc
+			list = append(list, css_ast.Rule{Loc: loc, Data: p.parseAtRule(atRuleContext{
 				isDeclarationList: true,
-			}))
+			})})
 
 		case css_lexer.TDelimAmpersand:
 			// Reference: https://drafts.csswg.org/css-nesting-1/
-			list = append(list, p.parseSelectorRule())
+			loc := p.current().Range.Loc
+			list = append(list, css_ast.Rule{Loc: loc, Data: p.parseSelectorRule(true /* isNested */)})
+
+		case css_lexer.TDelimDot, css_lexer.THash, css_lexer.TColon,
+			css_lexer.TDelimGreaterThan, css_lexer.TDelimPlus, css_lexer.TDelimTilde:
+			// Nested rules may also omit the "&" as long as they can't be confused
+			// with a declaration. Type selectors must still use "&" since they look
+			// like property names. "*" and "[" are left alone because they are
+			// common in browser hacks that are meant to be declarations.
+			loc := p.current().Range.Loc
+			list = append(list, css_ast.Rule{Loc: loc, Data: p.parseSelectorRule(true /* isNested */)})
 
 		default:
-			list = append(list, p.parseDeclaration())
+			loc := p.current().Range.Loc
+			list = append(list, css_ast.Rule{Loc: loc, Data: p.parseDeclaration()})
 		}
 	}
 }
 
-func removeEmptyRules(rules []css_ast.R) []css_ast.R {
+func removeEmptyRules(rules []css_ast.Rule) []css_ast.Rule {
 	end := 0
 	for _, rule := range rules {
-		switch r := rule.(type) {
+		switch r := rule.Data.(type) {
 		case *css_ast.RAtKeyframes:
 			if len(r.Blocks) == 0 {
 				continue
@@ -272,6 +333,16 @@ func removeEmptyRules(rules []css_ast.R) []css_ast.R {
 				continue
 			}
 
+		case *css_ast.RAtLayer:
+			// An empty named layer still affects the order of layers, but it's
+			// shorter to write it as a statement: "@layer a {}" => "@layer a;"
+			if r.Rules != nil && len(r.Rules) == 0 {
+				if len(r.Names) == 0 {
+					continue
+				}
+				r.Rules = nil
+			}
+
 		case *css_ast.RSelector:
 			if len(r.Rules) == 0 {
 				continue
@@ -284,6 +355,193 @@ func removeEmptyRules(rules []css_ast.R) []css_ast.R {
 	return rules[:end]
 }
 
+// "@layer a; @layer b, a;" => "@layer a, b;"
+func mergeAdjacentLayerStatements(rules []css_ast.Rule) []css_ast.Rule {
+	end := 0
+	for _, rule := range rules {
+		if r, ok := rule.Data.(*css_ast.RAtLayer); ok && r.Rules == nil && end > 0 {
+			if prev, ok := rules[end-1].Data.(*css_ast.RAtLayer); ok && prev.Rules == nil {
+				for _, name := range r.Names {
+					if !containsLayerName(prev.Names, name) {
+						prev.Names = append(prev.Names, name)
+					}
+				}
+				continue
+			}
+		}
+		rules[end] = rule
+		end++
+	}
+	return rules[:end]
+}
+
+// "a { color: red } a { margin: 0 }" => "a { color: red; margin: 0 }"
+// "a { color: red } b { color: red }" => "a, b { color: red }"
+//
+// Only adjacent rules are merged since moving a rule past another rule could
+// change which one takes precedence. Rules containing nested rules are left
+// alone.
+func (p *parser) mergeAdjacentSelectorRules(rules []css_ast.Rule) []css_ast.Rule {
+	end := 0
+	for _, rule := range rules {
+		if r, ok := rule.Data.(*css_ast.RSelector); ok && end > 0 && hasOnlyDeclarations(r.Rules) {
+			if prev, ok := rules[end-1].Data.(*css_ast.RSelector); ok && hasOnlyDeclarations(prev.Rules) {
+				if css_ast.ComplexSelectorsEqual(prev.Selectors, r.Selectors) {
+					combined := append(append(make([]css_ast.Rule, 0, len(prev.Rules)+len(r.Rules)), prev.Rules...), r.Rules...)
+					prev.Rules = p.processDeclarations(combined)
+					continue
+				}
+
+				// Browsers drop the whole rule if any selector in the list isn't
+				// supported, so only merge selectors that are widely supported
+				if declarationsEqual(prev.Rules, r.Rules) && isSafeSelectorList(prev.Selectors) && isSafeSelectorList(r.Selectors) {
+					selectors := append([]css_ast.ComplexSelector{}, prev.Selectors...)
+				next:
+					for _, sel := range r.Selectors {
+						for _, existing := range selectors {
+							if existing.Equal(sel) {
+								continue next
+							}
+						}
+						selectors = append(selectors, sel)
+					}
+					prev.Selectors = selectors
+					continue
+				}
+			}
+		}
+		rules[end] = rule
+		end++
+	}
+	return rules[:end]
+}
+
+func hasOnlyDeclarations(rules []css_ast.Rule) bool {
+	for _, rule := range rules {
+		if _, ok := rule.Data.(*css_ast.RDeclaration); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func declarationsEqual(a []css_ast.Rule, b []css_ast.Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, rule := range a {
+		x := rule.Data.(*css_ast.RDeclaration)
+		y := b[i].Data.(*css_ast.RDeclaration)
+		if x.KeyText != y.KeyText || x.Important != y.Important || !css_ast.TokensEqual(x.Value, y.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// These pseudo-classes and pseudo-elements are supported by all browsers that
+// are still in use. Anything else, including all vendor-prefixed selectors,
+// may cause the whole rule to be dropped in some browsers.
+var safePseudoSelectors = map[string]bool{
+	"active":           true,
+	"after":            true,
+	"before":           true,
+	"checked":          true,
+	"disabled":         true,
+	"empty":            true,
+	"enabled":          true,
+	"first-child":      true,
+	"first-letter":     true,
+	"first-line":       true,
+	"first-of-type":    true,
+	"focus":            true,
+	"hover":            true,
+	"lang":             true,
+	"last-child":       true,
+	"last-of-type":     true,
+	"link":             true,
+	"not":              true,
+	"nth-child":        true,
+	"nth-last-child":   true,
+	"nth-last-of-type": true,
+	"nth-of-type":      true,
+	"only-child":       true,
+	"only-of-type":     true,
+	"root":             true,
+	"target":           true,
+	"visited":          true,
+}
+
+func isSafeSelectorList(selectors []css_ast.ComplexSelector) bool {
+	for _, complex := range selectors {
+		for _, sel := range complex.Selectors {
+			for _, ss := range sel.SubclassSelectors {
+				if pseudo, ok := ss.(*css_ast.SSPseudoClass); ok && !isSafePseudoSelector(pseudo) {
+					return false
+				}
+			}
+			for i := range sel.PseudoClassSelectors {
+				if !isSafePseudoSelector(&sel.PseudoClassSelectors[i]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func isSafePseudoSelector(pseudo *css_ast.SSPseudoClass) bool {
+	if !safePseudoSelectors[pseudo.Name] {
+		return false
+	}
+
+	// Older browsers only support a single simple selector inside ":not()".
+	// Anything else such as ":not(.a, .b)" or ":not(.a .b)" makes the whole
+	// rule invalid in those browsers.
+	if pseudo.Name == "not" && !isSingleSimpleSelector(pseudo.Args) {
+		return false
+	}
+
+	// The arguments may contain other selectors that aren't safe
+	for _, t := range pseudo.Args {
+		if t.Kind == css_lexer.TColon {
+			return false
+		}
+	}
+	return true
+}
+
+// This matches "a", "*", "#a", ".a", and "[a]"
+func isSingleSimpleSelector(tokens []css_ast.Token) bool {
+	switch len(tokens) {
+	case 1:
+		switch tokens[0].Kind {
+		case css_lexer.TIdent, css_lexer.TDelimAsterisk, css_lexer.THash, css_lexer.TOpenBracket:
+			return true
+		}
+
+	case 2:
+		return tokens[0].Kind == css_lexer.TDelimDot && tokens[1].Kind == css_lexer.TIdent
+	}
+	return false
+}
+
+func containsLayerName(names [][]string, name []string) bool {
+outer:
+	for _, other := range names {
+		if len(other) != len(name) {
+			continue
+		}
+		for i, part := range other {
+			if part != name[i] {
+				continue outer
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func (p *parser) parseURLOrString() (string, logger.Range, bool) {
 	t := p.current()
 	switch t.Kind {
@@ -361,10 +619,14 @@ var specialAtRules = map[string]atRuleKind{
 	"viewport":     atRuleDeclarations,
 	"-ms-viewport": atRuleDeclarations,
 
-	"document": atRuleInheritContext,
-	"media":    atRuleInheritContext,
-	"scope":    atRuleInheritContext,
-	"supports": atRuleInheritContext,
+	// Reference: https://drafts.css-houdini.org/css-properties-values-api/#at-property-rule
+	"property": atRuleDeclarations,
+
+	"container": atRuleInheritContext,
+	"document":  atRuleInheritContext,
+	"media":     atRuleInheritContext,
+	"scope":     atRuleInheritContext,
+	"supports":  atRuleInheritContext,
 }
 
 type atRuleContext struct {
@@ -407,9 +669,15 @@ func (p *parser) parseAtRule(context atRuleContext) css_ast.R {
 			importConditions := p.convertTokens(p.tokens[importConditionsStart:p.index])
 			kind := ast.ImportAt
 
+			// A "layer" condition on its own can still be bundled since the imported
+			// file can just be wrapped in a "@layer" rule
+			layerName, hasLayer := layerNameFromImportConditions(importConditions)
+
 			// Insert or remove whitespace before the first token
 			if len(importConditions) > 0 {
-				kind = ast.ImportAtConditional
+				if !hasLayer {
+					kind = ast.ImportAtConditional
+				}
 				if p.options.RemoveWhitespace {
 					importConditions[0].Whitespace &= ^css_ast.WhitespaceBefore
 				} else {
@@ -427,15 +695,53 @@ func (p *parser) parseAtRule(context atRuleContext) css_ast.R {
 			return &css_ast.RAtImport{
 				ImportRecordIndex: importRecordIndex,
 				ImportConditions:  importConditions,
+				HasLayer:          hasLayer,
+				LayerName:         layerName,
 			}
 		}
 
+	case "layer":
+		// Reference: https://drafts.csswg.org/css-cascade-5/#layering
+		p.eat(css_lexer.TWhitespace)
+		names, ok := p.parseLayerNames()
+		if !ok {
+			break
+		}
+
+		// Parse a statement such as "@layer a, b;"
+		if !p.peek(css_lexer.TOpenBrace) {
+			if len(names) == 0 {
+				p.expect(css_lexer.TIdent)
+				break
+			}
+			p.expect(css_lexer.TSemicolon)
+			return &css_ast.RAtLayer{Names: names}
+		}
+
+		// Parse a block such as "@layer a { ... }"
+		if len(names) > 1 {
+			p.expect(css_lexer.TSemicolon)
+			break
+		}
+		p.advance()
+		rules := p.parseInheritedRules(context)
+		p.expect(css_lexer.TCloseBrace)
+		if rules == nil {
+			// A nil slice would turn this into a statement
+			rules = []css_ast.Rule{}
+		}
+		return &css_ast.RAtLayer{Names: names, Rules: rules}
+
 	case "keyframes", "-webkit-keyframes", "-moz-keyframes", "-ms-keyframes", "-o-keyframes":
 		p.eat(css_lexer.TWhitespace)
 		var name string
 
 		if p.peek(css_lexer.TIdent) {
 			name = p.decoded()
+			if p.options.LocalCSS {
+				p.localKeyframes[name] = true
+				name = p.localName(name)
+			}
 			p.advance()
 		} else if !p.expect(css_lexer.TIdent) && !p.eat(css_lexer.TString) && !p.peek(css_lexer.TOpenBrace) {
 			// Consider string names a syntax error even though they are allowed by
@@ -605,14 +911,7 @@ prelude:
 	case atRuleInheritContext:
 		// Parse known rules whose blocks consist of whatever the current context is
 		p.advance()
-		var rules []css_ast.R
-		if context.isDeclarationList {
-			rules = p.parseListOfDeclarations()
-		} else {
-			rules = p.parseListOfRules(ruleContext{
-				parseSelectors: true,
-			})
-		}
+		rules := p.parseInheritedRules(context)
 		p.expect(css_lexer.TCloseBrace)
 		return &css_ast.RKnownAt{AtToken: atToken, Prelude: prelude, Rules: rules}
 
@@ -624,6 +923,78 @@ prelude:
 	}
 }
 
+func (p *parser) parseInheritedRules(context atRuleContext) []css_ast.Rule {
+	if context.isDeclarationList {
+		return p.parseListOfDeclarations()
+	}
+	return p.parseListOfRules(ruleContext{
+		parseSelectors: true,
+	})
+}
+
+// This parses a possibly-empty comma-separated list of layer names where each
+// name is a sequence of identifiers separated by "."
+func (p *parser) parseLayerNames() (names [][]string, ok bool) {
+	for {
+		switch p.current().Kind {
+		case css_lexer.TOpenBrace, css_lexer.TSemicolon, css_lexer.TCloseBrace, css_lexer.TEndOfFile:
+			ok = true
+			return
+		}
+
+		if len(names) > 0 {
+			if !p.expect(css_lexer.TComma) {
+				return
+			}
+			p.eat(css_lexer.TWhitespace)
+		}
+
+		var name []string
+		for {
+			if !p.peek(css_lexer.TIdent) {
+				p.expect(css_lexer.TIdent)
+				return
+			}
+			name = append(name, p.decoded())
+			p.advance()
+			if !p.eat(css_lexer.TDelimDot) {
+				break
+			}
+		}
+		names = append(names, name)
+		p.eat(css_lexer.TWhitespace)
+	}
+}
+
+func layerNameFromImportConditions(conditions []css_ast.Token) (name []string, ok bool) {
+	if len(conditions) != 1 {
+		return
+	}
+	switch t := conditions[0]; t.Kind {
+	case css_lexer.TIdent:
+		ok = t.Text == "layer"
+		return
+
+	case css_lexer.TFunction:
+		if t.Text != "layer" || t.Children == nil {
+			return
+		}
+		for i, child := range *t.Children {
+			if i%2 == 0 {
+				if child.Kind != css_lexer.TIdent || (i > 0 && child.Whitespace&css_ast.WhitespaceBefore != 0) {
+					return
+				}
+				name = append(name, child.Text)
+			} else if child.Kind != css_lexer.TDelimDot || child.Whitespace&css_ast.WhitespaceBefore != 0 {
+				return
+			}
+		}
+		ok = len(name) > 0 && len(*t.Children)%2 == 1
+		return
+	}
+	return
+}
+
 func (p *parser) convertTokens(tokens []css_lexer.Token) []css_ast.Token {
 	result, _ := p.convertTokensHelper(tokens, css_lexer.TEndOfFile, convertTokensOpts{})
 	return result
@@ -823,15 +1194,18 @@ func mangleNumber(t string) (string, bool) {
 	return t, t != original
 }
 
-func (p *parser) parseSelectorRule() css_ast.R {
+func (p *parser) parseSelectorRule(isNested bool) css_ast.R {
 	preludeStart := p.index
 
 	// Try parsing the prelude as a selector list
-	if list, ok := p.parseSelectorList(); ok {
+	if list, ok := p.parseSelectorList(isNested); ok {
 		rule := css_ast.RSelector{Selectors: list}
 		if p.expect(css_lexer.TOpenBrace) {
 			rule.Rules = p.parseListOfDeclarations()
 			p.expect(css_lexer.TCloseBrace)
+			if p.options.LocalCSS {
+				rule.Rules = p.processComposes(list, rule.Rules)
+			}
 			return &rule
 		}
 	}
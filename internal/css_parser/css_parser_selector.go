@@ -5,10 +5,10 @@ import (
 	"github.com/evanw/esbuild/internal/css_lexer"
 )
 
-func (p *parser) parseSelectorList() (list []css_ast.ComplexSelector, ok bool) {
+func (p *parser) parseSelectorList(isNested bool) (list []css_ast.ComplexSelector, ok bool) {
 	// Parse the first selector
 	p.eat(css_lexer.TWhitespace)
-	sel, good := p.parseComplexSelector()
+	sel, good := p.parseComplexSelector(isNested)
 	if !good {
 		return
 	}
@@ -21,7 +21,7 @@ func (p *parser) parseSelectorList() (list []css_ast.ComplexSelector, ok bool) {
 			break
 		}
 		p.eat(css_lexer.TWhitespace)
-		sel, good := p.parseComplexSelector()
+		sel, good := p.parseComplexSelector(isNested)
 		if !good {
 			return
 		}
@@ -32,12 +32,26 @@ func (p *parser) parseSelectorList() (list []css_ast.ComplexSelector, ok bool) {
 	return
 }
 
-func (p *parser) parseComplexSelector() (result css_ast.ComplexSelector, ok bool) {
+func (p *parser) parseComplexSelector(isNested bool) (result css_ast.ComplexSelector, ok bool) {
+	// Nested selectors are relative to the parent rule and may start with a
+	// combinator (e.g. "> a" means the same thing as "& > a")
+	var leadingCombinator string
+	if isNested {
+		if leadingCombinator = p.parseCombinator(); leadingCombinator != "" {
+			p.eat(css_lexer.TWhitespace)
+		}
+	}
+
+	// Names in CSS modules are local by default
+	p.isLocalScope = p.options.LocalCSS
+	p.eatLocalOrGlobalPrefix()
+
 	// Parent
 	sel, good := p.parseCompoundSelector()
 	if !good {
 		return
 	}
+	sel.Combinator = leadingCombinator
 	result.Selectors = append(result.Selectors, sel)
 
 	for {
@@ -51,6 +65,7 @@ func (p *parser) parseComplexSelector() (result css_ast.ComplexSelector, ok bool
 		if combinator != "" {
 			p.eat(css_lexer.TWhitespace)
 		}
+		p.eatLocalOrGlobalPrefix()
 
 		// Child
 		sel, good := p.parseCompoundSelector()
@@ -117,6 +132,9 @@ subclassSelectors:
 		case css_lexer.TDelimDot:
 			p.advance()
 			name := p.decoded()
+			if p.isLocalScope && p.peek(css_lexer.TIdent) {
+				name = p.localName(name)
+			}
 			sel.SubclassSelectors = append(sel.SubclassSelectors, &css_ast.SSClass{Name: name})
 			p.expect(css_lexer.TIdent)
 
@@ -133,6 +151,14 @@ subclassSelectors:
 				// Stop if this is the start of the pseudo-element selector section
 				break subclassSelectors
 			}
+			if p.isLocalOrGlobalFunction() {
+				inner, good := p.parseLocalOrGlobalFunction()
+				if !good {
+					return
+				}
+				sel = mergeCompoundSelectors(sel, inner)
+				continue
+			}
 			pseudo := p.parsePseudoElementSelector()
 			sel.SubclassSelectors = append(sel.SubclassSelectors, &pseudo)
 
@@ -41,6 +41,7 @@ func expectPrintedCommon(t *testing.T, name string, contents string, expected st
 			MangleSyntax:           options.MangleSyntax,
 			RemoveWhitespace:       options.RemoveWhitespace,
 			UnsupportedCSSFeatures: options.UnsupportedCSSFeatures,
+			CSSPrefixData:          options.CSSPrefixData,
 		})
 		msgs := log.Done()
 		text := ""
@@ -50,10 +51,10 @@ func expectPrintedCommon(t *testing.T, name string, contents string, expected st
 			}
 		}
 		assertEqual(t, text, "")
-		css := css_printer.Print(tree, css_printer.Options{
+		result := css_printer.Print(tree, css_printer.Options{
 			RemoveWhitespace: options.RemoveWhitespace,
 		})
-		assertEqual(t, string(css), expected)
+		assertEqual(t, result.CSS, expected)
 	})
 }
 
@@ -69,6 +70,19 @@ func expectPrintedLower(t *testing.T, contents string, expected string) {
 	})
 }
 
+func expectPrintedWithAllPrefixes(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectPrintedCommon(t, contents+" [prefixed]", contents, expected, config.Options{
+		CSSPrefixData: compat.CSSPrefixDataForConstraints(map[compat.Engine][]int{
+			compat.Chrome:  {0},
+			compat.Edge:    {0},
+			compat.Firefox: {0},
+			compat.IOS:     {0},
+			compat.Safari:  {0},
+		}),
+	})
+}
+
 func expectPrintedMangle(t *testing.T, contents string, expected string) {
 	t.Helper()
 	expectPrintedCommon(t, contents+" [mangle]", contents, expected, config.Options{
@@ -92,6 +106,45 @@ func expectPrintedMangleMinify(t *testing.T, contents string, expected string) {
 	})
 }
 
+func expectPrintedLocal(t *testing.T, contents string, expected string) {
+	t.Helper()
+	t.Run(contents+" [local]", func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog()
+		tree := Parse(log, test.SourceForTest(contents), Options{
+			RemoveWhitespace: true,
+			LocalCSS:         true,
+		})
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			if msg.Kind == logger.Error {
+				text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+			}
+		}
+		assertEqual(t, text, "")
+		result := css_printer.Print(tree, css_printer.Options{
+			RemoveWhitespace: true,
+		})
+		assertEqual(t, result.CSS, expected)
+	})
+}
+
+func expectLocalParseError(t *testing.T, contents string, expected string) {
+	t.Helper()
+	t.Run(contents+" [local]", func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog()
+		Parse(log, test.SourceForTest(contents), Options{LocalCSS: true})
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqual(t, text, expected)
+	})
+}
+
 func TestEscapes(t *testing.T) {
 	// TIdent
 	expectPrinted(t, "a { value: id\\65nt }", "a {\n  value: ident;\n}\n")
@@ -666,6 +719,49 @@ func TestNestedSelector(t *testing.T) {
 	expectPrinted(t, "a { &*|b {} }", "a {\n  &*|b {\n  }\n}\n")
 	expectPrinted(t, "a { &a|b {} }", "a {\n  &a|b {\n  }\n}\n")
 	expectPrinted(t, "a { &[b] {} }", "a {\n  &[b] {\n  }\n}\n")
+	expectPrinted(t, "a { .b & {} }", "a {\n  .b & {\n  }\n}\n")
+	expectPrinted(t, "a { & > b {} }", "a {\n  & > b {\n  }\n}\n")
+
+	// Nested selectors without "&"
+	expectPrinted(t, "a { .b {} }", "a {\n  .b {\n  }\n}\n")
+	expectPrinted(t, "a { #b {} }", "a {\n  #b {\n  }\n}\n")
+	expectPrinted(t, "a { :b {} }", "a {\n  :b {\n  }\n}\n")
+	expectPrinted(t, "a { > b {} }", "a {\n  > b {\n  }\n}\n")
+	expectPrinted(t, "a { + b {} }", "a {\n  + b {\n  }\n}\n")
+	expectPrinted(t, "a { ~ b {} }", "a {\n  ~ b {\n  }\n}\n")
+	expectPrinted(t, "a { .b, > c {} }", "a {\n  .b,\n  > c {\n  }\n}\n")
+	expectPrinted(t, "a { .b { c: d } e: f }", "a {\n  .b {\n    c: d;\n  }\n  e: f;\n}\n")
+	expectParseError(t, "> a {}", "<stdin>: warning: Unexpected \">\"\n")
+}
+
+func TestLowerNesting(t *testing.T) {
+	expectPrintedLower(t, "a { b: c; & d { e: f } }", "a {\n  b: c;\n}\na d {\n  e: f;\n}\n")
+	expectPrintedLower(t, "a { .b { c: d } }", "a .b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { &.b { c: d } }", "a.b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { &:hover { c: d } }", "a:hover {\n  c: d;\n}\n")
+	expectPrintedLower(t, ".a { &b { c: d } }", "b.a {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { > b { c: d } }", "a > b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { + b { c: d } }", "a + b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { ~ b { c: d } }", "a ~ b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { .b & { c: d } }", ".b a {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { & + & { c: d } }", "a + a {\n  c: d;\n}\n")
+	expectPrintedLower(t, ".p, .q { & + & { c: d } }", ".p + .p,\n.p + .q,\n.q + .p,\n.q + .q {\n  c: d;\n}\n")
+	expectPrintedLower(t, ".p, .q { & .r & { c: d } }", ".p .r .p,\n.p .r .q,\n.q .r .p,\n.q .r .q {\n  c: d;\n}\n")
+	expectPrintedLower(t, ".p, .q { &.r, & + & { c: d } }", ".p.r,\n.q.r,\n.p + .p,\n.p + .q,\n.q + .p,\n.q + .q {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a b { & > c { d: e } }", "a b > c {\n  d: e;\n}\n")
+	expectPrintedLower(t, "a b { .c & { d: e } }", ".c a b {\n  d: e;\n}\n")
+	expectPrintedLower(t, "a, b { .c, &.d { e: f } }", "a .c,\nb .c,\na.d,\nb.d {\n  e: f;\n}\n")
+	expectPrintedLower(t, "a { .b { .c { d: e } } }", "a .b .c {\n  d: e;\n}\n")
+	expectPrintedLower(t, "a { .b { c: d } e: f }", "a {\n  e: f;\n}\na .b {\n  c: d;\n}\n")
+	expectPrintedLower(t, "a { .b {} }", "a .b {\n}\n")
+
+	// Nested conditional group rules are hoisted out of the style rule
+	expectPrintedLower(t, "a { @media screen { b: c } }", "@media screen {\n  a {\n    b: c;\n  }\n}\n")
+	expectPrintedLower(t, "a { b: c; @media screen { d: e; .f { g: h } } }",
+		"a {\n  b: c;\n}\n@media screen {\n  a {\n    d: e;\n  }\n  a .f {\n    g: h;\n  }\n}\n")
+	expectPrintedLower(t, "a { @supports (b: c) { @media screen { d: e } } }",
+		"@supports (b: c) {\n  @media screen {\n    a {\n      d: e;\n    }\n  }\n}\n")
+	expectPrintedLower(t, "@media screen { a { .b { c: d } } }", "@media screen {\n  a .b {\n    c: d;\n  }\n}\n")
 }
 
 func TestBadQualifiedRules(t *testing.T) {
@@ -791,6 +887,9 @@ func TestAtImport(t *testing.T) {
 	expectPrinted(t, "@import url(\"foo.css\") ;", "@import \"foo.css\";\n")
 	expectPrinted(t, "@import url(\"foo.css\") print;", "@import \"foo.css\" print;\n")
 	expectPrinted(t, "@import url(\"foo.css\") screen and (orientation:landscape);", "@import \"foo.css\" screen and (orientation:landscape);\n")
+	expectPrinted(t, "@import \"foo.css\" layer;", "@import \"foo.css\" layer;\n")
+	expectPrinted(t, "@import \"foo.css\" layer(a.b);", "@import \"foo.css\" layer(a.b);\n")
+	expectPrinted(t, "@import \"foo.css\" layer(a) screen;", "@import \"foo.css\" layer(a) screen;\n")
 
 	expectParseError(t, "@import;", "<stdin>: warning: Expected URL token but found \";\"\n")
 	expectParseError(t, "@import ;", "<stdin>: warning: Expected URL token but found \";\"\n")
@@ -840,6 +939,59 @@ func TestAtKeyframes(t *testing.T) {
 	expectParseError(t, "@keyframes name { 1%,,2% {} }", "<stdin>: warning: Expected percentage but found \",\"\n")
 }
 
+func TestAtLayer(t *testing.T) {
+	expectPrinted(t, "@layer a;", "@layer a;\n")
+	expectPrinted(t, "@layer a, b.c ,d;", "@layer a, b.c, d;\n")
+	expectPrinted(t, "@layer a { div { color: red } }", "@layer a {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer a.b{div{color:red}}", "@layer a.b {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer { div { color: red } }", "@layer {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer a {}", "@layer a {\n}\n")
+	expectPrinted(t, "div { @layer a { color: red } }", "div {\n  @layer a {\n    color: red;\n  }\n}\n")
+
+	expectParseError(t, "@layer a;", "")
+	expectParseError(t, "@layer a, b {}", "<stdin>: warning: Expected \";\"\n")
+	expectParseError(t, "@layer a, b{}", "<stdin>: warning: Expected \";\" but found \"{\"\n")
+	expectParseError(t, "@layer;", "<stdin>: warning: Expected identifier but found \";\"\n")
+	expectParseError(t, "@layer a b;", "<stdin>: warning: Expected \",\" but found \"b\"\n")
+	expectParseError(t, "@layer a.;", "<stdin>: warning: Expected identifier but found \";\"\n")
+	expectParseError(t, "@layer a", "<stdin>: warning: Expected \";\" but found end of file\n")
+
+	expectPrintedMangle(t, "@layer a {}", "@layer a;\n")
+	expectPrintedMangle(t, "@layer {}", "")
+	expectPrintedMangle(t, "@layer a; @layer b;", "@layer a, b;\n")
+	expectPrintedMangle(t, "@layer a, b; @layer b, c; @layer a.b;", "@layer a, b, c, a.b;\n")
+	expectPrintedMangle(t, "@layer a; @layer b {} @layer c;", "@layer a, b, c;\n")
+	expectPrintedMangle(t, "@layer a; div { color: red } @layer b;", "@layer a;\ndiv {\n  color: red;\n}\n@layer b;\n")
+	expectPrintedMangle(t, "@layer a; @layer b { div { color: red } } @layer c;",
+		"@layer a;\n@layer b {\n  div {\n    color: red;\n  }\n}\n@layer c;\n")
+	expectPrintedMangleMinify(t, "@layer a, b.c; @layer d { div { color: red } }", "@layer a,b.c;@layer d{div{color:red}}")
+	expectPrintedMangleMinify(t, "@layer { div { color: red } }", "@layer{div{color:red}}")
+
+	expectPrintedLower(t, ".a { @layer b { color: red } }", "@layer b {\n  .a {\n    color: red;\n  }\n}\n")
+}
+
+func TestAtContainer(t *testing.T) {
+	expectPrinted(t, "@container (min-width: 400px) { div { color: red } }",
+		"@container (min-width: 400px) {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@container sidebar (min-width: 400px) { div { color: red } }",
+		"@container sidebar (min-width: 400px) {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "div { @container (min-width: 400px) { color: red } }",
+		"div {\n  @container (min-width: 400px) {\n    color: red;\n  }\n}\n")
+
+	expectParseError(t, "@container (min-width: 400px) { div { color: red } }", "")
+	expectPrintedMangle(t, "@container (min-width: 400px) {}", "")
+	expectPrintedLower(t, ".a { @container (min-width: 400px) { color: red } }",
+		"@container (min-width: 400px) {\n  .a {\n    color: red;\n  }\n}\n")
+}
+
+func TestAtProperty(t *testing.T) {
+	expectPrinted(t, "@property --x { syntax: '<length>'; inherits: false; initial-value: 0px }",
+		"@property --x {\n  syntax: \"<length>\";\n  inherits: false;\n  initial-value: 0px;\n}\n")
+
+	expectParseError(t, "@property --x { syntax: '*'; inherits: true }", "")
+	expectPrintedMangle(t, "@property --x {}", "")
+}
+
 func TestAtRuleValidation(t *testing.T) {
 	expectParseError(t, "a {} @charset \"UTF-8\";",
 		"<stdin>: warning: \"@charset\" must be the first rule in the file\n"+
@@ -848,6 +1000,11 @@ func TestAtRuleValidation(t *testing.T) {
 	expectParseError(t, "a {} @import \"foo\";",
 		"<stdin>: warning: All \"@import\" rules must come first\n"+
 			"<stdin>: note: This rule cannot come before an \"@import\" rule\n")
+
+	expectParseError(t, "@layer a; @import \"foo\";", "")
+	expectParseError(t, "@layer a {} @import \"foo\";",
+		"<stdin>: warning: All \"@import\" rules must come first\n"+
+			"<stdin>: note: This rule cannot come before an \"@import\" rule\n")
 }
 
 func TestEmptyRule(t *testing.T) {
@@ -876,3 +1033,249 @@ func TestEmptyRule(t *testing.T) {
 	expectPrintedMangleMinify(t, "@keyframes test { from {} to { color: red } }", "@keyframes test{to{color:red}}")
 	expectPrintedMangleMinify(t, "@keyframes test { from { color: red } to {} }", "@keyframes test{0%{color:red}}")
 }
+
+func TestLocalCSS(t *testing.T) {
+	// The suffix is derived from a hash of the path "<stdin>"
+	expectPrintedLocal(t, ".a {}", ".a_yx3acuvw{}")
+	expectPrintedLocal(t, ".a .b, .a.c {}", ".a_yx3acuvw .b_yx3acuvw,.a_yx3acuvw.c_yx3acuvw{}")
+	expectPrintedLocal(t, "div.a#id {}", "div.a_yx3acuvw#id{}")
+	expectPrintedLocal(t, ".a:hover {}", ".a_yx3acuvw:hover{}")
+
+	expectPrintedLocal(t, ":global(.a) .b {}", ".a .b_yx3acuvw{}")
+	expectPrintedLocal(t, ":global(.a).b {}", ".a.b_yx3acuvw{}")
+	expectPrintedLocal(t, ":global .a .b {}", ".a .b{}")
+	expectPrintedLocal(t, ":global .a :local(.b) .c {}", ".a .b_yx3acuvw .c{}")
+	expectPrintedLocal(t, ":global .a :local .b .c {}", ".a .b_yx3acuvw .c_yx3acuvw{}")
+	expectPrintedLocal(t, ".a > :global .b, .c {}", ".a_yx3acuvw>.b,.c_yx3acuvw{}")
+
+	expectPrintedLocal(t, "@keyframes a { to { color: red } } .b { animation: a 1s }",
+		"@keyframes a_yx3acuvw{to{color:red}}.b_yx3acuvw{animation:a_yx3acuvw 1s}")
+	expectPrintedLocal(t, ".b { animation-name: a } @keyframes a {}",
+		".b_yx3acuvw{animation-name:a_yx3acuvw}@keyframes a_yx3acuvw{}")
+	expectPrintedLocal(t, ".b { animation-name: c }", ".b_yx3acuvw{animation-name:c}")
+
+	expectPrintedLocal(t, ".a { composes: b c; color: red }", ".a_yx3acuvw{color:red}")
+	expectPrintedLocal(t, ".a { composes: b from global }", ".a_yx3acuvw{}")
+	expectPrintedLocal(t, ".a { composes: b from \"./b.css\" }", ".a_yx3acuvw{}")
+
+	expectLocalParseError(t, ".a .b { composes: c }", "<stdin>: warning: \"composes\" only works inside single local class selectors\n")
+	expectLocalParseError(t, ":global(.a) { composes: c }", "<stdin>: warning: \"composes\" only works inside single local class selectors\n")
+	expectLocalParseError(t, ".a { composes: 1px }", "<stdin>: warning: Unexpected \"1px\" in \"composes\"\n")
+	expectLocalParseError(t, ".a { composes: b from c }", "<stdin>: warning: Unexpected \"c\" in \"composes\"\n")
+
+	// Names outside of a CSS module are left alone
+	expectPrinted(t, ".a :global(.b) {}", ".a :global(.b) {\n}\n")
+}
+
+func TestPrefixInsertion(t *testing.T) {
+	// General "-webkit-" tests
+	for _, key := range []string{
+		"backdrop-filter",
+		"box-decoration-break",
+		"clip-path",
+		"mask-image",
+	} {
+		expectPrintedWithAllPrefixes(t,
+			"a { "+key+": url(x.png) }",
+			"a {\n  -webkit-"+key+": url(x.png);\n  "+key+": url(x.png);\n}\n")
+
+		expectPrintedWithAllPrefixes(t,
+			"a { before: value; "+key+": url(x.png) }",
+			"a {\n  before: value;\n  -webkit-"+key+": url(x.png);\n  "+key+": url(x.png);\n}\n")
+
+		expectPrintedWithAllPrefixes(t,
+			"a { "+key+": url(x.png); after: value }",
+			"a {\n  -webkit-"+key+": url(x.png);\n  "+key+": url(x.png);\n  after: value;\n}\n")
+
+		expectPrintedWithAllPrefixes(t,
+			"a { "+key+": url(x.png) !important }",
+			"a {\n  -webkit-"+key+": url(x.png) !important;\n  "+key+": url(x.png) !important;\n}\n")
+	}
+
+	// Properties with more than one prefix
+	expectPrintedWithAllPrefixes(t, "a { appearance: none }",
+		"a {\n  -webkit-appearance: none;\n  -moz-appearance: none;\n  appearance: none;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { user-select: none }",
+		"a {\n  -webkit-user-select: none;\n  -moz-user-select: none;\n  -ms-user-select: none;\n  user-select: none;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { hyphens: auto }",
+		"a {\n  -webkit-hyphens: auto;\n  -moz-hyphens: auto;\n  hyphens: auto;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { tab-size: 2 }", "a {\n  -moz-tab-size: 2;\n  tab-size: 2;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { text-size-adjust: none }",
+		"a {\n  -webkit-text-size-adjust: none;\n  -ms-text-size-adjust: none;\n  text-size-adjust: none;\n}\n")
+
+	// Existing prefixed declarations are not duplicated
+	expectPrintedWithAllPrefixes(t, "a { -webkit-user-select: none; user-select: none }",
+		"a {\n  -webkit-user-select: none;\n  -moz-user-select: none;\n  -ms-user-select: none;\n  user-select: none;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { appearance: none; -webkit-appearance: auto }",
+		"a {\n  -moz-appearance: none;\n  appearance: none;\n  -webkit-appearance: auto;\n}\n")
+
+	// Only "position: sticky" has a prefix
+	expectPrintedWithAllPrefixes(t, "a { position: sticky }", "a {\n  position: -webkit-sticky;\n  position: sticky;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { position: relative }", "a {\n  position: relative;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a { position: -webkit-sticky; position: sticky }", "a {\n  position: -webkit-sticky;\n  position: sticky;\n}\n")
+
+	// Pseudo-elements are prefixed using separate rules
+	expectPrintedWithAllPrefixes(t, "input::placeholder { color: red }",
+		"input::-webkit-input-placeholder {\n  color: red;\n}\n"+
+			"input::-moz-placeholder {\n  color: red;\n}\n"+
+			"input::-ms-input-placeholder {\n  color: red;\n}\n"+
+			"input::placeholder {\n  color: red;\n}\n")
+	expectPrintedWithAllPrefixes(t, "a, b::placeholder { color: red }",
+		"b::-webkit-input-placeholder {\n  color: red;\n}\n"+
+			"b::-moz-placeholder {\n  color: red;\n}\n"+
+			"b::-ms-input-placeholder {\n  color: red;\n}\n"+
+			"a,\nb::placeholder {\n  color: red;\n}\n")
+	expectPrintedWithAllPrefixes(t, "@media screen { input:hover::placeholder { color: red } }",
+		"@media screen {\n"+
+			"  input:hover::-webkit-input-placeholder {\n    color: red;\n  }\n"+
+			"  input:hover::-moz-placeholder {\n    color: red;\n  }\n"+
+			"  input:hover::-ms-input-placeholder {\n    color: red;\n  }\n"+
+			"  input:hover::placeholder {\n    color: red;\n  }\n}\n")
+
+	// Nothing is prefixed without a target environment
+	expectPrinted(t, "a { user-select: none }", "a {\n  user-select: none;\n}\n")
+	expectPrinted(t, "input::placeholder {}", "input::placeholder {\n}\n")
+}
+
+func TestMergeDuplicateRules(t *testing.T) {
+	expectPrinted(t, "a { color: red } a { margin: 0 }", "a {\n  color: red;\n}\na {\n  margin: 0;\n}\n")
+
+	expectPrintedMangleMinify(t, "a { color: red } a { margin: 0 }", "a{color:red;margin:0}")
+	expectPrintedMangleMinify(t, "a { color: red } b { color: red }", "a,b{color:red}")
+	expectPrintedMangleMinify(t, "a, b { color: red } b, c { color: red }", "a,b,c{color:red}")
+	expectPrintedMangleMinify(t, "a { color: red } a { color: red }", "a{color:red}")
+	expectPrintedMangleMinify(t, "a { color: red } a { color: blue }", "a{color:red;color:#00f}")
+	expectPrintedMangleMinify(t, "a { color: red } b { color: blue } a { color: red }", "a{color:red}b{color:#00f}a{color:red}")
+	expectPrintedMangleMinify(t, "a { color: red } b { color: red !important }", "a{color:red}b{color:red!important}")
+	expectPrintedMangleMinify(t, "a { color: red } b { color: red; margin: 0 }", "a{color:red}b{color:red;margin:0}")
+	expectPrintedMangleMinify(t, "a { color: red } @media screen { a { color: red } }", "a{color:red}@media screen{a{color:red}}")
+	expectPrintedMangleMinify(t, "@media screen { a { color: red } b { color: red } }", "@media screen{a,b{color:red}}")
+
+	// Only widely-supported selectors are merged into a selector list
+	expectPrintedMangleMinify(t, "a:hover { color: red } b::before { color: red }", "a:hover,b::before{color:red}")
+	expectPrintedMangleMinify(t, "a:not(.x) { color: red } b { color: red }", "a:not(.x),b{color:red}")
+	expectPrintedMangleMinify(t, "a:not(:focus-visible) { color: red } b { color: red }", "a:not(:focus-visible){color:red}b{color:red}")
+	expectPrintedMangleMinify(t, "a:not(b) { color: red } c { color: red }", "a:not(b),c{color:red}")
+	expectPrintedMangleMinify(t, "a:not(#b) { color: red } c { color: red }", "a:not(#b),c{color:red}")
+	expectPrintedMangleMinify(t, "a:not([b]) { color: red } c { color: red }", "a:not([b]),c{color:red}")
+	expectPrintedMangleMinify(t, ".w:not(.a,.b){color:red}.v{color:red}", ".w:not(.a,.b){color:red}.v{color:red}")
+	expectPrintedMangleMinify(t, ".w:not(.a .b){color:red}.v{color:red}", ".w:not(.a .b){color:red}.v{color:red}")
+	expectPrintedMangleMinify(t, ".w:not(.a>.b){color:red}.v{color:red}", ".w:not(.a>.b){color:red}.v{color:red}")
+	expectPrintedMangleMinify(t, ".w:not(.a.b){color:red}.v{color:red}", ".w:not(.a.b){color:red}.v{color:red}")
+	expectPrintedMangleMinify(t, "a:focus-visible { color: red } b { color: red }", "a:focus-visible{color:red}b{color:red}")
+	expectPrintedMangleMinify(t, "a::placeholder { color: red } b { color: red }", "a::placeholder{color:red}b{color:red}")
+	expectPrintedMangleMinify(t, "a::-webkit-input-placeholder { color: red } a::-moz-placeholder { color: red }",
+		"a::-webkit-input-placeholder{color:red}a::-moz-placeholder{color:red}")
+	expectPrintedMangleMinify(t, "a:-ms-input-placeholder { color: red } b { color: red }", "a:-ms-input-placeholder{color:red}b{color:red}")
+
+	// Identical selectors are always merged
+	expectPrintedMangleMinify(t, "a::-webkit-scrollbar { width: 0 } a::-webkit-scrollbar { height: 0 }", "a::-webkit-scrollbar{width:0;height:0}")
+
+	// Rules with nested rules are left alone
+	expectPrintedMangleMinify(t, "a { color: red } a { & b { color: red } }", "a{color:red}a{& b{color:red}}")
+}
+
+func TestBoxShorthands(t *testing.T) {
+	for _, key := range []string{"margin", "padding"} {
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+":1px 2px 3px 4px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 2px }",
+			"a{"+key+":1px 2px 3px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-right: 2px; "+key+"-bottom: 1px; "+key+"-left: 2px }",
+			"a{"+key+":1px 2px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-right: 1px; "+key+"-bottom: 1px; "+key+"-left: 1px }",
+			"a{"+key+":1px}")
+		expectPrintedMangleMinify(t, "a { "+key+": 1px 2px 3px 2px }", "a{"+key+":1px 2px 3px}")
+		expectPrintedMangleMinify(t, "a { "+key+": 1px 1px }", "a{"+key+":1px}")
+		expectPrintedMangleMinify(t, "a { "+key+": 1px; "+key+"-top: 2px }", "a{"+key+":2px 1px 1px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 2px; "+key+": 1px }", "a{"+key+":1px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-top: 2px }", "a{"+key+"-top:2px}")
+
+		// Not all sides are present
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px; "+key+"-right: 2px; "+key+"-bottom: 3px }",
+			"a{"+key+"-top:1px;"+key+"-right:2px;"+key+"-bottom:3px}")
+
+		// "!important" must match
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px !important; "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+"-top:1px!important;"+key+"-right:2px;"+key+"-bottom:3px;"+key+"-left:4px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: 1px !important; "+key+"-right: 2px !important; "+key+"-bottom: 3px !important; "+key+"-left: 4px !important }",
+			"a{"+key+":1px 2px 3px 4px!important}")
+		expectPrintedMangleMinify(t, "a { "+key+": 1px !important; "+key+"-top: 2px }", "a{"+key+":1px!important;"+key+"-top:2px}")
+
+		// Values that may not stand for a single side are left alone
+		expectPrintedMangleMinify(t, "a { "+key+"-top: var(--x); "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+"-top:var(--x);"+key+"-right:2px;"+key+"-bottom:3px;"+key+"-left:4px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: inherit; "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+"-top:inherit;"+key+"-right:2px;"+key+"-bottom:3px;"+key+"-left:4px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: -webkit-calc(1px + 2px); "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+"-top:-webkit-calc(1px + 2px);"+key+"-right:2px;"+key+"-bottom:3px;"+key+"-left:4px}")
+		expectPrintedMangleMinify(t, "a { "+key+"-top: calc(1px + 2px); "+key+"-right: 2px; "+key+"-bottom: 3px; "+key+"-left: 4px }",
+			"a{"+key+":calc(1px + 2px) 2px 3px 4px}")
+		expectPrintedMangleMinify(t, "a { "+key+": 1px; "+key+"-top: var(--x) }", "a{"+key+":1px;"+key+"-top:var(--x)}")
+	}
+
+	expectPrintedMangle(t, "a { margin-top: 1px; margin-right: 2px; margin-bottom: 3px; margin-left: 4px }",
+		"a {\n  margin: 1px 2px 3px 4px;\n}\n")
+	expectPrintedMangle(t, "a { margin-top: 0; color: red; margin-right: 0; margin-bottom: 0; margin-left: 0 }",
+		"a {\n  color: red;\n  margin: 0;\n}\n")
+	expectPrintedMangle(t, "a { margin: auto; margin-left: 0 }", "a {\n  margin: auto auto auto 0;\n}\n")
+	expectPrintedMangleMinify(t, "a { margin-top: 1px; margin-block-start: 2px; margin-right: 1px; margin-bottom: 1px; margin-left: 1px }",
+		"a{margin-top:1px;margin-block-start:2px;margin-right:1px;margin-bottom:1px;margin-left:1px}")
+
+	expectPrintedMangleMinify(t, "a { border-top-width: 1px; border-right-width: 2px; border-bottom-width: 1px; border-left-width: 2px }",
+		"a{border-width:1px 2px}")
+	expectPrintedMangleMinify(t, "a { border-top-style: solid; border-right-style: solid; border-bottom-style: solid; border-left-style: solid }",
+		"a{border-style:solid}")
+	expectPrintedMangleMinify(t, "a { border-top-color: red; border-right-color: #00f; border-bottom-color: red; border-left-color: blue }",
+		"a{border-color:red #00f}")
+	expectPrintedMangleMinify(t, "a { border-color: red; border-top: 1px solid blue; border-left-color: green }",
+		"a{border-color:red;border-top:1px solid blue;border-left-color:green}")
+
+	// The corners of "border-radius" are in clockwise order starting at the top left
+	expectPrintedMangleMinify(t, "a { border-top-left-radius: 1px; border-top-right-radius: 2px; border-bottom-right-radius: 3px; border-bottom-left-radius: 4px }",
+		"a{border-radius:1px 2px 3px 4px}")
+	expectPrintedMangleMinify(t, "a { border-top-left-radius: 1px; border-top-right-radius: 2px; border-bottom-right-radius: 1px; border-bottom-left-radius: 2px }",
+		"a{border-radius:1px 2px}")
+	expectPrintedMangleMinify(t, "a { border-radius: 1px / 2px; border-top-left-radius: 3px }",
+		"a{border-radius:1px / 2px;border-top-left-radius:3px}")
+}
+
+func TestFontShorthand(t *testing.T) {
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-variant: small-caps; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: Arial, serif }",
+		"a{font:italic small-caps bold 12px/1.5 Arial,serif}")
+	expectPrintedMangleMinify(t, "a { font-style: normal; font-variant: normal; font-weight: normal; font-size: 12px; line-height: normal; font-family: serif }",
+		"a{font:12px serif}")
+	expectPrintedMangleMinify(t, "a { font-style: normal; font-variant: normal; font-weight: 700; font-stretch: condensed; font-size: 12px; line-height: 2; font-family: \"Open Sans\" }",
+		"a{font:700 condensed 12px/2 \"Open Sans\"}")
+	expectPrintedMangle(t, "a { color: red; font-style: italic; font-variant: normal; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: serif }",
+		"a {\n  color: red;\n  font: italic bold 12px/1.5 serif;\n}\n")
+
+	// Every CSS 2.1 font property must be present
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: serif }",
+		"a{font-style:italic;font-weight:bold;font-size:12px;line-height:1.5;font-family:serif}")
+
+	// Other font properties would be reset by the shorthand
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-variant: normal; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: serif; font-kerning: none }",
+		"a{font-style:italic;font-variant:normal;font-weight:bold;font-size:12px;line-height:1.5;font-family:serif;font-kerning:none}")
+
+	// Unsafe values are left alone
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-variant: normal; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: var(--font) }",
+		"a{font-style:italic;font-variant:normal;font-weight:bold;font-size:12px;line-height:1.5;font-family:var(--font)}")
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-variant: all-small-caps; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: serif }",
+		"a{font-style:italic;font-variant:all-small-caps;font-weight:bold;font-size:12px;line-height:1.5;font-family:serif}")
+	expectPrintedMangleMinify(t, "a { font-style: italic; font-variant: normal; font-weight: bold; font-size: 12px; line-height: 1.5; font-family: serif !important }",
+		"a{font-style:italic;font-variant:normal;font-weight:bold;font-size:12px;line-height:1.5;font-family:serif!important}")
+}
+
+func TestDuplicateDeclarations(t *testing.T) {
+	expectPrinted(t, "a { color: red; color: red }", "a {\n  color: red;\n  color: red;\n}\n")
+
+	expectPrintedMangleMinify(t, "a { color: red; color: red }", "a{color:red}")
+	expectPrintedMangleMinify(t, "a { color: red; color: blue; color: red }", "a{color:#00f;color:red}")
+	expectPrintedMangleMinify(t, "a { color: red !important; color: red }", "a{color:red!important;color:red}")
+	expectPrintedMangleMinify(t, "a { color: #f00; color: red }", "a{color:red}")
+
+	// Different values may be fallbacks for browsers that don't support the later value
+	expectPrintedMangleMinify(t, "a { display: -webkit-box; display: flex }", "a{display:-webkit-box;display:flex}")
+	expectPrintedMangleMinify(t, "a { width: 100px; width: calc(100% - 10px) }", "a{width:100px;width:calc(100% - 10px)}")
+}
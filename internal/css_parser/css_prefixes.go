@@ -0,0 +1,195 @@
+package css_parser
+
+import (
+	"github.com/evanw/esbuild/internal/compat"
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+)
+
+// Prefixed versions are always inserted in this order before the unprefixed
+// version so that the standard behavior takes precedence when it's supported
+var prefixOrder = []struct {
+	prefix compat.CSSPrefix
+	text   string
+}{
+	{prefix: compat.WebkitPrefix, text: "-webkit-"},
+	{prefix: compat.MozPrefix, text: "-moz-"},
+	{prefix: compat.MsPrefix, text: "-ms-"},
+}
+
+// The prefixed names of pseudo-elements don't follow a consistent pattern
+var prefixedPseudoElements = map[string]map[compat.CSSPrefix]string{
+	"placeholder": {
+		compat.WebkitPrefix: "-webkit-input-placeholder",
+		compat.MozPrefix:    "-moz-placeholder",
+		compat.MsPrefix:     "-ms-input-placeholder",
+	},
+}
+
+var pseudoElementsForPrefixes = map[string]compat.CSSPseudoElement{
+	"placeholder": compat.PseudoElementPlaceholder,
+}
+
+// This inserts "-webkit-user-select: none" before "user-select: none" and
+// similar for other properties that need a prefix in the target environment.
+// Prefixed properties that are already present are not duplicated.
+func (p *parser) insertPrefixedDeclarations(rules []css_ast.Rule) []css_ast.Rule {
+	if len(p.options.CSSPrefixData.Properties) == 0 {
+		return rules
+	}
+
+	var results []css_ast.Rule
+	for i, rule := range rules {
+		decl, ok := rule.Data.(*css_ast.RDeclaration)
+		if !ok {
+			if results != nil {
+				results = append(results, rule)
+			}
+			continue
+		}
+
+		prefixes := p.options.CSSPrefixData.Properties[decl.Key]
+		if prefixes == compat.NoPrefix {
+			if results != nil {
+				results = append(results, rule)
+			}
+			continue
+		}
+
+		// Only "position: sticky" needs a prefix, and it's the value that's
+		// prefixed instead of the property: "position: -webkit-sticky"
+		isSticky := false
+		if decl.Key == css_ast.DPosition {
+			if len(decl.Value) != 1 || decl.Value[0].Kind != css_lexer.TIdent || decl.Value[0].Text != "sticky" {
+				if results != nil {
+					results = append(results, rule)
+				}
+				continue
+			}
+			isSticky = true
+		}
+
+		for _, item := range prefixOrder {
+			if (prefixes & item.prefix) == 0 {
+				continue
+			}
+
+			prefixed := &css_ast.RDeclaration{
+				KeyText:   decl.KeyText,
+				KeyRange:  decl.KeyRange,
+				Important: decl.Important,
+			}
+			if isSticky {
+				prefixed.Key = decl.Key
+				prefixed.Value = []css_ast.Token{decl.Value[0]}
+				prefixed.Value[0].Text = item.text + "sticky"
+			} else {
+				prefixed.KeyText = item.text + decl.KeyText
+				prefixed.Value = append([]css_ast.Token{}, decl.Value...)
+			}
+			if hasEquivalentDeclaration(rules, prefixed) {
+				continue
+			}
+
+			if results == nil {
+				results = append(make([]css_ast.Rule, 0, len(rules)+1), rules[:i]...)
+			}
+			results = append(results, css_ast.Rule{Loc: rule.Loc, Data: prefixed})
+		}
+
+		if results != nil {
+			results = append(results, rule)
+		}
+	}
+
+	if results == nil {
+		return rules
+	}
+	return results
+}
+
+func hasEquivalentDeclaration(rules []css_ast.Rule, decl *css_ast.RDeclaration) bool {
+	for _, rule := range rules {
+		if other, ok := rule.Data.(*css_ast.RDeclaration); ok && other.KeyText == decl.KeyText {
+			// For properties, any existing prefixed declaration counts. For
+			// prefixed values, the value must also match.
+			if decl.Key != css_ast.DPosition {
+				return true
+			}
+			if len(other.Value) == 1 && other.Value[0].Text == decl.Value[0].Text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// This inserts "::-webkit-input-placeholder {}" before "::placeholder {}" and
+// similar for other pseudo-elements that need a prefix. Each prefixed version
+// must be a separate rule because browsers drop the whole rule when any of
+// the selectors in the list is unrecognized.
+func (p *parser) insertPrefixedSelectorRules(rules []css_ast.Rule) []css_ast.Rule {
+	if len(p.options.CSSPrefixData.PseudoElements) == 0 {
+		return rules
+	}
+
+	results := make([]css_ast.Rule, 0, len(rules))
+	for _, rule := range rules {
+		switch r := rule.Data.(type) {
+		case *css_ast.RSelector:
+			r.Rules = p.insertPrefixedSelectorRules(r.Rules)
+			for _, item := range prefixOrder {
+				if selectors := p.prefixedSelectors(r.Selectors, item.prefix); selectors != nil {
+					results = append(results, css_ast.Rule{Loc: rule.Loc, Data: &css_ast.RSelector{
+						Selectors: selectors,
+						Rules:     r.Rules,
+					}})
+				}
+			}
+
+		case *css_ast.RKnownAt:
+			r.Rules = p.insertPrefixedSelectorRules(r.Rules)
+
+		case *css_ast.RAtLayer:
+			if r.Rules != nil {
+				r.Rules = p.insertPrefixedSelectorRules(r.Rules)
+			}
+		}
+		results = append(results, rule)
+	}
+	return results
+}
+
+// Returns the selectors in this list that contain a pseudo-element that needs
+// the given prefix, with that pseudo-element replaced by the prefixed version
+func (p *parser) prefixedSelectors(selectors []css_ast.ComplexSelector, prefix compat.CSSPrefix) (results []css_ast.ComplexSelector) {
+	for _, complex := range selectors {
+		var compounds []css_ast.CompoundSelector
+		for i, sel := range complex.Selectors {
+			var pseudos []css_ast.SSPseudoClass
+			for j, pseudo := range sel.PseudoClassSelectors {
+				if len(pseudo.Args) > 0 {
+					continue
+				}
+				element, ok := pseudoElementsForPrefixes[pseudo.Name]
+				if !ok || (p.options.CSSPrefixData.PseudoElements[element]&prefix) == 0 {
+					continue
+				}
+				if pseudos == nil {
+					pseudos = append([]css_ast.SSPseudoClass{}, sel.PseudoClassSelectors...)
+				}
+				pseudos[j].Name = prefixedPseudoElements[pseudo.Name][prefix]
+			}
+			if pseudos != nil {
+				if compounds == nil {
+					compounds = append([]css_ast.CompoundSelector{}, complex.Selectors...)
+				}
+				compounds[i].PseudoClassSelectors = pseudos
+			}
+		}
+		if compounds != nil {
+			results = append(results, css_ast.ComplexSelector{Selectors: compounds})
+		}
+	}
+	return
+}
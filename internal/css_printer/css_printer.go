@@ -8,6 +8,9 @@ import (
 	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/css_ast"
 	"github.com/evanw/esbuild/internal/css_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/sourcemap"
 )
 
 const quoteForURL rune = -1
@@ -16,30 +19,65 @@ type printer struct {
 	options       Options
 	importRecords []ast.ImportRecord
 	sb            strings.Builder
+
+	// For source maps
+	sourceMap           []byte
+	prevLoc             logger.Loc
+	prevState           js_printer.SourceMapState
+	lastGeneratedUpdate int
+	generatedColumn     int
+	hasPrevState        bool
 }
 
 type Options struct {
 	RemoveWhitespace bool
 	ASCIIOnly        bool
+
+	// If we're writing out a source map, this table of line start indices lets
+	// us do binary search on to figure out what line a given AST node came from
+	LineOffsetTables []js_printer.LineOffsetTable
+
+	AddSourceMappings bool
 }
 
-func Print(tree css_ast.AST, options Options) string {
+type PrintResult struct {
+	CSS string
+
+	// This source map chunk just contains the VLQ-encoded offsets for the "CSS"
+	// field above. It's not a full source map. The bundler will be joining many
+	// source map chunks together to form the final source map.
+	SourceMapChunk js_printer.SourceMapChunk
+}
+
+func Print(tree css_ast.AST, options Options) PrintResult {
 	p := printer{
 		options:       options,
 		importRecords: tree.ImportRecords,
+		prevLoc:       logger.Loc{Start: -1},
 	}
 	for _, rule := range tree.Rules {
 		p.printRule(rule, 0, false)
 	}
-	return p.sb.String()
+	p.updateGeneratedLineAndColumn()
+	return PrintResult{
+		CSS: p.sb.String(),
+		SourceMapChunk: js_printer.SourceMapChunk{
+			Buffer:               p.sourceMap,
+			EndState:             p.prevState,
+			FinalGeneratedColumn: p.generatedColumn,
+			ShouldIgnore:         !p.hasPrevState,
+		},
+	}
 }
 
-func (p *printer) printRule(rule css_ast.R, indent int32, omitTrailingSemicolon bool) {
+func (p *printer) printRule(rule css_ast.Rule, indent int32, omitTrailingSemicolon bool) {
 	if !p.options.RemoveWhitespace {
 		p.printIndent(indent)
 	}
 
-	switch r := rule.(type) {
+	p.addSourceMapping(rule.Loc)
+
+	switch r := rule.Data.(type) {
 	case *css_ast.RAtCharset:
 		// It's not valid to remove the space in between these two tokens
 		p.print("@charset ")
@@ -120,6 +158,32 @@ func (p *printer) printRule(rule css_ast.R, indent int32, omitTrailingSemicolon
 		}
 		p.printRuleBlock(r.Rules, indent)
 
+	case *css_ast.RAtLayer:
+		p.print("@layer")
+		for i, parts := range r.Names {
+			if i == 0 {
+				p.print(" ")
+			} else if p.options.RemoveWhitespace {
+				p.print(",")
+			} else {
+				p.print(", ")
+			}
+			for j, part := range parts {
+				if j > 0 {
+					p.print(".")
+				}
+				p.printIdent(part, identNormal, canDiscardWhitespaceAfter)
+			}
+		}
+		if r.Rules == nil {
+			p.print(";")
+		} else {
+			if !p.options.RemoveWhitespace {
+				p.print(" ")
+			}
+			p.printRuleBlock(r.Rules, indent)
+		}
+
 	case *css_ast.RUnknownAt:
 		p.print("@")
 		whitespace := mayNeedWhitespaceAfter
@@ -186,7 +250,7 @@ func (p *printer) printRule(rule css_ast.R, indent int32, omitTrailingSemicolon
 	}
 }
 
-func (p *printer) printRuleBlock(rules []css_ast.R, indent int32) {
+func (p *printer) printRuleBlock(rules []css_ast.Rule, indent int32) {
 	if p.options.RemoveWhitespace {
 		p.print("{")
 	} else {
@@ -222,12 +286,8 @@ func (p *printer) printComplexSelectors(selectors []css_ast.ComplexSelector, ind
 }
 
 func (p *printer) printCompoundSelector(sel css_ast.CompoundSelector, isFirst bool, isLast bool) {
-	if sel.HasNestPrefix {
-		p.print("&")
-	}
-
 	if sel.Combinator != "" {
-		if !p.options.RemoveWhitespace {
+		if !p.options.RemoveWhitespace && !isFirst {
 			p.print(" ")
 		}
 		p.print(sel.Combinator)
@@ -238,6 +298,10 @@ func (p *printer) printCompoundSelector(sel css_ast.CompoundSelector, isFirst bo
 		p.print(" ")
 	}
 
+	if sel.HasNestPrefix {
+		p.print("&")
+	}
+
 	if sel.TypeSelector != nil {
 		whitespace := mayNeedWhitespaceAfter
 		if len(sel.SubclassSelectors) > 0 || len(sel.PseudoClassSelectors) > 0 {
@@ -358,6 +422,71 @@ func (p *printer) print(text string) {
 	p.sb.WriteString(text)
 }
 
+func (p *printer) addSourceMapping(loc logger.Loc) {
+	if !p.options.AddSourceMappings || loc == p.prevLoc {
+		return
+	}
+	p.prevLoc = loc
+
+	originalLine, originalColumn := js_printer.GetOriginalLoc(&p.options.LineOffsetTables, loc.Start)
+	p.updateGeneratedLineAndColumn()
+
+	var lastByte byte
+	if len(p.sourceMap) != 0 {
+		lastByte = p.sourceMap[len(p.sourceMap)-1]
+	}
+
+	currentState := js_printer.SourceMapState{
+		GeneratedLine:   p.prevState.GeneratedLine,
+		GeneratedColumn: p.generatedColumn,
+		OriginalLine:    originalLine,
+		OriginalColumn:  originalColumn,
+	}
+	p.sourceMap = appendMapping(p.sourceMap, lastByte, p.prevState, currentState)
+	p.prevState = currentState
+	p.hasPrevState = true
+}
+
+// Scan over the printed text since the last source mapping and update the
+// generated line and column numbers
+func (p *printer) updateGeneratedLineAndColumn() {
+	text := p.sb.String()
+	for _, c := range text[p.lastGeneratedUpdate:] {
+		if c == '\n' {
+			p.prevState.GeneratedLine++
+			p.prevState.GeneratedColumn = 0
+			p.generatedColumn = 0
+			p.sourceMap = append(p.sourceMap, ';')
+		} else if c <= 0xFFFF {
+			// Mozilla's "source-map" library counts columns using UTF-16 code units
+			p.generatedColumn++
+		} else {
+			p.generatedColumn += 2
+		}
+	}
+	p.lastGeneratedUpdate = len(text)
+}
+
+func appendMapping(buffer []byte, lastByte byte, prevState js_printer.SourceMapState, currentState js_printer.SourceMapState) []byte {
+	// Put commas in between mappings
+	if lastByte != 0 && lastByte != ';' {
+		buffer = append(buffer, ',')
+	}
+
+	// Record the generated column (the line is recorded using ';' elsewhere)
+	buffer = append(buffer, sourcemap.EncodeVLQ(currentState.GeneratedColumn-prevState.GeneratedColumn)...)
+
+	// Record the generated source
+	buffer = append(buffer, sourcemap.EncodeVLQ(currentState.SourceIndex-prevState.SourceIndex)...)
+
+	// Record the original line
+	buffer = append(buffer, sourcemap.EncodeVLQ(currentState.OriginalLine-prevState.OriginalLine)...)
+
+	// Record the original column
+	buffer = append(buffer, sourcemap.EncodeVLQ(currentState.OriginalColumn-prevState.OriginalColumn)...)
+	return buffer
+}
+
 func bestQuoteCharForString(text string, forURL bool) rune {
 	forURLCost := 0
 	singleCost := 2
@@ -31,8 +31,8 @@ func expectPrintedCommon(t *testing.T, name string, contents string, expected st
 			}
 		}
 		assertEqual(t, text, "")
-		css := Print(tree, options)
-		assertEqual(t, string(css), expected)
+		result := Print(tree, options)
+		assertEqual(t, result.CSS, expected)
 	})
 }
 
@@ -132,6 +132,9 @@ func TestNestedSelector(t *testing.T) {
 	expectPrintedMinify(t, "a { &b {} }", "a{&b{}}")
 	expectPrintedMinify(t, "a { & b {} }", "a{& b{}}")
 	expectPrintedMinify(t, "a { & :b {} }", "a{& :b{}}")
+	expectPrintedMinify(t, "a { .b & {} }", "a{.b &{}}")
+	expectPrintedMinify(t, "a { > b {} }", "a{>b{}}")
+	expectPrintedMinify(t, "a { .b, > c {} }", "a{.b,>c{}}")
 }
 
 func TestBadQualifiedRules(t *testing.T) {
@@ -322,6 +325,16 @@ func TestAtMedia(t *testing.T) {
 	expectPrintedMinify(t, "@media screen{div{color:red}}", "@media screen{div{color:red}}")
 }
 
+func TestAtLayer(t *testing.T) {
+	expectPrinted(t, "@layer a, b.c;", "@layer a, b.c;\n")
+	expectPrinted(t, "@layer a { div { color: red } }", "@layer a {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer { div { color: red } }", "@layer {\n  div {\n    color: red;\n  }\n}\n")
+	expectPrintedMinify(t, "@layer a, b.c;", "@layer a,b.c;")
+	expectPrintedMinify(t, "@layer a { div { color: red } }", "@layer a{div{color:red}}")
+	expectPrintedMinify(t, "@layer { div { color: red } }", "@layer{div{color:red}}")
+	expectPrintedMinify(t, "@layer \\61 { div { color: red } }", "@layer a{div{color:red}}")
+}
+
 func TestAtFontFace(t *testing.T) {
 	expectPrinted(t, "@font-face { font-family: 'Open Sans'; src: url('OpenSans.woff') format('woff') }",
 		"@font-face {\n  font-family: \"Open Sans\";\n  src: url(OpenSans.woff) format(\"woff\");\n}\n")
@@ -0,0 +1,288 @@
+// This is a wrapper around another "fs" implementation that layers in-memory
+// file contents on top of it. This lets editors build unsaved buffers and lets
+// tools build generated files without writing them to disk first. Directories
+// that contain overlay files are merged with the directories underneath them.
+
+package fs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// This holds the in-memory files and is safe to mutate while a build is
+// running. Each build takes a snapshot of it when the file system is wrapped so
+// that a single build always observes a consistent set of files.
+type Overlay struct {
+	mutex      sync.Mutex
+	files      map[string]overlayFile
+	generation uint64
+}
+
+type overlayFile struct {
+	contents string
+	version  uint64
+}
+
+// Versions are unique across all overlays so that modification keys made from
+// them can't collide even if the same cache is used with a different overlay
+var overlayVersion uint64
+
+func NewOverlay() *Overlay {
+	return &Overlay{files: make(map[string]overlayFile)}
+}
+
+// Relative paths are relative to the working directory of the build. Writing
+// a file replaces any previous contents.
+func (o *Overlay) WriteFile(path string, contents string) {
+	version := atomic.AddUint64(&overlayVersion, 1)
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.files[path] = overlayFile{contents: contents, version: version}
+	o.generation++
+}
+
+// This makes the file underneath the overlay visible again, if there is one
+func (o *Overlay) RemoveFile(path string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, ok := o.files[path]; ok {
+		delete(o.files, path)
+		o.generation++
+	}
+}
+
+type overlayFS struct {
+	inner   FS
+	overlay *Overlay
+
+	// These are from the snapshot of the overlay and are never mutated
+	files map[string]overlayFile
+	dirs  map[string]map[string]EntryKind
+
+	watchMutex   sync.Mutex
+	watchedFiles map[string]bool
+	watchedDirs  map[string]bool
+
+	// This is the latest state of the overlay, which is used to detect changes
+	currentMutex      sync.Mutex
+	currentGeneration uint64
+	currentFiles      map[string]overlayFile
+	currentDirs       map[string]map[string]EntryKind
+}
+
+func WrapWithOverlay(inner FS, overlay *Overlay) FS {
+	fs := &overlayFS{
+		inner:        inner,
+		overlay:      overlay,
+		watchedFiles: make(map[string]bool),
+		watchedDirs:  make(map[string]bool),
+	}
+	fs.files, fs.dirs = fs.current()
+	return fs
+}
+
+func (fs *overlayFS) current() (map[string]overlayFile, map[string]map[string]EntryKind) {
+	fs.currentMutex.Lock()
+	defer fs.currentMutex.Unlock()
+
+	fs.overlay.mutex.Lock()
+	if fs.currentFiles != nil && fs.currentGeneration == fs.overlay.generation {
+		fs.overlay.mutex.Unlock()
+		return fs.currentFiles, fs.currentDirs
+	}
+
+	// Paths are converted to the same form that the resolver uses
+	files := make(map[string]overlayFile, len(fs.overlay.files))
+	for path, file := range fs.overlay.files {
+		if fs.inner.IsAbs(path) {
+			path = fs.inner.Join(path)
+		} else {
+			path = fs.inner.Join(fs.inner.Cwd(), path)
+		}
+		files[path] = file
+	}
+	fs.currentGeneration = fs.overlay.generation
+	fs.overlay.mutex.Unlock()
+
+	fs.currentFiles = files
+	fs.currentDirs = overlayDirs(fs.inner, files)
+	return fs.currentFiles, fs.currentDirs
+}
+
+// This returns the entries that the overlay adds to each directory. Every
+// directory above an overlay file is implied to exist.
+func overlayDirs(fs FS, files map[string]overlayFile) map[string]map[string]EntryKind {
+	dirs := make(map[string]map[string]EntryKind)
+	for path := range files {
+		kind := FileEntry
+		for {
+			dir := fs.Dir(path)
+			if dir == path {
+				break
+			}
+			entries := dirs[dir]
+			if entries == nil {
+				entries = make(map[string]EntryKind)
+				dirs[dir] = entries
+			}
+			entries[fs.Base(path)] = kind
+			path = dir
+			kind = DirEntry
+		}
+	}
+	return dirs
+}
+
+func (fs *overlayFS) ReadDirectory(path string) (DirEntries, error, error) {
+	fs.watchMutex.Lock()
+	fs.watchedDirs[path] = true
+	fs.watchMutex.Unlock()
+
+	entries, canonicalError, originalError := fs.inner.ReadDirectory(path)
+	children := fs.dirs[path]
+	if len(children) == 0 {
+		return entries, canonicalError, originalError
+	}
+
+	// The inner entries are cached, so they must be copied instead of mutated
+	merged := MakeEmptyDirEntries(path)
+	if canonicalError == nil {
+		for key, entry := range entries.data {
+			merged.data[key] = entry
+		}
+	}
+	for base, kind := range children {
+		merged.data[strings.ToLower(base)] = &Entry{dir: path, base: base, kind: kind}
+	}
+	return merged, nil, nil
+}
+
+func (fs *overlayFS) ReadFile(path string) (string, error, error) {
+	fs.watchMutex.Lock()
+	fs.watchedFiles[path] = true
+	fs.watchMutex.Unlock()
+
+	if file, ok := fs.files[path]; ok {
+		return file.contents, nil, nil
+	}
+	if _, ok := fs.dirs[path]; ok {
+		return "", syscall.EISDIR, syscall.EISDIR
+	}
+	return fs.inner.ReadFile(path)
+}
+
+func (fs *overlayFS) ModKey(path string) (ModKey, error) {
+	if file, ok := fs.files[path]; ok {
+		// Real files always have a non-zero mode, so these can't collide with them
+		return ModKey{inode: file.version, size: int64(len(file.contents))}, nil
+	}
+	return fs.inner.ModKey(path)
+}
+
+func (fs *overlayFS) IsAbs(path string) bool {
+	return fs.inner.IsAbs(path)
+}
+
+func (fs *overlayFS) Abs(path string) (string, bool) {
+	return fs.inner.Abs(path)
+}
+
+func (fs *overlayFS) Dir(path string) string {
+	return fs.inner.Dir(path)
+}
+
+func (fs *overlayFS) Base(path string) string {
+	return fs.inner.Base(path)
+}
+
+func (fs *overlayFS) Ext(path string) string {
+	return fs.inner.Ext(path)
+}
+
+func (fs *overlayFS) Join(parts ...string) string {
+	return fs.inner.Join(parts...)
+}
+
+func (fs *overlayFS) Cwd() string {
+	return fs.inner.Cwd()
+}
+
+func (fs *overlayFS) Rel(base string, target string) (string, bool) {
+	return fs.inner.Rel(base, target)
+}
+
+func (fs *overlayFS) kind(dir string, base string) (symlink string, kind EntryKind) {
+	if kind, ok := fs.dirs[dir][base]; ok {
+		return "", kind
+	}
+	return fs.inner.kind(dir, base)
+}
+
+// Changes to the overlay are detected by comparing the snapshot that this
+// build used against the current state of the overlay
+func (fs *overlayFS) WatchData() WatchData {
+	paths := fs.inner.WatchData().Paths
+	if paths == nil {
+		paths = make(map[string]func() bool)
+	}
+
+	fs.watchMutex.Lock()
+	defer fs.watchMutex.Unlock()
+
+	for path := range fs.watchedFiles {
+		// Each closure below needs its own copy of these loop variables
+		path := path
+		old, wasInOverlay := fs.files[path]
+		inner := paths[path]
+
+		paths[path] = func() bool {
+			files, _ := fs.current()
+			file, isInOverlay := files[path]
+			if isInOverlay != wasInOverlay || file.version != old.version {
+				return true
+			}
+			return !isInOverlay && inner != nil && inner()
+		}
+	}
+
+	for path := range fs.watchedDirs {
+		// Each closure below needs its own copy of these loop variables
+		path := path
+		old := sortedOverlayNames(fs.dirs[path])
+		inner := paths[path]
+
+		paths[path] = func() bool {
+			_, dirs := fs.current()
+			names := sortedOverlayNames(dirs[path])
+			if len(names) != len(old) {
+				return true
+			}
+			for i, name := range names {
+				if name != old[i] {
+					return true
+				}
+			}
+			return inner != nil && inner()
+		}
+	}
+
+	return WatchData{Paths: paths}
+}
+
+// The kind is included so that replacing a file with a directory is a change
+func sortedOverlayNames(entries map[string]EntryKind) []string {
+	names := make([]string, 0, len(entries))
+	for name, kind := range entries {
+		if kind == DirEntry {
+			names = append(names, name+"/")
+		} else {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
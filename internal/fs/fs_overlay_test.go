@@ -0,0 +1,142 @@
+package fs
+
+import (
+	"testing"
+)
+
+func TestOverlayFSBasic(t *testing.T) {
+	overlay := NewOverlay()
+	overlay.WriteFile("/src/index.js", "// overlay index.js")
+	overlay.WriteFile("/src/gen/types.js", "// types.js")
+	overlay.WriteFile("/out/../new/file.js", "// file.js")
+	fs := WrapWithOverlay(MockFS(map[string]string{
+		"/src/index.js": "// index.js",
+		"/src/util.js":  "// util.js",
+	}), overlay)
+
+	// Test a file that is replaced by the overlay
+	index, err, _ := fs.ReadFile("/src/index.js")
+	if err != nil || index != "// overlay index.js" {
+		t.Fatalf("Incorrect contents for /src/index.js: %q", index)
+	}
+
+	// Test a file that is only on the file system
+	util, err, _ := fs.ReadFile("/src/util.js")
+	if err != nil || util != "// util.js" {
+		t.Fatalf("Incorrect contents for /src/util.js: %q", util)
+	}
+
+	// Test a file in a directory that is only in the overlay
+	file, err, _ := fs.ReadFile("/new/file.js")
+	if err != nil || file != "// file.js" {
+		t.Fatalf("Incorrect contents for /new/file.js: %q", file)
+	}
+
+	// Test a directory that is merged with the overlay
+	src, err, _ := fs.ReadDirectory("/src")
+	if err != nil {
+		t.Fatal("Expected to find /src")
+	}
+	indexEntry, _ := src.Get("index.js")
+	utilEntry, _ := src.Get("util.js")
+	genEntry, _ := src.Get("gen")
+	if len(src.data) != 3 ||
+		indexEntry == nil || indexEntry.Kind(fs) != FileEntry ||
+		utilEntry == nil || utilEntry.Kind(fs) != FileEntry ||
+		genEntry == nil || genEntry.Kind(fs) != DirEntry {
+		t.Fatalf("Incorrect contents for /src: %v", src)
+	}
+
+	// Test a directory that is only in the overlay
+	gen, err, _ := fs.ReadDirectory("/src/gen")
+	if err != nil {
+		t.Fatal("Expected to find /src/gen")
+	}
+	typesEntry, _ := gen.Get("types.js")
+	if len(gen.data) != 1 || typesEntry == nil || typesEntry.Kind(fs) != FileEntry {
+		t.Fatalf("Incorrect contents for /src/gen: %v", gen)
+	}
+
+	// Test a missing directory
+	_, err, _ = fs.ReadDirectory("/missing")
+	if err == nil {
+		t.Fatal("Unexpectedly found /missing")
+	}
+
+	// Changes after the file system was wrapped are not visible to it
+	overlay.WriteFile("/src/util.js", "// overlay util.js")
+	util, err, _ = fs.ReadFile("/src/util.js")
+	if err != nil || util != "// util.js" {
+		t.Fatalf("Incorrect contents for /src/util.js: %q", util)
+	}
+}
+
+func TestOverlayFSModKey(t *testing.T) {
+	overlay := NewOverlay()
+	overlay.WriteFile("/index.js", "// index.js")
+	first, err := WrapWithOverlay(MockFS(map[string]string{}), overlay).ModKey("/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlay.WriteFile("/index.js", "// index.js")
+	second, err := WrapWithOverlay(MockFS(map[string]string{}), overlay).ModKey("/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("Expected writing to a file to change its modification key")
+	}
+}
+
+func TestOverlayFSWatchData(t *testing.T) {
+	overlay := NewOverlay()
+	overlay.WriteFile("/src/index.js", "// index.js")
+	fs := WrapWithOverlay(MockFS(map[string]string{
+		"/src/util.js": "// util.js",
+	}), overlay)
+	fs.ReadFile("/src/index.js")
+	fs.ReadFile("/src/util.js")
+	fs.ReadFile("/src/missing.js")
+	fs.ReadDirectory("/src")
+	fs.ReadDirectory("/lib")
+
+	expect := func(changed ...string) {
+		t.Helper()
+		isChanged := make(map[string]bool)
+		for _, path := range changed {
+			isChanged[path] = true
+		}
+		for path, fn := range fs.WatchData().Paths {
+			if fn() != isChanged[path] {
+				t.Fatalf("Expected %q to have changed=%v", path, isChanged[path])
+			}
+		}
+	}
+	expect()
+
+	// Writing the same contents again is still a change
+	overlay.WriteFile("/src/index.js", "// index.js")
+	expect("/src/index.js")
+
+	// Adding a file changes the file and the directories above it
+	overlay = NewOverlay()
+	overlay.WriteFile("/src/index.js", "// index.js")
+	fs = WrapWithOverlay(fs.(*overlayFS).inner, overlay)
+	fs.ReadFile("/src/index.js")
+	fs.ReadFile("/src/missing.js")
+	fs.ReadDirectory("/src")
+	fs.ReadDirectory("/lib")
+	overlay.WriteFile("/src/missing.js", "// missing.js")
+	overlay.WriteFile("/lib/a/b.js", "// b.js")
+	expect("/src/missing.js", "/src", "/lib")
+
+	// Removing the files again undoes those changes
+	overlay.RemoveFile("/src/missing.js")
+	overlay.RemoveFile("/lib/a/b.js")
+	expect()
+
+	// Removing a file from the overlay is a change
+	overlay.RemoveFile("/src/index.js")
+	expect("/src/index.js", "/src")
+}
@@ -0,0 +1,370 @@
+// This is a wrapper around another "fs" implementation that can also read
+// files and directories inside of zip archives. Yarn's Plug'n'Play mode stores
+// packages as zip files in its cache and refers to the files inside of them
+// using paths such as ".yarn/cache/pkg.zip/node_modules/pkg/index.js".
+//
+// Archives are only looked into once a Yarn PnP manifest has been found, since
+// other builds shouldn't pay for reading any ".zip" file that happens to be on
+// a missing path.
+
+package fs
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+type zipFS struct {
+	inner FS
+
+	// This is set to 1 by "EnableZipArchives"
+	isEnabled int32
+
+	zipFilesMutex sync.Mutex
+	zipFiles      map[string]*zipFile
+}
+
+type zipFile struct {
+	// Archives are indexed the first time they are used. This happens outside
+	// of "zipFilesMutex" so that indexing one doesn't block other lookups.
+	once sync.Once
+
+	reader *zip.Reader
+	err    error
+
+	dirs  map[string]*compressedDir
+	files map[string]*compressedFile
+}
+
+type compressedDir struct {
+	entries map[string]EntryKind
+}
+
+type compressedFile struct {
+	compressed *zip.File
+
+	mutex    sync.Mutex
+	contents string
+	err      error
+	wasRead  bool
+}
+
+func WrapWithZip(inner FS) FS {
+	return &zipFS{
+		inner:    inner,
+		zipFiles: make(map[string]*zipFile),
+	}
+}
+
+// This is called by the resolver when it finds a Yarn PnP manifest. It does
+// nothing if the file system wasn't wrapped using "WrapWithZip".
+func EnableZipArchives(fs FS) {
+	if zipFS, ok := fs.(*zipFS); ok {
+		atomic.StoreInt32(&zipFS.isEnabled, 1)
+	}
+}
+
+func (fs *zipFS) isZipEnabled() bool {
+	return atomic.LoadInt32(&fs.isEnabled) != 0
+}
+
+func (fs *zipFS) checkForZip(path string, kind EntryKind) (*zipFile, string) {
+	var zipPath string
+	var pathTail string
+
+	// Do a quick check for a ".zip" in the path at all
+	path = strings.ReplaceAll(path, "\\", "/")
+	if i := strings.Index(path, ".zip/"); i != -1 {
+		zipPath = path[:i+len(".zip")]
+		pathTail = path[i+len(".zip/"):]
+	} else if kind == DirEntry && strings.HasSuffix(path, ".zip") {
+		zipPath = path
+	} else {
+		return nil, ""
+	}
+
+	// If there is one, then check whether it's a file on the file system or not
+	fs.zipFilesMutex.Lock()
+	archive := fs.zipFiles[zipPath]
+	if archive == nil {
+		archive = &zipFile{}
+		fs.zipFiles[zipPath] = archive
+	}
+	fs.zipFilesMutex.Unlock()
+
+	archive.once.Do(func() { fs.indexZip(archive, zipPath) })
+	if archive.err != nil {
+		return nil, ""
+	}
+	return archive, pathTail
+}
+
+func (fs *zipFS) indexZip(archive *zipFile, zipPath string) {
+	contents, err, _ := fs.inner.ReadFile(zipPath)
+	if err != nil {
+		archive.err = err
+		return
+	}
+	reader, err := zip.NewReader(strings.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		archive.err = err
+		return
+	}
+	archive.reader = reader
+	archive.dirs = make(map[string]*compressedDir)
+	archive.files = make(map[string]*compressedFile)
+
+	// Build an index of all files in the archive
+	archive.dirs[""] = &compressedDir{entries: make(map[string]EntryKind)}
+	for _, file := range reader.File {
+		name := strings.TrimSuffix(file.Name, "/")
+		if name == "" {
+			continue
+		}
+		if strings.HasSuffix(file.Name, "/") {
+			archive.addDir(name)
+		} else {
+			archive.files[name] = &compressedFile{compressed: file}
+			archive.addEntry(name, FileEntry)
+		}
+	}
+}
+
+func (archive *zipFile) addDir(name string) {
+	if _, ok := archive.dirs[name]; ok {
+		return
+	}
+	archive.dirs[name] = &compressedDir{entries: make(map[string]EntryKind)}
+	archive.addEntry(name, DirEntry)
+}
+
+func (archive *zipFile) addEntry(name string, kind EntryKind) {
+	dir, base := "", name
+	if slash := strings.LastIndexByte(name, '/'); slash != -1 {
+		dir, base = name[:slash], name[slash+1:]
+		archive.addDir(dir)
+	}
+	archive.dirs[dir].entries[base] = kind
+}
+
+func (fs *zipFS) ReadDirectory(path string) (entries DirEntries, canonicalError error, originalError error) {
+	if !fs.isZipEnabled() {
+		return fs.inner.ReadDirectory(path)
+	}
+	path = mangleYarnPnPVirtualPath(path)
+
+	entries, canonicalError, originalError = fs.inner.ReadDirectory(path)
+	if canonicalError != syscall.ENOENT && canonicalError != syscall.ENOTDIR {
+		return
+	}
+
+	// Yarn's virtual directories only exist in paths
+	if isYarnPnPVirtualDir(path) {
+		return MakeEmptyDirEntries(path), nil, nil
+	}
+
+	// If the directory doesn't exist, try reading from an enclosing zip archive
+	archive, pathTail := fs.checkForZip(path, DirEntry)
+	if archive == nil {
+		return
+	}
+	dir, ok := archive.dirs[pathTail]
+	if !ok {
+		return
+	}
+
+	entries = MakeEmptyDirEntries(path)
+	for base, kind := range dir.entries {
+		entries.data[strings.ToLower(base)] = &Entry{
+			dir:  path,
+			base: base,
+			kind: kind,
+		}
+	}
+	return entries, nil, nil
+}
+
+func (fs *zipFS) ReadFile(path string) (contents string, canonicalError error, originalError error) {
+	if !fs.isZipEnabled() {
+		return fs.inner.ReadFile(path)
+	}
+	path = mangleYarnPnPVirtualPath(path)
+
+	contents, canonicalError, originalError = fs.inner.ReadFile(path)
+	if canonicalError != syscall.ENOENT && canonicalError != syscall.ENOTDIR {
+		return
+	}
+
+	// If the file doesn't exist, try reading from an enclosing zip archive
+	archive, pathTail := fs.checkForZip(path, FileEntry)
+	if archive == nil {
+		return
+	}
+	file, ok := archive.files[pathTail]
+	if !ok {
+		return
+	}
+
+	// Decompress the file the first time it's read
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if !file.wasRead {
+		file.wasRead = true
+		reader, err := file.compressed.Open()
+		if err != nil {
+			file.err = err
+		} else {
+			bytes, err := ioutil.ReadAll(reader)
+			reader.Close()
+			file.contents = string(bytes)
+			file.err = err
+		}
+	}
+	if file.err != nil {
+		return "", file.err, file.err
+	}
+	return file.contents, nil, nil
+}
+
+func (fs *zipFS) ModKey(path string) (ModKey, error) {
+	if !fs.isZipEnabled() {
+		return fs.inner.ModKey(path)
+	}
+	path = mangleYarnPnPVirtualPath(path)
+
+	// Files inside of a zip archive change when the archive itself changes
+	if i := strings.Index(strings.ReplaceAll(path, "\\", "/"), ".zip/"); i != -1 {
+		path = path[:i+len(".zip")]
+	}
+	return fs.inner.ModKey(path)
+}
+
+func (fs *zipFS) IsAbs(path string) bool {
+	return fs.inner.IsAbs(path)
+}
+
+func (fs *zipFS) Abs(path string) (string, bool) {
+	return fs.inner.Abs(path)
+}
+
+func (fs *zipFS) Dir(path string) string {
+	return fs.inner.Dir(path)
+}
+
+func (fs *zipFS) Base(path string) string {
+	return fs.inner.Base(path)
+}
+
+func (fs *zipFS) Ext(path string) string {
+	return fs.inner.Ext(path)
+}
+
+func (fs *zipFS) Join(parts ...string) string {
+	return fs.inner.Join(parts...)
+}
+
+func (fs *zipFS) Cwd() string {
+	return fs.inner.Cwd()
+}
+
+func (fs *zipFS) Rel(base string, target string) (string, bool) {
+	return fs.inner.Rel(base, target)
+}
+
+func (fs *zipFS) kind(dir string, base string) (symlink string, kind EntryKind) {
+	if !fs.isZipEnabled() {
+		return fs.inner.kind(dir, base)
+	}
+	return fs.inner.kind(mangleYarnPnPVirtualPath(dir), base)
+}
+
+func (fs *zipFS) WatchData() WatchData {
+	return fs.inner.WatchData()
+}
+
+// Yarn uses virtual paths to give packages with peer dependencies a different
+// identity for each set of peer dependencies. These paths don't exist on the
+// file system. They have the form "base/__virtual__/hash/n/subpath", which
+// refers to the path "subpath" relative to "base" after going up "n" levels.
+//
+// Reference: https://yarnpkg.com/advanced/pnp-spec#virtual-folders
+func mangleYarnPnPVirtualPath(path string) string {
+	start, end, depth, ok := parseYarnPnPVirtualPath(path)
+	if !ok {
+		return path
+	}
+
+	// Go up "depth" levels from the directory containing "__virtual__"
+	base := path[:start]
+	for i := 0; i < depth; i++ {
+		if slash := strings.LastIndexAny(base, "/\\"); slash != -1 {
+			base = base[:slash]
+		}
+	}
+	if end == len(path) {
+		return base
+	}
+	return base + path[end:]
+}
+
+// This returns the range of "/__virtual__/hash/n" in the path and the number
+// "n", if there is one
+func parseYarnPnPVirtualPath(path string) (start int, end int, depth int, ok bool) {
+	i := 0
+	for {
+		start = i
+		slash := strings.IndexAny(path[i:], "/\\")
+		if slash == -1 {
+			return
+		}
+		i += slash + 1
+
+		// Look for the "__virtual__" segment
+		if !strings.HasPrefix(path[i:], "__virtual__") {
+			continue
+		}
+		rest := path[i+len("__virtual__"):]
+		if rest == "" || (rest[0] != '/' && rest[0] != '\\') {
+			continue
+		}
+		start = i - 1
+
+		// Skip over the hash segment
+		rest = rest[1:]
+		slash = strings.IndexAny(rest, "/\\")
+		if slash == -1 {
+			return
+		}
+		rest = rest[slash+1:]
+
+		// Parse the depth segment
+		slash = strings.IndexAny(rest, "/\\")
+		if slash == -1 {
+			slash = len(rest)
+		}
+		n, err := strconv.Atoi(rest[:slash])
+		if err != nil || n < 0 {
+			return
+		}
+		end = len(path) - len(rest) + slash
+		return start, end, n, true
+	}
+}
+
+// Returns true for "base/__virtual__" and "base/__virtual__/hash", which are
+// the directories above the ones that can be mapped to real directories
+func isYarnPnPVirtualDir(path string) bool {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if strings.HasSuffix(path, "/__virtual__") {
+		return true
+	}
+	if slash := strings.LastIndexByte(path, '/'); slash != -1 {
+		return strings.HasSuffix(path[:slash], "/__virtual__")
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package fs
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func makeZipForTest(t *testing.T, files map[string]string) string {
+	t.Helper()
+	buffer := bytes.Buffer{}
+	writer := zip.NewWriter(&buffer)
+	for name, contents := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestZipFSBasic(t *testing.T) {
+	fs := WrapWithZip(MockFS(map[string]string{
+		"/README.md": "// README.md",
+		"/cache/pkg.zip": makeZipForTest(t, map[string]string{
+			"node_modules/pkg/package.json": "{}",
+			"node_modules/pkg/index.js":     "// index.js",
+			"node_modules/pkg/lib/util.js":  "// util.js",
+		}),
+	}))
+	EnableZipArchives(fs)
+
+	// Test a file outside of the archive
+	readme, err, _ := fs.ReadFile("/README.md")
+	if err != nil || readme != "// README.md" {
+		t.Fatalf("Incorrect contents for /README.md: %q", readme)
+	}
+
+	// Test a file inside of the archive
+	index, err, _ := fs.ReadFile("/cache/pkg.zip/node_modules/pkg/index.js")
+	if err != nil || index != "// index.js" {
+		t.Fatalf("Incorrect contents for index.js: %q", index)
+	}
+
+	// Test a missing file inside of the archive
+	_, err, _ = fs.ReadFile("/cache/pkg.zip/node_modules/pkg/missing.js")
+	if err == nil {
+		t.Fatal("Unexpectedly found missing.js")
+	}
+
+	// Test the archive itself as a directory
+	root, err, _ := fs.ReadDirectory("/cache/pkg.zip")
+	if err != nil {
+		t.Fatal("Expected to find /cache/pkg.zip")
+	}
+	nodeModulesEntry, _ := root.Get("node_modules")
+	if len(root.data) != 1 || nodeModulesEntry == nil || nodeModulesEntry.Kind(fs) != DirEntry {
+		t.Fatalf("Incorrect contents for /cache/pkg.zip: %v", root)
+	}
+
+	// Test a nested directory inside of the archive
+	pkg, err, _ := fs.ReadDirectory("/cache/pkg.zip/node_modules/pkg")
+	if err != nil {
+		t.Fatal("Expected to find /cache/pkg.zip/node_modules/pkg")
+	}
+	packageEntry, _ := pkg.Get("package.json")
+	indexEntry, _ := pkg.Get("index.js")
+	libEntry, _ := pkg.Get("lib")
+	if len(pkg.data) != 3 ||
+		packageEntry == nil || packageEntry.Kind(fs) != FileEntry ||
+		indexEntry == nil || indexEntry.Kind(fs) != FileEntry ||
+		libEntry == nil || libEntry.Kind(fs) != DirEntry {
+		t.Fatalf("Incorrect contents for /cache/pkg.zip/node_modules/pkg: %v", pkg)
+	}
+
+	// Test a missing directory inside of the archive
+	_, err, _ = fs.ReadDirectory("/cache/pkg.zip/missing")
+	if err == nil {
+		t.Fatal("Unexpectedly found /cache/pkg.zip/missing")
+	}
+}
+
+func TestZipFSDisabled(t *testing.T) {
+	fs := WrapWithZip(MockFS(map[string]string{
+		"/cache/pkg.zip": makeZipForTest(t, map[string]string{
+			"node_modules/pkg/index.js": "// index.js",
+		}),
+	}))
+
+	// Archives are ignored until a Yarn PnP manifest has been found
+	if _, err, _ := fs.ReadFile("/cache/pkg.zip/node_modules/pkg/index.js"); err == nil {
+		t.Fatal("Unexpectedly read from the archive before it was enabled")
+	}
+	if _, err, _ := fs.ReadDirectory("/cache/pkg.zip"); err == nil {
+		t.Fatal("Unexpectedly found /cache/pkg.zip before it was enabled")
+	}
+
+	EnableZipArchives(fs)
+	index, err, _ := fs.ReadFile("/cache/pkg.zip/node_modules/pkg/index.js")
+	if err != nil || index != "// index.js" {
+		t.Fatalf("Incorrect contents for index.js: %q", index)
+	}
+}
+
+func TestZipFSYarnPnPVirtualPaths(t *testing.T) {
+	fs := WrapWithZip(MockFS(map[string]string{
+		"/project/.yarn/cache/pkg.zip": makeZipForTest(t, map[string]string{
+			"node_modules/pkg/index.js": "// index.js",
+		}),
+		"/project/src/file.js": "// file.js",
+	}))
+	EnableZipArchives(fs)
+
+	index, err, _ := fs.ReadFile("/project/.yarn/__virtual__/pkg-virtual-123/0/cache/pkg.zip/node_modules/pkg/index.js")
+	if err != nil || index != "// index.js" {
+		t.Fatalf("Incorrect contents for index.js: %q", index)
+	}
+
+	file, err, _ := fs.ReadFile("/project/.yarn/__virtual__/pkg-virtual-123/1/src/file.js")
+	if err != nil || file != "// file.js" {
+		t.Fatalf("Incorrect contents for file.js: %q", file)
+	}
+
+	// The directories above the mapped directories should look empty
+	for _, dir := range []string{"/project/.yarn/__virtual__", "/project/.yarn/__virtual__/pkg-virtual-123"} {
+		entries, err, _ := fs.ReadDirectory(dir)
+		if err != nil || entries.Len() != 0 {
+			t.Fatalf("Expected %q to be an empty directory", dir)
+		}
+	}
+
+	expect := func(input string, output string) {
+		t.Helper()
+		if actual := mangleYarnPnPVirtualPath(input); actual != output {
+			t.Fatalf("Expected %q to map to %q, got %q", input, output, actual)
+		}
+	}
+	expect("/a/__virtual__/x/0/b", "/a/b")
+	expect("/a/b/__virtual__/x/1/c", "/a/c")
+	expect("/a/b/__virtual__/x/2/c/d", "/c/d")
+	expect("/a/__virtual__/x/0", "/a")
+	expect("/a/__virtual__/x", "/a/__virtual__/x")
+	expect("/a/__virtual__/x/y/b", "/a/__virtual__/x/y/b")
+	expect("/a/__virtual__x/0/b", "/a/__virtual__x/0/b")
+}
@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// This records how long each phase of a build takes and which goroutine it
+// ran on. The result is a JSON file in Chrome's trace event format, which can
+// be opened in "chrome://tracing" or https://ui.perfetto.dev to see where the
+// time went and how much of the work ran in parallel.
+//
+// All methods can be called on a nil tracer, in which case nothing is
+// recorded. That way the code being traced doesn't need to check whether
+// tracing is enabled.
+type Tracer struct {
+	start  time.Time
+	mutex  sync.Mutex
+	events []traceEvent
+}
+
+type traceEvent struct {
+	name      string
+	path      string
+	goroutine uint64
+	start     time.Duration
+	duration  time.Duration
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{start: time.Now()}
+}
+
+type TraceSpan struct {
+	tracer    *Tracer
+	name      string
+	path      string
+	goroutine uint64
+	start     time.Time
+}
+
+// This is meant to be used like "defer tracer.Begin(name, path).End()". The
+// path is optional and is usually the file being processed.
+func (t *Tracer) Begin(name string, path string) TraceSpan {
+	if t == nil {
+		return TraceSpan{}
+	}
+	return TraceSpan{
+		tracer:    t,
+		name:      name,
+		path:      path,
+		goroutine: goroutineID(),
+		start:     time.Now(),
+	}
+}
+
+func (s TraceSpan) End() {
+	t := s.tracer
+	if t == nil {
+		return
+	}
+	event := traceEvent{
+		name:      s.name,
+		path:      s.path,
+		goroutine: s.goroutine,
+		start:     s.start.Sub(t.start),
+		duration:  time.Since(s.start),
+	}
+	t.mutex.Lock()
+	t.events = append(t.events, event)
+	t.mutex.Unlock()
+}
+
+// Go deliberately doesn't expose goroutine ids, but they are the first thing
+// in the header of the goroutine's stack trace: "goroutine 123 [running]:"
+func goroutineID() uint64 {
+	var buffer [64]byte
+	header := buffer[:runtime.Stack(buffer[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	if space := bytes.IndexByte(header, ' '); space != -1 {
+		header = header[:space]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
+}
+
+type jsonTraceEvent struct {
+	Name      string            `json:"name"`
+	Phase     string            `json:"ph"`
+	Timestamp int64             `json:"ts"`
+	Duration  int64             `json:"dur"`
+	PID       int               `json:"pid"`
+	TID       uint64            `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+// Events use the "complete" phase and microsecond timestamps. Each goroutine
+// is shown as its own thread.
+func (t *Tracer) JSON() []byte {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	events := append([]traceEvent{}, t.events...)
+	t.mutex.Unlock()
+
+	// Sort for determinism
+	sort.SliceStable(events, func(i int, j int) bool {
+		a, b := events[i], events[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return a.duration > b.duration
+	})
+
+	jsonEvents := []jsonTraceEvent{{
+		Name:  "process_name",
+		Phase: "M",
+		PID:   1,
+		Args:  map[string]string{"name": "esbuild"},
+	}}
+	namedGoroutines := make(map[uint64]bool)
+	for _, event := range events {
+		if !namedGoroutines[event.goroutine] {
+			namedGoroutines[event.goroutine] = true
+			jsonEvents = append(jsonEvents, jsonTraceEvent{
+				Name:  "thread_name",
+				Phase: "M",
+				PID:   1,
+				TID:   event.goroutine,
+				Args:  map[string]string{"name": "goroutine " + strconv.FormatUint(event.goroutine, 10)},
+			})
+		}
+		var args map[string]string
+		if event.path != "" {
+			args = map[string]string{"path": event.path}
+		}
+		jsonEvents = append(jsonEvents, jsonTraceEvent{
+			Name:      event.name,
+			Phase:     "X",
+			Timestamp: event.start.Microseconds(),
+			Duration:  event.duration.Microseconds(),
+			PID:       1,
+			TID:       event.goroutine,
+			Args:      args,
+		})
+	}
+
+	result, _ := json.Marshal(struct {
+		TraceEvents     []jsonTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string           `json:"displayTimeUnit"`
+	}{jsonEvents, "ms"})
+	return result
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type parsedTrace struct {
+	TraceEvents []struct {
+		Name      string            `json:"name"`
+		Phase     string            `json:"ph"`
+		Timestamp int64             `json:"ts"`
+		Duration  int64             `json:"dur"`
+		PID       int               `json:"pid"`
+		TID       uint64            `json:"tid"`
+		Args      map[string]string `json:"args"`
+	} `json:"traceEvents"`
+	DisplayTimeUnit string `json:"displayTimeUnit"`
+}
+
+func parseTrace(t *testing.T, tracer *Tracer) parsedTrace {
+	t.Helper()
+	var trace parsedTrace
+	if err := json.Unmarshal(tracer.JSON(), &trace); err != nil {
+		t.Fatalf("Invalid trace JSON: %s", err)
+	}
+	return trace
+}
+
+func TestTracerNil(t *testing.T) {
+	var tracer *Tracer
+	tracer.Begin("name", "path").End()
+	if data := tracer.JSON(); data != nil {
+		t.Fatalf("Expected no JSON, got %s", data)
+	}
+}
+
+func TestTracerNesting(t *testing.T) {
+	tracer := NewTracer()
+	outer := tracer.Begin("outer", "")
+	time.Sleep(time.Millisecond)
+	inner := tracer.Begin("inner", "/file.js")
+	time.Sleep(time.Millisecond)
+	inner.End()
+	outer.End()
+
+	trace := parseTrace(t, tracer)
+	if trace.DisplayTimeUnit != "ms" {
+		t.Fatalf("Unexpected display time unit: %q", trace.DisplayTimeUnit)
+	}
+	events := trace.TraceEvents
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %v", events)
+	}
+
+	// Metadata comes first and names the process and the goroutine
+	if events[0].Phase != "M" || events[0].Name != "process_name" || events[0].Args["name"] != "esbuild" {
+		t.Fatalf("Unexpected process metadata: %v", events[0])
+	}
+	outerEvent, innerEvent := events[2], events[3]
+	if events[1].Phase != "M" || events[1].Name != "thread_name" || events[1].TID != outerEvent.TID {
+		t.Fatalf("Unexpected thread metadata: %v", events[1])
+	}
+
+	// Spans are ordered by start time even though the inner one ended first
+	if outerEvent.Phase != "X" || outerEvent.Name != "outer" || outerEvent.Args != nil {
+		t.Fatalf("Unexpected outer span: %v", outerEvent)
+	}
+	if innerEvent.Phase != "X" || innerEvent.Name != "inner" || innerEvent.Args["path"] != "/file.js" {
+		t.Fatalf("Unexpected inner span: %v", innerEvent)
+	}
+	if outerEvent.TID != innerEvent.TID || outerEvent.PID != 1 || innerEvent.PID != 1 {
+		t.Fatalf("Expected both spans on the same thread: %v %v", outerEvent, innerEvent)
+	}
+
+	// Timestamps are rounded down to microseconds, so allow for that
+	if innerEvent.Timestamp < outerEvent.Timestamp ||
+		innerEvent.Timestamp+innerEvent.Duration > outerEvent.Timestamp+outerEvent.Duration+1 ||
+		innerEvent.Duration < 1000 || outerEvent.Duration < 2000 {
+		t.Fatalf("Expected the inner span to be inside the outer span: %v %v", outerEvent, innerEvent)
+	}
+}
+
+func TestTracerGoroutines(t *testing.T) {
+	tracer := NewTracer()
+	span := tracer.Begin("main", "")
+	done := make(chan struct{})
+	go func() {
+		tracer.Begin("other", "").End()
+		close(done)
+	}()
+	<-done
+	span.End()
+
+	// Each goroutine is shown as its own named thread
+	threads := make(map[uint64]string)
+	spans := make(map[string]uint64)
+	for _, event := range parseTrace(t, tracer).TraceEvents {
+		switch event.Name {
+		case "thread_name":
+			threads[event.TID] = event.Args["name"]
+		case "main", "other":
+			spans[event.Name] = event.TID
+		}
+	}
+	if len(threads) != 2 || spans["main"] == spans["other"] {
+		t.Fatalf("Expected two threads, got %v", threads)
+	}
+	for name, tid := range spans {
+		if threads[tid] == "" {
+			t.Fatalf("Missing thread name for span %q", name)
+		}
+	}
+}
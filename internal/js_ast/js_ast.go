@@ -265,8 +265,14 @@ const (
 	PropertyGet
 	PropertySet
 	PropertySpread
+	PropertyClassStaticBlock
 )
 
+type ClassStaticBlock struct {
+	Loc   logger.Loc
+	Stmts []Stmt
+}
+
 type Property struct {
 	TSDecorators []Expr
 	Key          Expr
@@ -285,6 +291,12 @@ type Property struct {
 	//
 	Initializer *Expr
 
+	// This is only used for class static blocks, which have no key or value:
+	//
+	//   class Foo { static { ... } }
+	//
+	ClassStaticBlock *ClassStaticBlock
+
 	Kind         PropertyKind
 	IsComputed   bool
 	IsMethod     bool
@@ -631,6 +643,7 @@ type ERequireResolve struct {
 
 type EImport struct {
 	Expr              Expr
+	OptionsOrNil      Expr
 	ImportRecordIndex ast.Index32
 
 	// Comments inside "import()" expressions have special meaning for Webpack.
@@ -1508,6 +1521,7 @@ const (
 
 	// The scopes below stop hoisted variables from extending into parent scopes
 	ScopeEntry // This is a module, TypeScript enum, or TypeScript namespace
+	ScopeClassStaticInit
 	ScopeFunctionArgs
 	ScopeFunctionBody
 )
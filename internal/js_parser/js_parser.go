@@ -79,6 +79,21 @@ type parser struct {
 	privateGetters map[js_ast.Ref]js_ast.Ref
 	privateSetters map[js_ast.Ref]js_ast.Ref
 
+	// Private brand checks ("#x in obj") can only be lowered by lowering every
+	// private name, since they need the WeakMap or WeakSet for each name
+	lowerAllPrivateNames bool
+
+	// For lowering classes to functions. This is the parameter of the function
+	// wrapping the class that holds the value of the "extends" clause, and is
+	// only present while visiting the members of a class that is lowered.
+	classSuperRef      *js_ast.Ref
+	isClassSuperStatic bool
+
+	// The constructor of a derived class that is lowered to a function uses
+	// the value returned by the lowered "super()" call instead of "this". This
+	// is only present until the constructor's function is visited.
+	classCtorThisRef *js_ast.Ref
+
 	// These are for TypeScript
 	shouldFoldNumericConstants bool
 	emittedNamespaceVars       map[js_ast.Ref]bool
@@ -225,8 +240,17 @@ type parser struct {
 	resolveCallTarget js_ast.E
 
 	// Temporary variables used for lowering
-	tempRefsToDeclare []tempRef
-	tempRefCount      int
+	tempRefsToDeclare         []tempRef
+	tempRefCount              int
+	topLevelTempRefsToDeclare []tempRef
+	topLevelTempRefCount      int
+
+	// For lowering "let" and "const". Block-scoped symbols that were turned into
+	// function-scoped symbols are tracked since loops that declare them may need
+	// to be moved into a closure.
+	loweredBlockScopedRefs map[js_ast.Ref]bool
+	loweredLoops           []*loweredLoop
+	loweredLoopLabel       js_ast.Ref
 
 	// When bundling, hoisted top-level local variables declared with "var" in
 	// nested scopes are moved up to be declared in the top-level scope instead.
@@ -376,6 +400,25 @@ func stringArraysEqual(a []string, b []string) bool {
 	return true
 }
 
+// This is used by the persistent cache, which can't compare options directly.
+// Injected files are a problem because they refer to other files by source
+// index, so this returns false for them. The defines are also not included
+// because they contain functions. The caller must include the build options
+// that the defines were generated from in its key instead.
+func (a *Options) PersistentCacheKey() (string, bool) {
+	if len(a.injectedFiles) > 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%+v %v %q %q %v %q",
+		a.optionsThatSupportStructuralEquality,
+		a.jsx.Parse,
+		a.jsx.Factory,
+		a.jsx.Fragment,
+		a.CreateSnapshot,
+		a.SnapshotAbsBaseDir,
+	), true
+}
+
 type tempRef struct {
 	ref   js_ast.Ref
 	value *js_ast.Expr
@@ -403,6 +446,9 @@ type fnOrArrowDataParse struct {
 	isConstructor       bool
 	isTypeScriptDeclare bool
 
+	// Class static blocks can't contain "await" or "return"
+	isClassStaticInit bool
+
 	// In TypeScript, forward declarations of functions have no bodies
 	allowMissingBodyForTypeScript bool
 
@@ -418,6 +464,7 @@ type fnOrArrowDataVisit struct {
 
 	isArrow            bool
 	isAsync            bool
+	isGenerator        bool
 	isInsideLoop       bool
 	isInsideSwitch     bool
 	isOutsideFnOrArrow bool
@@ -458,12 +505,27 @@ type fnOnlyDataVisit struct {
 	// replaced with the class name.
 	thisClassStaticRef *js_ast.Ref
 
+	// Inside the constructor of a derived class that is lowered to a function,
+	// "this" expressions should be replaced with the value returned by the
+	// lowered "super()" call. The base class may have returned a different
+	// object than "this" (e.g. "Error" always does). This is also used inside
+	// instance field initializers, which are moved into the constructor.
+	thisClassCtorRef *js_ast.Ref
+
 	// If we're inside an async arrow function and async functions are not
 	// supported, then we will have to convert that arrow function to a generator
 	// function. That means references to "arguments" inside the arrow function
 	// will have to reference a captured variable instead of the real variable.
 	isInsideAsyncArrowFn bool
 
+	// If we're inside a generator function and generators are not supported,
+	// then that generator function will have to be converted to a state machine
+	// that runs inside a nested function. That means references to "arguments"
+	// inside the generator function will have to reference a captured variable
+	// instead of the real variable. The same is true of async functions when
+	// they are converted to generator functions.
+	isInsideLoweredGeneratorFn bool
+
 	// If false, the value for "this" is the top-level module scope "this" value.
 	// That means it's "undefined" for ECMAScript modules and "exports" for
 	// CommonJS modules. We track this information so that we can substitute the
@@ -1270,7 +1332,7 @@ func (p *parser) canMergeSymbols(scope *js_ast.Scope, existing js_ast.SymbolKind
 	// "function foo() {} var foo;"
 	// "function *foo() {} function *foo() {}" but not "{ function *foo() {} function *foo() {} }"
 	if new.IsHoistedOrFunction() && existing.IsHoistedOrFunction() &&
-		(scope.Kind == js_ast.ScopeEntry || scope.Kind == js_ast.ScopeFunctionBody ||
+		(scope.Kind == js_ast.ScopeEntry || scope.Kind == js_ast.ScopeFunctionBody || scope.Kind == js_ast.ScopeClassStaticInit ||
 			(new.IsHoisted() && existing.IsHoisted())) {
 		return mergeKeepExisting
 	}
@@ -1428,7 +1490,8 @@ func (p *parser) hoistSymbols(scope *js_ast.Scope) {
 					//
 					// Is this unbound (i.e. a global access) or also hoisted?
 					if existingSymbol.Kind == js_ast.SymbolUnbound || existingSymbol.Kind == js_ast.SymbolHoisted ||
-						(existingSymbol.Kind.IsFunction() && (s.Kind == js_ast.ScopeEntry || s.Kind == js_ast.ScopeFunctionBody)) {
+						(existingSymbol.Kind.IsFunction() && (s.Kind == js_ast.ScopeEntry ||
+							s.Kind == js_ast.ScopeFunctionBody || s.Kind == js_ast.ScopeClassStaticInit)) {
 						// Silently merge this symbol into the existing symbol
 						symbol.Link = existingMember.Ref
 						s.Members[symbol.OriginalName] = existingMember
@@ -1535,7 +1598,7 @@ func (p *parser) ignoreUsage(ref js_ast.Ref) {
 	// the value is ignored because that's what the TypeScript compiler does.
 }
 
-func (p *parser) callRuntime(loc logger.Loc, name string, args []js_ast.Expr) js_ast.Expr {
+func (p *parser) importFromRuntime(loc logger.Loc, name string) js_ast.Expr {
 	ref, ok := p.runtimeImports[name]
 	if !ok {
 		ref = p.newSymbol(js_ast.SymbolOther, name)
@@ -1543,8 +1606,12 @@ func (p *parser) callRuntime(loc logger.Loc, name string, args []js_ast.Expr) js
 		p.runtimeImports[name] = ref
 	}
 	p.recordUsage(ref)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}}
+}
+
+func (p *parser) callRuntime(loc logger.Loc, name string, args []js_ast.Expr) js_ast.Expr {
 	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
-		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}},
+		Target: p.importFromRuntime(loc, name),
 		Args:   args,
 	}}
 }
@@ -1755,7 +1822,9 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 
 	case js_lexer.TOpenBracket:
 		isComputed = true
-		p.markSyntaxFeature(compat.ObjectExtensions, p.lexer.Range())
+		if !opts.isClass {
+			p.markSyntaxFeature(compat.ObjectExtensions, p.lexer.Range())
+		}
 		p.lexer.Next()
 		wasIdentifier := p.lexer.Token == js_lexer.TIdentifier
 		expr := p.parseExpr(js_ast.LComma)
@@ -1796,6 +1865,15 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 		}
 		p.lexer.Next()
 
+		// Parse a class static block
+		if opts.isClass && kind == js_ast.PropertyNormal && !opts.isStatic && !opts.isAsync && !opts.isGenerator &&
+			raw == "static" && p.lexer.Token == js_lexer.TOpenBrace {
+			if len(opts.tsDecorators) > 0 {
+				p.log.AddRangeError(&p.source, nameRange, "Decorators are not allowed on class static blocks")
+			}
+			return p.parseClassStaticBlock(), true
+		}
+
 		// Support contextual keywords
 		if kind == js_ast.PropertyNormal && !opts.isGenerator {
 			// Does the following token look like a key?
@@ -1827,7 +1905,6 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 					if !opts.isAsync && raw == name && !p.lexer.HasNewlineBefore {
 						opts.isAsync = true
 						opts.asyncRange = nameRange
-						p.markLoweredSyntaxFeature(compat.AsyncAwait, nameRange, compat.Generator)
 						return p.parseProperty(kind, opts, nil)
 					}
 
@@ -1943,7 +2020,7 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 	// Parse a method expression
 	if p.lexer.Token == js_lexer.TOpenParen || kind != js_ast.PropertyNormal ||
 		opts.isClass || opts.isAsync || opts.isGenerator {
-		if p.lexer.Token == js_lexer.TOpenParen && kind != js_ast.PropertyGet && kind != js_ast.PropertySet {
+		if p.lexer.Token == js_lexer.TOpenParen && kind != js_ast.PropertyGet && kind != js_ast.PropertySet && !opts.isClass {
 			p.markSyntaxFeature(compat.ObjectExtensions, p.lexer.Range())
 		}
 		loc := p.lexer.Loc()
@@ -2218,7 +2295,6 @@ func (p *parser) parseAsyncPrefixExpr(asyncRange logger.Range, level js_ast.L) j
 
 		// "async x => {}"
 		case js_lexer.TIdentifier:
-			p.markLoweredSyntaxFeature(compat.AsyncAwait, asyncRange, compat.Generator)
 			ref := p.storeNameInRef(p.lexer.Identifier)
 			arg := js_ast.Arg{Binding: js_ast.Binding{Loc: p.lexer.Loc(), Data: &js_ast.BIdentifier{Ref: ref}}}
 			p.lexer.Next()
@@ -2255,10 +2331,7 @@ func (p *parser) parseFnExpr(loc logger.Loc, isAsync bool, asyncRange logger.Ran
 	p.lexer.Next()
 	isGenerator := p.lexer.Token == js_lexer.TAsterisk
 	if isGenerator {
-		p.markSyntaxFeature(compat.Generator, p.lexer.Range())
 		p.lexer.Next()
-	} else if isAsync {
-		p.markLoweredSyntaxFeature(compat.AsyncAwait, asyncRange, compat.Generator)
 	}
 	var name *js_ast.LocRef
 
@@ -2385,10 +2458,6 @@ func (p *parser) parseParenExpr(loc logger.Loc, opts parenExprOpts) js_ast.Expr
 		invalidLog := []logger.Loc{}
 		args := []js_ast.Arg{}
 
-		if opts.isAsync {
-			p.markLoweredSyntaxFeature(compat.AsyncAwait, opts.asyncRange, compat.Generator)
-		}
-
 		// First, try converting the expressions to bindings
 		for _, item := range items {
 			isSpread := false
@@ -2505,14 +2574,13 @@ func (p *parser) convertExprToBinding(expr js_ast.Expr, invalidLog []logger.Loc)
 		if e.IsParenthesized {
 			invalidLog = append(invalidLog, p.source.RangeOfOperatorBefore(expr.Loc, "(").Loc)
 		}
-		p.markSyntaxFeature(compat.Destructuring, p.source.RangeOfOperatorAfter(expr.Loc, "["))
 		items := []js_ast.ArrayBinding{}
 		isSpread := false
 		for _, item := range e.Items {
 			if i, ok := item.Data.(*js_ast.ESpread); ok {
 				isSpread = true
 				item = i.Value
-				if _, ok := item.Data.(*js_ast.EIdentifier); !ok {
+				if _, ok := item.Data.(*js_ast.EIdentifier); !ok && !p.options.unsupportedJSFeatures.Has(compat.Destructuring) {
 					p.markSyntaxFeature(compat.NestedRestBinding, p.source.RangeOfOperatorAfter(item.Loc, "["))
 				}
 			}
@@ -2533,7 +2601,6 @@ func (p *parser) convertExprToBinding(expr js_ast.Expr, invalidLog []logger.Loc)
 		if e.IsParenthesized {
 			invalidLog = append(invalidLog, p.source.RangeOfOperatorBefore(expr.Loc, "(").Loc)
 		}
-		p.markSyntaxFeature(compat.Destructuring, p.source.RangeOfOperatorAfter(expr.Loc, "{"))
 		properties := []js_ast.PropertyBinding{}
 		for _, item := range e.Properties {
 			if item.IsMethod || item.Kind == js_ast.PropertyGet || item.Kind == js_ast.PropertySet {
@@ -2658,6 +2725,8 @@ func (p *parser) parsePrefix(level js_ast.L, errors *deferredErrors, flags exprF
 					}
 					return js_ast.Expr{Loc: loc, Data: &js_ast.EAwait{Value: value}}
 				}
+			} else if p.fnOrArrowDataParse.isClassStaticInit {
+				p.log.AddRangeError(&p.source, nameRange, "Cannot use \"await\" inside a class static block")
 			}
 
 		case "yield":
@@ -2848,7 +2917,6 @@ func (p *parser) parsePrefix(level js_ast.L, errors *deferredErrors, flags exprF
 
 	case js_lexer.TClass:
 		classKeyword := p.lexer.Range()
-		p.markSyntaxFeature(compat.Class, classKeyword)
 		p.lexer.Next()
 		var name *js_ast.LocRef
 
@@ -3140,6 +3208,22 @@ func (p *parser) parsePrefix(level js_ast.L, errors *deferredErrors, flags exprF
 		p.lexer.Next()
 		return p.parseImportExpr(loc, level)
 
+	case js_lexer.TPrivateIdentifier:
+		// "#x in obj"
+		if !p.allowPrivateIdentifiers || !p.allowIn || level >= js_ast.LCompare {
+			p.lexer.Unexpected()
+			return js_ast.Expr{}
+		}
+		ref := p.storeNameInRef(p.lexer.Identifier)
+		p.lexer.Next()
+		if p.lexer.Token != js_lexer.TIn {
+			p.lexer.Expected(js_lexer.TIn)
+		}
+		if p.options.unsupportedJSFeatures.Has(compat.ClassPrivateBrandCheck) {
+			p.lowerAllPrivateNames = true
+		}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EPrivateIdentifier{Ref: ref}}
+
 	default:
 		p.lexer.Unexpected()
 		return js_ast.Expr{}
@@ -3227,10 +3311,31 @@ func (p *parser) parseImportExpr(loc logger.Loc, level js_ast.L) js_ast.Expr {
 	p.lexer.PreserveAllCommentsBefore = false
 
 	value := p.parseExpr(js_ast.LComma)
+	var optionsOrNil js_ast.Expr
+
+	if p.lexer.Token == js_lexer.TComma {
+		// "import('./foo.json', )"
+		p.lexer.Next()
+
+		if p.lexer.Token != js_lexer.TCloseParen {
+			// "import('./foo.json', { assert: { type: 'json' } })"
+			optionsOrNil = p.parseExpr(js_ast.LComma)
+
+			if p.lexer.Token == js_lexer.TComma {
+				// "import('./foo.json', { assert: { type: 'json' } }, )"
+				p.lexer.Next()
+			}
+		}
+	}
+
 	p.lexer.Expect(js_lexer.TCloseParen)
 
 	p.allowIn = oldAllowIn
-	return js_ast.Expr{Loc: loc, Data: &js_ast.EImport{Expr: value, LeadingInteriorComments: comments}}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EImport{
+		Expr:                    value,
+		OptionsOrNil:            optionsOrNil,
+		LeadingInteriorComments: comments,
+	}}
 }
 
 func (p *parser) parseExprOrBindings(level js_ast.L, errors *deferredErrors) js_ast.Expr {
@@ -3421,7 +3526,6 @@ func (p *parser) parseSuffix(left js_ast.Expr, level js_ast.L, errors *deferredE
 			if oldOptionalChain != js_ast.OptionalChainNone {
 				p.log.AddRangeError(&p.source, p.lexer.Range(), "Template literals cannot have an optional chain as a tag")
 			}
-			p.markSyntaxFeature(compat.TemplateLiteral, p.lexer.Range())
 			head := p.lexer.StringLiteral
 			headRaw := p.lexer.RawTemplateContents()
 			p.lexer.Next()
@@ -3432,7 +3536,6 @@ func (p *parser) parseSuffix(left js_ast.Expr, level js_ast.L, errors *deferredE
 			if oldOptionalChain != js_ast.OptionalChainNone {
 				p.log.AddRangeError(&p.source, p.lexer.Range(), "Template literals cannot have an optional chain as a tag")
 			}
-			p.markSyntaxFeature(compat.TemplateLiteral, p.lexer.Range())
 			head := p.lexer.StringLiteral
 			headRaw := p.lexer.RawTemplateContents()
 			parts, _ := p.parseTemplateParts(true /* includeRaw */)
@@ -3937,7 +4040,6 @@ func (p *parser) parseExprOrLetStmt(opts parseStmtOpts) (js_ast.Expr, js_ast.Stm
 			if opts.lexicalDecl != lexicalDeclAllowAll {
 				p.forbidLexicalDecl(letRange.Loc)
 			}
-			p.markSyntaxFeature(compat.Let, letRange)
 			decls := p.parseAndDeclareDecls(js_ast.SymbolOther, opts)
 			return js_ast.Expr{}, js_ast.Stmt{Loc: letRange.Loc, Data: &js_ast.SLocal{
 				Kind:     js_ast.LocalLet,
@@ -4430,7 +4532,6 @@ func (p *parser) parseBinding() js_ast.Binding {
 		return js_ast.Binding{Loc: loc, Data: &js_ast.BIdentifier{Ref: ref}}
 
 	case js_lexer.TOpenBracket:
-		p.markSyntaxFeature(compat.Destructuring, p.lexer.Range())
 		p.lexer.Next()
 		isSingleLine := !p.lexer.HasNewlineBefore
 		items := []js_ast.ArrayBinding{}
@@ -4449,8 +4550,9 @@ func (p *parser) parseBinding() js_ast.Binding {
 					p.lexer.Next()
 					hasSpread = true
 
-					// This was a bug in the ES2015 spec that was fixed in ES2016
-					if p.lexer.Token != js_lexer.TIdentifier {
+					// This was a bug in the ES2015 spec that was fixed in ES2016. This
+					// isn't a problem if destructuring is lowered entirely.
+					if p.lexer.Token != js_lexer.TIdentifier && !p.options.unsupportedJSFeatures.Has(compat.Destructuring) {
 						p.markSyntaxFeature(compat.NestedRestBinding, p.lexer.Range())
 					}
 				}
@@ -4498,7 +4600,6 @@ func (p *parser) parseBinding() js_ast.Binding {
 		}}
 
 	case js_lexer.TOpenBrace:
-		p.markSyntaxFeature(compat.Destructuring, p.lexer.Range())
 		p.lexer.Next()
 		isSingleLine := !p.lexer.HasNewlineBefore
 		properties := []js_ast.PropertyBinding{}
@@ -4546,10 +4647,6 @@ func (p *parser) parseBinding() js_ast.Binding {
 }
 
 func (p *parser) parseFn(name *js_ast.LocRef, data fnOrArrowDataParse) (fn js_ast.Fn, hadBody bool) {
-	if data.allowAwait && data.allowYield {
-		p.markSyntaxFeature(compat.AsyncGenerator, data.asyncRange)
-	}
-
 	fn.Name = name
 	fn.HasRestArg = false
 	fn.IsAsync = data.allowAwait
@@ -4699,7 +4796,6 @@ func (p *parser) parseClassStmt(loc logger.Loc, opts parseStmtOpts) js_ast.Stmt
 	var name *js_ast.LocRef
 	classKeyword := p.lexer.Range()
 	if p.lexer.Token == js_lexer.TClass {
-		p.markSyntaxFeature(compat.Class, classKeyword)
 		p.lexer.Next()
 	} else {
 		p.lexer.Expected(js_lexer.TClass)
@@ -4848,6 +4944,29 @@ func (p *parser) parseClass(classKeyword logger.Range, name *js_ast.LocRef, clas
 	}
 }
 
+// Class static blocks are like function bodies without arguments. They have
+// their own scope for "var" declarations and "this" is the class object.
+func (p *parser) parseClassStaticBlock() js_ast.Property {
+	loc := p.lexer.Loc()
+	oldFnOrArrowData := p.fnOrArrowDataParse
+	p.fnOrArrowDataParse = fnOrArrowDataParse{
+		isClassStaticInit:   true,
+		isTypeScriptDeclare: oldFnOrArrowData.isTypeScriptDeclare,
+	}
+
+	p.pushScopeForParsePass(js_ast.ScopeClassStaticInit, loc)
+	p.lexer.Expect(js_lexer.TOpenBrace)
+	stmts := p.parseStmtsUpTo(js_lexer.TCloseBrace, parseStmtOpts{})
+	p.lexer.Next()
+	p.popScope()
+
+	p.fnOrArrowDataParse = oldFnOrArrowData
+	return js_ast.Property{
+		Kind:             js_ast.PropertyClassStaticBlock,
+		ClassStaticBlock: &js_ast.ClassStaticBlock{Loc: loc, Stmts: stmts},
+	}
+}
+
 func (p *parser) parseLabelName() *js_ast.LocRef {
 	if p.lexer.Token != js_lexer.TIdentifier || p.lexer.HasNewlineBefore {
 		return nil
@@ -4858,7 +4977,7 @@ func (p *parser) parseLabelName() *js_ast.LocRef {
 	return &name
 }
 
-func (p *parser) parsePath() (logger.Loc, string) {
+func (p *parser) parsePath() (logger.Loc, string, *[]ast.AssertEntry) {
 	pathLoc := p.lexer.Loc()
 	pathText := js_lexer.UTF16ToString(p.lexer.StringLiteral)
 	if p.lexer.Token == js_lexer.TNoSubstitutionTemplateLiteral {
@@ -4866,17 +4985,71 @@ func (p *parser) parsePath() (logger.Loc, string) {
 	} else {
 		p.lexer.Expect(js_lexer.TStringLiteral)
 	}
-	return pathLoc, pathText
+
+	// See https://github.com/tc39/proposal-import-assertions for more info
+	var assertions *[]ast.AssertEntry
+	if !p.lexer.HasNewlineBefore && p.lexer.IsContextualKeyword("assert") {
+		// "import './foo.json' assert { type: 'json' }"
+		var entries []ast.AssertEntry
+		duplicates := make(map[string]logger.Range)
+		p.lexer.Next()
+		p.lexer.Expect(js_lexer.TOpenBrace)
+
+		for p.lexer.Token != js_lexer.TCloseBrace {
+			// Parse the key
+			keyLoc := p.lexer.Loc()
+			preferQuotedKey := false
+			var key []uint16
+			var keyText string
+			if p.lexer.IsIdentifierOrKeyword() {
+				keyText = p.lexer.Identifier
+				key = js_lexer.StringToUTF16(keyText)
+			} else if p.lexer.Token == js_lexer.TStringLiteral {
+				key = p.lexer.StringLiteral
+				keyText = js_lexer.UTF16ToString(key)
+				preferQuotedKey = !p.options.mangleSyntax
+			} else {
+				p.lexer.Expect(js_lexer.TIdentifier)
+			}
+			if prevRange, ok := duplicates[keyText]; ok {
+				p.log.AddRangeErrorWithNotes(&p.source, p.lexer.Range(), fmt.Sprintf("Duplicate import assertion %q", keyText),
+					[]logger.MsgData{logger.RangeData(&p.source, prevRange, fmt.Sprintf("The first %q was here:", keyText))})
+			}
+			duplicates[keyText] = p.lexer.Range()
+			p.lexer.Next()
+			p.lexer.Expect(js_lexer.TColon)
+
+			// Parse the value
+			valueLoc := p.lexer.Loc()
+			value := p.lexer.StringLiteral
+			p.lexer.Expect(js_lexer.TStringLiteral)
+
+			entries = append(entries, ast.AssertEntry{
+				Key:             key,
+				KeyLoc:          keyLoc,
+				Value:           value,
+				ValueLoc:        valueLoc,
+				PreferQuotedKey: preferQuotedKey,
+			})
+
+			if p.lexer.Token != js_lexer.TComma {
+				break
+			}
+			p.lexer.Next()
+		}
+
+		p.lexer.Expect(js_lexer.TCloseBrace)
+		assertions = &entries
+	}
+
+	return pathLoc, pathText, assertions
 }
 
 // This assumes the "function" token has already been parsed
 func (p *parser) parseFnStmt(loc logger.Loc, opts parseStmtOpts, isAsync bool, asyncRange logger.Range) js_ast.Stmt {
 	isGenerator := p.lexer.Token == js_lexer.TAsterisk
 	if isGenerator {
-		p.markSyntaxFeature(compat.Generator, p.lexer.Range())
 		p.lexer.Next()
-	} else if isAsync {
-		p.markLoweredSyntaxFeature(compat.AsyncAwait, asyncRange, compat.Generator)
 	}
 
 	switch opts.lexicalDecl {
@@ -5224,6 +5397,7 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 			var alias *js_ast.ExportStarAlias
 			var pathLoc logger.Loc
 			var pathText string
+			var assertions *[]ast.AssertEntry
 
 			if p.lexer.IsContextualKeyword("as") {
 				// "export * as ns from 'path'"
@@ -5237,15 +5411,15 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 				p.checkForNonBMPCodePoint(alias.Loc, name)
 				p.lexer.Next()
 				p.lexer.ExpectContextualKeyword("from")
-				pathLoc, pathText = p.parsePath()
+				pathLoc, pathText, assertions = p.parsePath()
 			} else {
 				// "export * from 'path'"
 				p.lexer.ExpectContextualKeyword("from")
-				pathLoc, pathText = p.parsePath()
+				pathLoc, pathText, assertions = p.parsePath()
 				name := js_ast.GenerateNonUniqueNameFromPath(pathText) + "_star"
 				namespaceRef = p.storeNameInRef(name)
 			}
-			importRecordIndex := p.addImportRecord(ast.ImportStmt, pathLoc, pathText)
+			importRecordIndex := p.addImportRecord(ast.ImportStmt, pathLoc, pathText, assertions)
 
 			p.lexer.ExpectOrInsertSemicolon()
 			return js_ast.Stmt{Loc: loc, Data: &js_ast.SExportStar{
@@ -5262,8 +5436,8 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 			items, isSingleLine := p.parseExportClause()
 			if p.lexer.IsContextualKeyword("from") {
 				p.lexer.Next()
-				pathLoc, pathText := p.parsePath()
-				importRecordIndex := p.addImportRecord(ast.ImportStmt, pathLoc, pathText)
+				pathLoc, pathText, assertions := p.parsePath()
+				importRecordIndex := p.addImportRecord(ast.ImportStmt, pathLoc, pathText, assertions)
 				name := "import_" + js_ast.GenerateNonUniqueNameFromPath(pathText)
 				namespaceRef := p.storeNameInRef(name)
 				p.lexer.ExpectOrInsertSemicolon()
@@ -5365,7 +5539,6 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 		if opts.lexicalDecl != lexicalDeclAllowAll {
 			p.forbidLexicalDecl(loc)
 		}
-		p.markSyntaxFeature(compat.Const, p.lexer.Range())
 		p.lexer.Next()
 
 		if p.options.ts.Parse && p.lexer.Token == js_lexer.TEnum {
@@ -5576,8 +5749,8 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 				p.log.AddRangeError(&p.source, awaitRange, "Cannot use \"await\" outside an async function")
 				isForAwait = false
 			} else {
-				didGenerateError := p.markSyntaxFeature(compat.ForAwait, awaitRange)
-				if p.fnOrArrowDataParse.isTopLevel && !didGenerateError {
+				// "for await" loops are only lowered inside async functions
+				if p.fnOrArrowDataParse.isTopLevel && !p.markSyntaxFeature(compat.ForAwait, awaitRange) {
 					p.topLevelAwaitKeyword = awaitRange
 					p.markSyntaxFeature(compat.TopLevelAwait, awaitRange)
 				}
@@ -5609,7 +5782,6 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 			init = &js_ast.Stmt{Loc: initLoc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: decls}}
 
 		case js_lexer.TConst:
-			p.markSyntaxFeature(compat.Const, p.lexer.Range())
 			p.lexer.Next()
 			decls = p.parseAndDeclareDecls(js_ast.SymbolConst, parseStmtOpts{})
 			init = &js_ast.Stmt{Loc: initLoc, Data: &js_ast.SLocal{Kind: js_ast.LocalConst, Decls: decls}}
@@ -5644,7 +5816,9 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 				}
 			}
 			p.forbidInitializers(decls, "of", false)
-			p.markSyntaxFeature(compat.ForOf, p.lexer.Range())
+			if !isForAwait {
+				p.markSyntaxFeature(compat.ForOf, p.lexer.Range())
+			}
 			p.lexer.Next()
 			value := p.parseExpr(js_ast.LComma)
 			p.lexer.Expect(js_lexer.TCloseParen)
@@ -5828,8 +6002,8 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 			return js_ast.Stmt{}
 		}
 
-		pathLoc, pathText := p.parsePath()
-		stmt.ImportRecordIndex = p.addImportRecord(ast.ImportStmt, pathLoc, pathText)
+		pathLoc, pathText, assertions := p.parsePath()
+		stmt.ImportRecordIndex = p.addImportRecord(ast.ImportStmt, pathLoc, pathText, assertions)
 		p.importRecords[stmt.ImportRecordIndex].WasOriginallyBareImport = wasOriginallyBareImport
 		p.lexer.ExpectOrInsertSemicolon()
 
@@ -5882,6 +6056,9 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 		return js_ast.Stmt{Loc: loc, Data: &js_ast.SContinue{Label: name}}
 
 	case js_lexer.TReturn:
+		if p.fnOrArrowDataParse.isClassStaticInit {
+			p.log.AddRangeError(&p.source, p.lexer.Range(), "A return statement cannot be used inside a class static block")
+		}
 		p.lexer.Next()
 		var value *js_ast.Expr
 		if p.lexer.Token != js_lexer.TSemicolon &&
@@ -6093,16 +6270,64 @@ func extractDeclsForBinding(binding js_ast.Binding, decls []js_ast.Decl) []js_as
 	return decls
 }
 
-func (p *parser) addImportRecord(kind ast.ImportKind, loc logger.Loc, text string) uint32 {
+func (p *parser) addImportRecord(kind ast.ImportKind, loc logger.Loc, text string, assertions *[]ast.AssertEntry) uint32 {
 	index := uint32(len(p.importRecords))
 	p.importRecords = append(p.importRecords, ast.ImportRecord{
-		Kind:  kind,
-		Range: p.source.RangeOfString(loc),
-		Path:  logger.Path{Text: text},
+		Kind:       kind,
+		Range:      p.source.RangeOfString(loc),
+		Path:       logger.Path{Text: text},
+		Assertions: assertions,
 	})
 	return index
 }
 
+// This extracts the entries of "import(path, { assert: { type: 'json' } })"
+// as long as they are all string literals. Anything more complicated than
+// that can't be validated at compile time, so it's ignored.
+func importAssertionsFromOptions(options js_ast.Expr) *[]ast.AssertEntry {
+	object, ok := options.Data.(*js_ast.EObject)
+	if !ok {
+		return nil
+	}
+
+	for _, property := range object.Properties {
+		if property.Kind != js_ast.PropertyNormal || property.IsComputed || property.IsMethod || property.Value == nil {
+			continue
+		}
+		if key, ok := property.Key.Data.(*js_ast.EString); !ok || !js_lexer.UTF16EqualsString(key.Value, "assert") {
+			continue
+		}
+		assert, ok := property.Value.Data.(*js_ast.EObject)
+		if !ok {
+			return nil
+		}
+
+		entries := []ast.AssertEntry{}
+		for _, entry := range assert.Properties {
+			if entry.Kind != js_ast.PropertyNormal || entry.IsComputed || entry.IsMethod || entry.Value == nil {
+				return nil
+			}
+			key, ok := entry.Key.Data.(*js_ast.EString)
+			if !ok {
+				return nil
+			}
+			value, ok := entry.Value.Data.(*js_ast.EString)
+			if !ok {
+				return nil
+			}
+			entries = append(entries, ast.AssertEntry{
+				Key:      key.Value,
+				KeyLoc:   entry.Key.Loc,
+				Value:    value.Value,
+				ValueLoc: entry.Value.Loc,
+			})
+		}
+		return &entries
+	}
+
+	return nil
+}
+
 func (p *parser) parseFnBody(data fnOrArrowDataParse) js_ast.FnBody {
 	oldFnOrArrowData := p.fnOrArrowDataParse
 	oldAllowIn := p.allowIn
@@ -6232,11 +6457,26 @@ func (p *parser) generateTempRef(declare generateTempRefArg, optionalName string
 	ref := p.newSymbol(js_ast.SymbolOther, optionalName)
 	if declare == tempRefNeedsDeclare {
 		p.tempRefsToDeclare = append(p.tempRefsToDeclare, tempRef{ref: ref})
+	} else if scope == p.moduleScope {
+		// The caller declares this symbol itself, but it must still be renamed
+		// along with other top-level symbols to avoid collisions across parts
+		p.declaredSymbols = append(p.declaredSymbols, js_ast.DeclaredSymbol{Ref: ref, IsTopLevel: true})
 	}
 	scope.Generated = append(scope.Generated, ref)
 	return ref
 }
 
+// Top-level temporary variables hold values that must be shared by all
+// invocations of the code using them, such as the cached template objects for
+// tagged template literals. They are declared at the end of the current part.
+func (p *parser) generateTopLevelTempRef() js_ast.Ref {
+	ref := p.newSymbol(js_ast.SymbolOther, "_"+js_ast.DefaultNameMinifier.NumberToMinifiedName(p.topLevelTempRefCount))
+	p.topLevelTempRefsToDeclare = append(p.topLevelTempRefsToDeclare, tempRef{ref: ref})
+	p.moduleScope.Generated = append(p.moduleScope.Generated, ref)
+	p.topLevelTempRefCount++
+	return ref
+}
+
 func (p *parser) pushScopeForVisitPass(kind js_ast.ScopeKind, loc logger.Loc) {
 	order := p.scopesInOrder[0]
 
@@ -7164,11 +7404,19 @@ func (p *parser) substituteSingleUseSymbolInExpr(
 			return expr, status
 		}
 
+		if e.OptionsOrNil.Data != nil {
+			if value, status := p.substituteSingleUseSymbolInExpr(e.OptionsOrNil, ref, replacement, replacementCanBeRemoved); status != substituteContinue {
+				e.OptionsOrNil = value
+				return expr, status
+			}
+		}
+
 		// The "import()" expression has side effects but the side effects are
 		// always asynchronous so there is no way for the side effects to modify
 		// the replacement value. So it's ok to reorder the replacement value
 		// past the "import()" expression assuming everything else checks out.
-		if replacementCanBeRemoved && p.exprCanBeRemovedIfUnused(e.Expr) {
+		if replacementCanBeRemoved && p.exprCanBeRemovedIfUnused(e.Expr) &&
+			(e.OptionsOrNil.Data == nil || p.exprCanBeRemovedIfUnused(e.OptionsOrNil)) {
 			return expr, substituteContinue
 		}
 
@@ -7338,11 +7586,17 @@ func (p *parser) substituteSingleUseSymbolInExpr(
 	return expr, substituteFailure
 }
 
-func (p *parser) visitLoopBody(stmt js_ast.Stmt) js_ast.Stmt {
+func (p *parser) visitLoopBody(loop *loweredLoop, stmt js_ast.Stmt) js_ast.Stmt {
 	oldIsInsideLoop := p.fnOrArrowDataVisit.isInsideLoop
 	p.fnOrArrowDataVisit.isInsideLoop = true
 	p.loopBody = stmt.Data
+	if loop != nil {
+		loop.isVisitingBody = true
+	}
 	stmt = p.visitSingleStmt(stmt, stmtsLoopBody)
+	if loop != nil {
+		loop.isVisitingBody = false
+	}
 	p.fnOrArrowDataVisit.isInsideLoop = oldIsInsideLoop
 	return stmt
 }
@@ -7401,6 +7655,7 @@ func (p *parser) visitForLoopInit(stmt js_ast.Stmt, isInOrOf bool) js_ast.Stmt {
 		}
 		s.Decls = p.lowerObjectRestInDecls(s.Decls)
 		s.Kind = p.selectLocalKind(s.Kind)
+		p.lowerBlockScopedDecls(s, true /* isLoopInit */)
 
 	default:
 		panic("Internal error")
@@ -8053,10 +8308,10 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 				return stmts
 
 			case *js_ast.SClass:
-				shadowRef := p.visitClass(s.Value.Stmt.Loc, &s2.Class)
+				shadowRef, superRef, ctorThisRef := p.visitClass(s.Value.Stmt.Loc, &s2.Class)
 
 				// Lower class field syntax for browsers that don't support it
-				classStmts, _ := p.lowerClass(stmt, js_ast.Expr{}, shadowRef)
+				classStmts, _ := p.lowerClass(stmt, js_ast.Expr{}, shadowRef, superRef, ctorThisRef)
 				return append(stmts, classStmts...)
 
 			default:
@@ -8109,10 +8364,19 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		switch s.Stmt.Data.(type) {
 		case *js_ast.SFor, *js_ast.SForIn, *js_ast.SForOf, *js_ast.SWhile, *js_ast.SDoWhile:
 			p.currentScope.LabelStmtIsLoop = true
+			p.loweredLoopLabel = ref
 		}
+		_, isForOf := s.Stmt.Data.(*js_ast.SForOf)
 		s.Stmt = p.visitSingleStmt(s.Stmt, stmtsNormal)
 		p.popScope()
 
+		// Keep the label on the loop inside a lowered "for await" loop so that
+		// "continue" statements that use the label still work
+		if try, ok := s.Stmt.Data.(*js_ast.STry); ok && isForOf {
+			try.Body[0] = js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SLabel{Name: s.Name, Stmt: try.Body[0]}}
+			stmt = s.Stmt
+		}
+
 	case *js_ast.SLocal:
 		for i, d := range s.Decls {
 			p.visitBinding(d.Binding, bindingOpts{})
@@ -8175,9 +8439,12 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 
 		s.Decls = p.lowerObjectRestInDecls(s.Decls)
 		s.Kind = p.selectLocalKind(s.Kind)
+		wasBlockScoped := p.lowerBlockScopedDecls(s, false /* isLoopInit */)
 
-		// Potentially relocate "var" declarations to the top level
-		if !p.options.CreateSnapshot && s.Kind == js_ast.LocalVar {
+		// Potentially relocate "var" declarations to the top level. Lowered "let"
+		// and "const" declarations are left alone since they may be inside of a
+		// loop body that is moved into a closure.
+		if !p.options.CreateSnapshot && s.Kind == js_ast.LocalVar && !wasBlockScoped {
 			if assign, ok := p.maybeRelocateVarsToTopLevel(s.Decls, relocateVarsNormal); ok {
 				if assign.Data != nil {
 					stmts = append(stmts, assign)
@@ -8219,7 +8486,12 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 			}
 		}
 
-		if s.Value != nil {
+		// "return" inside a lowered derived class constructor returns the value
+		// of "this", which may have been replaced by the base class
+		if s.Value == nil && p.fnOnlyDataVisit.thisClassCtorRef != nil && !p.fnOrArrowDataVisit.isArrow {
+			p.recordUsage(*p.fnOnlyDataVisit.thisClassCtorRef)
+			s.Value = &js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EIdentifier{Ref: *p.fnOnlyDataVisit.thisClassCtorRef}}
+		} else if s.Value != nil {
 			*s.Value = p.visitExpr(*s.Value)
 
 			// Returning undefined is implicit
@@ -8262,8 +8534,14 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		p.popScope()
 
 	case *js_ast.SWhile:
+		loop := p.pushLoweredLoop()
 		s.Test = p.visitExpr(s.Test)
-		s.Body = p.visitLoopBody(s.Body)
+		s.Body = p.visitLoopBody(loop, s.Body)
+		p.popLoweredLoop(loop)
+		if body, before, ok := p.lowerLoopBodyIntoClosure(loop, s.Body); ok {
+			s.Body = body
+			stmts = append(stmts, before...)
+		}
 
 		if p.options.mangleSyntax {
 			s.Test = p.simplifyBooleanExpr(s.Test)
@@ -8281,8 +8559,14 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SDoWhile:
-		s.Body = p.visitLoopBody(s.Body)
+		loop := p.pushLoweredLoop()
+		s.Body = p.visitLoopBody(loop, s.Body)
 		s.Test = p.visitExpr(s.Test)
+		p.popLoweredLoop(loop)
+		if body, before, ok := p.lowerLoopBodyIntoClosure(loop, s.Body); ok {
+			s.Body = body
+			stmts = append(stmts, before...)
+		}
 
 		if p.options.mangleSyntax {
 			s.Test = p.simplifyBooleanExpr(s.Test)
@@ -8333,9 +8617,11 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SFor:
+		loop := p.pushLoweredLoop()
 		p.pushScopeForVisitPass(js_ast.ScopeBlock, stmt.Loc)
 		if s.Init != nil {
 			p.visitForLoopInit(*s.Init, false)
+			p.recordLoweredLoopHead(loop, s.Init)
 		}
 
 		if s.Test != nil {
@@ -8354,8 +8640,13 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		if s.Update != nil {
 			*s.Update = p.visitExpr(*s.Update)
 		}
-		s.Body = p.visitLoopBody(s.Body)
+		s.Body = p.visitLoopBody(loop, s.Body)
 		p.popScope()
+		p.popLoweredLoop(loop)
+		if body, before, ok := p.lowerLoopBodyIntoClosure(loop, s.Body); ok {
+			s.Body = body
+			stmts = append(stmts, before...)
+		}
 
 		// Potentially relocate "var" declarations to the top level
 		if s.Init != nil {
@@ -8375,12 +8666,20 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SForIn:
+		loop := p.pushLoweredLoop()
 		p.pushScopeForVisitPass(js_ast.ScopeBlock, stmt.Loc)
 		p.visitForLoopInit(s.Init, true)
+		p.recordLoweredLoopHead(loop, &s.Init)
 		s.Value = p.visitExpr(s.Value)
-		s.Body = p.visitLoopBody(s.Body)
+		s.Body = p.visitLoopBody(loop, s.Body)
 		p.popScope()
+		p.popLoweredLoop(loop)
 		p.lowerObjectRestInForLoopInit(s.Init, &s.Body)
+		p.recordLoweredLoopHead(loop, &s.Init)
+		if body, before, ok := p.lowerLoopBodyIntoClosure(loop, s.Body); ok {
+			s.Body = body
+			stmts = append(stmts, before...)
+		}
 
 		// Check for a variable initializer
 		if local, ok := s.Init.Data.(*js_ast.SLocal); ok && local.Kind == js_ast.LocalVar && len(local.Decls) == 1 {
@@ -8405,11 +8704,14 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SForOf:
+		loop := p.pushLoweredLoop()
 		p.pushScopeForVisitPass(js_ast.ScopeBlock, stmt.Loc)
 		p.visitForLoopInit(s.Init, true)
+		p.recordLoweredLoopHead(loop, &s.Init)
 		s.Value = p.visitExpr(s.Value)
-		s.Body = p.visitLoopBody(s.Body)
+		s.Body = p.visitLoopBody(loop, s.Body)
 		p.popScope()
+		p.popLoweredLoop(loop)
 
 		// Potentially relocate "var" declarations to the top level
 		if init, ok := s.Init.Data.(*js_ast.SLocal); ok && init.Kind == js_ast.LocalVar {
@@ -8419,6 +8721,16 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 		p.lowerObjectRestInForLoopInit(s.Init, &s.Body)
+		p.recordLoweredLoopHead(loop, &s.Init)
+		if body, before, ok := p.lowerLoopBodyIntoClosure(loop, s.Body); ok {
+			s.Body = body
+			stmts = append(stmts, before...)
+		}
+
+		// Lower "for await" loops inside async functions
+		if s.IsAwait && p.options.unsupportedJSFeatures.Has(compat.ForAwait) && !p.fnOrArrowDataVisit.isOutsideFnOrArrow {
+			stmt = p.lowerForAwaitLoop(stmt.Loc, s)
+		}
 
 	case *js_ast.STry:
 		p.pushScopeForVisitPass(js_ast.ScopeBlock, stmt.Loc)
@@ -8495,7 +8807,7 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		return stmts
 
 	case *js_ast.SClass:
-		shadowRef := p.visitClass(stmt.Loc, &s.Class)
+		shadowRef, superRef, ctorThisRef := p.visitClass(stmt.Loc, &s.Class)
 
 		// Remove the export flag inside a namespace
 		wasExportInsideNamespace := s.IsExport && p.enclosingNamespaceArgRef != nil
@@ -8504,7 +8816,7 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 		// Lower class field syntax for browsers that don't support it
-		classStmts, _ := p.lowerClass(stmt, js_ast.Expr{}, shadowRef)
+		classStmts, _ := p.lowerClass(stmt, js_ast.Expr{}, shadowRef, superRef, ctorThisRef)
 		stmts = append(stmts, classStmts...)
 
 		// Handle exporting this class from a namespace
@@ -8922,7 +9234,7 @@ func (p *parser) visitTSDecorators(tsDecorators []js_ast.Expr) []js_ast.Expr {
 	return tsDecorators
 }
 
-func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast.Ref {
+func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) (shadowRef js_ast.Ref, superRef js_ast.Ref, ctorThisRef js_ast.Ref) {
 	class.TSDecorators = p.visitTSDecorators(class.TSDecorators)
 
 	if class.Name != nil {
@@ -8933,7 +9245,7 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 	// initializers either if static fields are not supported or if we are
 	// converting this class to a "var" to avoid the temporal dead zone.
 	replaceThisInStaticFieldInit := p.options.unsupportedJSFeatures.Has(compat.ClassStaticField) ||
-		(p.options.mode == config.ModeBundle && p.currentScope.Parent == nil)
+		p.mustLowerStaticBlocks(class) || (p.options.mode == config.ModeBundle && p.currentScope.Parent == nil)
 
 	p.pushScopeForVisitPass(js_ast.ScopeClassName, nameScopeLoc)
 	oldEnclosingClassKeyword := p.enclosingClassKeyword
@@ -8954,7 +9266,7 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 	// original value of the name. This matters for class statements because the
 	// symbol can be re-assigned to something else later. The captured values
 	// must be the original value of the name, not the re-assigned value.
-	shadowRef := js_ast.InvalidRef
+	shadowRef = js_ast.InvalidRef
 	if classNameRef != js_ast.InvalidRef {
 		// Use "const" for this symbol to match JavaScript run-time semantics. You
 		// are not allowed to assign to this symbol (it throws a TypeError).
@@ -8970,11 +9282,63 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 		*class.Extends = p.visitExpr(*class.Extends)
 	}
 
+	// Classes that are lowered to functions pass the value of the "extends"
+	// clause to the function as an argument, which is used instead of "super"
+	superRef = js_ast.InvalidRef
+	ctorThisRef = js_ast.InvalidRef
+	oldClassSuperRef := p.classSuperRef
+	oldIsClassSuperStatic := p.isClassSuperStatic
+	if p.options.unsupportedJSFeatures.Has(compat.Class) {
+		p.classSuperRef = nil
+		if class.Extends != nil {
+			superRef = p.newSymbol(js_ast.SymbolOther, "_super")
+			p.currentScope.Generated = append(p.currentScope.Generated, superRef)
+		}
+	}
+	ctorThis := func() *js_ast.Ref {
+		if ctorThisRef == js_ast.InvalidRef {
+			ctorThisRef = p.newSymbol(js_ast.SymbolOther, "_this")
+		}
+		return &ctorThisRef
+	}
+
 	// A scope is needed for private identifiers
 	p.pushScopeForVisitPass(js_ast.ScopeClassBody, class.BodyLoc)
 	defer p.popScope()
 
 	for i, property := range class.Properties {
+		if property.Kind == js_ast.PropertyClassStaticBlock {
+			oldFnOrArrowData := p.fnOrArrowDataVisit
+			oldFnOnlyData := p.fnOnlyDataVisit
+			p.fnOrArrowDataVisit = fnOrArrowDataVisit{}
+			p.fnOnlyDataVisit = fnOnlyDataVisit{isThisNested: true}
+
+			// Replace "this" with the class name inside static blocks that are
+			// moved outside of the class body
+			if replaceThisInStaticFieldInit {
+				p.fnOnlyDataVisit.thisClassStaticRef = &shadowRef
+			}
+			if superRef != js_ast.InvalidRef {
+				p.classSuperRef = &superRef
+				p.isClassSuperStatic = true
+			}
+
+			p.pushScopeForVisitPass(js_ast.ScopeClassStaticInit, property.ClassStaticBlock.Loc)
+
+			// Make it an error to use "arguments" in a static block
+			p.currentScope.ForbidArguments = true
+
+			property.ClassStaticBlock.Stmts = p.visitStmtsAndPrependTempRefs(property.ClassStaticBlock.Stmts,
+				prependTempRefsOpts{fnBodyLoc: &property.ClassStaticBlock.Loc, kind: stmtsFnBody})
+			p.popScope()
+
+			p.fnOrArrowDataVisit = oldFnOrArrowData
+			p.fnOnlyDataVisit = oldFnOnlyData
+			p.classSuperRef = oldClassSuperRef
+			p.isClassSuperStatic = oldIsClassSuperStatic
+			continue
+		}
+
 		property.TSDecorators = p.visitTSDecorators(property.TSDecorators)
 		private, isPrivate := property.Key.Data.(*js_ast.EPrivateIdentifier)
 
@@ -8999,8 +9363,10 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 		// The value of "this" is shadowed inside property values
 		oldIsThisCaptured := p.fnOnlyDataVisit.isThisNested
 		oldThis := p.fnOnlyDataVisit.thisClassStaticRef
+		oldCtorThis := p.fnOnlyDataVisit.thisClassCtorRef
 		p.fnOnlyDataVisit.isThisNested = true
 		p.fnOnlyDataVisit.thisClassStaticRef = nil
+		p.fnOnlyDataVisit.thisClassCtorRef = nil
 
 		// We need to explicitly assign the name to the property initializer if it
 		// will be transformed such that it is no longer an inline initializer.
@@ -9015,6 +9381,19 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 			}
 		}
 
+		// Only "super" inside of property values refers to the class being lowered
+		if superRef != js_ast.InvalidRef {
+			p.classSuperRef = &superRef
+			p.isClassSuperStatic = property.IsStatic
+		}
+
+		// "this" inside the constructor refers to the value returned by "super()"
+		if superRef != js_ast.InvalidRef && property.IsMethod && !property.IsStatic && !property.IsComputed {
+			if str, ok := property.Key.Data.(*js_ast.EString); ok && js_lexer.UTF16EqualsString(str.Value, "constructor") {
+				p.classCtorThisRef = ctorThis()
+			}
+		}
+
 		if property.Value != nil {
 			if nameToKeep != "" {
 				wasAnonymousNamedExpr := p.isAnonymousNamedExpr(*property.Value)
@@ -9028,6 +9407,9 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 			if property.IsStatic && replaceThisInStaticFieldInit {
 				// Replace "this" with the class name inside static property initializers
 				p.fnOnlyDataVisit.thisClassStaticRef = &shadowRef
+			} else if !property.IsStatic && p.options.unsupportedJSFeatures.Has(compat.Class) {
+				// Instance property initializers end up inside the constructor
+				p.fnOnlyDataVisit.thisClassCtorRef = ctorThis()
 			}
 			if nameToKeep != "" {
 				wasAnonymousNamedExpr := p.isAnonymousNamedExpr(*property.Initializer)
@@ -9039,12 +9421,25 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 
 		// Restore "this" so it will take the inherited value in property keys
 		p.fnOnlyDataVisit.thisClassStaticRef = oldThis
+		p.fnOnlyDataVisit.thisClassCtorRef = oldCtorThis
 		p.fnOnlyDataVisit.isThisNested = oldIsThisCaptured
+		p.classSuperRef = oldClassSuperRef
+		p.isClassSuperStatic = oldIsClassSuperStatic
+		p.classCtorThisRef = nil
 
 		// Restore the ability to use "arguments" in decorators and computed properties
 		p.currentScope.ForbidArguments = false
 	}
 
+	// Only declare "_this" if something needed it
+	if ctorThisRef != js_ast.InvalidRef {
+		if p.symbols[ctorThisRef.InnerIndex].UseCountEstimate == 0 {
+			ctorThisRef = js_ast.InvalidRef
+		} else {
+			p.currentScope.Generated = append(p.currentScope.Generated, ctorThisRef)
+		}
+	}
+
 	p.enclosingClassKeyword = oldEnclosingClassKeyword
 	p.popScope()
 
@@ -9062,7 +9457,7 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 		}
 	}
 
-	return shadowRef
+	return
 }
 
 func isSimpleParameterList(args []js_ast.Arg, hasRestArg bool) bool {
@@ -9641,6 +10036,12 @@ func (p *parser) valueForThis(loc logger.Loc) (js_ast.Expr, bool) {
 		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: *p.fnOnlyDataVisit.thisClassStaticRef}}, true
 	}
 
+	// Substitute "this" if we're inside a lowered class constructor
+	if p.fnOnlyDataVisit.thisClassCtorRef != nil {
+		p.recordUsage(*p.fnOnlyDataVisit.thisClassCtorRef)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: *p.fnOnlyDataVisit.thisClassCtorRef}}, true
+	}
+
 	if p.options.mode != config.ModePassThrough && !p.fnOnlyDataVisit.isThisNested {
 		if p.hasESModuleSyntax {
 			// In an ES6 module, "this" is supposed to be undefined. Instead of
@@ -9823,6 +10224,11 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EIdentifier{Ref: p.captureThis()}}, exprOut{}
 		}
 
+		// Loop bodies that are moved into a closure must forward "this"
+		if len(p.loweredLoops) > 0 {
+			p.recordThisInLoweredLoops()
+		}
+
 	case *js_ast.EImportMeta:
 		isDeleteTarget := e == p.deleteTarget
 
@@ -9965,11 +10371,40 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			e.Parts[i].Value = p.visitExpr(part.Value)
 		}
 
+		// Lower tagged template literals for browsers that don't support them
+		if e.Tag != nil && p.options.unsupportedJSFeatures.Has(compat.TemplateLiteral) {
+			return p.lowerTemplateLiteral(expr.Loc, e), exprOut{}
+		}
+
 		if p.options.mangleSyntax {
 			return p.mangleTemplate(expr.Loc, e), exprOut{}
 		}
 
 	case *js_ast.EBinary:
+		// Special-case EPrivateIdentifier to allow it here
+		if private, ok := e.Left.Data.(*js_ast.EPrivateIdentifier); ok && e.Op == js_ast.BinOpIn {
+			name := p.loadNameFromRef(private.Ref)
+			result := p.findSymbol(e.Left.Loc, name)
+			private.Ref = result.ref
+
+			// Unlike regular identifiers, there are no unbound private identifiers
+			if !p.symbols[result.ref.InnerIndex].Kind.IsPrivate() {
+				r := logger.Range{Loc: e.Left.Loc, Len: int32(len(name))}
+				p.log.AddRangeError(&p.source, r, fmt.Sprintf("Private name %q must be declared in an enclosing class", name))
+			}
+
+			e.Right = p.visitExpr(e.Right)
+
+			// "#x in obj" => "__privateIn(_x, obj)"
+			if p.isPrivateUnsupported(private) {
+				return p.callRuntime(expr.Loc, "__privateIn", []js_ast.Expr{
+					{Loc: e.Left.Loc, Data: &js_ast.EIdentifier{Ref: private.Ref}},
+					e.Right,
+				}), exprOut{}
+			}
+			return expr, exprOut{}
+		}
+
 		isCallTarget := e == p.callTarget
 		isStmtExpr := e == p.stmtExprValue
 		wasAnonymousNamedExpr := p.isAnonymousNamedExpr(e.Right)
@@ -10342,6 +10777,9 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSet(target, loc, private, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySet(e.Left.Loc, key, e.Right), exprOut{}
+			}
 
 			// Lower assignment destructuring patterns for browsers that don't
 			// support them. Note that assignment expressions are used to represent
@@ -10361,26 +10799,41 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpAdd, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpAdd, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpSubAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpSub, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpSub, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpMulAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpMul, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpMul, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpDivAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpDiv, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpDiv, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpRemAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpRem, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpRem, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpPowAssign:
 			// Lower the exponentiation operator for browsers that don't support it
@@ -10391,36 +10844,57 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpPow, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpPow, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpShlAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpShl, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpShl, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpShrAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpShr, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpShr, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpUShrAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpUShr, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpUShr, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpBitwiseOrAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpBitwiseOr, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpBitwiseOr, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpBitwiseAndAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpBitwiseAnd, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpBitwiseAnd, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpBitwiseXorAssign:
 			if target, loc, private := p.extractPrivateIndex(e.Left); private != nil {
 				return p.lowerPrivateSetBinOp(target, loc, private, js_ast.BinOpBitwiseXor, e.Right), exprOut{}
 			}
+			if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+				return p.lowerSuperPropertySetBinOp(e.Left.Loc, key, js_ast.BinOpBitwiseXor, e.Right), exprOut{}
+			}
 
 		case js_ast.BinOpNullishCoalescingAssign:
 			if p.options.unsupportedJSFeatures.Has(compat.LogicalAssignment) {
@@ -10510,7 +10984,10 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 
 		// Lower "super[prop]" if necessary
 		if !isCallTarget && p.shouldLowerSuperPropertyAccess(e.Target) {
-			return p.lowerSuperPropertyAccess(expr.Loc, e.Index), exprOut{}
+			if in.assignTarget != js_ast.AssignTargetNone {
+				return p.lowerSuperPropertyAccess(expr.Loc, e.Index), exprOut{}
+			}
+			return p.lowerSuperPropertyGet(expr.Loc, e.Index), exprOut{}
 		}
 
 		// Lower optional chaining if we're the top of the chain
@@ -10672,21 +11149,33 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				if target, loc, private := p.extractPrivateIndex(e.Value); private != nil {
 					return p.lowerPrivateSetUnOp(target, loc, private, js_ast.BinOpSub, false), exprOut{}
 				}
+				if key, ok := p.extractSuperPropertyKey(e.Value); ok {
+					return p.lowerSuperPropertySetUnOp(e.Value.Loc, key, js_ast.BinOpSub, false), exprOut{}
+				}
 
 			case js_ast.UnOpPreInc:
 				if target, loc, private := p.extractPrivateIndex(e.Value); private != nil {
 					return p.lowerPrivateSetUnOp(target, loc, private, js_ast.BinOpAdd, false), exprOut{}
 				}
+				if key, ok := p.extractSuperPropertyKey(e.Value); ok {
+					return p.lowerSuperPropertySetUnOp(e.Value.Loc, key, js_ast.BinOpAdd, false), exprOut{}
+				}
 
 			case js_ast.UnOpPostDec:
 				if target, loc, private := p.extractPrivateIndex(e.Value); private != nil {
 					return p.lowerPrivateSetUnOp(target, loc, private, js_ast.BinOpSub, true), exprOut{}
 				}
+				if key, ok := p.extractSuperPropertyKey(e.Value); ok {
+					return p.lowerSuperPropertySetUnOp(e.Value.Loc, key, js_ast.BinOpSub, true), exprOut{}
+				}
 
 			case js_ast.UnOpPostInc:
 				if target, loc, private := p.extractPrivateIndex(e.Value); private != nil {
 					return p.lowerPrivateSetUnOp(target, loc, private, js_ast.BinOpAdd, true), exprOut{}
 				}
+				if key, ok := p.extractSuperPropertyKey(e.Value); ok {
+					return p.lowerSuperPropertySetUnOp(e.Value.Loc, key, js_ast.BinOpAdd, true), exprOut{}
+				}
 			}
 		}
 
@@ -10756,7 +11245,10 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		// Lower "super.prop" if necessary
 		if !isCallTarget && p.shouldLowerSuperPropertyAccess(e.Target) {
 			key := js_ast.Expr{Loc: e.NameLoc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(e.Name)}}
-			return p.lowerSuperPropertyAccess(expr.Loc, key), exprOut{}
+			if in.assignTarget != js_ast.AssignTargetNone {
+				return p.lowerSuperPropertyAccess(expr.Loc, key), exprOut{}
+			}
+			return p.lowerSuperPropertyGet(expr.Loc, key), exprOut{}
 		}
 
 		// Lower optional chaining if we're the top of the chain
@@ -10852,13 +11344,16 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		e.Value = p.visitExpr(e.Value)
 
 		// "await" expressions turn into "yield" expressions when lowering
-		if p.options.unsupportedJSFeatures.Has(compat.AsyncAwait) {
-			return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EYield{Value: &e.Value}}, exprOut{}
-		}
+		return p.maybeLowerAwait(expr.Loc, e.Value), exprOut{}
 
 	case *js_ast.EYield:
 		if e.Value != nil {
 			*e.Value = p.visitExpr(*e.Value)
+
+			// "yield*" inside a lowered async generator must handle async iterators
+			if e.IsStar && p.fnOrArrowDataVisit.isAsync && p.options.unsupportedJSFeatures.Has(compat.AsyncGenerator) {
+				*e.Value = p.callRuntime(expr.Loc, "__yieldStar", []js_ast.Expr{*e.Value})
+			}
 		}
 
 	case *js_ast.EArray:
@@ -10866,7 +11361,6 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if e.CommaAfterSpread.Start != 0 {
 				p.log.AddRangeError(&p.source, logger.Range{Loc: e.CommaAfterSpread, Len: 1}, "Unexpected \",\" after rest pattern")
 			}
-			p.markSyntaxFeature(compat.Destructuring, logger.Range{Loc: expr.Loc, Len: 1})
 		}
 		hasSpread := false
 		for i, item := range e.Items {
@@ -10905,7 +11399,6 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if e.CommaAfterSpread.Start != 0 {
 				p.log.AddRangeError(&p.source, logger.Range{Loc: e.CommaAfterSpread, Len: 1}, "Unexpected \",\" after rest pattern")
 			}
-			p.markSyntaxFeature(compat.Destructuring, logger.Range{Loc: expr.Loc, Len: 1})
 		}
 		hasSpread := false
 		hasProto := false
@@ -11047,7 +11540,25 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		isThenCatchTarget := e == p.thenCatchChain.nextTarget && p.thenCatchChain.hasCatch
 		e.Expr = p.visitExpr(e.Expr)
 
-		return p.maybeTransposeIfExprChain(e.Expr, func(arg js_ast.Expr) js_ast.Expr {
+		var assertions *[]ast.AssertEntry
+		var optionsSideEffects js_ast.Expr
+		if e.OptionsOrNil.Data != nil {
+			e.OptionsOrNil = p.visitExpr(e.OptionsOrNil)
+			assertions = importAssertionsFromOptions(e.OptionsOrNil)
+
+			// Strip the options argument if the target doesn't support import
+			// assertions. Any side effects in the options must still happen.
+			if p.options.unsupportedJSFeatures.Has(compat.ImportAssertions) {
+				if p.exprCanBeRemovedIfUnused(e.OptionsOrNil) {
+					e.OptionsOrNil = js_ast.Expr{}
+				} else if p.exprCanBeRemovedIfUnused(e.Expr) {
+					optionsSideEffects = e.OptionsOrNil
+					e.OptionsOrNil = js_ast.Expr{}
+				}
+			}
+		}
+
+		visit := func(arg js_ast.Expr) js_ast.Expr {
 			// The argument must be a string
 			if str, ok := arg.Data.(*js_ast.EString); ok {
 				// Ignore calls to import() if the control flow is provably dead here.
@@ -11057,10 +11568,11 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 					return js_ast.Expr{Loc: arg.Loc, Data: &js_ast.ENull{}}
 				}
 
-				importRecordIndex := p.addImportRecord(ast.ImportDynamic, arg.Loc, js_lexer.UTF16ToString(str.Value))
+				importRecordIndex := p.addImportRecord(ast.ImportDynamic, arg.Loc, js_lexer.UTF16ToString(str.Value), assertions)
 				p.importRecordsForCurrentPart = append(p.importRecordsForCurrentPart, importRecordIndex)
 				return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EImport{
 					Expr:                    arg,
+					OptionsOrNil:            e.OptionsOrNil,
 					ImportRecordIndex:       ast.MakeIndex32(importRecordIndex),
 					LeadingInteriorComments: e.LeadingInteriorComments,
 				}}
@@ -11113,7 +11625,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				} else {
 					then = js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EArrow{Body: body, PreferExpr: true}}
 				}
-				return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ECall{
+				result := js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ECall{
 					Target: js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EDot{
 						Target: js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ECall{
 							Target: js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EDot{
@@ -11127,13 +11639,34 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 					}},
 					Args: []js_ast.Expr{then},
 				}}
+
+				// "require()" has no options argument, but its side effects must remain
+				if e.OptionsOrNil.Data != nil {
+					result = js_ast.JoinWithComma(e.OptionsOrNil, result)
+				}
+				return result
 			}
 
 			return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EImport{
 				Expr:                    arg,
+				OptionsOrNil:            e.OptionsOrNil,
 				LeadingInteriorComments: e.LeadingInteriorComments,
 			}}
-		}), exprOut{}
+		}
+
+		// If there's an additional argument, this can't be split because the
+		// additional argument requires evaluation and our AST nodes can't be
+		// reused in different places
+		var result js_ast.Expr
+		if e.OptionsOrNil.Data != nil {
+			result = visit(e.Expr)
+		} else {
+			result = p.maybeTransposeIfExprChain(e.Expr, visit)
+		}
+		if optionsSideEffects.Data != nil {
+			result = js_ast.JoinWithComma(optionsSideEffects, result)
+		}
+		return result, exprOut{}
 
 	case *js_ast.ECall:
 		p.callTarget = e.Target.Data
@@ -11197,7 +11730,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 								return js_ast.Expr{Loc: arg.Loc, Data: &js_ast.ENull{}}
 							}
 
-							importRecordIndex := p.addImportRecord(ast.ImportRequireResolve, e.Args[0].Loc, js_lexer.UTF16ToString(str.Value))
+							importRecordIndex := p.addImportRecord(ast.ImportRequireResolve, e.Args[0].Loc, js_lexer.UTF16ToString(str.Value), nil)
 							p.importRecords[importRecordIndex].IsInsideTryBody = p.fnOrArrowDataVisit.tryBodyCount != 0
 							p.importRecordsForCurrentPart = append(p.importRecordsForCurrentPart, importRecordIndex)
 
@@ -11299,6 +11832,22 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 					CanBeUnwrappedIfUnused: e.CanBeUnwrappedIfUnused,
 				}}), exprOut{}
 			}
+			// "super(a, b)" => "_this = _super.call(this, a, b) || this"
+			if _, ok := e.Target.Data.(*js_ast.ESuper); ok && p.classSuperRef != nil {
+				// The real value of "this" is passed to the base class
+				ctorThisRef := p.fnOnlyDataVisit.thisClassCtorRef
+				p.fnOnlyDataVisit.thisClassCtorRef = nil
+				thisValue := p.visitExpr(js_ast.Expr{Loc: e.Target.Loc, Data: &js_ast.EThis{}})
+				value := p.lowerSuperCall(expr.Loc, e.Args, *p.classSuperRef, thisValue)
+				if ctorThisRef != nil {
+					thisValue = p.visitExpr(js_ast.Expr{Loc: e.Target.Loc, Data: &js_ast.EThis{}})
+					p.fnOnlyDataVisit.thisClassCtorRef = ctorThisRef
+					p.recordUsage(*ctorThisRef)
+					value = js_ast.Assign(js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EIdentifier{Ref: *ctorThisRef}},
+						js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EBinary{Op: js_ast.BinOpLogicalOr, Left: value, Right: thisValue}})
+				}
+				return value, exprOut{}
+			}
 			p.maybeLowerSuperPropertyAccessInsideCall(e)
 		}
 
@@ -11323,7 +11872,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 									return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ENull{}}
 								}
 
-								importRecordIndex := p.addImportRecord(ast.ImportRequire, arg.Loc, js_lexer.UTF16ToString(str.Value))
+								importRecordIndex := p.addImportRecord(ast.ImportRequire, arg.Loc, js_lexer.UTF16ToString(str.Value), nil)
 								p.importRecords[importRecordIndex].IsInsideTryBody = p.fnOrArrowDataVisit.tryBodyCount != 0
 								p.importRecordsForCurrentPart = append(p.importRecordsForCurrentPart, importRecordIndex)
 
@@ -11400,7 +11949,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		p.pushScopeForVisitPass(js_ast.ScopeFunctionBody, e.Body.Loc)
 		e.Body.Stmts = p.visitStmtsAndPrependTempRefs(e.Body.Stmts, prependTempRefsOpts{kind: stmtsFnBody})
 		p.popScope()
-		p.lowerFunction(&e.IsAsync, &e.Args, e.Body.Loc, &e.Body.Stmts, &e.PreferExpr, &e.HasRestArg, true /* isArrow */)
+		p.lowerFunction(&e.IsAsync, nil, &e.Args, e.Body.Loc, &e.Body.Stmts, &e.PreferExpr, &e.HasRestArg, true /* isArrow */)
 		p.popScope()
 
 		if p.options.mangleSyntax && len(e.Body.Stmts) == 1 {
@@ -11444,10 +11993,10 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		}
 
 	case *js_ast.EClass:
-		shadowRef := p.visitClass(expr.Loc, &e.Class)
+		shadowRef, superRef, ctorThisRef := p.visitClass(expr.Loc, &e.Class)
 
 		// Lower class field syntax for browsers that don't support it
-		_, expr = p.lowerClass(js_ast.Stmt{}, expr, shadowRef)
+		_, expr = p.lowerClass(js_ast.Stmt{}, expr, shadowRef, superRef, ctorThisRef)
 
 	default:
 		panic("Internal error")
@@ -11520,11 +12069,16 @@ type identifierOpts struct {
 func (p *parser) handleIdentifier(loc logger.Loc, e *js_ast.EIdentifier, opts identifierOpts) js_ast.Expr {
 	ref := e.Ref
 
+	// Track which variables are captured by closures inside of loops
+	if len(p.loweredLoops) > 0 {
+		p.recordIdentifierInLoweredLoops(loc, ref, opts.assignTarget)
+	}
+
 	// Capture the "arguments" variable if necessary
 	if p.fnOnlyDataVisit.argumentsRef != nil && ref == *p.fnOnlyDataVisit.argumentsRef {
 		isInsideUnsupportedArrow := p.fnOrArrowDataVisit.isArrow && p.options.unsupportedJSFeatures.Has(compat.Arrow)
 		isInsideUnsupportedAsyncArrow := p.fnOnlyDataVisit.isInsideAsyncArrowFn && p.options.unsupportedJSFeatures.Has(compat.AsyncAwait)
-		if isInsideUnsupportedArrow || isInsideUnsupportedAsyncArrow {
+		if isInsideUnsupportedArrow || isInsideUnsupportedAsyncArrow || p.fnOnlyDataVisit.isInsideLoweredGeneratorFn {
 			return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: p.captureArguments()}}
 		}
 	}
@@ -11599,12 +12153,17 @@ func (p *parser) visitFn(fn *js_ast.Fn, scopeLoc logger.Loc) {
 	oldFnOrArrowData := p.fnOrArrowDataVisit
 	oldFnOnlyData := p.fnOnlyDataVisit
 	p.fnOrArrowDataVisit = fnOrArrowDataVisit{
-		isAsync: fn.IsAsync,
+		isAsync:     fn.IsAsync,
+		isGenerator: fn.IsGenerator,
 	}
 	p.fnOnlyDataVisit = fnOnlyDataVisit{
-		isThisNested: true,
-		argumentsRef: &fn.ArgumentsRef,
+		isThisNested:     true,
+		argumentsRef:     &fn.ArgumentsRef,
+		thisClassCtorRef: p.classCtorThisRef,
+		isInsideLoweredGeneratorFn: p.options.unsupportedJSFeatures.Has(compat.Generator) &&
+			(fn.IsGenerator || (fn.IsAsync && p.options.unsupportedJSFeatures.Has(compat.AsyncAwait))),
 	}
+	p.classCtorThisRef = nil
 
 	if fn.Name != nil {
 		p.recordDeclaredSymbol(fn.Name.Ref)
@@ -11622,7 +12181,7 @@ func (p *parser) visitFn(fn *js_ast.Fn, scopeLoc logger.Loc) {
 	p.pushScopeForVisitPass(js_ast.ScopeFunctionBody, fn.Body.Loc)
 	fn.Body.Stmts = p.visitStmtsAndPrependTempRefs(fn.Body.Stmts, prependTempRefsOpts{fnBodyLoc: &fn.Body.Loc, kind: stmtsFnBody})
 	p.popScope()
-	p.lowerFunction(&fn.IsAsync, &fn.Args, fn.Body.Loc, &fn.Body.Stmts, nil, &fn.HasRestArg, false /* isArrow */)
+	p.lowerFunction(&fn.IsAsync, &fn.IsGenerator, &fn.Args, fn.Body.Loc, &fn.Body.Stmts, nil, &fn.HasRestArg, false /* isArrow */)
 	p.popScope()
 
 	p.fnOrArrowDataVisit = oldFnOrArrowData
@@ -12106,6 +12665,17 @@ func (p *parser) appendPart(parts []js_ast.Part, stmts []js_ast.Stmt) []js_ast.P
 		p.relocatedTopLevelVars = nil
 	}
 
+	// Declare any top-level temporary variables generated while lowering
+	if len(p.topLevelTempRefsToDeclare) > 0 {
+		decls := make([]js_ast.Decl, 0, len(p.topLevelTempRefsToDeclare))
+		for _, temp := range p.topLevelTempRefsToDeclare {
+			decls = append(decls, js_ast.Decl{Binding: js_ast.Binding{Data: &js_ast.BIdentifier{Ref: temp.ref}}})
+			p.declaredSymbols = append(p.declaredSymbols, js_ast.DeclaredSymbol{Ref: temp.ref, IsTopLevel: true})
+		}
+		part.Stmts = append(part.Stmts, js_ast.Stmt{Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: decls}})
+		p.topLevelTempRefsToDeclare = nil
+	}
+
 	if len(part.Stmts) > 0 {
 		part.CanBeRemovedIfUnused = p.stmtsCanBeRemovedIfUnused(part.Stmts)
 		part.DeclaredSymbols = p.declaredSymbols
@@ -12195,6 +12765,12 @@ func (p *parser) classCanBeRemovedIfUnused(class js_ast.Class) bool {
 	}
 
 	for _, property := range class.Properties {
+		if property.Kind == js_ast.PropertyClassStaticBlock {
+			if len(property.ClassStaticBlock.Stmts) > 0 {
+				return false
+			}
+			continue
+		}
 		if !p.exprCanBeRemovedIfUnused(property.Key) {
 			return false
 		}
@@ -12620,6 +13196,9 @@ func newParser(log logger.Log, source logger.Source, lexer js_lexer.Lexer, optio
 		privateGetters: make(map[js_ast.Ref]js_ast.Ref),
 		privateSetters: make(map[js_ast.Ref]js_ast.Ref),
 
+		// For lowering "let" and "const"
+		loweredLoopLabel: js_ast.InvalidRef,
+
 		// These are for TypeScript
 		emittedNamespaceVars:      make(map[js_ast.Ref]bool),
 		isExportedInsideNamespace: make(map[js_ast.Ref]js_ast.Ref),
@@ -12998,7 +13577,7 @@ func (p *parser) generateImportStmt(
 	p.moduleScope.Generated = append(p.moduleScope.Generated, namespaceRef)
 	declaredSymbols := make([]js_ast.DeclaredSymbol, len(imports))
 	clauseItems := make([]js_ast.ClauseItem, len(imports))
-	importRecordIndex := p.addImportRecord(ast.ImportStmt, logger.Loc{}, path)
+	importRecordIndex := p.addImportRecord(ast.ImportStmt, logger.Loc{}, path, nil)
 	p.importRecords[importRecordIndex].SourceIndex = ast.MakeIndex32(sourceIndex)
 
 	// Create per-import information
@@ -6,6 +6,7 @@ package js_parser
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
@@ -189,16 +190,22 @@ func (p *parser) markStrictModeFeature(feature strictModeFeature, r logger.Range
 	}
 }
 
-// Mark the feature if "loweredFeature" is unsupported. This is used when one
-// feature is implemented in terms of another feature.
-func (p *parser) markLoweredSyntaxFeature(feature compat.JSFeature, r logger.Range, loweredFeature compat.JSFeature) {
-	if p.options.unsupportedJSFeatures.Has(loweredFeature) {
-		p.markSyntaxFeature(feature, r)
-	}
+func (p *parser) isPrivateUnsupported(private *js_ast.EPrivateIdentifier) bool {
+	return p.lowerAllPrivateNames || p.options.unsupportedJSFeatures.Has(p.symbols[private.Ref.InnerIndex].Kind.Feature())
 }
 
-func (p *parser) isPrivateUnsupported(private *js_ast.EPrivateIdentifier) bool {
-	return p.options.unsupportedJSFeatures.Has(p.symbols[private.Ref.InnerIndex].Kind.Feature())
+// Static blocks and static fields are evaluated in order while the class is
+// being defined. Moving a static block after the class means every static
+// field must be moved after the class too.
+func (p *parser) mustLowerStaticBlocks(class *js_ast.Class) bool {
+	if p.options.unsupportedJSFeatures.Has(compat.ClassStaticBlocks) {
+		for _, prop := range class.Properties {
+			if prop.Kind == js_ast.PropertyClassStaticBlock {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (p *parser) captureThis() js_ast.Ref {
@@ -219,6 +226,7 @@ func (p *parser) captureArguments() js_ast.Ref {
 
 func (p *parser) lowerFunction(
 	isAsync *bool,
+	isGenerator *bool,
 	args *[]js_ast.Arg,
 	bodyLoc logger.Loc,
 	bodyStmts *[]js_ast.Stmt,
@@ -261,7 +269,7 @@ func (p *parser) lowerFunction(
 		// thinking that perhaps scope matters more in real-world code than side
 		// effect order.
 		for i, arg := range *args {
-			if bindingHasObjectRest(arg.Binding) {
+			if p.shouldLowerBinding(arg.Binding) {
 				ref := p.generateTempRef(tempRefNoDeclare, "")
 				target := js_ast.ConvertBindingToExpr(arg.Binding, nil)
 				init := js_ast.Expr{Loc: arg.Binding.Loc, Data: &js_ast.EIdentifier{Ref: ref}}
@@ -283,8 +291,10 @@ func (p *parser) lowerFunction(
 		}
 	}
 
-	// Lower async functions
-	if p.options.unsupportedJSFeatures.Has(compat.AsyncAwait) && *isAsync {
+	// Lower async functions and async generator functions
+	isAsyncGenerator := *isAsync && isGenerator != nil && *isGenerator
+	if *isAsync && ((!isAsyncGenerator && p.options.unsupportedJSFeatures.Has(compat.AsyncAwait)) ||
+		(isAsyncGenerator && p.options.unsupportedJSFeatures.Has(compat.AsyncGenerator))) {
 		// Use the shortened form if we're an arrow function
 		if preferExpr != nil {
 			*preferExpr = true
@@ -313,8 +323,10 @@ func (p *parser) lowerFunction(
 			}
 		}
 
-		// Forward the arguments to the wrapper function
-		usesArgumentsRef := !isArrow && p.fnOnlyDataVisit.argumentsRef != nil && p.symbolUses[*p.fnOnlyDataVisit.argumentsRef].CountEstimate > 0
+		// Forward the arguments to the wrapper function. Note that "arguments" may
+		// have been captured if the generator function will be lowered too.
+		usesArgumentsRef := !isArrow && p.fnOnlyDataVisit.argumentsRef != nil &&
+			(p.symbolUses[*p.fnOnlyDataVisit.argumentsRef].CountEstimate > 0 || p.fnOnlyDataVisit.argumentsCaptureRef != nil)
 		var forwardedArgs js_ast.Expr
 		if !couldThrowErrors && !usesArgumentsRef {
 			// Simple case: the arguments can stay on the outer function. It's
@@ -405,9 +417,21 @@ func (p *parser) lowerFunction(
 			}
 		}
 
+		// The generator function may need to be lowered too
+		if p.options.unsupportedJSFeatures.Has(compat.Generator) {
+			fn.IsGenerator = false
+			fn.Body.Stmts = p.lowerGeneratorBody(bodyLoc, fn.Body.Stmts)
+		}
+
 		// "async function foo(a, b) { stmts }" => "function foo(a, b) { return __async(this, null, function* () { stmts }) }"
+		// "async function* foo(a, b) { stmts }" => "function foo(a, b) { return __asyncGen(this, null, function* () { stmts }) }"
+		runtimeName := "__async"
+		if isAsyncGenerator {
+			runtimeName = "__asyncGen"
+			*isGenerator = false
+		}
 		*isAsync = false
-		callAsync := p.callRuntime(bodyLoc, "__async", []js_ast.Expr{
+		callAsync := p.callRuntime(bodyLoc, runtimeName, []js_ast.Expr{
 			thisValue,
 			forwardedArgs,
 			{Loc: bodyLoc, Data: &js_ast.EFunction{Fn: fn}},
@@ -444,6 +468,30 @@ func (p *parser) lowerFunction(
 			*bodyStmts = []js_ast.Stmt{returnStmt}
 		}
 	}
+
+	// Lower generator functions
+	if isGenerator != nil && *isGenerator && p.options.unsupportedJSFeatures.Has(compat.Generator) {
+		*isGenerator = false
+		*bodyStmts = p.lowerGeneratorBody(bodyLoc, *bodyStmts)
+	}
+}
+
+func (p *parser) maybeLowerAwait(loc logger.Loc, value js_ast.Expr) js_ast.Expr {
+	// "await x" turns into "yield new __awaitValue(x)" inside an async generator
+	if p.fnOrArrowDataVisit.isGenerator && p.options.unsupportedJSFeatures.Has(compat.AsyncGenerator) {
+		value = js_ast.Expr{Loc: loc, Data: &js_ast.ENew{
+			Target: p.importFromRuntime(loc, "__awaitValue"),
+			Args:   []js_ast.Expr{value},
+		}}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EYield{Value: &value}}
+	}
+
+	// "await x" turns into "yield x" inside an async function
+	if p.options.unsupportedJSFeatures.Has(compat.AsyncAwait) {
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EYield{Value: &value}}
+	}
+
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EAwait{Value: value}}
 }
 
 func (p *parser) lowerOptionalChain(expr js_ast.Expr, in exprIn, childOut exprOut) (js_ast.Expr, exprOut) {
@@ -805,6 +853,16 @@ func (p *parser) lowerExponentiationAssignmentOperator(loc logger.Loc, e *js_ast
 			})))
 	}
 
+	if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+		// "super.a **= b" => "__superSet(_super.prototype, 'a', __pow(__superGet(_super.prototype, 'a', this), b), this)"
+		keyFunc, keyWrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, key, valueDefinitelyNotMutated)
+		return keyWrapFunc(p.lowerSuperPropertySet(loc, keyFunc(),
+			p.callRuntime(loc, "__pow", []js_ast.Expr{
+				p.lowerSuperPropertyGet(loc, keyFunc()),
+				e.Right,
+			})))
+	}
+
 	return p.lowerAssignmentOperator(e.Left, func(a js_ast.Expr, b js_ast.Expr) js_ast.Expr {
 		// "a **= b" => "a = __pow(a, b)"
 		return js_ast.Assign(a, p.callRuntime(loc, "__pow", []js_ast.Expr{b, e.Right}))
@@ -830,6 +888,17 @@ func (p *parser) lowerNullishCoalescingAssignmentOperator(loc logger.Loc, e *js_
 		}})
 	}
 
+	if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+		// "super.a ??= b" => "__superGet(_super.prototype, 'a', this) ?? __superSet(_super.prototype, 'a', b, this)"
+		keyFunc, keyWrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, key, valueDefinitelyNotMutated)
+		left := p.lowerSuperPropertyGet(loc, keyFunc())
+		right := p.lowerSuperPropertySet(loc, keyFunc(), e.Right)
+		if p.options.unsupportedJSFeatures.Has(compat.NullishCoalescing) {
+			return keyWrapFunc(p.lowerNullishCoalescing(loc, left, right))
+		}
+		return keyWrapFunc(js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{Op: js_ast.BinOpNullishCoalescing, Left: left, Right: right}})
+	}
+
 	return p.lowerAssignmentOperator(e.Left, func(a js_ast.Expr, b js_ast.Expr) js_ast.Expr {
 		if p.options.unsupportedJSFeatures.Has(compat.NullishCoalescing) {
 			// "a ??= b" => "(_a = a) != null ? _a : a = b"
@@ -857,6 +926,16 @@ func (p *parser) lowerLogicalAssignmentOperator(loc logger.Loc, e *js_ast.EBinar
 		}})
 	}
 
+	if key, ok := p.extractSuperPropertyKey(e.Left); ok {
+		// "super.a &&= b" => "__superGet(_super.prototype, 'a', this) && __superSet(_super.prototype, 'a', b, this)"
+		keyFunc, keyWrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, key, valueDefinitelyNotMutated)
+		return keyWrapFunc(js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+			Op:    op,
+			Left:  p.lowerSuperPropertyGet(loc, keyFunc()),
+			Right: p.lowerSuperPropertySet(loc, keyFunc(), e.Right),
+		}})
+	}
+
 	return p.lowerAssignmentOperator(e.Left, func(a js_ast.Expr, b js_ast.Expr) js_ast.Expr {
 		// "a &&= b" => "a && (a = b)"
 		// "a ||= b" => "a || (a = b)"
@@ -970,6 +1049,62 @@ func (p *parser) lowerObjectSpread(loc logger.Loc, e *js_ast.EObject) js_ast.Exp
 	return result
 }
 
+// Tagged template literals are lowered into a call to the tag function. The
+// template object is created once per call site and then cached in a top-level
+// variable since the tag function may rely on its identity:
+//
+//   // Input:
+//   tag`a${b}c`
+//
+//   // Output:
+//   var _a;
+//   tag(_a || (_a = __template(["a", "c"])), b);
+//
+func (p *parser) lowerTemplateLiteral(loc logger.Loc, e *js_ast.ETemplate) js_ast.Expr {
+	cooked := make([]js_ast.Expr, 0, 1+len(e.Parts))
+	raw := make([]js_ast.Expr, 0, 1+len(e.Parts))
+	rawIsCooked := true
+
+	addString := func(loc logger.Loc, value []uint16, rawValue string) {
+		cooked = append(cooked, js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: value}})
+		raw = append(raw, js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(rawValue)}})
+		if rawIsCooked && !js_lexer.UTF16EqualsString(value, rawValue) {
+			rawIsCooked = false
+		}
+	}
+
+	addString(loc, e.Head, e.HeadRaw)
+	for _, part := range e.Parts {
+		addString(part.TailLoc, part.Tail, part.TailRaw)
+	}
+
+	// The raw strings can be omitted if they are the same as the cooked strings
+	args := []js_ast.Expr{{Loc: loc, Data: &js_ast.EArray{Items: cooked, IsSingleLine: true}}}
+	if !rawIsCooked {
+		args = append(args, js_ast.Expr{Loc: loc, Data: &js_ast.EArray{Items: raw, IsSingleLine: true}})
+	}
+
+	// "tag`a${b}c`" => "tag(_a || (_a = __template(['a', 'c'])), b)"
+	templateRef := p.generateTopLevelTempRef()
+	p.recordUsage(templateRef)
+	p.recordUsage(templateRef)
+	templateObj := js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+		Op:   js_ast.BinOpLogicalOr,
+		Left: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: templateRef}},
+		Right: js_ast.Assign(
+			js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: templateRef}},
+			p.callRuntime(loc, "__template", args),
+		),
+	}}
+
+	callArgs := make([]js_ast.Expr, 0, 1+len(e.Parts))
+	callArgs = append(callArgs, templateObj)
+	for _, part := range e.Parts {
+		callArgs = append(callArgs, part.Value)
+	}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{Target: *e.Tag, Args: callArgs}}
+}
+
 func (p *parser) lowerPrivateGet(target js_ast.Expr, loc logger.Loc, private *js_ast.EPrivateIdentifier) js_ast.Expr {
 	switch p.symbols[private.Ref.InnerIndex].Kind {
 	case js_ast.SymbolPrivateMethod, js_ast.SymbolPrivateStaticMethod:
@@ -1123,6 +1258,28 @@ func exprHasObjectRest(expr js_ast.Expr) bool {
 	return false
 }
 
+// Binding patterns are lowered entirely if destructuring is unsupported.
+// Otherwise only binding patterns containing object rest patterns are lowered.
+func (p *parser) shouldLowerBinding(binding js_ast.Binding) bool {
+	if p.options.unsupportedJSFeatures.Has(compat.Destructuring) {
+		switch binding.Data.(type) {
+		case *js_ast.BArray, *js_ast.BObject:
+			return true
+		}
+	}
+	return bindingHasObjectRest(binding)
+}
+
+func (p *parser) shouldLowerAssignTarget(expr js_ast.Expr) bool {
+	if p.options.unsupportedJSFeatures.Has(compat.Destructuring) {
+		switch expr.Data.(type) {
+		case *js_ast.EArray, *js_ast.EObject:
+			return true
+		}
+	}
+	return exprHasObjectRest(expr)
+}
+
 func (p *parser) lowerObjectRestInDecls(decls []js_ast.Decl) []js_ast.Decl {
 	if !p.options.unsupportedJSFeatures.Has(compat.ObjectRestSpread) {
 		return decls
@@ -1131,7 +1288,7 @@ func (p *parser) lowerObjectRestInDecls(decls []js_ast.Decl) []js_ast.Decl {
 	// Don't do any allocations if there are no object rest patterns. We want as
 	// little overhead as possible in the common case.
 	for i, decl := range decls {
-		if decl.Value != nil && bindingHasObjectRest(decl.Binding) {
+		if decl.Value != nil && p.shouldLowerBinding(decl.Binding) {
 			clone := append([]js_ast.Decl{}, decls[:i]...)
 			for _, decl := range decls[i:] {
 				if decl.Value != nil {
@@ -1162,7 +1319,7 @@ func (p *parser) lowerObjectRestInForLoopInit(init js_ast.Stmt, body *js_ast.Stm
 	case *js_ast.SExpr:
 		// "for ({...x} in y) {}"
 		// "for ({...x} of y) {}"
-		if exprHasObjectRest(s.Value) {
+		if p.shouldLowerAssignTarget(s.Value) {
 			ref := p.generateTempRef(tempRefNeedsDeclare, "")
 			if expr, ok := p.lowerAssign(s.Value, js_ast.Expr{Loc: init.Loc, Data: &js_ast.EIdentifier{Ref: ref}}, objRestReturnValueIsUnused); ok {
 				s.Value.Data = &js_ast.EIdentifier{Ref: ref}
@@ -1173,7 +1330,7 @@ func (p *parser) lowerObjectRestInForLoopInit(init js_ast.Stmt, body *js_ast.Stm
 	case *js_ast.SLocal:
 		// "for (let {...x} in y) {}"
 		// "for (let {...x} of y) {}"
-		if len(s.Decls) == 1 && bindingHasObjectRest(s.Decls[0].Binding) {
+		if len(s.Decls) == 1 && p.shouldLowerBinding(s.Decls[0].Binding) {
 			ref := p.generateTempRef(tempRefNoDeclare, "")
 			decl := js_ast.Decl{Binding: s.Decls[0].Binding, Value: &js_ast.Expr{Loc: init.Loc, Data: &js_ast.EIdentifier{Ref: ref}}}
 			p.recordUsage(ref)
@@ -1200,14 +1357,16 @@ func (p *parser) lowerObjectRestInCatchBinding(catch *js_ast.Catch) {
 		return
 	}
 
-	if catch.Binding != nil && bindingHasObjectRest(*catch.Binding) {
+	if catch.Binding != nil && p.shouldLowerBinding(*catch.Binding) {
 		ref := p.generateTempRef(tempRefNoDeclare, "")
 		decl := js_ast.Decl{Binding: *catch.Binding, Value: &js_ast.Expr{Loc: catch.Binding.Loc, Data: &js_ast.EIdentifier{Ref: ref}}}
 		p.recordUsage(ref)
 		decls := p.lowerObjectRestInDecls([]js_ast.Decl{decl})
 		catch.Binding.Data = &js_ast.BIdentifier{Ref: ref}
+		local := &js_ast.SLocal{Kind: js_ast.LocalLet, Decls: decls}
+		p.lowerBlockScopedDecls(local, false /* isLoopInit */)
 		stmts := make([]js_ast.Stmt, 0, 1+len(catch.Body))
-		stmts = append(stmts, js_ast.Stmt{Loc: catch.Binding.Loc, Data: &js_ast.SLocal{Kind: js_ast.LocalLet, Decls: decls}})
+		stmts = append(stmts, js_ast.Stmt{Loc: catch.Binding.Loc, Data: local})
 		catch.Body = append(stmts, catch.Body...)
 	}
 }
@@ -1326,6 +1485,9 @@ func (p *parser) lowerObjectRestHelper(
 	declare generateTempRefArg,
 	mode objRestMode,
 ) (wrapFunc func(js_ast.Expr) js_ast.Expr, ok bool) {
+	if p.options.unsupportedJSFeatures.Has(compat.Destructuring) {
+		return p.lowerDestructuringHelper(rootExpr, rootInit, assign, declare, mode)
+	}
 	if !p.options.unsupportedJSFeatures.Has(compat.ObjectRestSpread) {
 		return nil, false
 	}
@@ -1588,6 +1750,183 @@ func (p *parser) lowerObjectRestHelper(
 	return wrapFunc, true
 }
 
+// This lowers all binding patterns into individual assignments for browsers
+// that don't support destructuring:
+//
+//   // Input:
+//   let [a, {b, c = 1, ...d}] = e;
+//
+//   // Output:
+//   var a = e[0], _a = e[1], b = _a.b, _b = _a.c, c = _b === void 0 ? 1 : _b,
+//     d = __objRest(_a, ["b", "c"]);
+//
+// Note that array patterns are lowered using indexing, so they only work with
+// array-like values and not with arbitrary iterables.
+func (p *parser) lowerDestructuringHelper(
+	rootExpr js_ast.Expr,
+	rootInit js_ast.Expr,
+	assign func(js_ast.Expr, js_ast.Expr),
+	declare generateTempRefArg,
+	mode objRestMode,
+) (wrapFunc func(js_ast.Expr) js_ast.Expr, ok bool) {
+	switch rootExpr.Data.(type) {
+	case *js_ast.EArray, *js_ast.EObject:
+	default:
+		return nil, false
+	}
+
+	// Identifiers can be referenced multiple times without being captured again
+	// as long as the pattern itself doesn't assign to them. This is always the
+	// case for the temporaries generated here.
+	unsafeRefs := make(map[js_ast.Ref]bool)
+	var findAssignedRefs func(js_ast.Expr)
+	findAssignedRefs = func(expr js_ast.Expr) {
+		switch e := expr.Data.(type) {
+		case *js_ast.EIdentifier:
+			unsafeRefs[e.Ref] = true
+		case *js_ast.EBinary:
+			findAssignedRefs(e.Left)
+		case *js_ast.ESpread:
+			findAssignedRefs(e.Value)
+		case *js_ast.EArray:
+			for _, item := range e.Items {
+				findAssignedRefs(item)
+			}
+		case *js_ast.EObject:
+			for _, property := range e.Properties {
+				findAssignedRefs(*property.Value)
+			}
+		}
+	}
+	findAssignedRefs(rootExpr)
+	capture := func(expr js_ast.Expr, isEmpty bool) js_ast.Expr {
+		if id, ok := expr.Data.(*js_ast.EIdentifier); ok && !unsafeRefs[id.Ref] && !isEmpty {
+			return expr
+		}
+		ref := p.generateTempRef(declare, "")
+		assign(js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EIdentifier{Ref: ref}}, expr)
+		p.recordUsage(ref)
+		return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EIdentifier{Ref: ref}}
+	}
+	use := func(expr js_ast.Expr) js_ast.Expr {
+		if id, ok := expr.Data.(*js_ast.EIdentifier); ok {
+			p.recordUsage(id.Ref)
+		}
+		return expr
+	}
+
+	// "a = b" => "_a === void 0 ? b : _a"
+	withDefault := func(init js_ast.Expr, defaultValue js_ast.Expr) js_ast.Expr {
+		init = capture(init, false)
+		return js_ast.Expr{Loc: init.Loc, Data: &js_ast.EIf{
+			Test: js_ast.Expr{Loc: init.Loc, Data: &js_ast.EBinary{
+				Op:    js_ast.BinOpStrictEq,
+				Left:  use(init),
+				Right: js_ast.Expr{Loc: init.Loc, Data: &js_ast.EUndefined{}},
+			}},
+			Yes: defaultValue,
+			No:  use(init),
+		}}
+	}
+
+	var visit func(js_ast.Expr, js_ast.Expr)
+	visit = func(expr js_ast.Expr, init js_ast.Expr) {
+		switch e := expr.Data.(type) {
+		case *js_ast.EBinary:
+			if e.Op == js_ast.BinOpAssign {
+				visit(e.Left, withDefault(init, e.Right))
+				return
+			}
+
+		case *js_ast.EArray:
+			// The initializer must be evaluated even if nothing is read from it
+			count := 0
+			for _, item := range e.Items {
+				if _, ok := item.Data.(*js_ast.EMissing); !ok {
+					count++
+				}
+			}
+			if count != 1 {
+				init = capture(init, count == 0)
+			}
+			for i, item := range e.Items {
+				loc := item.Loc
+				switch item := item.Data.(type) {
+				case *js_ast.EMissing:
+				case *js_ast.ESpread:
+					// "[...a] = b" => "a = b.slice(0)"
+					visit(item.Value, js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+						Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: use(init), Name: "slice", NameLoc: loc}},
+						Args:   []js_ast.Expr{{Loc: loc, Data: &js_ast.ENumber{Value: float64(i)}}},
+					}})
+				default:
+					// "[a] = b" => "a = b[0]"
+					visit(e.Items[i], js_ast.Expr{Loc: loc, Data: &js_ast.EIndex{
+						Target: use(init),
+						Index:  js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: float64(i)}},
+					}})
+				}
+			}
+			return
+
+		case *js_ast.EObject:
+			last := len(e.Properties) - 1
+			endsWithRestBinding := last >= 0 && e.Properties[last].Kind == js_ast.PropertySpread
+			if len(e.Properties) != 1 || endsWithRestBinding {
+				init = capture(init, len(e.Properties) == 0)
+			}
+			var capturedKeys []func() js_ast.Expr
+			for _, property := range e.Properties {
+				// "{...a} = b" => "a = __objRest(b, [])"
+				if property.Kind == js_ast.PropertySpread {
+					keysToExclude := make([]js_ast.Expr, len(capturedKeys))
+					for i, capturedKey := range capturedKeys {
+						keysToExclude[i] = capturedKey()
+					}
+					visit(*property.Value, p.callRuntime(property.Value.Loc, "__objRest", []js_ast.Expr{use(init),
+						{Loc: property.Value.Loc, Data: &js_ast.EArray{Items: keysToExclude, IsSingleLine: e.IsSingleLine}}}))
+					continue
+				}
+
+				// Save a copy of this key so the rest binding can exclude it
+				key := property.Key
+				if endsWithRestBinding {
+					var capturedKey func() js_ast.Expr
+					key, capturedKey = p.captureKeyForObjectRest(key)
+					capturedKeys = append(capturedKeys, capturedKey)
+				}
+
+				// "{a} = b" => "a = b.a"
+				var value js_ast.Expr
+				if str, ok := key.Data.(*js_ast.EString); ok && js_lexer.IsIdentifier(js_lexer.UTF16ToString(str.Value)) {
+					value = js_ast.Expr{Loc: key.Loc, Data: &js_ast.EDot{Target: use(init), Name: js_lexer.UTF16ToString(str.Value), NameLoc: key.Loc}}
+				} else {
+					value = js_ast.Expr{Loc: key.Loc, Data: &js_ast.EIndex{Target: use(init), Index: key}}
+				}
+				if property.Initializer != nil {
+					value = withDefault(value, *property.Initializer)
+				}
+				visit(*property.Value, value)
+			}
+			return
+		}
+
+		assign(expr, init)
+	}
+
+	// See the comment in "lowerObjectRestHelper" about the return value
+	if mode == objRestMustReturnInitExpr {
+		initFunc, initWrapFunc := p.captureValueWithPossibleSideEffects(rootInit.Loc, 2, rootInit, valueCouldBeMutated)
+		rootInit = initFunc()
+		wrapFunc = func(expr js_ast.Expr) js_ast.Expr {
+			return initWrapFunc(js_ast.JoinWithComma(expr, initFunc()))
+		}
+	}
+
+	visit(rootExpr, rootInit)
+	return wrapFunc, true
+}
+
 // Save a copy of the key for the call to "__objRest" later on. Certain
 // expressions can be converted to keys more efficiently than others.
 func (p *parser) captureKeyForObjectRest(originalKey js_ast.Expr) (finalKey js_ast.Expr, capturedKey func() js_ast.Expr) {
@@ -1634,7 +1973,7 @@ func (p *parser) captureKeyForObjectRest(originalKey js_ast.Expr) (finalKey js_a
 
 // Lower class fields for environments that don't support them. This either
 // takes a statement or an expression.
-func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast.Ref) ([]js_ast.Stmt, js_ast.Expr) {
+func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast.Ref, superRef js_ast.Ref, ctorThisRef js_ast.Ref) ([]js_ast.Stmt, js_ast.Expr) {
 	type classKind uint8
 	const (
 		classKindExpr classKind = iota
@@ -1714,6 +2053,22 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 	var wrapFunc func(js_ast.Expr) js_ast.Expr
 	didCaptureClassExpr := false
 
+	// Instance members are initialized in the constructor. If the class is
+	// lowered to a function, they must be stored on the value returned by the
+	// lowered "super()" call instead of on "this".
+	thisFunc := func(loc logger.Loc) js_ast.Expr {
+		if superRef == js_ast.InvalidRef {
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}}
+		}
+		if ctorThisRef == js_ast.InvalidRef {
+			// The constructor will be generated below
+			ctorThisRef = p.newSymbol(js_ast.SymbolOther, "_this")
+			p.currentScope.Generated = append(p.currentScope.Generated, ctorThisRef)
+		}
+		p.recordUsage(ctorThisRef)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ctorThisRef}}
+	}
+
 	// Class statements can be missing a name if they are in an
 	// "export default" statement:
 	//
@@ -1772,7 +2127,34 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 	// Safari workaround: Automatically avoid TDZ issues when bundling
 	avoidTDZ := p.options.mode == config.ModeBundle && p.currentScope.Parent == nil
 
+	// Static blocks are moved after the class whenever static fields are, since
+	// they must run in order
+	mustLowerStaticBlocks := p.mustLowerStaticBlocks(class)
+	lowerStaticBlocks := mustLowerStaticBlocks || p.options.unsupportedJSFeatures.Has(compat.ClassStaticField) ||
+		(avoidTDZ && shadowRef != js_ast.InvalidRef)
+
 	for _, prop := range class.Properties {
+		if prop.Kind == js_ast.PropertyClassStaticBlock {
+			if !lowerStaticBlocks {
+				class.Properties[end] = prop
+				end++
+				continue
+			}
+
+			// "static { foo() }" => "(() => { foo() })()"
+			if block := prop.ClassStaticBlock; len(block.Stmts) > 0 {
+				body := js_ast.FnBody{Loc: block.Loc, Stmts: block.Stmts}
+				var fn js_ast.E = &js_ast.EArrow{Body: body}
+				if p.options.unsupportedJSFeatures.Has(compat.Arrow) {
+					fn = &js_ast.EFunction{Fn: js_ast.Fn{Body: body}}
+				}
+				staticMembers = append(staticMembers, js_ast.Expr{Loc: block.Loc, Data: &js_ast.ECall{
+					Target: js_ast.Expr{Loc: block.Loc, Data: fn},
+				}})
+			}
+			continue
+		}
+
 		// Merge parameter decorators with method decorators
 		if p.options.ts.Parse && prop.IsMethod {
 			if fn, ok := prop.Value.Data.(*js_ast.EFunction); ok {
@@ -1808,14 +2190,16 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 		shouldOmitFieldInitializer := p.options.ts.Parse && !prop.IsMethod && prop.Initializer == nil &&
 			!p.options.useDefineForClassFields && !mustLowerPrivate
 
-		// Class fields must be lowered if the environment doesn't support them
-		mustLowerField := !prop.IsMethod &&
-			((!prop.IsStatic && p.options.unsupportedJSFeatures.Has(compat.ClassField)) ||
-				(prop.IsStatic && p.options.unsupportedJSFeatures.Has(compat.ClassStaticField)))
+		// Class fields must be lowered if the environment doesn't support them.
+		// Private fields must also be lowered when every private name is lowered.
+		mustLowerField := !prop.IsMethod && (mustLowerPrivate ||
+			(!prop.IsStatic && p.options.unsupportedJSFeatures.Has(compat.ClassField)) ||
+			(prop.IsStatic && p.options.unsupportedJSFeatures.Has(compat.ClassStaticField)))
 
 		// Be conservative and always lower static fields when we're doing TDZ-
 		// avoidance and the shadowing name for the class was captured somewhere.
-		if !prop.IsMethod && prop.IsStatic && avoidTDZ && shadowRef != js_ast.InvalidRef {
+		// Static fields must also stay in order with lowered static blocks.
+		if !prop.IsMethod && prop.IsStatic && ((avoidTDZ && shadowRef != js_ast.InvalidRef) || mustLowerStaticBlocks) {
 			mustLowerField = true
 		}
 
@@ -1915,7 +2299,7 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 				if prop.IsStatic {
 					target = nameFunc()
 				} else {
-					target = js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}}
+					target = thisFunc(loc)
 				}
 
 				// Generate the assignment initializer
@@ -2025,7 +2409,7 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 					if prop.IsStatic {
 						target = nameFunc()
 					} else {
-						target = js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}}
+						target = thisFunc(loc)
 					}
 
 					// Add every newly-constructed instance into this map
@@ -2081,7 +2465,7 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 								if id, ok := arg.Binding.Data.(*js_ast.BIdentifier); ok {
 									parameterFields = append(parameterFields, js_ast.AssignStmt(
 										js_ast.Expr{Loc: arg.Binding.Loc, Data: &js_ast.EDot{
-											Target:  js_ast.Expr{Loc: arg.Binding.Loc, Data: &js_ast.EThis{}},
+											Target:  thisFunc(arg.Binding.Loc),
 											Name:    p.symbols[id.Ref.InnerIndex].OriginalName,
 											NameLoc: arg.Binding.Loc,
 										}},
@@ -2131,7 +2515,7 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 		stmtsFrom := ctor.Fn.Body.Stmts
 		stmtsTo := []js_ast.Stmt{}
 		for i, stmt := range stmtsFrom {
-			if js_ast.IsSuperCall(stmt) {
+			if js_ast.IsSuperCall(stmt) || isLoweredSuperCall(stmt, superRef) {
 				stmtsTo = append(stmtsTo, stmtsFrom[0:i+1]...)
 				stmtsFrom = stmtsFrom[i+1:]
 				break
@@ -2188,6 +2572,11 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 			expr = wrapFunc(expr)
 		}
 
+		// Lower the class itself for browsers that don't support classes
+		if p.options.unsupportedJSFeatures.Has(compat.Class) {
+			p.lowerClassExprToFunction(&expr, superRef, ctorThisRef)
+		}
+
 		// Optionally preserve the name
 		if p.options.keepNames && nameToKeep != "" {
 			expr = p.keepExprSymbolName(expr, nameToKeep)
@@ -2368,69 +2757,2365 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 		// know we won't call "nameFunc" after this point.
 		class.Name = nil
 	}
+	if p.options.unsupportedJSFeatures.Has(compat.Class) {
+		stmts = p.lowerClassStmtsToFunction(stmts, superRef, ctorThisRef)
+	}
 	if keepNameStmt.Data != nil {
 		stmts = append(stmts, keepNameStmt)
 	}
 	return stmts, js_ast.Expr{}
 }
 
-func (p *parser) shouldLowerSuperPropertyAccess(expr js_ast.Expr) bool {
-	if p.fnOrArrowDataVisit.isAsync && p.options.unsupportedJSFeatures.Has(compat.AsyncAwait) {
-		_, isSuper := expr.Data.(*js_ast.ESuper)
-		return isSuper
+// Classes are lowered to a constructor function that is wrapped in another
+// function. The wrapper is passed the value of the "extends" clause:
+//
+//   // Input:
+//   class Foo extends Bar {
+//     constructor() { super(); }
+//     foo() { return super.foo(); }
+//     get bar() { return 1; }
+//   }
+//
+//   // Output:
+//   var Foo = function(_super) {
+//     __inherits(Foo, _super);
+//     function Foo() {
+//       _super.call(this);
+//     }
+//     __defineProperty(Foo.prototype, "foo", {value: function() {
+//       return _super.prototype.foo.call(this);
+//     }});
+//     __defineProperty(Foo.prototype, "bar", {get: function() {
+//       return 1;
+//     }});
+//     return Foo;
+//   }(Bar);
+//
+func (p *parser) lowerClassToFunction(loc logger.Loc, class *js_ast.Class, nameRef js_ast.Ref, superRef js_ast.Ref, ctorThisRef js_ast.Ref) js_ast.Expr {
+	name := func() js_ast.Expr {
+		p.recordUsage(nameRef)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: nameRef}}
 	}
-	return false
-}
 
-func (p *parser) lowerSuperPropertyAccess(loc logger.Loc, key js_ast.Expr) js_ast.Expr {
-	if p.fnOrArrowDataVisit.superIndexRef == nil {
-		ref := p.newSymbol(js_ast.SymbolOther, "__super")
-		p.fnOrArrowDataVisit.superIndexRef = &ref
+	// "__inherits(Foo, _super)"
+	var stmts []js_ast.Stmt
+	if class.Extends != nil {
+		p.recordUsage(superRef)
+		stmts = append(stmts, js_ast.Stmt{Loc: loc, Data: &js_ast.SExpr{Value: p.callRuntime(loc, "__inherits", []js_ast.Expr{
+			name(),
+			{Loc: loc, Data: &js_ast.EIdentifier{Ref: superRef}},
+		})}})
 	}
-	p.recordUsage(*p.fnOrArrowDataVisit.superIndexRef)
-	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
-		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: *p.fnOrArrowDataVisit.superIndexRef}},
-		Args:   []js_ast.Expr{key},
-	}}
-}
 
-func (p *parser) maybeLowerSuperPropertyAccessInsideCall(call *js_ast.ECall) {
-	var key js_ast.Expr
+	// The constructor becomes a function declaration with the name of the class
+	var ctor js_ast.Fn
+	var members []js_ast.Stmt
+	hasCtor := false
+	for _, prop := range class.Properties {
+		if !prop.IsMethod {
+			continue
+		}
+		fn, ok := prop.Value.Data.(*js_ast.EFunction)
+		if !ok {
+			continue
+		}
+		if key, ok := prop.Key.Data.(*js_ast.EString); ok && !prop.IsStatic && !prop.IsComputed &&
+			js_lexer.UTF16EqualsString(key.Value, "constructor") {
+			ctor = fn.Fn
+			hasCtor = true
+			continue
+		}
 
-	switch e := call.Target.Data.(type) {
-	case *js_ast.EDot:
-		// Lower "super.prop" if necessary
-		if !p.shouldLowerSuperPropertyAccess(e.Target) {
-			return
+		// Instance methods go on the prototype, static methods go on the class
+		target := name()
+		if !prop.IsStatic {
+			target = js_ast.Expr{Loc: prop.Key.Loc, Data: &js_ast.EDot{Target: target, Name: "prototype", NameLoc: prop.Key.Loc}}
 		}
-		key = js_ast.Expr{Loc: e.NameLoc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(e.Name)}}
 
-	case *js_ast.EIndex:
-		// Lower "super[prop]" if necessary
-		if !p.shouldLowerSuperPropertyAccess(e.Target) {
-			return
+		// "__defineProperty(Foo.prototype, 'foo', {value: function() {}})"
+		descriptorKey := "value"
+		switch prop.Kind {
+		case js_ast.PropertyGet:
+			descriptorKey = "get"
+		case js_ast.PropertySet:
+			descriptorKey = "set"
 		}
-		key = e.Index
+		members = append(members, js_ast.Stmt{Loc: prop.Key.Loc, Data: &js_ast.SExpr{Value: p.callRuntime(prop.Key.Loc, "__defineProperty", []js_ast.Expr{
+			target,
+			prop.Key,
+			{Loc: prop.Value.Loc, Data: &js_ast.EObject{Properties: []js_ast.Property{{
+				Key:   js_ast.Expr{Loc: prop.Key.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(descriptorKey)}},
+				Value: prop.Value,
+			}}, IsSingleLine: true}},
+		})}})
+	}
 
-	default:
-		return
+	// Derived classes without a constructor forward their arguments to the base
+	// class and return whatever it returns:
+	//
+	//   function Foo() {
+	//     return _super.apply(this, arguments) || this;
+	//   }
+	//
+	if class.Extends != nil && !hasCtor {
+		argumentsRef := p.newSymbol(js_ast.SymbolUnbound, "arguments")
+		p.currentScope.Generated = append(p.currentScope.Generated, argumentsRef)
+		value := js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+			Op: js_ast.BinOpLogicalOr,
+			Left: p.lowerSuperCall(loc, []js_ast.Expr{{Loc: loc, Data: &js_ast.ESpread{Value: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: argumentsRef}}}}},
+				superRef, js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}}),
+			Right: js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}},
+		}}
+		ctor.Body.Stmts = []js_ast.Stmt{{Loc: loc, Data: &js_ast.SReturn{Value: &value}}}
 	}
 
-	// "super.foo(a, b)" => "__superIndex('foo').call(this, a, b)"
-	call.Target.Data = &js_ast.EDot{
-		Target:  p.lowerSuperPropertyAccess(call.Target.Loc, key),
-		NameLoc: key.Loc,
-		Name:    "call",
+	// Otherwise "this" in the constructor was replaced with "_this", which holds
+	// the value returned by the lowered "super()" call:
+	//
+	//   function Foo(x) {
+	//     var _this = _super.call(this, x) || this;
+	//     _this.x = x;
+	//     return _this;
+	//   }
+	//
+	// Base classes may also use "_this" in instance field initializers that
+	// were moved into the constructor, in which case it's just "this".
+	if hasCtor && (class.Extends != nil || ctorThisRef != js_ast.InvalidRef) {
+		if ctorThisRef == js_ast.InvalidRef {
+			ctorThisRef = p.newSymbol(js_ast.SymbolOther, "_this")
+			p.currentScope.Generated = append(p.currentScope.Generated, ctorThisRef)
+		}
+		thisRef := func(loc logger.Loc) js_ast.Expr {
+			p.recordUsage(ctorThisRef)
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ctorThisRef}}
+		}
+
+		// A generated constructor may still contain a "super()" call
+		if class.Extends != nil {
+			for i, stmt := range ctor.Body.Stmts {
+				if js_ast.IsSuperCall(stmt) {
+					call := stmt.Data.(*js_ast.SExpr).Value
+					ctor.Body.Stmts[i].Data = &js_ast.SExpr{Value: js_ast.Assign(thisRef(call.Loc), js_ast.Expr{Loc: call.Loc, Data: &js_ast.EBinary{
+						Op:    js_ast.BinOpLogicalOr,
+						Left:  p.lowerSuperCall(call.Loc, call.Data.(*js_ast.ECall).Args, superRef, js_ast.Expr{Loc: call.Loc, Data: &js_ast.EThis{}}),
+						Right: js_ast.Expr{Loc: call.Loc, Data: &js_ast.EThis{}},
+					}})}
+				}
+			}
+		}
+
+		// Declare "_this" after any directives
+		decl := js_ast.Decl{Binding: js_ast.Binding{Loc: ctor.Body.Loc, Data: &js_ast.BIdentifier{Ref: ctorThisRef}}}
+		var ctorStmts []js_ast.Stmt
+		rest := ctor.Body.Stmts
+		for len(rest) > 0 {
+			if _, ok := rest[0].Data.(*js_ast.SDirective); !ok {
+				break
+			}
+			ctorStmts = append(ctorStmts, rest[0])
+			rest = rest[1:]
+		}
+		if class.Extends == nil {
+			decl.Value = &js_ast.Expr{Loc: ctor.Body.Loc, Data: &js_ast.EThis{}}
+		} else if len(rest) > 0 {
+			// Turn a leading "_this = ..." into the declaration of "_this"
+			if expr, ok := rest[0].Data.(*js_ast.SExpr); ok {
+				if assign, ok := expr.Value.Data.(*js_ast.EBinary); ok && assign.Op == js_ast.BinOpAssign {
+					if id, ok := assign.Left.Data.(*js_ast.EIdentifier); ok && id.Ref == ctorThisRef {
+						decl.Binding.Loc = expr.Value.Loc
+						decl.Value = &assign.Right
+						rest = rest[1:]
+					}
+				}
+			}
+		}
+		ctorStmts = append(ctorStmts, js_ast.Stmt{Loc: decl.Binding.Loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: []js_ast.Decl{decl}}})
+		ctorStmts = append(ctorStmts, rest...)
+
+		// "return _this"
+		if class.Extends != nil {
+			if _, ok := ctorStmts[len(ctorStmts)-1].Data.(*js_ast.SReturn); !ok {
+				value := thisRef(ctor.Body.Loc)
+				ctorStmts = append(ctorStmts, js_ast.Stmt{Loc: ctor.Body.Loc, Data: &js_ast.SReturn{Value: &value}})
+			}
+		}
+		ctor.Body.Stmts = ctorStmts
 	}
-	thisExpr := js_ast.Expr{Loc: call.Target.Loc, Data: &js_ast.EThis{}}
-	call.Args = append([]js_ast.Expr{thisExpr}, call.Args...)
+	ctor.Name = &js_ast.LocRef{Loc: loc, Ref: nameRef}
+	stmts = append(stmts, js_ast.Stmt{Loc: loc, Data: &js_ast.SFunction{Fn: ctor}})
+	stmts = append(stmts, members...)
+
+	// "return Foo"
+	value := name()
+	stmts = append(stmts, js_ast.Stmt{Loc: loc, Data: &js_ast.SReturn{Value: &value}})
+
+	// "function(_super) { ... }(Bar)"
+	var args []js_ast.Arg
+	var callArgs []js_ast.Expr
+	if class.Extends != nil {
+		args = []js_ast.Arg{{Binding: js_ast.Binding{Loc: class.Extends.Loc, Data: &js_ast.BIdentifier{Ref: superRef}}}}
+		callArgs = []js_ast.Expr{*class.Extends}
+	}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EFunction{Fn: js_ast.Fn{
+			Args: args,
+			Body: js_ast.FnBody{Loc: class.BodyLoc, Stmts: stmts},
+		}}},
+		Args: callArgs,
+	}}
 }
 
-func couldPotentiallyThrow(data js_ast.E) bool {
-	switch data.(type) {
-	case *js_ast.ENull, *js_ast.EUndefined, *js_ast.EBoolean, *js_ast.ENumber,
-		*js_ast.EBigInt, *js_ast.EString, *js_ast.EFunction, *js_ast.EArrow:
-		return false
+// The class was either left as a class expression or it was stored in a
+// temporary variable by a comma expression, so find it and replace it
+func (p *parser) lowerClassExprToFunction(expr *js_ast.Expr, superRef js_ast.Ref, ctorThisRef js_ast.Ref) bool {
+	switch e := expr.Data.(type) {
+	case *js_ast.EClass:
+		var nameRef js_ast.Ref
+		if e.Class.Name != nil {
+			nameRef = e.Class.Name.Ref
+		} else {
+			nameRef = p.newSymbol(js_ast.SymbolOther, "_class")
+			p.currentScope.Generated = append(p.currentScope.Generated, nameRef)
+		}
+		*expr = p.lowerClassToFunction(expr.Loc, &e.Class, nameRef, superRef, ctorThisRef)
+		return true
+
+	case *js_ast.EBinary:
+		return p.lowerClassExprToFunction(&e.Left, superRef, ctorThisRef) || p.lowerClassExprToFunction(&e.Right, superRef, ctorThisRef)
 	}
-	return true
+	return false
+}
+
+func (p *parser) lowerClassStmtsToFunction(stmts []js_ast.Stmt, superRef js_ast.Ref, ctorThisRef js_ast.Ref) []js_ast.Stmt {
+	for i, stmt := range stmts {
+		switch s := stmt.Data.(type) {
+		case *js_ast.SClass:
+			// "class Foo {}" => "var Foo = function() { ... }()"
+			local := &js_ast.SLocal{Kind: js_ast.LocalLet, IsExport: s.IsExport, Decls: []js_ast.Decl{{
+				Binding: js_ast.Binding{Loc: s.Class.Name.Loc, Data: &js_ast.BIdentifier{Ref: s.Class.Name.Ref}},
+				Value:   &js_ast.Expr{},
+			}}}
+			*local.Decls[0].Value = p.lowerClassToFunction(stmt.Loc, &s.Class, s.Class.Name.Ref, superRef, ctorThisRef)
+			p.lowerBlockScopedDecls(local, false /* isLoopInit */)
+			stmts[i].Data = local
+
+		case *js_ast.SExportDefault:
+			// "export default class {}" => "export default function() { ... }()"
+			if s.Value.Stmt == nil {
+				break
+			}
+			if s2, ok := s.Value.Stmt.Data.(*js_ast.SClass); ok {
+				nameRef := s.DefaultName.Ref
+				if s2.Class.Name != nil {
+					nameRef = s2.Class.Name.Ref
+				}
+				value := p.lowerClassToFunction(stmt.Loc, &s2.Class, nameRef, superRef, ctorThisRef)
+				s.Value = js_ast.ExprOrStmt{Expr: &value}
+
+				// The class name must still be declared if it was used elsewhere
+				if s2.Class.Name != nil {
+					defaultRef := p.generateTempRef(tempRefNoDeclare, p.source.IdentifierName+"_default")
+					p.recordDeclaredSymbol(defaultRef)
+					p.recordUsage(nameRef)
+					s.Value = js_ast.ExprOrStmt{Expr: &js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EIdentifier{Ref: nameRef}}}
+					s.DefaultName.Ref = defaultRef
+					stmts = append(stmts[:i], append([]js_ast.Stmt{{Loc: stmt.Loc, Data: &js_ast.SLocal{
+						Kind: js_ast.LocalVar,
+						Decls: []js_ast.Decl{{
+							Binding: js_ast.Binding{Loc: s2.Class.Name.Loc, Data: &js_ast.BIdentifier{Ref: nameRef}},
+							Value:   &value,
+						}},
+					}}}, stmts[i:]...)...)
+					return stmts
+				}
+			}
+
+		case *js_ast.SLocal:
+			// "let Foo = class {}" => "var Foo = function() { ... }()"
+			for _, decl := range s.Decls {
+				if decl.Value != nil {
+					if class, ok := decl.Value.Data.(*js_ast.EClass); ok && class.Class.Name == nil {
+						if id, ok := decl.Binding.Data.(*js_ast.BIdentifier); ok {
+							class.Class.Name = &js_ast.LocRef{Loc: decl.Binding.Loc, Ref: id.Ref}
+						}
+					}
+					p.lowerClassExprToFunction(decl.Value, superRef, ctorThisRef)
+				}
+			}
+			p.lowerBlockScopedDecls(s, false /* isLoopInit */)
+		}
+	}
+	return stmts
+}
+
+// "super(a, b)" => "_super.call(this, a, b)"
+// "super(...args)" => "_super.apply(this, args)"
+func (p *parser) lowerSuperCall(loc logger.Loc, args []js_ast.Expr, superRef js_ast.Ref, thisValue js_ast.Expr) js_ast.Expr {
+	p.recordUsage(superRef)
+	method := "call"
+	if len(args) == 1 {
+		if spread, ok := args[0].Data.(*js_ast.ESpread); ok {
+			method = "apply"
+			args = []js_ast.Expr{spread.Value}
+		}
+	}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{
+			Target:  js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: superRef}},
+			Name:    method,
+			NameLoc: loc,
+		}},
+		Args: append([]js_ast.Expr{thisValue}, args...),
+	}}
+}
+
+func isLoweredSuperCall(stmt js_ast.Stmt, superRef js_ast.Ref) bool {
+	if expr, ok := stmt.Data.(*js_ast.SExpr); ok && superRef != js_ast.InvalidRef {
+		if call, ok := expr.Value.Data.(*js_ast.ECall); ok {
+			if dot, ok := call.Target.Data.(*js_ast.EDot); ok {
+				if id, ok := dot.Target.Data.(*js_ast.EIdentifier); ok && id.Ref == superRef {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) shouldLowerSuperPropertyAccess(expr js_ast.Expr) bool {
+	if p.classSuperRef != nil {
+		_, isSuper := expr.Data.(*js_ast.ESuper)
+		return isSuper
+	}
+	if p.fnOrArrowDataVisit.isAsync && p.options.unsupportedJSFeatures.Has(compat.AsyncAwait) {
+		_, isSuper := expr.Data.(*js_ast.ESuper)
+		return isSuper
+	}
+	return false
+}
+
+func (p *parser) lowerSuperPropertyAccess(loc logger.Loc, key js_ast.Expr) js_ast.Expr {
+	// "super.foo" => "_super.prototype.foo" or "_super.foo" if static
+	if p.classSuperRef != nil {
+		target := p.lowerSuperTarget(loc)
+		if str, ok := key.Data.(*js_ast.EString); ok && js_lexer.IsIdentifierUTF16(str.Value) {
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: target, Name: js_lexer.UTF16ToString(str.Value), NameLoc: key.Loc}}
+		}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIndex{Target: target, Index: key}}
+	}
+
+	if p.fnOrArrowDataVisit.superIndexRef == nil {
+		ref := p.newSymbol(js_ast.SymbolOther, "__super")
+		p.fnOrArrowDataVisit.superIndexRef = &ref
+	}
+	p.recordUsage(*p.fnOrArrowDataVisit.superIndexRef)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: *p.fnOrArrowDataVisit.superIndexRef}},
+		Args:   []js_ast.Expr{key},
+	}}
+}
+
+// Returns "_super.prototype" or "_super" if static
+func (p *parser) lowerSuperTarget(loc logger.Loc) js_ast.Expr {
+	p.recordUsage(*p.classSuperRef)
+	target := js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: *p.classSuperRef}}
+	if !p.isClassSuperStatic {
+		target = js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: target, Name: "prototype", NameLoc: loc}}
+	}
+	return target
+}
+
+func (p *parser) lowerSuperThis(loc logger.Loc) js_ast.Expr {
+	// Visit "this" since this may be inside of an arrow function that is lowered
+	return p.visitExpr(js_ast.Expr{Loc: loc, Data: &js_ast.EThis{}})
+}
+
+func (p *parser) lowerSuperPropertyGet(loc logger.Loc, key js_ast.Expr) js_ast.Expr {
+	if p.classSuperRef == nil {
+		return p.lowerSuperPropertyAccess(loc, key)
+	}
+
+	// "super.foo" => "__superGet(_super.prototype, 'foo', this)"
+	return p.callRuntime(loc, "__superGet", []js_ast.Expr{
+		p.lowerSuperTarget(loc),
+		key,
+		p.lowerSuperThis(loc),
+	})
+}
+
+func (p *parser) lowerSuperPropertySet(loc logger.Loc, key js_ast.Expr, value js_ast.Expr) js_ast.Expr {
+	// "super.foo = 123" => "__superSet(_super.prototype, 'foo', 123, this)"
+	return p.callRuntime(loc, "__superSet", []js_ast.Expr{
+		p.lowerSuperTarget(loc),
+		key,
+		value,
+		p.lowerSuperThis(loc),
+	})
+}
+
+func (p *parser) lowerSuperPropertySetUnOp(loc logger.Loc, key js_ast.Expr, op js_ast.OpCode, isSuffix bool) js_ast.Expr {
+	keyFunc, keyWrapFunc := p.captureValueWithPossibleSideEffects(key.Loc, 2, key, valueDefinitelyNotMutated)
+	key = keyFunc()
+
+	// Load the property and then use the unary "+" operator to force it to be
+	// a number, like "lowerPrivateSetUnOp" does
+	value := js_ast.Expr{Loc: loc, Data: &js_ast.EUnary{
+		Op:    js_ast.UnOpPos,
+		Value: p.lowerSuperPropertyGet(loc, keyFunc()),
+	}}
+
+	if isSuffix {
+		// "super.foo++" => "__superSet(_super.prototype, 'foo', (_a = +__superGet(_super.prototype, 'foo', this)) + 1, this), _a"
+		valueFunc, valueWrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, value, valueDefinitelyNotMutated)
+		assign := valueWrapFunc(keyWrapFunc(p.lowerSuperPropertySet(loc, key, js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+			Op:    op,
+			Left:  valueFunc(),
+			Right: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: 1}},
+		}})))
+		return js_ast.JoinWithComma(assign, valueFunc())
+	}
+
+	// "++super.foo" => "__superSet(_super.prototype, 'foo', +__superGet(_super.prototype, 'foo', this) + 1, this)"
+	return keyWrapFunc(p.lowerSuperPropertySet(loc, key, js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+		Op:    op,
+		Left:  value,
+		Right: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: 1}},
+	}}))
+}
+
+func (p *parser) lowerSuperPropertySetBinOp(loc logger.Loc, key js_ast.Expr, op js_ast.OpCode, value js_ast.Expr) js_ast.Expr {
+	// "super.foo += 123" => "__superSet(_super.prototype, 'foo', __superGet(_super.prototype, 'foo', this) + 123, this)"
+	keyFunc, keyWrapFunc := p.captureValueWithPossibleSideEffects(loc, 2, key, valueDefinitelyNotMutated)
+	return keyWrapFunc(p.lowerSuperPropertySet(loc, keyFunc(), js_ast.Expr{Loc: value.Loc, Data: &js_ast.EBinary{
+		Op:    op,
+		Left:  p.lowerSuperPropertyGet(loc, keyFunc()),
+		Right: value,
+	}}))
+}
+
+// Returns the key if target is an assignment target of the form "super.foo"
+// that was lowered to "_super.prototype.foo" while lowering a class
+func (p *parser) extractSuperPropertyKey(target js_ast.Expr) (js_ast.Expr, bool) {
+	if p.classSuperRef == nil {
+		return js_ast.Expr{}, false
+	}
+	switch e := target.Data.(type) {
+	case *js_ast.EDot:
+		if p.isLoweredSuperTarget(e.Target) {
+			return js_ast.Expr{Loc: e.NameLoc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(e.Name)}}, true
+		}
+	case *js_ast.EIndex:
+		if p.isLoweredSuperTarget(e.Target) {
+			return e.Index, true
+		}
+	}
+	return js_ast.Expr{}, false
+}
+
+func (p *parser) isLoweredSuperTarget(target js_ast.Expr) bool {
+	if !p.isClassSuperStatic {
+		dot, ok := target.Data.(*js_ast.EDot)
+		if !ok || dot.Name != "prototype" {
+			return false
+		}
+		target = dot.Target
+	}
+	id, ok := target.Data.(*js_ast.EIdentifier)
+	return ok && id.Ref == *p.classSuperRef
+}
+
+func (p *parser) maybeLowerSuperPropertyAccessInsideCall(call *js_ast.ECall) {
+	var key js_ast.Expr
+
+	switch e := call.Target.Data.(type) {
+	case *js_ast.EDot:
+		// Lower "super.prop" if necessary
+		if !p.shouldLowerSuperPropertyAccess(e.Target) {
+			return
+		}
+		key = js_ast.Expr{Loc: e.NameLoc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(e.Name)}}
+
+	case *js_ast.EIndex:
+		// Lower "super[prop]" if necessary
+		if !p.shouldLowerSuperPropertyAccess(e.Target) {
+			return
+		}
+		key = e.Index
+
+	default:
+		return
+	}
+
+	// "super.foo(a, b)" => "__superIndex('foo').call(this, a, b)"
+	call.Target.Data = &js_ast.EDot{
+		Target:  p.lowerSuperPropertyAccess(call.Target.Loc, key),
+		NameLoc: key.Loc,
+		Name:    "call",
+	}
+	thisExpr := js_ast.Expr{Loc: call.Target.Loc, Data: &js_ast.EThis{}}
+	if p.classSuperRef != nil {
+		thisExpr = p.lowerSuperThis(call.Target.Loc)
+	}
+	call.Args = append([]js_ast.Expr{thisExpr}, call.Args...)
+}
+
+func couldPotentiallyThrow(data js_ast.E) bool {
+	switch data.(type) {
+	case *js_ast.ENull, *js_ast.EUndefined, *js_ast.EBoolean, *js_ast.ENumber,
+		*js_ast.EBigInt, *js_ast.EString, *js_ast.EFunction, *js_ast.EArrow:
+		return false
+	}
+	return true
+}
+
+// Lowers "let" and "const" declarations (as well as lowered class declarations)
+// to "var" declarations. Block-scoped symbols are turned into function-scoped
+// symbols, so they are renamed like they were declared in the nearest function
+// scope to avoid collisions with other symbols of the same name in that scope.
+func (p *parser) lowerBlockScopedDecls(local *js_ast.SLocal, isLoopInit bool) bool {
+	switch local.Kind {
+	case js_ast.LocalLet:
+		if !p.options.unsupportedJSFeatures.Has(compat.Let) {
+			return false
+		}
+	case js_ast.LocalConst:
+		if !p.options.unsupportedJSFeatures.Has(compat.Const) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	local.Kind = js_ast.LocalVar
+	for i, decl := range local.Decls {
+		for _, id := range findIdentifiers(decl.Binding, nil) {
+			p.hoistLoweredBlockScopedSymbol(id.Binding.Data.(*js_ast.BIdentifier).Ref)
+		}
+
+		// A "let" without an initializer inside of a loop must be reset on every
+		// iteration now that it's a "var":
+		//
+		//   // Input:
+		//   for (;;) { let x; x ||= 1 }
+		//
+		//   // Output:
+		//   for (;;) { var x = void 0; x ||= 1 }
+		//
+		if decl.Value == nil && !isLoopInit && p.fnOrArrowDataVisit.isInsideLoop {
+			if _, ok := decl.Binding.Data.(*js_ast.BIdentifier); ok {
+				local.Decls[i].Value = &js_ast.Expr{Loc: decl.Binding.Loc, Data: &js_ast.EUndefined{}}
+			}
+		}
+	}
+	return true
+}
+
+func (p *parser) hoistLoweredBlockScopedSymbol(ref js_ast.Ref) {
+	if p.loweredBlockScopedRefs == nil {
+		p.loweredBlockScopedRefs = make(map[js_ast.Ref]bool)
+	}
+	p.loweredBlockScopedRefs[ref] = true
+
+	// Symbols declared directly inside a loop may be captured by closures
+	for _, loop := range p.loweredLoops {
+		if !p.isNestedInFnInsideLoweredLoop(loop) {
+			loop.declaredRefs[ref] = true
+		}
+	}
+
+	scope := p.currentScope
+	for !scope.Kind.StopsHoisting() {
+		scope = scope.Parent
+	}
+	if scope == p.currentScope {
+		return
+	}
+	scope.Generated = append(scope.Generated, ref)
+	if scope == p.moduleScope {
+		p.declaredSymbols = append(p.declaredSymbols, js_ast.DeclaredSymbol{Ref: ref, IsTopLevel: true})
+	}
+}
+
+// Closures inside of a loop capture a fresh binding for each "let" and "const"
+// declared in the loop on each iteration. Since "var" only has a single binding
+// for the whole function, the body of such a loop is moved into a function
+// that is called on every iteration instead:
+//
+//   // Input:
+//   for (let i = 0; i < 3; i++) {
+//     if (i === 1) continue;
+//     fns.push(() => i);
+//   }
+//
+//   // Output:
+//   var _loop;
+//   for (var i = 0; i < 3; i++) {
+//     _loop = function(i) {
+//       if (i === 1) return;
+//       fns.push(function() { return i; });
+//     };
+//     _loop(i);
+//   }
+//
+type loweredLoop struct {
+	// The scope the loop statement is in. Identifiers that are used inside a
+	// function between this scope and the identifier are used by a closure.
+	scope *js_ast.Scope
+
+	label        js_ast.Ref
+	declaredRefs map[js_ast.Ref]bool
+	closureRefs  map[js_ast.Ref]bool
+	headRefs     map[js_ast.Ref]bool
+
+	// The first construct that can't be moved into a function
+	unsupportedRange logger.Range
+
+	isVisitingBody bool
+	isHeadMutated  bool
+	usesThis       bool
+}
+
+func (p *parser) pushLoweredLoop() *loweredLoop {
+	label := p.loweredLoopLabel
+	p.loweredLoopLabel = js_ast.InvalidRef
+	if !p.options.unsupportedJSFeatures.Has(compat.Let) {
+		return nil
+	}
+	loop := &loweredLoop{
+		scope:        p.currentScope,
+		label:        label,
+		declaredRefs: make(map[js_ast.Ref]bool),
+		closureRefs:  make(map[js_ast.Ref]bool),
+		headRefs:     make(map[js_ast.Ref]bool),
+	}
+	p.loweredLoops = append(p.loweredLoops, loop)
+	return loop
+}
+
+func (p *parser) popLoweredLoop(loop *loweredLoop) {
+	if loop != nil {
+		p.loweredLoops = p.loweredLoops[:len(p.loweredLoops)-1]
+	}
+}
+
+// The variables declared in the head of a loop are passed to the function
+// containing the body of the loop. This is called again if lowering a binding
+// pattern in the head of the loop moved these variables into the body.
+func (p *parser) recordLoweredLoopHead(loop *loweredLoop, init *js_ast.Stmt) {
+	if loop == nil || init == nil {
+		return
+	}
+	loop.headRefs = make(map[js_ast.Ref]bool)
+	if local, ok := init.Data.(*js_ast.SLocal); ok {
+		for _, decl := range local.Decls {
+			for _, id := range findIdentifiers(decl.Binding, nil) {
+				ref := id.Binding.Data.(*js_ast.BIdentifier).Ref
+				if p.loweredBlockScopedRefs[ref] {
+					loop.headRefs[ref] = true
+				}
+			}
+		}
+	}
+}
+
+func (p *parser) isNestedInFnInsideLoweredLoop(loop *loweredLoop) bool {
+	for scope := p.currentScope; scope != nil && scope != loop.scope; scope = scope.Parent {
+		if scope.Kind == js_ast.ScopeFunctionBody {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) recordIdentifierInLoweredLoops(loc logger.Loc, ref js_ast.Ref, assignTarget js_ast.AssignTarget) {
+	isArguments := p.fnOnlyDataVisit.argumentsRef != nil && ref == *p.fnOnlyDataVisit.argumentsRef
+	for _, loop := range p.loweredLoops {
+		isNested := p.isNestedInFnInsideLoweredLoop(loop)
+		if isNested {
+			loop.closureRefs[ref] = true
+		} else if isArguments && loop.isVisitingBody && loop.unsupportedRange.Len == 0 {
+			loop.unsupportedRange = js_lexer.RangeOfIdentifier(p.source, loc)
+		}
+		if assignTarget != js_ast.AssignTargetNone && loop.isVisitingBody && loop.headRefs[ref] && !loop.isHeadMutated {
+			loop.isHeadMutated = true
+			if loop.unsupportedRange.Len == 0 {
+				loop.unsupportedRange = js_lexer.RangeOfIdentifier(p.source, loc)
+			}
+		}
+	}
+}
+
+func (p *parser) recordThisInLoweredLoops() {
+	for _, loop := range p.loweredLoops {
+		if loop.isVisitingBody && !p.isNestedInFnInsideLoweredLoop(loop) {
+			loop.usesThis = true
+		}
+	}
+}
+
+func (loop *loweredLoop) isCapturedByClosure() bool {
+	for ref := range loop.closureRefs {
+		if loop.declaredRefs[ref] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the statements that replace the body of the loop
+func (p *parser) lowerLoopBodyIntoClosure(loop *loweredLoop, body js_ast.Stmt) (js_ast.Stmt, []js_ast.Stmt, bool) {
+	if loop == nil || !loop.isCapturedByClosure() {
+		return body, nil, false
+	}
+
+	// Moving the body into a function changes the meaning of "arguments" and of
+	// jumps to labels outside of the loop, and the function can't update the
+	// variables in the head of the loop
+	if loop.unsupportedRange.Len > 0 {
+		p.markSyntaxFeature(compat.Let, loop.unsupportedRange)
+		return body, nil, false
+	}
+
+	// A "yield" can't cross the function boundary either
+	if stmtContainsYield(body) {
+		p.markSyntaxFeature(compat.Let, logger.Range{Loc: body.Loc})
+		return body, nil, false
+	}
+	jumps := loweredLoopJumps{p: p, loop: loop, labels: make(map[js_ast.Ref]bool)}
+	stmts := jumps.visitStmts([]js_ast.Stmt{body}, 0, 0)
+	if jumps.unsupportedLoc != nil {
+		p.markSyntaxFeature(compat.Let, js_lexer.RangeOfIdentifier(p.source, *jumps.unsupportedLoc))
+		return body, nil, false
+	}
+
+	loc := body.Loc
+	if block, ok := body.Data.(*js_ast.SBlock); ok {
+		stmts = block.Stmts
+	}
+
+	// The variables in the head of the loop become the arguments of the function.
+	// They use the same symbols as the variables in the head of the loop since
+	// the arguments shadow them.
+	var args []js_ast.Arg
+	var callArgs []js_ast.Expr
+	for _, ref := range loop.sortedHeadRefs() {
+		args = append(args, js_ast.Arg{Binding: js_ast.Binding{Loc: loc, Data: &js_ast.BIdentifier{Ref: ref}}})
+		callArgs = append(callArgs, js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}})
+		p.recordUsage(ref)
+	}
+
+	// "_loop = function(i) { ... }"
+	loopRef := p.generateTempRef(tempRefNeedsDeclare, "_loop")
+	p.recordUsage(loopRef)
+	result := []js_ast.Stmt{js_ast.AssignStmt(
+		js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: loopRef}},
+		js_ast.Expr{Loc: loc, Data: &js_ast.EFunction{Fn: js_ast.Fn{
+			Args: args,
+			Body: js_ast.FnBody{Loc: loc, Stmts: stmts},
+		}}},
+	)}
+
+	// "_loop(i)" or "_loop.call(this, i)"
+	p.recordUsage(loopRef)
+	call := js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: loopRef}},
+		Args:   callArgs,
+	}}
+	if loop.usesThis {
+		call.Data = &js_ast.ECall{
+			Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{
+				Target:  js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: loopRef}},
+				Name:    "call",
+				NameLoc: loc,
+			}},
+			Args: append([]js_ast.Expr{{Loc: loc, Data: &js_ast.EThis{}}}, callArgs...),
+		}
+	}
+
+	breakStmt := js_ast.Stmt{Loc: loc, Data: &js_ast.SBreak{}}
+	switch {
+	case !jumps.hasBreak && !jumps.hasReturn:
+		result = append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SExpr{Value: call}})
+
+	case !jumps.hasReturn:
+		// "if (_loop(i) === 'break') break"
+		result = append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SIf{
+			Test: js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+				Op:    js_ast.BinOpStrictEq,
+				Left:  call,
+				Right: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16("break")}},
+			}},
+			Yes: breakStmt,
+		}})
+
+	default:
+		// "_ret = _loop(i); if (_ret === 'break') break; if (typeof _ret === 'object') return _ret.v"
+		retRef := p.generateTempRef(tempRefNeedsDeclare, "_ret")
+		ret := func() js_ast.Expr {
+			p.recordUsage(retRef)
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: retRef}}
+		}
+		result = append(result, js_ast.AssignStmt(ret(), call))
+		if jumps.hasBreak {
+			result = append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SIf{
+				Test: js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+					Op:    js_ast.BinOpStrictEq,
+					Left:  ret(),
+					Right: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16("break")}},
+				}},
+				Yes: breakStmt,
+			}})
+		}
+		value := js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: ret(), Name: "v", NameLoc: loc}}
+		result = append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SIf{
+			Test: js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+				Op:    js_ast.BinOpStrictEq,
+				Left:  js_ast.Expr{Loc: loc, Data: &js_ast.EUnary{Op: js_ast.UnOpTypeof, Value: ret()}},
+				Right: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16("object")}},
+			}},
+			Yes: js_ast.Stmt{Loc: loc, Data: &js_ast.SReturn{Value: &value}},
+		}})
+	}
+
+	// Variables declared with "var" inside the body still belong to the
+	// enclosing function, so they are declared before the loop
+	var before []js_ast.Stmt
+	if len(jumps.vars) > 0 {
+		decls := make([]js_ast.Decl, len(jumps.vars))
+		for i, local := range jumps.vars {
+			decls[i] = js_ast.Decl{Binding: js_ast.Binding{Loc: local.Loc, Data: &js_ast.BIdentifier{Ref: local.Ref}}}
+		}
+		before = append(before, js_ast.Stmt{Loc: loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: decls}})
+	}
+
+	return js_ast.Stmt{Loc: loc, Data: &js_ast.SBlock{Stmts: result}}, before, true
+}
+
+func (loop *loweredLoop) sortedHeadRefs() []js_ast.Ref {
+	refs := make([]js_ast.Ref, 0, len(loop.headRefs))
+	for ref := range loop.headRefs {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i int, j int) bool {
+		return refs[i].InnerIndex < refs[j].InnerIndex
+	})
+	return refs
+}
+
+// Rewrites the jumps inside a loop body that is moved into a function. This
+// doesn't visit nested functions since jumps can't cross function boundaries.
+type loweredLoopJumps struct {
+	p              *parser
+	loop           *loweredLoop
+	labels         map[js_ast.Ref]bool
+	vars           []js_ast.LocRef
+	unsupportedLoc *logger.Loc
+	hasBreak       bool
+	hasReturn      bool
+}
+
+func (j *loweredLoopJumps) visitStmts(stmts []js_ast.Stmt, loopDepth int, switchDepth int) []js_ast.Stmt {
+	result := stmts[:0]
+	for _, stmt := range stmts {
+		if stmt, ok := j.visitStmt(stmt, loopDepth, switchDepth); ok {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+func (j *loweredLoopJumps) visitSingleStmt(stmt js_ast.Stmt, loopDepth int, switchDepth int) js_ast.Stmt {
+	if stmt, ok := j.visitStmt(stmt, loopDepth, switchDepth); ok {
+		return stmt
+	}
+	return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SEmpty{}}
+}
+
+func (j *loweredLoopJumps) visitStmt(stmt js_ast.Stmt, loopDepth int, switchDepth int) (js_ast.Stmt, bool) {
+	switch s := stmt.Data.(type) {
+	case *js_ast.SBreak:
+		if s.Label != nil {
+			if j.labels[s.Label.Ref] {
+				break
+			}
+			if s.Label.Ref != j.loop.label {
+				j.markUnsupported(s.Label.Loc)
+				break
+			}
+		} else if loopDepth > 0 || switchDepth > 0 {
+			break
+		}
+
+		// "break" => "return 'break'"
+		j.hasBreak = true
+		return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SReturn{Value: &js_ast.Expr{Loc: stmt.Loc,
+			Data: &js_ast.EString{Value: js_lexer.StringToUTF16("break")}}}}, true
+
+	case *js_ast.SContinue:
+		if s.Label != nil {
+			if j.labels[s.Label.Ref] {
+				break
+			}
+			if s.Label.Ref != j.loop.label {
+				j.markUnsupported(s.Label.Loc)
+				break
+			}
+		} else if loopDepth > 0 {
+			break
+		}
+
+		// "continue" => "return"
+		return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SReturn{}}, true
+
+	case *js_ast.SReturn:
+		// "return x" => "return {v: x}"
+		j.hasReturn = true
+		value := js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EUndefined{}}
+		if s.Value != nil {
+			value = *s.Value
+		}
+		return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SReturn{Value: &js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EObject{
+			Properties: []js_ast.Property{{
+				Key:   js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16("v")}},
+				Value: &value,
+			}},
+			IsSingleLine: true,
+		}}}}, true
+
+	case *js_ast.SLocal:
+		if value, ok := j.varToAssign(s); ok {
+			if value.Data == nil {
+				return stmt, false
+			}
+			return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SExpr{Value: value}}, true
+		}
+
+	case *js_ast.SBlock:
+		s.Stmts = j.visitStmts(s.Stmts, loopDepth, switchDepth)
+
+	case *js_ast.SIf:
+		s.Yes = j.visitSingleStmt(s.Yes, loopDepth, switchDepth)
+		if s.No != nil {
+			*s.No = j.visitSingleStmt(*s.No, loopDepth, switchDepth)
+		}
+
+	case *js_ast.SLabel:
+		j.labels[s.Name.Ref] = true
+		s.Stmt = j.visitSingleStmt(s.Stmt, loopDepth, switchDepth)
+
+	case *js_ast.SWith:
+		s.Body = j.visitSingleStmt(s.Body, loopDepth, switchDepth)
+
+	case *js_ast.STry:
+		s.Body = j.visitStmts(s.Body, loopDepth, switchDepth)
+		if s.Catch != nil {
+			s.Catch.Body = j.visitStmts(s.Catch.Body, loopDepth, switchDepth)
+		}
+		if s.Finally != nil {
+			s.Finally.Stmts = j.visitStmts(s.Finally.Stmts, loopDepth, switchDepth)
+		}
+
+	case *js_ast.SSwitch:
+		for i := range s.Cases {
+			s.Cases[i].Body = j.visitStmts(s.Cases[i].Body, loopDepth, switchDepth+1)
+		}
+
+	case *js_ast.SFor:
+		if s.Init != nil {
+			if local, ok := s.Init.Data.(*js_ast.SLocal); ok {
+				if value, ok := j.varToAssign(local); ok {
+					if value.Data == nil {
+						s.Init = nil
+					} else {
+						s.Init = &js_ast.Stmt{Loc: s.Init.Loc, Data: &js_ast.SExpr{Value: value}}
+					}
+				}
+			}
+		}
+		s.Body = j.visitSingleStmt(s.Body, loopDepth+1, switchDepth)
+
+	case *js_ast.SForIn:
+		j.forInOrOfInitToAssign(&s.Init)
+		s.Body = j.visitSingleStmt(s.Body, loopDepth+1, switchDepth)
+
+	case *js_ast.SForOf:
+		j.forInOrOfInitToAssign(&s.Init)
+		s.Body = j.visitSingleStmt(s.Body, loopDepth+1, switchDepth)
+
+	case *js_ast.SWhile:
+		s.Body = j.visitSingleStmt(s.Body, loopDepth+1, switchDepth)
+
+	case *js_ast.SDoWhile:
+		s.Body = j.visitSingleStmt(s.Body, loopDepth+1, switchDepth)
+	}
+
+	return stmt, true
+}
+
+func (j *loweredLoopJumps) markUnsupported(loc logger.Loc) {
+	if j.unsupportedLoc == nil {
+		j.unsupportedLoc = &loc
+	}
+}
+
+// Converts a "var" declaration inside the loop body into an assignment. Lowered
+// "let" and "const" declarations are left alone since they belong to the body.
+func (j *loweredLoopJumps) varToAssign(local *js_ast.SLocal) (js_ast.Expr, bool) {
+	if local.Kind != js_ast.LocalVar || len(local.Decls) == 0 {
+		return js_ast.Expr{}, false
+	}
+	for _, decl := range local.Decls {
+		for _, id := range findIdentifiers(decl.Binding, nil) {
+			if j.p.loweredBlockScopedRefs[id.Binding.Data.(*js_ast.BIdentifier).Ref] {
+				return js_ast.Expr{}, false
+			}
+		}
+	}
+	wrapIdentifier := func(loc logger.Loc, ref js_ast.Ref) js_ast.Expr {
+		j.vars = append(j.vars, js_ast.LocRef{Loc: loc, Ref: ref})
+		j.p.recordUsage(ref)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}}
+	}
+	var value js_ast.Expr
+	for _, decl := range local.Decls {
+		binding := js_ast.ConvertBindingToExpr(decl.Binding, wrapIdentifier)
+		if decl.Value != nil {
+			value = js_ast.JoinWithComma(value, js_ast.Assign(binding, *decl.Value))
+		}
+	}
+	return value, true
+}
+
+func (j *loweredLoopJumps) forInOrOfInitToAssign(init *js_ast.Stmt) {
+	if local, ok := init.Data.(*js_ast.SLocal); ok && len(local.Decls) == 1 {
+		if _, ok := j.varToAssign(local); ok {
+			wrapIdentifier := func(loc logger.Loc, ref js_ast.Ref) js_ast.Expr {
+				return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}}
+			}
+			*init = js_ast.Stmt{Loc: init.Loc, Data: &js_ast.SExpr{Value: js_ast.ConvertBindingToExpr(local.Decls[0].Binding, wrapIdentifier)}}
+		}
+	}
+}
+
+// "for await" loops are converted into regular loops that call the methods of
+// the async iterator directly:
+//
+//   // Input:
+//   for await (let x of y) z(x);
+//
+//   // Output:
+//   try {
+//     for (var iter = __forAwait(y), more, temp, error; more = !(temp = await iter.next()).done; more = false) {
+//       let x = temp.value;
+//       z(x);
+//     }
+//   } catch (temp) {
+//     error = [temp];
+//   } finally {
+//     try {
+//       more && (temp = iter.return) && await temp.call(iter);
+//     } finally {
+//       if (error)
+//         throw error[0];
+//     }
+//   }
+//
+func (p *parser) lowerForAwaitLoop(loc logger.Loc, loop *js_ast.SForOf) js_ast.Stmt {
+	iterRef := p.generateTempRef(tempRefNoDeclare, "iter")
+	moreRef := p.generateTempRef(tempRefNoDeclare, "more")
+	tempRef := p.generateTempRef(tempRefNoDeclare, "temp")
+	errorRef := p.generateTempRef(tempRefNoDeclare, "error")
+	ref := func(ref js_ast.Ref) js_ast.Expr {
+		p.recordUsage(ref)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}}
+	}
+	binding := func(ref js_ast.Ref) js_ast.Binding {
+		return js_ast.Binding{Loc: loc, Data: &js_ast.BIdentifier{Ref: ref}}
+	}
+
+	// "let x = temp.value" or "x = temp.value"
+	value := js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: ref(tempRef), Name: "value", NameLoc: loc}}
+	var assign js_ast.Stmt
+	switch init := loop.Init.Data.(type) {
+	case *js_ast.SLocal:
+		assign = js_ast.Stmt{Loc: loop.Init.Loc, Data: &js_ast.SLocal{Kind: init.Kind, Decls: []js_ast.Decl{{
+			Binding: init.Decls[0].Binding,
+			Value:   &value,
+		}}}}
+	case *js_ast.SExpr:
+		assign = js_ast.AssignStmt(init.Value, value)
+	}
+	var bodyStmts []js_ast.Stmt
+	if block, ok := loop.Body.Data.(*js_ast.SBlock); ok {
+		bodyStmts = append([]js_ast.Stmt{assign}, block.Stmts...)
+	} else {
+		bodyStmts = []js_ast.Stmt{assign, loop.Body}
+	}
+
+	// "more = !(temp = await iter.next()).done"
+	next := p.maybeLowerAwait(loc, js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: ref(iterRef), Name: "next", NameLoc: loc}},
+	}})
+	test := js_ast.Assign(ref(moreRef), js_ast.Not(js_ast.Expr{Loc: loc, Data: &js_ast.EDot{
+		Target:  js_ast.Assign(ref(tempRef), next),
+		Name:    "done",
+		NameLoc: loc,
+	}}))
+	update := js_ast.Assign(ref(moreRef), js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: false}})
+	forAwait := p.callRuntime(loc, "__forAwait", []js_ast.Expr{loop.Value})
+	forStmt := js_ast.Stmt{Loc: loc, Data: &js_ast.SFor{
+		Init: &js_ast.Stmt{Loc: loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: []js_ast.Decl{
+			{Binding: binding(iterRef), Value: &forAwait},
+			{Binding: binding(moreRef)},
+			{Binding: binding(tempRef)},
+			{Binding: binding(errorRef)},
+		}}},
+		Test:   &test,
+		Update: &update,
+		Body:   js_ast.Stmt{Loc: loop.Body.Loc, Data: &js_ast.SBlock{Stmts: bodyStmts}},
+	}}
+
+	// "more && (temp = iter.return) && await temp.call(iter)"
+	callReturn := p.maybeLowerAwait(loc, js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: ref(tempRef), Name: "call", NameLoc: loc}},
+		Args:   []js_ast.Expr{ref(iterRef)},
+	}})
+	closeIter := js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+		Op: js_ast.BinOpLogicalAnd,
+		Left: js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+			Op:    js_ast.BinOpLogicalAnd,
+			Left:  ref(moreRef),
+			Right: js_ast.Assign(ref(tempRef), js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: ref(iterRef), Name: "return", NameLoc: loc}}),
+		}},
+		Right: callReturn,
+	}}
+
+	// "if (error) throw error[0]"
+	rethrow := js_ast.Stmt{Loc: loc, Data: &js_ast.SIf{
+		Test: ref(errorRef),
+		Yes: js_ast.Stmt{Loc: loc, Data: &js_ast.SThrow{Value: js_ast.Expr{Loc: loc, Data: &js_ast.EIndex{
+			Target: ref(errorRef),
+			Index:  js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: 0}},
+		}}}},
+	}}
+
+	catchBinding := binding(tempRef)
+	return js_ast.Stmt{Loc: loc, Data: &js_ast.STry{
+		BodyLoc: loc,
+		Body:    []js_ast.Stmt{forStmt},
+		Catch: &js_ast.Catch{
+			Loc:     loc,
+			Binding: &catchBinding,
+			Body: []js_ast.Stmt{js_ast.AssignStmt(ref(errorRef), js_ast.Expr{Loc: loc, Data: &js_ast.EArray{
+				Items:        []js_ast.Expr{ref(tempRef)},
+				IsSingleLine: true,
+			}})},
+		},
+		Finally: &js_ast.Finally{Loc: loc, Stmts: []js_ast.Stmt{{Loc: loc, Data: &js_ast.STry{
+			BodyLoc: loc,
+			Body:    []js_ast.Stmt{{Loc: loc, Data: &js_ast.SExpr{Value: closeIter}}},
+			Finally: &js_ast.Finally{Loc: loc, Stmts: []js_ast.Stmt{rethrow}},
+		}}}},
+	}}
+}
+
+// Generator functions are converted into a state machine when they aren't
+// supported. The body is moved into a function that is called by the
+// "__stateMachine" runtime helper every time the generator resumes. It's split
+// into numbered cases wherever execution can resume or jump to, and it returns
+// an instruction for the runtime helper whenever it needs to yield, return, or
+// jump somewhere else:
+//
+//   // Input:
+//   function* foo(x) {
+//     try {
+//       var y = yield x;
+//     } finally {
+//       bar(y);
+//     }
+//   }
+//
+//   // Output:
+//   function foo(x) {
+//     var y;
+//     return __stateMachine(this, function(_state) {
+//       switch (_state.label) {
+//         case 0:
+//           _state.trys.push([0, 0, 2, 3]);
+//           return [1, x, 1];
+//         case 1:
+//           y = _state.sent;
+//           return [3, 3];
+//         case 2:
+//           bar(y);
+//           return [4];
+//         case 3:
+//       }
+//     });
+//   }
+//
+// Variables declared in the body are hoisted into the outer function so that
+// they keep their values between calls to the state machine function.
+func (p *parser) lowerGeneratorBody(loc logger.Loc, stmts []js_ast.Stmt) []js_ast.Stmt {
+	g := generatorLowering{
+		p:            p,
+		stateRef:     p.newSymbol(js_ast.SymbolOther, "_state"),
+		cases:        []js_ast.Case{{Value: &js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: 0}}}},
+		hoistedRefs:  make(map[js_ast.Ref]bool),
+		tempRefs:     make(map[js_ast.Ref]bool),
+		pendingLabel: js_ast.InvalidRef,
+	}
+	p.currentScope.Generated = append(p.currentScope.Generated, g.stateRef)
+
+	// Some statements must stay in the outer function
+	var outerStmts []js_ast.Stmt
+	for _, stmt := range stmts {
+		switch s := stmt.Data.(type) {
+		case *js_ast.SDirective, *js_ast.SFunction:
+			outerStmts = append(outerStmts, stmt)
+			continue
+
+		case *js_ast.SLocal:
+			// The captured value of "arguments" must come from the outer function
+			if p.fnOnlyDataVisit.argumentsRef != nil && s.Kind == js_ast.LocalVar {
+				var captured []js_ast.Decl
+				var decls []js_ast.Decl
+				for _, decl := range s.Decls {
+					if decl.Value != nil {
+						if id, ok := decl.Value.Data.(*js_ast.EIdentifier); ok && id.Ref == *p.fnOnlyDataVisit.argumentsRef {
+							captured = append(captured, decl)
+							continue
+						}
+					}
+					decls = append(decls, decl)
+				}
+				if len(captured) > 0 {
+					outerStmts = append(outerStmts, js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: captured}})
+					if len(decls) == 0 {
+						continue
+					}
+					stmt = js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: decls}}
+				}
+			}
+		}
+		g.visitStmt(stmt)
+	}
+
+	// Assign case numbers to all labels now that every case has been generated
+	for _, label := range g.labels {
+		for _, number := range label.numbers {
+			number.Value = float64(label.caseIndex)
+		}
+	}
+
+	// Avoid the "switch" statement if there's only one case
+	body := g.cases[0].Body
+	if len(g.cases) > 1 {
+		body = []js_ast.Stmt{{Loc: loc, Data: &js_ast.SSwitch{
+			Test:    js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: g.state(loc), Name: "label", NameLoc: loc}},
+			BodyLoc: loc,
+			Cases:   g.cases,
+		}}}
+	}
+
+	// "return __stateMachine(this, function(_state) { ... })"
+	var result []js_ast.Stmt
+	if len(g.hoisted) > 0 {
+		result = append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SLocal{Kind: js_ast.LocalVar, Decls: g.hoisted}})
+	}
+	result = append(result, outerStmts...)
+	callStateMachine := p.callRuntime(loc, "__stateMachine", []js_ast.Expr{
+		{Loc: loc, Data: &js_ast.EThis{}},
+		{Loc: loc, Data: &js_ast.EFunction{Fn: js_ast.Fn{
+			Args: []js_ast.Arg{{Binding: js_ast.Binding{Loc: loc, Data: &js_ast.BIdentifier{Ref: g.stateRef}}}},
+			Body: js_ast.FnBody{Loc: loc, Stmts: body},
+		}}},
+	})
+	return append(result, js_ast.Stmt{Loc: loc, Data: &js_ast.SReturn{Value: &callStateMachine}})
+}
+
+// The instructions returned by the state machine function
+const (
+	generatorOpReturn float64 = iota
+	generatorOpYield
+	generatorOpYieldStar
+	generatorOpJump
+	generatorOpEndFinally
+)
+
+type generatorLowering struct {
+	p        *parser
+	stateRef js_ast.Ref
+	cases    []js_ast.Case
+	labels   []generatorLabel
+	jumps    []generatorJump
+
+	// Every variable declared in the body is hoisted into the outer function
+	hoisted     []js_ast.Decl
+	hoistedRefs map[js_ast.Ref]bool
+
+	// Temporary variables are never reassigned after they are initialized
+	tempRefs  map[js_ast.Ref]bool
+	tempCount int
+
+	// This is the name of the label on the loop that's about to be visited
+	pendingLabel js_ast.Ref
+}
+
+type generatorLabel struct {
+	caseIndex int
+	numbers   []*js_ast.ENumber
+}
+
+type generatorJump struct {
+	name          js_ast.Ref
+	breakLabel    int
+	continueLabel int // This is -1 if this isn't a loop
+	isLabelOnly   bool
+}
+
+func (g *generatorLowering) newLabel() int {
+	g.labels = append(g.labels, generatorLabel{})
+	return len(g.labels) - 1
+}
+
+// Execution can jump to the current position using this label. A new case is
+// started unless the current case is still empty.
+func (g *generatorLowering) mark(label int) {
+	if last := len(g.cases) - 1; len(g.cases[last].Body) == 0 {
+		g.labels[label].caseIndex = last
+		return
+	}
+	g.cases = append(g.cases, js_ast.Case{Value: &js_ast.Expr{Data: &js_ast.ENumber{Value: float64(len(g.cases))}}})
+	g.labels[label].caseIndex = len(g.cases) - 1
+}
+
+func (g *generatorLowering) label(loc logger.Loc, label int) js_ast.Expr {
+	number := &js_ast.ENumber{}
+	g.labels[label].numbers = append(g.labels[label].numbers, number)
+	return js_ast.Expr{Loc: loc, Data: number}
+}
+
+func (g *generatorLowering) emit(stmt js_ast.Stmt) {
+	last := &g.cases[len(g.cases)-1]
+	last.Body = append(last.Body, stmt)
+}
+
+func (g *generatorLowering) state(loc logger.Loc) js_ast.Expr {
+	g.p.recordUsage(g.stateRef)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: g.stateRef}}
+}
+
+// "return [op, ...args]"
+func (g *generatorLowering) instruction(loc logger.Loc, op float64, args ...js_ast.Expr) js_ast.Stmt {
+	items := append([]js_ast.Expr{{Loc: loc, Data: &js_ast.ENumber{Value: op}}}, args...)
+	return js_ast.Stmt{Loc: loc, Data: &js_ast.SReturn{Value: &js_ast.Expr{Loc: loc, Data: &js_ast.EArray{
+		Items:        items,
+		IsSingleLine: true,
+	}}}}
+}
+
+func (g *generatorLowering) jump(loc logger.Loc, label int) js_ast.Stmt {
+	return g.instruction(loc, generatorOpJump, g.label(loc, label))
+}
+
+// "if (test) return [3, label]"
+func (g *generatorLowering) emitJumpIf(test js_ast.Expr, label int) {
+	g.emit(js_ast.Stmt{Loc: test.Loc, Data: &js_ast.SIf{Test: test, Yes: g.jump(test.Loc, label)}})
+}
+
+func (g *generatorLowering) hoist(binding js_ast.Binding) {
+	for _, decl := range findIdentifiers(binding, nil) {
+		ref := decl.Binding.Data.(*js_ast.BIdentifier).Ref
+		if !g.hoistedRefs[ref] {
+			g.hoistedRefs[ref] = true
+			g.hoisted = append(g.hoisted, js_ast.Decl{Binding: decl.Binding})
+			g.p.currentScope.Generated = append(g.p.currentScope.Generated, ref)
+		}
+	}
+}
+
+func (g *generatorLowering) newTemp(loc logger.Loc) js_ast.Ref {
+	ref := g.p.newSymbol(js_ast.SymbolOther, "_"+js_ast.DefaultNameMinifier.NumberToMinifiedName(g.tempCount))
+	g.tempCount++
+	g.tempRefs[ref] = true
+	g.hoist(js_ast.Binding{Loc: loc, Data: &js_ast.BIdentifier{Ref: ref}})
+	return ref
+}
+
+func (g *generatorLowering) ref(loc logger.Loc, ref js_ast.Ref) js_ast.Expr {
+	g.p.recordUsage(ref)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: ref}}
+}
+
+// The value of an expression must be stored in a temporary variable if other
+// code will run between when it's evaluated and when it's used
+func (g *generatorLowering) save(expr js_ast.Expr) js_ast.Expr {
+	switch e := expr.Data.(type) {
+	case *js_ast.ENumber, *js_ast.EString, *js_ast.EBoolean, *js_ast.ENull, *js_ast.EUndefined,
+		*js_ast.EThis, *js_ast.EMissing, *js_ast.EFunction, *js_ast.EArrow:
+		return expr
+
+	case *js_ast.EIdentifier:
+		if g.tempRefs[e.Ref] {
+			return expr
+		}
+
+	case *js_ast.ESpread:
+		return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ESpread{Value: g.save(e.Value)}}
+	}
+
+	ref := g.newTemp(expr.Loc)
+	g.emit(js_ast.AssignStmt(g.ref(expr.Loc, ref), expr))
+	return g.ref(expr.Loc, ref)
+}
+
+func (g *generatorLowering) unsupported(loc logger.Loc) {
+	g.p.markSyntaxFeature(compat.Generator, logger.Range{Loc: loc})
+}
+
+func (g *generatorLowering) findJump(loc logger.Loc, label *js_ast.LocRef, isContinue bool) int {
+	for i := len(g.jumps) - 1; i >= 0; i-- {
+		jump := g.jumps[i]
+		if label != nil {
+			if jump.name != label.Ref {
+				continue
+			}
+		} else if jump.isLabelOnly || (isContinue && jump.continueLabel == -1) {
+			continue
+		}
+		if isContinue {
+			return jump.continueLabel
+		}
+		return jump.breakLabel
+	}
+	g.unsupported(loc)
+	return 0
+}
+
+func (g *generatorLowering) pushJump(breakLabel int, continueLabel int) {
+	g.jumps = append(g.jumps, generatorJump{name: g.pendingLabel, breakLabel: breakLabel, continueLabel: continueLabel})
+	g.pendingLabel = js_ast.InvalidRef
+}
+
+func (g *generatorLowering) popJump() {
+	g.jumps = g.jumps[:len(g.jumps)-1]
+}
+
+// "var a = 1, b" => "a = 1" after "a" and "b" have been hoisted
+func (g *generatorLowering) localToAssign(local *js_ast.SLocal) (value js_ast.Expr) {
+	for _, decl := range local.Decls {
+		g.hoist(decl.Binding)
+		if decl.Value != nil {
+			value = js_ast.JoinWithComma(value, js_ast.Assign(js_ast.ConvertBindingToExpr(decl.Binding, nil), *decl.Value))
+		} else if local.Kind != js_ast.LocalVar {
+			// A "let" without an initializer must still be reset
+			value = js_ast.JoinWithComma(value, js_ast.Assign(js_ast.ConvertBindingToExpr(decl.Binding, nil),
+				js_ast.Expr{Loc: decl.Binding.Loc, Data: &js_ast.EUndefined{}}))
+		}
+	}
+	return
+}
+
+func (g *generatorLowering) visitStmts(stmts []js_ast.Stmt) {
+	for _, stmt := range stmts {
+		g.visitStmt(stmt)
+	}
+}
+
+func (g *generatorLowering) visitStmt(stmt js_ast.Stmt) {
+	// Statements without a "yield" don't need to be split up
+	if !stmtContainsYield(stmt) {
+		for _, stmt := range g.rewriteStmts([]js_ast.Stmt{stmt}, generatorRewriteOpts{isDirectlyInBody: true}) {
+			g.emit(stmt)
+		}
+		return
+	}
+
+	switch s := stmt.Data.(type) {
+	case *js_ast.SExpr:
+		g.emitExprForEffect(s.Value)
+
+	case *js_ast.SLocal:
+		for _, decl := range s.Decls {
+			g.hoist(decl.Binding)
+			if decl.Value != nil {
+				target := js_ast.ConvertBindingToExpr(decl.Binding, nil)
+				if bindingContainsYield(decl.Binding) {
+					g.unsupported(decl.Binding.Loc)
+				}
+				g.emit(js_ast.AssignStmt(target, g.visitExpr(*decl.Value)))
+			}
+		}
+
+	case *js_ast.SReturn:
+		g.emit(g.instruction(stmt.Loc, generatorOpReturn, g.visitExpr(*s.Value)))
+
+	case *js_ast.SThrow:
+		g.emit(js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SThrow{Value: g.visitExpr(s.Value)}})
+
+	case *js_ast.SBlock:
+		g.visitStmts(s.Stmts)
+
+	case *js_ast.SLabel:
+		switch s.Stmt.Data.(type) {
+		case *js_ast.SFor, *js_ast.SForIn, *js_ast.SForOf, *js_ast.SWhile, *js_ast.SDoWhile:
+			g.pendingLabel = s.Name.Ref
+			g.visitStmt(s.Stmt)
+
+		default:
+			end := g.newLabel()
+			g.jumps = append(g.jumps, generatorJump{name: s.Name.Ref, breakLabel: end, continueLabel: -1, isLabelOnly: true})
+			g.visitStmt(s.Stmt)
+			g.popJump()
+			g.mark(end)
+		}
+
+	case *js_ast.SIf:
+		end := g.newLabel()
+		otherwise := end
+		if s.No != nil {
+			otherwise = g.newLabel()
+		}
+		g.emitJumpIf(js_ast.Not(g.visitExpr(s.Test)), otherwise)
+		g.visitStmt(s.Yes)
+		if s.No != nil {
+			g.emit(g.jump(stmt.Loc, end))
+			g.mark(otherwise)
+			g.visitStmt(*s.No)
+		}
+		g.mark(end)
+
+	case *js_ast.SWhile:
+		head := g.newLabel()
+		end := g.newLabel()
+		g.mark(head)
+		g.emitJumpIf(js_ast.Not(g.visitExpr(s.Test)), end)
+		g.pushJump(end, head)
+		g.visitStmt(s.Body)
+		g.popJump()
+		g.emit(g.jump(stmt.Loc, head))
+		g.mark(end)
+
+	case *js_ast.SDoWhile:
+		head := g.newLabel()
+		test := g.newLabel()
+		end := g.newLabel()
+		g.mark(head)
+		g.pushJump(end, test)
+		g.visitStmt(s.Body)
+		g.popJump()
+		g.mark(test)
+		g.emitJumpIf(g.visitExpr(s.Test), head)
+		g.mark(end)
+
+	case *js_ast.SFor:
+		label := g.pendingLabel
+		g.pendingLabel = js_ast.InvalidRef
+		if s.Init != nil {
+			g.visitStmt(*s.Init)
+		}
+		head := g.newLabel()
+		update := g.newLabel()
+		end := g.newLabel()
+		g.mark(head)
+		if s.Test != nil {
+			g.emitJumpIf(js_ast.Not(g.visitExpr(*s.Test)), end)
+		}
+		g.pendingLabel = label
+		g.pushJump(end, update)
+		g.visitStmt(s.Body)
+		g.popJump()
+		g.mark(update)
+		if s.Update != nil {
+			g.emitExprForEffect(*s.Update)
+		}
+		g.emit(g.jump(stmt.Loc, head))
+		g.mark(end)
+
+	case *js_ast.SForIn:
+		// The keys are collected up front so the loop can be split up:
+		//
+		//   for (_b in _a = obj) _c.push(_b);
+		//   for (_d = 0; _d < _c.length; _d++) { x = _c[_d]; ... }
+		//
+		loc := stmt.Loc
+		object := g.visitExpr(s.Value)
+		keys := g.newTemp(loc)
+		key := g.newTemp(loc)
+		index := g.newTemp(loc)
+		g.emit(js_ast.AssignStmt(g.ref(loc, keys), js_ast.Expr{Loc: loc, Data: &js_ast.EArray{}}))
+		g.emit(js_ast.Stmt{Loc: loc, Data: &js_ast.SForIn{
+			Init:  js_ast.Stmt{Loc: loc, Data: &js_ast.SExpr{Value: g.ref(loc, key)}},
+			Value: object,
+			Body: js_ast.Stmt{Loc: loc, Data: &js_ast.SExpr{Value: js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+				Target: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: g.ref(loc, keys), Name: "push", NameLoc: loc}},
+				Args:   []js_ast.Expr{g.ref(loc, key)},
+			}}}},
+		}})
+		g.emit(js_ast.AssignStmt(g.ref(loc, index), js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: 0}}))
+		head := g.newLabel()
+		update := g.newLabel()
+		end := g.newLabel()
+		g.mark(head)
+		g.emitJumpIf(js_ast.Not(js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+			Op:    js_ast.BinOpLt,
+			Left:  g.ref(loc, index),
+			Right: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: g.ref(loc, keys), Name: "length", NameLoc: loc}},
+		}}), end)
+		value := js_ast.Expr{Loc: loc, Data: &js_ast.EIndex{Target: g.ref(loc, keys), Index: g.ref(loc, index)}}
+		switch init := s.Init.Data.(type) {
+		case *js_ast.SLocal:
+			g.hoist(init.Decls[0].Binding)
+			g.emit(js_ast.AssignStmt(js_ast.ConvertBindingToExpr(init.Decls[0].Binding, nil), value))
+		case *js_ast.SExpr:
+			g.emit(js_ast.AssignStmt(g.visitAssignTarget(init.Value, false), value))
+		}
+		g.pushJump(end, update)
+		g.visitStmt(s.Body)
+		g.popJump()
+		g.mark(update)
+		g.emit(js_ast.Stmt{Loc: loc, Data: &js_ast.SExpr{Value: js_ast.Expr{Loc: loc, Data: &js_ast.EUnary{
+			Op:    js_ast.UnOpPostInc,
+			Value: g.ref(loc, index),
+		}}}})
+		g.emit(g.jump(loc, head))
+		g.mark(end)
+
+	case *js_ast.STry:
+		// Each try statement registers its labels with the runtime helper:
+		//
+		//   _state.trys.push([tryLabel, catchLabel, finallyLabel, endLabel])
+		//
+		start := g.newLabel()
+		end := g.newLabel()
+		g.mark(start)
+		entry := []js_ast.Expr{g.label(stmt.Loc, start), {Loc: stmt.Loc, Data: &js_ast.ENumber{}}, {Loc: stmt.Loc, Data: &js_ast.ENumber{}}, g.label(stmt.Loc, end)}
+		catch, finally := -1, -1
+		if s.Catch != nil {
+			catch = g.newLabel()
+			entry[1] = g.label(stmt.Loc, catch)
+		}
+		if s.Finally != nil {
+			finally = g.newLabel()
+			entry[2] = g.label(stmt.Loc, finally)
+		}
+		g.emit(js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SExpr{Value: js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.ECall{
+			Target: js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EDot{
+				Target:  js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EDot{Target: g.state(stmt.Loc), Name: "trys", NameLoc: stmt.Loc}},
+				Name:    "push",
+				NameLoc: stmt.Loc,
+			}},
+			Args: []js_ast.Expr{{Loc: stmt.Loc, Data: &js_ast.EArray{Items: entry, IsSingleLine: true}}},
+		}}}})
+		g.visitStmts(s.Body)
+		g.emit(g.jump(stmt.Loc, end))
+
+		// "catch (e) { ... }" => "e = _state.sent; ..."
+		if s.Catch != nil {
+			g.mark(catch)
+			if s.Catch.Binding != nil {
+				g.hoist(*s.Catch.Binding)
+				g.emit(js_ast.AssignStmt(js_ast.ConvertBindingToExpr(*s.Catch.Binding, nil),
+					js_ast.Expr{Loc: s.Catch.Loc, Data: &js_ast.EDot{Target: g.state(s.Catch.Loc), Name: "sent", NameLoc: s.Catch.Loc}}))
+			}
+			g.visitStmts(s.Catch.Body)
+			g.emit(g.jump(s.Catch.Loc, end))
+		}
+
+		// "finally { ... }" => "...; return [4]"
+		if s.Finally != nil {
+			g.mark(finally)
+			g.visitStmts(s.Finally.Stmts)
+			g.emit(g.instruction(s.Finally.Loc, generatorOpEndFinally))
+		}
+		g.mark(end)
+
+	case *js_ast.SSwitch:
+		// "switch (x) { case y: ... }" => "if (x === y) return [3, label]; ..."
+		test := g.save(g.visitExpr(s.Test))
+		end := g.newLabel()
+		labels := make([]int, len(s.Cases))
+		defaultLabel := end
+		for i, c := range s.Cases {
+			labels[i] = g.newLabel()
+			if c.Value == nil {
+				defaultLabel = labels[i]
+				continue
+			}
+			g.emitJumpIf(js_ast.Expr{Loc: c.Value.Loc, Data: &js_ast.EBinary{
+				Op:    js_ast.BinOpStrictEq,
+				Left:  test,
+				Right: g.visitExpr(*c.Value),
+			}}, labels[i])
+		}
+		g.emit(g.jump(stmt.Loc, defaultLabel))
+		g.pushJump(end, -1)
+		for i, c := range s.Cases {
+			g.mark(labels[i])
+			g.visitStmts(c.Body)
+		}
+		g.popJump()
+		g.mark(end)
+
+	default:
+		// This includes "for-of" loops and "with" statements
+		g.unsupported(stmt.Loc)
+		g.emit(stmt)
+	}
+}
+
+func (g *generatorLowering) emitExprForEffect(expr js_ast.Expr) {
+	expr = g.visitExpr(expr)
+	switch e := expr.Data.(type) {
+	case *js_ast.EIdentifier, *js_ast.EUndefined:
+		return
+
+	case *js_ast.EDot:
+		// "_state.sent"
+		if id, ok := e.Target.Data.(*js_ast.EIdentifier); ok && id.Ref == g.stateRef {
+			return
+		}
+	}
+	g.emit(js_ast.Stmt{Loc: expr.Loc, Data: &js_ast.SExpr{Value: expr}})
+}
+
+// Expressions with "yield" inside them are split up by evaluating everything
+// before each "yield" into temporary variables. The returned expression has
+// no "yield" and is valid in the current case.
+func (g *generatorLowering) visitExpr(expr js_ast.Expr) js_ast.Expr {
+	if !exprContainsYield(expr) {
+		return expr
+	}
+	loc := expr.Loc
+
+	switch e := expr.Data.(type) {
+	case *js_ast.EYield:
+		value := js_ast.Expr{Loc: loc, Data: &js_ast.EUndefined{}}
+		if e.Value != nil {
+			value = g.visitExpr(*e.Value)
+		}
+		op := generatorOpYield
+		if e.IsStar {
+			op = generatorOpYieldStar
+		}
+		resume := g.newLabel()
+		g.emit(g.instruction(loc, op, value, g.label(loc, resume)))
+		g.mark(resume)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: g.state(loc), Name: "sent", NameLoc: loc}}
+
+	case *js_ast.EBinary:
+		switch e.Op {
+		case js_ast.BinOpComma:
+			g.emitExprForEffect(e.Left)
+			return g.visitExpr(e.Right)
+
+		case js_ast.BinOpLogicalAnd, js_ast.BinOpLogicalOr, js_ast.BinOpNullishCoalescing:
+			// "a && b" => "_a = a; if (!_a) return [3, label]; _a = b; label:"
+			temp := g.newTemp(loc)
+			g.emit(js_ast.AssignStmt(g.ref(loc, temp), g.visitExpr(e.Left)))
+			end := g.newLabel()
+			switch e.Op {
+			case js_ast.BinOpLogicalAnd:
+				g.emitJumpIf(js_ast.Not(g.ref(loc, temp)), end)
+			case js_ast.BinOpLogicalOr:
+				g.emitJumpIf(g.ref(loc, temp), end)
+			default:
+				g.emitJumpIf(js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{
+					Op:    js_ast.BinOpLooseNe,
+					Left:  g.ref(loc, temp),
+					Right: js_ast.Expr{Loc: loc, Data: &js_ast.ENull{}},
+				}}, end)
+			}
+			g.emit(js_ast.AssignStmt(g.ref(loc, temp), g.visitExpr(e.Right)))
+			g.mark(end)
+			return g.ref(loc, temp)
+
+		case js_ast.BinOpAssign:
+			target := g.visitAssignTarget(e.Left, exprContainsYield(e.Right))
+			return js_ast.Assign(target, g.visitExpr(e.Right))
+
+		default:
+			if op, ok := compoundAssignOps[e.Op]; ok {
+				// "a.b += yield c" => "_a = a; _b = _a.b; ...; _a.b = _b + _state.sent"
+				target := g.visitAssignTarget(e.Left, true)
+				old := g.save(cloneAssignTarget(target))
+				return js_ast.Assign(target, js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{Op: op, Left: old, Right: g.visitExpr(e.Right)}})
+			}
+			if e.Op.BinaryAssignTarget() != js_ast.AssignTargetNone {
+				g.unsupported(loc)
+				return expr
+			}
+			items := g.visitOperands([]js_ast.Expr{e.Left, e.Right})
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EBinary{Op: e.Op, Left: items[0], Right: items[1]}}
+		}
+
+	case *js_ast.EUnary:
+		if e.Op.UnaryAssignTarget() != js_ast.AssignTargetNone || e.Op == js_ast.UnOpDelete {
+			return js_ast.Expr{Loc: loc, Data: &js_ast.EUnary{Op: e.Op, Value: g.visitAssignTarget(e.Value, false)}}
+		}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EUnary{Op: e.Op, Value: g.visitExpr(e.Value)}}
+
+	case *js_ast.EIf:
+		// "a ? b : c" => "if (!a) return [3, no]; _a = b; return [3, end]; no: _a = c; end:"
+		temp := g.newTemp(loc)
+		no := g.newLabel()
+		end := g.newLabel()
+		g.emitJumpIf(js_ast.Not(g.visitExpr(e.Test)), no)
+		g.emit(js_ast.AssignStmt(g.ref(loc, temp), g.visitExpr(e.Yes)))
+		g.emit(g.jump(loc, end))
+		g.mark(no)
+		g.emit(js_ast.AssignStmt(g.ref(loc, temp), g.visitExpr(e.No)))
+		g.mark(end)
+		return g.ref(loc, temp)
+
+	case *js_ast.EDot:
+		clone := *e
+		clone.Target = g.visitExpr(e.Target)
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.EIndex:
+		items := g.visitOperands([]js_ast.Expr{e.Target, e.Index})
+		clone := *e
+		clone.Target = items[0]
+		clone.Index = items[1]
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.ECall:
+		clone := *e
+		argsContainYield := false
+		for _, arg := range e.Args {
+			if exprContainsYield(arg) {
+				argsContainYield = true
+				break
+			}
+		}
+
+		// The property must be read before evaluating the arguments, but the
+		// call must still pass the object as "this":
+		//
+		//   "a.b(yield c)" => "_a = a; _b = _a.b; ...; _b.call(_a, _state.sent)"
+		//
+		if argsContainYield {
+			var object, fn js_ast.Expr
+			switch target := e.Target.Data.(type) {
+			case *js_ast.EDot:
+				object = g.save(g.visitExpr(target.Target))
+				fn = g.save(js_ast.Expr{Loc: e.Target.Loc, Data: &js_ast.EDot{Target: object, Name: target.Name, NameLoc: target.NameLoc}})
+			case *js_ast.EIndex:
+				items := g.visitOperands([]js_ast.Expr{target.Target, target.Index, {Loc: loc, Data: &js_ast.EYield{}}})
+				object = g.save(items[0])
+				fn = g.save(js_ast.Expr{Loc: e.Target.Loc, Data: &js_ast.EIndex{Target: object, Index: items[1]}})
+			}
+			if fn.Data != nil {
+				clone.Target = js_ast.Expr{Loc: e.Target.Loc, Data: &js_ast.EDot{Target: fn, Name: "call", NameLoc: e.Target.Loc}}
+				clone.Args = append([]js_ast.Expr{object}, g.visitOperands(e.Args)...)
+				return js_ast.Expr{Loc: loc, Data: &clone}
+			}
+		}
+
+		items := g.visitOperands(append([]js_ast.Expr{e.Target}, e.Args...))
+		clone.Target = items[0]
+		clone.Args = items[1:]
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.ENew:
+		items := g.visitOperands(append([]js_ast.Expr{e.Target}, e.Args...))
+		clone := *e
+		clone.Target = items[0]
+		clone.Args = items[1:]
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.EArray:
+		clone := *e
+		clone.Items = g.visitOperands(e.Items)
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.EObject:
+		// Keys and values are evaluated in order
+		var operands []js_ast.Expr
+		for _, property := range e.Properties {
+			if property.IsComputed {
+				operands = append(operands, property.Key)
+			}
+			if property.Value != nil && !property.IsMethod {
+				operands = append(operands, *property.Value)
+			}
+		}
+		operands = g.visitOperands(operands)
+		clone := *e
+		clone.Properties = append([]js_ast.Property{}, e.Properties...)
+		for i := range clone.Properties {
+			property := &clone.Properties[i]
+			if property.IsComputed {
+				property.Key = operands[0]
+				operands = operands[1:]
+			}
+			if property.Value != nil && !property.IsMethod {
+				property.Value = &operands[0]
+				operands = operands[1:]
+			}
+		}
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.ETemplate:
+		var operands []js_ast.Expr
+		if e.Tag != nil {
+			operands = append(operands, *e.Tag)
+		}
+		for _, part := range e.Parts {
+			operands = append(operands, part.Value)
+		}
+		operands = g.visitOperands(operands)
+		clone := *e
+		if e.Tag != nil {
+			clone.Tag = &operands[0]
+			operands = operands[1:]
+		}
+		clone.Parts = append([]js_ast.TemplatePart{}, e.Parts...)
+		for i := range clone.Parts {
+			clone.Parts[i].Value = operands[i]
+		}
+		return js_ast.Expr{Loc: loc, Data: &clone}
+
+	case *js_ast.ESpread:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ESpread{Value: g.visitExpr(e.Value)}}
+
+	case *js_ast.EImport:
+		clone := *e
+		if e.OptionsOrNil.Data != nil {
+			items := g.visitOperands([]js_ast.Expr{e.Expr, e.OptionsOrNil})
+			clone.Expr = items[0]
+			clone.OptionsOrNil = items[1]
+		} else {
+			clone.Expr = g.visitExpr(e.Expr)
+		}
+		return js_ast.Expr{Loc: loc, Data: &clone}
+	}
+
+	g.unsupported(loc)
+	return expr
+}
+
+// Operands are evaluated in order. Everything before the last operand that
+// contains a "yield" must be saved before that "yield" happens.
+func (g *generatorLowering) visitOperands(exprs []js_ast.Expr) []js_ast.Expr {
+	last := -1
+	for i, expr := range exprs {
+		if exprContainsYield(expr) {
+			last = i
+		}
+	}
+	result := make([]js_ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		if i < last {
+			result[i] = g.save(g.visitExpr(expr))
+		} else if i == last {
+			result[i] = g.visitExpr(expr)
+		} else {
+			result[i] = expr
+		}
+	}
+	return result
+}
+
+func (g *generatorLowering) visitAssignTarget(target js_ast.Expr, mustSave bool) js_ast.Expr {
+	switch e := target.Data.(type) {
+	case *js_ast.EIdentifier:
+		return target
+
+	case *js_ast.EDot:
+		object := g.visitExpr(e.Target)
+		if mustSave {
+			object = g.save(object)
+		}
+		return js_ast.Expr{Loc: target.Loc, Data: &js_ast.EDot{Target: object, Name: e.Name, NameLoc: e.NameLoc}}
+
+	case *js_ast.EIndex:
+		items := []js_ast.Expr{e.Target, e.Index}
+		if mustSave {
+			items = g.visitOperands(append(items, js_ast.Expr{Loc: target.Loc, Data: &js_ast.EYield{}}))
+			items[0] = g.save(items[0])
+			items[1] = g.save(items[1])
+		} else {
+			items = g.visitOperands(items)
+		}
+		return js_ast.Expr{Loc: target.Loc, Data: &js_ast.EIndex{Target: items[0], Index: items[1]}}
+	}
+
+	if exprContainsYield(target) {
+		g.unsupported(target.Loc)
+	}
+	return target
+}
+
+func cloneAssignTarget(target js_ast.Expr) js_ast.Expr {
+	switch e := target.Data.(type) {
+	case *js_ast.EDot:
+		clone := *e
+		return js_ast.Expr{Loc: target.Loc, Data: &clone}
+	case *js_ast.EIndex:
+		clone := *e
+		return js_ast.Expr{Loc: target.Loc, Data: &clone}
+	}
+	return target
+}
+
+var compoundAssignOps = map[js_ast.OpCode]js_ast.OpCode{
+	js_ast.BinOpAddAssign:        js_ast.BinOpAdd,
+	js_ast.BinOpSubAssign:        js_ast.BinOpSub,
+	js_ast.BinOpMulAssign:        js_ast.BinOpMul,
+	js_ast.BinOpDivAssign:        js_ast.BinOpDiv,
+	js_ast.BinOpRemAssign:        js_ast.BinOpRem,
+	js_ast.BinOpPowAssign:        js_ast.BinOpPow,
+	js_ast.BinOpShlAssign:        js_ast.BinOpShl,
+	js_ast.BinOpShrAssign:        js_ast.BinOpShr,
+	js_ast.BinOpUShrAssign:       js_ast.BinOpUShr,
+	js_ast.BinOpBitwiseOrAssign:  js_ast.BinOpBitwiseOr,
+	js_ast.BinOpBitwiseAndAssign: js_ast.BinOpBitwiseAnd,
+	js_ast.BinOpBitwiseXorAssign: js_ast.BinOpBitwiseXor,
+}
+
+type generatorRewriteOpts struct {
+	localLabels       []js_ast.Ref
+	isDirectlyInBody  bool
+	isInsideLoop      bool
+	isInsideBreakable bool
+}
+
+// Statements without a "yield" are kept mostly as-is. But variable
+// declarations must be turned into assignments to the hoisted variables, and
+// "return", "break", and "continue" must be turned into instructions if they
+// leave the statement.
+func (g *generatorLowering) rewriteStmts(stmts []js_ast.Stmt, opts generatorRewriteOpts) []js_ast.Stmt {
+	result := make([]js_ast.Stmt, 0, len(stmts))
+	for _, stmt := range stmts {
+		switch s := stmt.Data.(type) {
+		case *js_ast.SLocal:
+			if s.Kind == js_ast.LocalVar || opts.isDirectlyInBody {
+				if value := g.localToAssign(s); value.Data != nil {
+					result = append(result, js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SExpr{Value: value}})
+				}
+				continue
+			}
+
+		case *js_ast.SClass:
+			if opts.isDirectlyInBody {
+				g.hoist(js_ast.Binding{Loc: s.Class.Name.Loc, Data: &js_ast.BIdentifier{Ref: s.Class.Name.Ref}})
+				result = append(result, js_ast.AssignStmt(g.ref(s.Class.Name.Loc, s.Class.Name.Ref),
+					js_ast.Expr{Loc: stmt.Loc, Data: &js_ast.EClass{Class: s.Class}}))
+				continue
+			}
+
+		case *js_ast.SReturn:
+			var args []js_ast.Expr
+			if s.Value != nil {
+				args = append(args, *s.Value)
+			}
+			stmt = g.instruction(stmt.Loc, generatorOpReturn, args...)
+
+		case *js_ast.SBreak:
+			if s.Label != nil {
+				if !refsContain(opts.localLabels, s.Label.Ref) {
+					stmt = g.jump(stmt.Loc, g.findJump(stmt.Loc, s.Label, false))
+				}
+			} else if !opts.isInsideBreakable {
+				stmt = g.jump(stmt.Loc, g.findJump(stmt.Loc, nil, false))
+			}
+
+		case *js_ast.SContinue:
+			if s.Label != nil {
+				if !refsContain(opts.localLabels, s.Label.Ref) {
+					stmt = g.jump(stmt.Loc, g.findJump(stmt.Loc, s.Label, true))
+				}
+			} else if !opts.isInsideLoop {
+				stmt = g.jump(stmt.Loc, g.findJump(stmt.Loc, nil, true))
+			}
+
+		case *js_ast.SBlock:
+			s.Stmts = g.rewriteStmts(s.Stmts, opts.nested())
+
+		case *js_ast.SLabel:
+			nested := opts.nested()
+			nested.localLabels = append(append([]js_ast.Ref{}, opts.localLabels...), s.Name.Ref)
+			s.Stmt = g.rewriteStmt(s.Stmt, nested)
+
+		case *js_ast.SIf:
+			s.Yes = g.rewriteStmt(s.Yes, opts.nested())
+			if s.No != nil {
+				no := g.rewriteStmt(*s.No, opts.nested())
+				s.No = &no
+			}
+
+		case *js_ast.SFor:
+			if s.Init != nil {
+				if local, ok := s.Init.Data.(*js_ast.SLocal); ok && local.Kind == js_ast.LocalVar {
+					if value := g.localToAssign(local); value.Data != nil {
+						s.Init = &js_ast.Stmt{Loc: s.Init.Loc, Data: &js_ast.SExpr{Value: value}}
+					} else {
+						s.Init = nil
+					}
+				}
+			}
+			s.Body = g.rewriteStmt(s.Body, opts.loop())
+
+		case *js_ast.SForIn:
+			g.rewriteForInOrOfInit(&s.Init)
+			s.Body = g.rewriteStmt(s.Body, opts.loop())
+
+		case *js_ast.SForOf:
+			g.rewriteForInOrOfInit(&s.Init)
+			s.Body = g.rewriteStmt(s.Body, opts.loop())
+
+		case *js_ast.SWhile:
+			s.Body = g.rewriteStmt(s.Body, opts.loop())
+
+		case *js_ast.SDoWhile:
+			s.Body = g.rewriteStmt(s.Body, opts.loop())
+
+		case *js_ast.SWith:
+			s.Body = g.rewriteStmt(s.Body, opts.nested())
+
+		case *js_ast.STry:
+			s.Body = g.rewriteStmts(s.Body, opts.nested())
+			if s.Catch != nil {
+				s.Catch.Body = g.rewriteStmts(s.Catch.Body, opts.nested())
+			}
+			if s.Finally != nil {
+				s.Finally.Stmts = g.rewriteStmts(s.Finally.Stmts, opts.nested())
+			}
+
+		case *js_ast.SSwitch:
+			nested := opts.nested()
+			nested.isInsideBreakable = true
+			for i, c := range s.Cases {
+				s.Cases[i].Body = g.rewriteStmts(c.Body, nested)
+			}
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+func (g *generatorLowering) rewriteStmt(stmt js_ast.Stmt, opts generatorRewriteOpts) js_ast.Stmt {
+	stmts := g.rewriteStmts([]js_ast.Stmt{stmt}, opts)
+	switch len(stmts) {
+	case 0:
+		return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SEmpty{}}
+	case 1:
+		return stmts[0]
+	}
+	return js_ast.Stmt{Loc: stmt.Loc, Data: &js_ast.SBlock{Stmts: stmts}}
+}
+
+// "for (var x in y)" => "for (x in y)"
+func (g *generatorLowering) rewriteForInOrOfInit(init *js_ast.Stmt) {
+	if local, ok := init.Data.(*js_ast.SLocal); ok && local.Kind == js_ast.LocalVar {
+		g.hoist(local.Decls[0].Binding)
+		*init = js_ast.Stmt{Loc: init.Loc, Data: &js_ast.SExpr{Value: js_ast.ConvertBindingToExpr(local.Decls[0].Binding, nil)}}
+	}
+}
+
+func (opts generatorRewriteOpts) nested() generatorRewriteOpts {
+	opts.isDirectlyInBody = false
+	return opts
+}
+
+func (opts generatorRewriteOpts) loop() generatorRewriteOpts {
+	opts.isDirectlyInBody = false
+	opts.isInsideLoop = true
+	opts.isInsideBreakable = true
+	return opts
+}
+
+func refsContain(refs []js_ast.Ref, ref js_ast.Ref) bool {
+	for _, it := range refs {
+		if it == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// These don't look inside nested functions since a "yield" can't cross a
+// function boundary. Only the computed keys and the "extends" clause of a
+// class can contain a "yield".
+func stmtsContainYield(stmts []js_ast.Stmt) bool {
+	for _, stmt := range stmts {
+		if stmtContainsYield(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+func stmtContainsYield(stmt js_ast.Stmt) bool {
+	switch s := stmt.Data.(type) {
+	case *js_ast.SBlock:
+		return stmtsContainYield(s.Stmts)
+
+	case *js_ast.SExpr:
+		return exprContainsYield(s.Value)
+
+	case *js_ast.SLocal:
+		for _, decl := range s.Decls {
+			if bindingContainsYield(decl.Binding) || (decl.Value != nil && exprContainsYield(*decl.Value)) {
+				return true
+			}
+		}
+
+	case *js_ast.SReturn:
+		return s.Value != nil && exprContainsYield(*s.Value)
+
+	case *js_ast.SThrow:
+		return exprContainsYield(s.Value)
+
+	case *js_ast.SIf:
+		return exprContainsYield(s.Test) || stmtContainsYield(s.Yes) || (s.No != nil && stmtContainsYield(*s.No))
+
+	case *js_ast.SFor:
+		return (s.Init != nil && stmtContainsYield(*s.Init)) || (s.Test != nil && exprContainsYield(*s.Test)) ||
+			(s.Update != nil && exprContainsYield(*s.Update)) || stmtContainsYield(s.Body)
+
+	case *js_ast.SForIn:
+		return stmtContainsYield(s.Init) || exprContainsYield(s.Value) || stmtContainsYield(s.Body)
+
+	case *js_ast.SForOf:
+		return stmtContainsYield(s.Init) || exprContainsYield(s.Value) || stmtContainsYield(s.Body)
+
+	case *js_ast.SWhile:
+		return exprContainsYield(s.Test) || stmtContainsYield(s.Body)
+
+	case *js_ast.SDoWhile:
+		return stmtContainsYield(s.Body) || exprContainsYield(s.Test)
+
+	case *js_ast.SWith:
+		return exprContainsYield(s.Value) || stmtContainsYield(s.Body)
+
+	case *js_ast.SLabel:
+		return stmtContainsYield(s.Stmt)
+
+	case *js_ast.STry:
+		return stmtsContainYield(s.Body) || (s.Catch != nil && (stmtsContainYield(s.Catch.Body) ||
+			(s.Catch.Binding != nil && bindingContainsYield(*s.Catch.Binding)))) ||
+			(s.Finally != nil && stmtsContainYield(s.Finally.Stmts))
+
+	case *js_ast.SSwitch:
+		if exprContainsYield(s.Test) {
+			return true
+		}
+		for _, c := range s.Cases {
+			if (c.Value != nil && exprContainsYield(*c.Value)) || stmtsContainYield(c.Body) {
+				return true
+			}
+		}
+
+	case *js_ast.SClass:
+		return classContainsYield(&s.Class)
+	}
+	return false
+}
+
+func bindingContainsYield(binding js_ast.Binding) bool {
+	switch b := binding.Data.(type) {
+	case *js_ast.BArray:
+		for _, item := range b.Items {
+			if bindingContainsYield(item.Binding) || (item.DefaultValue != nil && exprContainsYield(*item.DefaultValue)) {
+				return true
+			}
+		}
+
+	case *js_ast.BObject:
+		for _, property := range b.Properties {
+			if (property.IsComputed && exprContainsYield(property.Key)) || bindingContainsYield(property.Value) ||
+				(property.DefaultValue != nil && exprContainsYield(*property.DefaultValue)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func classContainsYield(class *js_ast.Class) bool {
+	if class.Extends != nil && exprContainsYield(*class.Extends) {
+		return true
+	}
+	for _, property := range class.Properties {
+		if property.IsComputed && exprContainsYield(property.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+func exprsContainYield(exprs []js_ast.Expr) bool {
+	for _, expr := range exprs {
+		if exprContainsYield(expr) {
+			return true
+		}
+	}
+	return false
+}
+
+func exprContainsYield(expr js_ast.Expr) bool {
+	switch e := expr.Data.(type) {
+	case *js_ast.EYield:
+		return true
+
+	case *js_ast.EAwait:
+		return exprContainsYield(e.Value)
+
+	case *js_ast.EUnary:
+		return exprContainsYield(e.Value)
+
+	case *js_ast.EBinary:
+		return exprContainsYield(e.Left) || exprContainsYield(e.Right)
+
+	case *js_ast.EIf:
+		return exprContainsYield(e.Test) || exprContainsYield(e.Yes) || exprContainsYield(e.No)
+
+	case *js_ast.EDot:
+		return exprContainsYield(e.Target)
+
+	case *js_ast.EIndex:
+		return exprContainsYield(e.Target) || exprContainsYield(e.Index)
+
+	case *js_ast.ECall:
+		return exprContainsYield(e.Target) || exprsContainYield(e.Args)
+
+	case *js_ast.ENew:
+		return exprContainsYield(e.Target) || exprsContainYield(e.Args)
+
+	case *js_ast.EArray:
+		return exprsContainYield(e.Items)
+
+	case *js_ast.EObject:
+		for _, property := range e.Properties {
+			if (property.IsComputed && exprContainsYield(property.Key)) ||
+				(property.Value != nil && exprContainsYield(*property.Value)) ||
+				(property.Initializer != nil && exprContainsYield(*property.Initializer)) {
+				return true
+			}
+		}
+
+	case *js_ast.ESpread:
+		return exprContainsYield(e.Value)
+
+	case *js_ast.ETemplate:
+		if e.Tag != nil && exprContainsYield(*e.Tag) {
+			return true
+		}
+		for _, part := range e.Parts {
+			if exprContainsYield(part.Value) {
+				return true
+			}
+		}
+
+	case *js_ast.EImport:
+		return exprContainsYield(e.Expr) || (e.OptionsOrNil.Data != nil && exprContainsYield(e.OptionsOrNil))
+
+	case *js_ast.EClass:
+		return classContainsYield(&e.Class)
+	}
+	return false
 }
@@ -385,6 +385,28 @@ func TestLowerClassStaticThis(t *testing.T) {
 		"var _a;\nx = (_a = class {\n}, __publicField(_a, \"x\", class extends _a {\n}), _a);\n")
 }
 
+func TestLowerClassStaticBlocks(t *testing.T) {
+	expectPrintedTarget(t, 2020, "class Foo { static {} }",
+		"class Foo {\n}\n")
+	expectPrintedTarget(t, 2020, "class Foo { static { foo(this) } }",
+		"const _Foo = class {\n};\nlet Foo = _Foo;\n(() => {\n  foo(_Foo);\n})();\n")
+	expectPrintedTarget(t, 2020, "class Foo { static x = 1; static { foo(this.x) } static y = this.x }",
+		"const _Foo = class {\n};\nlet Foo = _Foo;\n__publicField(Foo, \"x\", 1);\n(() => {\n  foo(_Foo.x);\n})();\n__publicField(Foo, \"y\", _Foo.x);\n")
+	expectPrintedTarget(t, 2020, "x = class { static { foo(() => this) } }",
+		"var _a;\nx = (_a = class {\n}, (() => {\n  foo(() => _a);\n})(), _a);\n")
+	expectPrintedTarget(t, 5, "class Foo { static { foo(this) } }",
+		"var _Foo = function() {\n  function _Foo() {\n  }\n  return _Foo;\n}();\nvar Foo = _Foo;\n(function() {\n  foo(_Foo);\n})();\n")
+}
+
+func TestLowerPrivateBrandCheck(t *testing.T) {
+	expectPrintedTarget(t, 2020, "class Foo { #x; static is(y) { return #x in y } }",
+		"var _x;\nclass Foo {\n  constructor() {\n    _x.set(this, void 0);\n  }\n  static is(y) {\n    return __privateIn(_x, y);\n  }\n}\n_x = new WeakMap();\n")
+	expectPrintedTarget(t, 2020, "class Foo { #x() {} static is(y) { return #x in y } }",
+		"var _x, x_fn;\nclass Foo {\n  constructor() {\n    _x.add(this);\n  }\n  static is(y) {\n    return __privateIn(_x, y);\n  }\n}\n_x = new WeakSet();\nx_fn = function() {\n};\n")
+	expectPrintedTarget(t, 2020, "class Foo { static #x = 1; static is(y) { return #x in y } }",
+		"var _x;\nclass Foo {\n  static is(y) {\n    return __privateIn(_x, y);\n  }\n}\n_x = new WeakMap();\n_x.set(Foo, 1);\n")
+}
+
 func TestLowerOptionalChain(t *testing.T) {
 	expectPrintedTarget(t, 2019, "a?.b.c", "a == null ? void 0 : a.b.c;\n")
 	expectPrintedTarget(t, 2019, "(a?.b).c", "(a == null ? void 0 : a.b).c;\n")
@@ -1384,6 +1384,31 @@ func TestClassFields(t *testing.T) {
 	expectPrinted(t, "class Foo { static ['prototype'] = 1 }", "class Foo {\n  static [\"prototype\"] = 1;\n}\n")
 }
 
+func TestClassStaticBlocks(t *testing.T) {
+	expectPrinted(t, "class Foo { static {} }", "class Foo {\n  static {\n  }\n}\n")
+	expectPrinted(t, "class Foo { static { x } }", "class Foo {\n  static {\n    x;\n  }\n}\n")
+	expectPrinted(t, "class Foo { static x; static { this.x } static y() {} }",
+		"class Foo {\n  static x;\n  static {\n    this.x;\n  }\n  static y() {\n  }\n}\n")
+	expectPrinted(t, "class Foo { static {} static {} }", "class Foo {\n  static {\n  }\n  static {\n  }\n}\n")
+	expectPrinted(t, "class Foo { static { var x; let y } static { var x; let y } }",
+		"class Foo {\n  static {\n    var x;\n    let y;\n  }\n  static {\n    var x;\n    let y;\n  }\n}\n")
+	expectPrinted(t, "class Foo { static() {} }", "class Foo {\n  static() {\n  }\n}\n")
+	expectPrinted(t, "class Foo { static static() {} }", "class Foo {\n  static static() {\n  }\n}\n")
+	expectPrinted(t, "var x; class Foo { static { var x } }", "var x;\nclass Foo {\n  static {\n    var x;\n  }\n}\n")
+
+	expectParseError(t, "class Foo { static { return } }",
+		"<stdin>: error: A return statement cannot be used inside a class static block\n")
+	expectParseError(t, "class Foo { static { await } }",
+		"<stdin>: error: Cannot use \"await\" inside a class static block\n")
+	expectParseError(t, "class Foo { static { arguments } }",
+		"<stdin>: error: Cannot access \"arguments\" here\n")
+	expectParseError(t, "class Foo { static { let x; var x } }",
+		"<stdin>: error: \"x\" has already been declared\n<stdin>: note: \"x\" was originally declared here\n")
+	expectParseError(t, "class Foo { static static {} }", "<stdin>: error: Expected \";\" but found \"{\"\n")
+	expectParseError(t, "class Foo { async static {} }", "<stdin>: error: Expected \"(\" but found \"{\"\n")
+	expectParseError(t, "({ static {} })", "<stdin>: error: Expected \"}\" but found \"{\"\n")
+}
+
 func TestGenerator(t *testing.T) {
 	expectParseError(t, "(class { * foo })", "<stdin>: error: Expected \"(\" but found \"}\"\n")
 	expectParseError(t, "(class { * *foo() {} })", "<stdin>: error: Unexpected \"*\"\n")
@@ -1988,7 +2013,7 @@ func TestImport(t *testing.T) {
 	expectPrinted(t, "new (import('foo'))", "new (import(\"foo\"))();\n")
 	expectParseError(t, "import()", "<stdin>: error: Unexpected \")\"\n")
 	expectParseError(t, "import(...a)", "<stdin>: error: Unexpected \"...\"\n")
-	expectParseError(t, "import(a, b)", "<stdin>: error: Expected \")\" but found \",\"\n")
+	expectParseError(t, "import(a, b, c)", "<stdin>: error: Expected \")\" but found \"c\"\n")
 	expectParseError(t, "new import('foo')", "<stdin>: error: Cannot use an \"import\" expression here without parentheses\n")
 
 	expectPrinted(t, "import.meta", "import.meta;\n")
@@ -2027,6 +2052,52 @@ func TestImport(t *testing.T) {
 	expectPrinted(t, "import {\\u0061rguments as x} from 'foo'", "import {arguments as x} from \"foo\";\n")
 }
 
+func TestImportAssertions(t *testing.T) {
+	expectPrinted(t, "import 'x' assert {}", "import \"x\" assert {};\n")
+	expectPrinted(t, "import 'x' assert {\n}", "import \"x\" assert {};\n")
+	expectPrinted(t, "import 'x' assert\n{}", "import \"x\" assert {};\n")
+	expectPrinted(t, "import 'x'\nassert\n{}", "import \"x\";\nassert;\n{\n}\n")
+	expectPrinted(t, "import 'x' assert {type: 'json'}", "import \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "import 'x' assert {type: 'json',}", "import \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "import 'x' assert {'type': 'json'}", "import \"x\" assert { \"type\": \"json\" };\n")
+	expectPrinted(t, "import 'x' assert {a: 'b', c: 'd'}", "import \"x\" assert { a: \"b\", c: \"d\" };\n")
+	expectPrinted(t, "import 'x' assert {if: 'keyword'}", "import \"x\" assert { if: \"keyword\" };\n")
+	expectPrinted(t, "import * as ns from 'x' assert {type: 'json'}", "import * as ns from \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "import y from 'x' assert {type: 'json'}", "import y from \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "import {y} from 'x' assert {type: 'json'}", "import {y} from \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "export * from 'x' assert {type: 'json'}", "export * from \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "export * as ns from 'x' assert {type: 'json'}", "export * as ns from \"x\" assert { type: \"json\" };\n")
+	expectPrinted(t, "export {y} from 'x' assert {type: 'json'}", "export {y} from \"x\" assert { type: \"json\" };\n")
+	expectPrintedMangle(t, "import 'x' assert {'type': 'json'}", "import \"x\" assert { type: \"json\" };\n")
+
+	expectPrinted(t, "import('x', {assert: {type: 'json'}})", "import(\"x\", {assert: {type: \"json\"}});\n")
+	expectPrinted(t, "import('x', {assert: {type: 'json'}},)", "import(\"x\", {assert: {type: \"json\"}});\n")
+	expectPrinted(t, "import('x',)", "import(\"x\");\n")
+	expectPrinted(t, "import(x, y)", "import(x, y);\n")
+
+	expectParseError(t, "import 'x' assert {,}", "<stdin>: error: Expected identifier but found \",\"\n")
+	expectParseError(t, "import 'x' assert {x}", "<stdin>: error: Expected \":\" but found \"}\"\n")
+	expectParseError(t, "import 'x' assert {x 'y'}", "<stdin>: error: Expected \":\" but found \"'y'\"\n")
+	expectParseError(t, "import 'x' assert {x: y}", "<stdin>: error: Expected string but found \"y\"\n")
+	expectParseError(t, "import 'x' assert {x: 'y',,}", "<stdin>: error: Expected identifier but found \",\"\n")
+	expectParseError(t, "import 'x' assert {`x`: 'y'}", "<stdin>: error: Expected identifier but found \"`x`\"\n")
+	expectParseError(t, "import 'x' assert {x: `y`}", "<stdin>: error: Expected string but found \"`y`\"\n")
+	expectParseError(t, "import 'x' assert: {x: 'y'}", "<stdin>: error: Expected \"{\" but found \":\"\n")
+	expectParseError(t, "import 'x' assert {type: 'json', type: 'json'}",
+		"<stdin>: error: Duplicate import assertion \"type\"\n<stdin>: note: The first \"type\" was here:\n")
+	expectParseError(t, "import 'x' assert {type: 'json', 'type': 'json'}",
+		"<stdin>: error: Duplicate import assertion \"type\"\n<stdin>: note: The first \"type\" was here:\n")
+
+	// Import assertions are stripped when the target doesn't support them
+	expectPrintedTarget(t, 2020, "import 'x' assert {type: 'json'}", "import \"x\";\n")
+	expectPrintedTarget(t, 2020, "export * from 'x' assert {type: 'json'}", "export * from \"x\";\n")
+	expectPrintedTarget(t, 2020, "export {y} from 'x' assert {type: 'json'}", "export {y} from \"x\";\n")
+	expectPrintedTarget(t, 2020, "import('x', {assert: {type: 'json'}})", "import(\"x\");\n")
+	expectPrintedTarget(t, 2020, "import(x, {assert: {type: 'json'}})", "import(x);\n")
+	expectPrintedTarget(t, 2020, "import('x', y())", "y(), import(\"x\");\n")
+	expectPrintedTarget(t, 2020, "import(x, y())", "import(x, y());\n")
+}
+
 func TestExport(t *testing.T) {
 	expectPrinted(t, "export default x", "export default x;\n")
 	expectPrinted(t, "export class x {}", "export class x {\n}\n")
@@ -3937,6 +4008,17 @@ func TestPrivateIdentifiers(t *testing.T) {
   }
 }
 `)
+
+	// Private brand checks
+	expectPrinted(t, "class Foo { #x; static is(y) { return #x in y } }",
+		"class Foo {\n  #x;\n  static is(y) {\n    return #x in y;\n  }\n}\n")
+	expectPrinted(t, "class Foo { #x() {} is(y) { return !(#x in y) && #x in y in z } }",
+		"class Foo {\n  #x() {\n  }\n  is(y) {\n    return !(#x in y) && #x in y in z;\n  }\n}\n")
+	expectParseError(t, "#x in y", "<stdin>: error: Unexpected \"#x\"\n")
+	expectParseError(t, "class Foo { #x; is(y) { return #x } }", "<stdin>: error: Expected \"in\" but found \"}\"\n")
+	expectParseError(t, "class Foo { #x; is(y) { return 1 + #x in y } }", "<stdin>: error: Unexpected \"#x\"\n")
+	expectParseError(t, "class Foo { is(y) { return #x in y } }",
+		"<stdin>: error: Private name \"#x\" must be declared in an enclosing class\n")
 }
 
 func TestES5(t *testing.T) {
@@ -3973,51 +4055,31 @@ func TestES5(t *testing.T) {
 		"<stdin>: error: Transforming object literal extensions to the configured target environment is not supported yet\n")
 	expectParseErrorTarget(t, 5, "({ set [x](x) {} });",
 		"<stdin>: error: Transforming object literal extensions to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "function foo([]) {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "function foo({}) {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "(function([]) {})",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "(function({}) {})",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "([]) => {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "({}) => {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "var [] = [];",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "var {} = {};",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "([] = []);",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "({} = {});",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "for ([] in []);",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "for ({} in []);",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "function foo([...x]) {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "(function([...x]) {})",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "([...x]) => {}",
-		"<stdin>: error: Transforming destructuring to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "function foo([...[x]]) {}",
-		`<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming non-identifier array rest patterns to the configured target environment is not supported yet
-`)
-	expectParseErrorTarget(t, 5, "(function([...[x]]) {})",
-		`<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming non-identifier array rest patterns to the configured target environment is not supported yet
-`)
-	expectParseErrorTarget(t, 5, "([...[x]]) => {}",
-		`<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming destructuring to the configured target environment is not supported yet
-<stdin>: error: Transforming non-identifier array rest patterns to the configured target environment is not supported yet
-`)
+	expectPrintedTarget(t, 5, "function foo([]) {}", "function foo(_a) {\n  var _b = _a;\n}\n")
+	expectPrintedTarget(t, 5, "function foo({}) {}", "function foo(_a) {\n  var _b = _a;\n}\n")
+	expectPrintedTarget(t, 5, "(function([]) {})", "(function(_a) {\n  var _b = _a;\n});\n")
+	expectPrintedTarget(t, 5, "(function({}) {})", "(function(_a) {\n  var _b = _a;\n});\n")
+	expectPrintedTarget(t, 5, "([]) => {}", "(function(_a) {\n  var _b = _a;\n});\n")
+	expectPrintedTarget(t, 5, "({}) => {}", "(function(_a) {\n  var _b = _a;\n});\n")
+	expectPrintedTarget(t, 5, "var [] = [];", "var _a = [];\n")
+	expectPrintedTarget(t, 5, "var {} = {};", "var _a = {};\n")
+	expectPrintedTarget(t, 5, "([] = []);", "var _a;\n_a = [];\n")
+	expectPrintedTarget(t, 5, "({} = {});", "var _a;\n_a = {};\n")
+	expectPrintedTarget(t, 5, "for ([] in []);", "var _a, _b;\nfor (_a in []) {\n  _b = _a;\n  ;\n}\n")
+	expectPrintedTarget(t, 5, "for ({} in []);", "var _a, _b;\nfor (_a in []) {\n  _b = _a;\n  ;\n}\n")
+	expectPrintedTarget(t, 5, "function foo([...x]) {}", "function foo(_a) {\n  var x = _a.slice(0);\n}\n")
+	expectPrintedTarget(t, 5, "(function([...x]) {})", "(function(_a) {\n  var x = _a.slice(0);\n});\n")
+	expectPrintedTarget(t, 5, "([...x]) => {}", "(function(_a) {\n  var x = _a.slice(0);\n});\n")
+	expectPrintedTarget(t, 5, "function foo([...[x]]) {}", "function foo(_a) {\n  var x = _a.slice(0)[0];\n}\n")
+	expectPrintedTarget(t, 5, "(function([...[x]]) {})", "(function(_a) {\n  var x = _a.slice(0)[0];\n});\n")
+	expectPrintedTarget(t, 5, "([...[x]]) => {}", "(function(_a) {\n  var x = _a.slice(0)[0];\n});\n")
+	expectPrintedTarget(t, 5, "var [a, , ...b] = c;", "var a = c[0], b = c.slice(2);\n")
+	expectPrintedTarget(t, 5, "var {a, b: [c = 1]} = d;", "var a = d.a, _a = d.b[0], c = _a === void 0 ? 1 : _a;\n")
+	expectPrintedTarget(t, 5, "var {a, ...b} = c;", "var a = c.a, b = __objRest(c, [\"a\"]);\n")
+	expectPrintedTarget(t, 5, "var {[a()]: b, 'c-d': e} = f;", "var b = f[a()], e = f[\"c-d\"];\n")
+	expectPrintedTarget(t, 5, "[a, b] = [b, a];", "var _a;\n_a = [b, a], a = _a[0], b = _a[1];\n")
+	expectPrintedTarget(t, 5, "x = [a] = b;", "var _a;\nx = (a = (_a = b)[0], _a);\n")
+	expectPrintedTarget(t, 5, "try {} catch ({a}) {}", "try {\n} catch (_a) {\n  var a = _a.a;\n}\n")
 	expectParseErrorTarget(t, 5, "([...[x]])",
 		"<stdin>: error: Transforming array spread to the configured target environment is not supported yet\n")
 	expectPrintedTarget(t, 5, "`abc`;", "\"abc\";\n")
@@ -4029,30 +4091,325 @@ func TestES5(t *testing.T) {
 	expectPrintedTarget(t, 5, "`a${b}${c}d`;", "\"a\" + b + c + \"d\";\n")
 	expectPrintedTarget(t, 5, "`a${b}c${d}`;", "\"a\" + b + \"c\" + d;\n")
 	expectPrintedTarget(t, 5, "`a${b}c${d}e`;", "\"a\" + b + \"c\" + d + \"e\";\n")
-	expectParseErrorTarget(t, 5, "tag`abc`;",
-		"<stdin>: error: Transforming tagged template literals to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "tag`a${b}c`;",
-		"<stdin>: error: Transforming tagged template literals to the configured target environment is not supported yet\n")
+	expectPrintedTarget(t, 5, "tag`abc`;", "tag(_a || (_a = __template([\"abc\"])));\nvar _a;\n")
+	expectPrintedTarget(t, 5, "tag`a${b}c`;", "tag(_a || (_a = __template([\"a\", \"c\"])), b);\nvar _a;\n")
+	expectPrintedTarget(t, 5, "tag`\\n`;", "tag(_a || (_a = __template([\"\\n\"], [\"\\\\n\"])));\nvar _a;\n")
+	expectPrintedTarget(t, 5, "function f() { return tag`a`; }",
+		"function f() {\n  return tag(_a || (_a = __template([\"a\"])));\n}\nvar _a;\n")
 	expectParseErrorTarget(t, 5, "class Foo { constructor() { new.target } }",
-		"<stdin>: error: Transforming class syntax to the configured target environment is not supported yet\n"+
-			"<stdin>: error: Transforming object literal extensions to the configured target environment is not supported yet\n"+
-			"<stdin>: error: Transforming new.target to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "const x = 1;",
-		"<stdin>: error: Transforming const to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "let x = 2;",
+		"<stdin>: error: Transforming new.target to the configured target environment is not supported yet\n")
+	expectPrintedTarget(t, 5, "const x = 1;", "var x = 1;\n")
+	expectPrintedTarget(t, 5, "let x = 2;", "var x = 2;\n")
+	expectPrintedTarget(t, 5, "let x = 1; { let x = 2; }", "var x = 1;\n{\n  var x = 2;\n}\n")
+	expectPrintedTarget(t, 5, "function f() { for (;;) { let x; } }", "function f() {\n  for (; ; ) {\n    var x = void 0;\n  }\n}\n")
+	expectPrintedTarget(t, 5, "for (let i = 0; i < 3; i++) fns.push(() => i);",
+		"var _loop;\nfor (var i = 0; i < 3; i++) {\n  _loop = function(i) {\n    fns.push(function() {\n      return i;\n    });\n  };\n  _loop(i);\n}\n")
+	expectPrintedTarget(t, 5, "for (let i in x) { if (i) break; fns.push(() => i); }",
+		"var _loop;\nfor (var i in x) {\n  _loop = function(i) {\n    if (i)\n      return \"break\";\n    fns.push(function() {\n      return i;\n    });\n  };\n  if (_loop(i) === \"break\")\n    break;\n}\n")
+	expectPrintedTarget(t, 5, "function f() { while (x) { let y = x; if (y) return y; fns.push(() => y, this); } }",
+		"function f() {\n  var _loop, _ret;\n  while (x) {\n    _loop = function() {\n      var y = x;\n      if (y)\n        return {v: y};\n      fns.push(function() {\n        return y;\n      }, this);\n    };\n    _ret = _loop.call(this);\n    if (typeof _ret === \"object\")\n      return _ret.v;\n  }\n}\n")
+	expectParseErrorTarget(t, 5, "for (let i = 0; i < 3; i++) { fns.push(() => i); i++ }",
+		"<stdin>: error: Transforming let to the configured target environment is not supported yet\n")
+	expectParseErrorTarget(t, 5, "function f() { for (let i of x) { fns.push(() => i); arguments } }",
+		"<stdin>: error: Transforming for-of loops to the configured target environment is not supported yet\n"+
+			"<stdin>: error: Transforming let to the configured target environment is not supported yet\n")
+	expectParseErrorTarget(t, 5, "a: for (;;) for (let i in x) { fns.push(() => i); continue a }",
 		"<stdin>: error: Transforming let to the configured target environment is not supported yet\n")
 	expectPrintedTarget(t, 5, "async => foo;", "(function(async) {\n  return foo;\n});\n")
 	expectPrintedTarget(t, 5, "x => x;", "(function(x) {\n  return x;\n});\n")
-	expectParseErrorTarget(t, 5, "async () => foo;",
-		"<stdin>: error: Transforming async functions to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "class Foo {}",
-		"<stdin>: error: Transforming class syntax to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "(class {});",
-		"<stdin>: error: Transforming class syntax to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "function* gen() {}",
-		"<stdin>: error: Transforming generator functions to the configured target environment is not supported yet\n")
-	expectParseErrorTarget(t, 5, "(function* () {});",
-		"<stdin>: error: Transforming generator functions to the configured target environment is not supported yet\n")
+	expectPrintedTarget(t, 5, "async () => foo;",
+		`(function() {
+  return __async(this, null, function() {
+    return __stateMachine(this, function(_state) {
+      return [0, foo];
+    });
+  });
+});
+`)
+	expectPrintedTarget(t, 5, "class Foo {}", "var Foo = function() {\n  function Foo() {\n  }\n  return Foo;\n}();\n")
+	expectPrintedTarget(t, 5, "(class {});", "(function() {\n  function _class() {\n  }\n  return _class;\n})();\n")
+	expectPrintedTarget(t, 5, "class Foo { foo() {} static bar() {} get baz() {} set baz(x) {} [qux]() {} }",
+		`var Foo = function() {
+  function Foo() {
+  }
+  __defineProperty(Foo.prototype, "foo", {value: function() {
+  }});
+  __defineProperty(Foo, "bar", {value: function() {
+  }});
+  __defineProperty(Foo.prototype, "baz", {get: function() {
+  }});
+  __defineProperty(Foo.prototype, "baz", {set: function(x) {
+  }});
+  __defineProperty(Foo.prototype, qux, {value: function() {
+  }});
+  return Foo;
+}();
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Bar {}",
+		"var Foo = function(_super) {\n  __inherits(Foo, _super);\n  function Foo() {\n    return _super.apply(this, arguments) || this;\n  }\n  return Foo;\n}(Bar);\n")
+	expectPrintedTarget(t, 5, "class Foo extends Bar { constructor(x) { super(x) } foo() { return super.foo() } static bar() { return () => super.bar } }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo(x) {
+    var _this = _super.call(this, x) || this;
+    return _this;
+  }
+  __defineProperty(Foo.prototype, "foo", {value: function() {
+    return _super.prototype.foo.call(this);
+  }});
+  __defineProperty(Foo, "bar", {value: function() {
+    var _this = this;
+    return function() {
+      return __superGet(_super, "bar", _this);
+    };
+  }});
+  return Foo;
+}(Bar);
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Bar { foo() { super.x = 1; super[y] += 2; return super.z++ } }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo() {
+    return _super.apply(this, arguments) || this;
+  }
+  __defineProperty(Foo.prototype, "foo", {value: function() {
+    var _a;
+    __superSet(_super.prototype, "x", 1, this);
+    __superSet(_super.prototype, y, __superGet(_super.prototype, y, this) + 2, this);
+    return __superSet(_super.prototype, "z", (_a = +__superGet(_super.prototype, "z", this)) + 1, this), _a;
+  }});
+  return Foo;
+}(Bar);
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Bar { foo() { return ++super[y()] } }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo() {
+    return _super.apply(this, arguments) || this;
+  }
+  __defineProperty(Foo.prototype, "foo", {value: function() {
+    var _a;
+    return __superSet(_super.prototype, _a = y(), +__superGet(_super.prototype, _a, this) + 1, this);
+  }});
+  return Foo;
+}(Bar);
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Error { constructor(x) { super(x); this.name = 'Foo' } }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo(x) {
+    var _this = _super.call(this, x) || this;
+    _this.name = "Foo";
+    return _this;
+  }
+  return Foo;
+}(Error);
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Bar { constructor(y) { if (y) { super(y); return } super(); foo(() => this) } }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo(y) {
+    var _this;
+    if (y) {
+      _this = _super.call(this, y) || this;
+      return _this;
+    }
+    _this = _super.call(this) || this;
+    foo(function() {
+      return _this;
+    });
+    return _this;
+  }
+  return Foo;
+}(Bar);
+`)
+	expectPrintedTarget(t, 5, "class Foo extends Bar { x = () => this }",
+		`var Foo = function(_super) {
+  __inherits(Foo, _super);
+  function Foo() {
+    var _this = _super.apply(this, arguments) || this;
+    __publicField(_this, "x", function() {
+      return _this;
+    });
+    return _this;
+  }
+  return Foo;
+}(Bar);
+`)
+	expectPrintedTarget(t, 5, "class Foo { x = () => this }",
+		`var Foo = function() {
+  function Foo() {
+    var _this = this;
+    __publicField(this, "x", function() {
+      return _this;
+    });
+  }
+  return Foo;
+}();
+`)
+	expectPrintedTarget(t, 5, "class Foo { x = 1; static y = Foo }",
+		"var _Foo = function() {\n  function _Foo() {\n    __publicField(this, \"x\", 1);\n  }\n  return _Foo;\n}();\nvar Foo = _Foo;\n__publicField(Foo, \"y\", _Foo);\n")
+	expectPrintedTarget(t, 5, "if (x) { class Foo {} }",
+		"if (x) {\n  var Foo = function() {\n    function Foo() {\n    }\n    return Foo;\n  }();\n}\n")
+	expectPrintedTarget(t, 5, "function* gen() {}",
+		`function gen() {
+  return __stateMachine(this, function(_state) {
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "(function* () {});",
+		`(function() {
+  return __stateMachine(this, function(_state) {
+  });
+});
+`)
+	expectPrintedTarget(t, 5, "function* gen(x) { var y = yield x; return y }",
+		`function gen(x) {
+  var y;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        return [1, x, 1];
+      case 1:
+        y = _state.sent;
+        return [0, y];
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "function* gen() { try { yield 1 } catch (e) { yield e } finally { foo() } }",
+		`function gen() {
+  var e;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        _state.trys.push([0, 2, 4, 5]);
+        return [1, 1, 1];
+      case 1:
+        return [3, 5];
+      case 2:
+        e = _state.sent;
+        return [1, e, 3];
+      case 3:
+        return [3, 5];
+      case 4:
+        foo();
+        return [4];
+      case 5:
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "function* gen() { for (var i = 0; i < 3; i++) { if (i) continue; yield i } }",
+		`function gen() {
+  var i;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        i = 0;
+      case 1:
+        if (!(i < 3))
+          return [3, 3];
+        if (i)
+          return [3, 2];
+        return [1, i, 2];
+      case 2:
+        i++;
+        return [3, 1];
+      case 3:
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "function* gen() { for (var k in x) yield k }",
+		`function gen() {
+  var _a, _b, _c, k;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        _a = [];
+        for (_b in x)
+          _a.push(_b);
+        _c = 0;
+      case 1:
+        if (!(_c < _a.length))
+          return [3, 3];
+        k = _a[_c];
+        return [1, k, 2];
+      case 2:
+        _c++;
+        return [3, 1];
+      case 3:
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "function* gen() { foo(yield a, yield b); x.y += yield; return (yield) || (yield) }",
+		`function gen() {
+  var _a, _b, _c, _d, _e;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        _a = foo;
+        return [1, a, 1];
+      case 1:
+        _b = _state.sent;
+        return [1, b, 2];
+      case 2:
+        _a(_b, _state.sent);
+        _c = x;
+        _d = _c.y;
+        return [1, void 0, 3];
+      case 3:
+        _c.y = _d + _state.sent;
+        return [1, void 0, 4];
+      case 4:
+        _e = _state.sent;
+        if (_e)
+          return [3, 6];
+        return [1, void 0, 5];
+      case 5:
+        _e = _state.sent;
+      case 6:
+        return [0, _e];
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "function* gen() { yield* foo(); yield this; yield arguments }",
+		`function gen() {
+  var _arguments = arguments;
+  return __stateMachine(this, function(_state) {
+    switch (_state.label) {
+      case 0:
+        return [2, foo(), 1];
+      case 1:
+        return [1, this, 2];
+      case 2:
+        return [1, _arguments, 3];
+      case 3:
+    }
+  });
+}
+`)
+	expectPrintedTarget(t, 5, "async function foo() { await bar() }",
+		`function foo() {
+  return __async(this, null, function() {
+    return __stateMachine(this, function(_state) {
+      switch (_state.label) {
+        case 0:
+          return [1, bar(), 1];
+        case 1:
+      }
+    });
+  });
+}
+`)
+	expectParseErrorTarget(t, 5, "function* gen() { for (var x of y) yield x }",
+		"<stdin>: error: Transforming generator functions to the configured target environment is not supported yet\n"+
+			"<stdin>: error: Transforming for-of loops to the configured target environment is not supported yet\n")
+	expectParseErrorTarget(t, 5, "function* gen() { for (let i = 0; i < 3; i++) { fns.push(() => i); yield i } }",
+		"<stdin>: error: Transforming let to the configured target environment is not supported yet\n")
 }
 
 func TestASCIIOnly(t *testing.T) {
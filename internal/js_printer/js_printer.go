@@ -473,6 +473,43 @@ func (p *printer) printQuotedUTF8(text string, allowBacktick bool) {
 	p.print(c)
 }
 
+func (p *printer) printImportRecordPath(record *ast.ImportRecord) {
+	p.printQuotedUTF8(record.Path.Text, false /* allowBacktick */)
+
+	// Import assertions are stripped if the target doesn't support them
+	if record.Assertions != nil && !p.options.UnsupportedFeatures.Has(compat.ImportAssertions) {
+		p.printSpace()
+		p.print("assert")
+		p.printSpace()
+		p.print("{")
+		for i, entry := range *record.Assertions {
+			if i > 0 {
+				p.print(",")
+			}
+			p.printSpace()
+			if !entry.PreferQuotedKey && p.canPrintIdentifierUTF16(entry.Key) {
+				p.printSpaceBeforeIdentifier()
+				p.printIdentifierUTF16(entry.Key)
+			} else {
+				c := p.bestQuoteCharForString(entry.Key, false /* allowBacktick */)
+				p.print(c)
+				p.printQuotedUTF16(entry.Key, rune(c[0]))
+				p.print(c)
+			}
+			p.print(":")
+			p.printSpace()
+			c := p.bestQuoteCharForString(entry.Value, false /* allowBacktick */)
+			p.print(c)
+			p.printQuotedUTF16(entry.Value, rune(c[0]))
+			p.print(c)
+		}
+		if len(*record.Assertions) > 0 {
+			p.printSpace()
+		}
+		p.print("}")
+	}
+}
+
 func (p *printer) addSourceMapping(loc logger.Loc) {
 	if !p.options.AddSourceMappings || loc == p.prevLoc {
 		return
@@ -1008,6 +1045,15 @@ func (p *printer) printClass(class js_ast.Class) {
 	for _, item := range class.Properties {
 		p.printSemicolonIfNeeded()
 		p.printIndent()
+
+		if item.Kind == js_ast.PropertyClassStaticBlock {
+			p.print("static")
+			p.printSpace()
+			p.printBlock(item.ClassStaticBlock.Loc, item.ClassStaticBlock.Stmts)
+			p.printNewline()
+			continue
+		}
+
 		p.printProperty(item)
 
 		// Need semicolons after class fields
@@ -1211,7 +1257,19 @@ type requireCallArgs struct {
 	mustReturnPromise bool
 }
 
-func (p *printer) printRequireOrImportExpr(importRecordIndex uint32, leadingInteriorComments []js_ast.Comment, level js_ast.L, flags int) {
+func (p *printer) printImportCallOptions(options js_ast.Expr) {
+	p.print(",")
+	p.printSpace()
+	p.printExpr(options, js_ast.LComma, 0)
+}
+
+func (p *printer) printRequireOrImportExpr(
+	importRecordIndex uint32,
+	leadingInteriorComments []js_ast.Comment,
+	importOptions js_ast.Expr,
+	level js_ast.L,
+	flags int,
+) {
 	record := &p.importRecords[importRecordIndex]
 
 	if level >= js_ast.LNew || (flags&forbidCall) != 0 {
@@ -1241,6 +1299,9 @@ func (p *printer) printRequireOrImportExpr(importRecordIndex uint32, leadingInte
 			p.printSpaceBeforeIdentifier()
 			p.print("import(")
 			defer p.print(")")
+			if importOptions.Data != nil {
+				defer p.printImportCallOptions(importOptions)
+			}
 		} else {
 			p.printSpaceBeforeIdentifier()
 			p.print("Promise.resolve()")
@@ -1507,7 +1568,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 		}
 
 	case *js_ast.ERequire:
-		p.printRequireOrImportExpr(e.ImportRecordIndex, nil, level, flags)
+		p.printRequireOrImportExpr(e.ImportRecordIndex, nil, js_ast.Expr{}, level, flags)
 
 	case *js_ast.ERequireResolve:
 		wrap := level >= js_ast.LNew || (flags&forbidCall) != 0
@@ -1529,7 +1590,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 		}
 
 		if e.ImportRecordIndex.IsValid() {
-			p.printRequireOrImportExpr(e.ImportRecordIndex.GetIndex(), leadingInteriorComments, level, flags)
+			p.printRequireOrImportExpr(e.ImportRecordIndex.GetIndex(), leadingInteriorComments, e.OptionsOrNil, level, flags)
 		} else {
 			// Handle non-string expressions
 			if !e.ImportRecordIndex.IsValid() {
@@ -1548,6 +1609,9 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 					p.printIndent()
 				}
 				p.printExpr(e.Expr, js_ast.LComma, 0)
+				if e.OptionsOrNil.Data != nil {
+					p.printImportCallOptions(e.OptionsOrNil)
+				}
 				if len(leadingInteriorComments) > 0 {
 					p.printNewline()
 					p.options.Indent--
@@ -1920,6 +1984,10 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 			}
 		}
 
+	case *js_ast.EPrivateIdentifier:
+		// This is only valid before the "in" operator: "#x in obj"
+		p.printSymbol(e.Ref)
+
 	case *js_ast.EIdentifier:
 		name := p.renamer.NameForSymbol(e.Ref)
 		wrap := len(p.js) == p.forOfInitStart && name == "let"
@@ -2604,7 +2672,7 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		}
 		p.print("from")
 		p.printSpace()
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SExportClause:
@@ -2687,7 +2755,7 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		p.printSpace()
 		p.print("from")
 		p.printSpace()
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SLocal:
@@ -2961,7 +3029,7 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 			p.printSpace()
 		}
 
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SBlock:
@@ -3065,6 +3133,9 @@ type Options struct {
 	// Snapshot related
 	IsRuntime bool
 	FilePath  string
+
+	// The path of the file relative to the snapshot base dir, i.e. "./lib/foo.js"
+	SnapshotRelPath string
 }
 
 type RequireOrImportMeta struct {
@@ -594,6 +594,11 @@ func TestClass(t *testing.T) {
 	expectPrinted(t, "class Foo { static foo() {} }", "class Foo {\n  static foo() {\n  }\n}\n")
 	expectPrinted(t, "class Foo { static get foo() {} }", "class Foo {\n  static get foo() {\n  }\n}\n")
 	expectPrinted(t, "class Foo { static set foo(x) {} }", "class Foo {\n  static set foo(x) {\n  }\n}\n")
+	expectPrinted(t, "class Foo { static { foo() } }", "class Foo {\n  static {\n    foo();\n  }\n}\n")
+	expectPrinted(t, "class Foo { #x; static is(y) { return #x in y } }",
+		"class Foo {\n  #x;\n  static is(y) {\n    return #x in y;\n  }\n}\n")
+	expectPrintedMinify(t, "class Foo { static x; static { foo() } static {} }", "class Foo{static x;static{foo()}static{}}")
+	expectPrintedMinify(t, "class Foo { #x; is(y) { return #x in y } }", "class Foo{#x;is(y){return #x in y}}")
 }
 
 func TestImport(t *testing.T) {
@@ -604,6 +609,13 @@ func TestImport(t *testing.T) {
 	expectPrinted(t, "import(/* comment 1 */ /* comment 2 */ 'path');", "import(\n  /* comment 1 */\n  /* comment 2 */\n  \"path\"\n);\n")
 	expectPrinted(t, "import(\n    /* multi\n     * line\n     * comment */ 'path');", "import(\n  /* multi\n   * line\n   * comment */\n  \"path\"\n);\n")
 	expectPrinted(t, "import(/* comment 1 */ 'path' /* comment 2 */);", "import(\n  /* comment 1 */\n  \"path\"\n);\n")
+
+	// Test import assertions
+	expectPrinted(t, "import 'path' assert {type: 'json'};", "import \"path\" assert { type: \"json\" };\n")
+	expectPrinted(t, "import('path', {assert: {type: 'json'}});", "import(\"path\", {assert: {type: \"json\"}});\n")
+	expectPrintedMinify(t, "import x from 'path' assert {type: 'json', 'a b': 'c'};", "import x from\"path\"assert{type:\"json\",\"a b\":\"c\"};")
+	expectPrintedMinify(t, "export * from 'path' assert {};", "export*from\"path\"assert{};")
+	expectPrintedMinify(t, "import(x, {assert: {type: 'json'}});", "import(x,{assert:{type:\"json\"}});")
 }
 
 func TestExportDefault(t *testing.T) {
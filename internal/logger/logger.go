@@ -30,6 +30,10 @@ type Log struct {
 	AlmostDone func()
 
 	Done func() []Msg
+
+	// This returns a sorted copy of the messages logged so far without ending
+	// the log. Plugins use this to see the messages before the build ends.
+	Peek func() []Msg
 }
 
 type LogLevel int8
@@ -146,6 +150,10 @@ type Path struct {
 	// like "?#iefix" and "#icons" to some of their import paths as a hack for IE6.
 	// The intent is for these suffix parts to be ignored but passed through to
 	// the output. This is supported by other bundlers, so we also support this.
+	//
+	// Plugins also use this to attach query strings such as "?inline" to paths.
+	// The suffix is kept separate from the path so that the path can still be
+	// used to read the file from the file system.
 	IgnoredSuffix string
 
 	Flags PathFlags
@@ -468,6 +476,14 @@ func NewStderrLog(options OutputOptions) Log {
 			sort.Stable(msgs)
 			return msgs
 		},
+		Peek: func() []Msg {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			sorted := append(SortableMsgs{}, msgs...)
+			sort.Stable(sorted)
+			return sorted
+		},
 	}
 }
 
@@ -800,6 +816,13 @@ func NewDeferLog() Log {
 			sort.Stable(msgs)
 			return msgs
 		},
+		Peek: func() []Msg {
+			mutex.Lock()
+			defer mutex.Unlock()
+			sorted := append(SortableMsgs{}, msgs...)
+			sort.Stable(sorted)
+			return sorted
+		},
 	}
 }
 
@@ -65,6 +65,9 @@ type packageJSON struct {
 
 	// This represents the "exports" field in this package.json file.
 	exportsMap *peMap
+
+	// This represents the "imports" field in this package.json file.
+	importsMap *peMap
 }
 
 func (r resolverQuery) parsePackageJSON(path string) *packageJSON {
@@ -152,19 +155,7 @@ func (r resolverQuery) parsePackageJSON(path string) *packageJSON {
 					packageJSON.absMainFields = make(map[string]string)
 				}
 				if absPath := toAbsPath(r.fs.Join(path, main), jsonSource.RangeOfString(mainJSON.Loc)); absPath != nil {
-					// HACK: module resolution related, this is to mitigate the hacky version dance going on inside
-					// https://github.com/bevry/safefs/blob/11c7818dc3b3968e080003e0e960ae13e487dd1a/es6guardian.js
-					// It prevents to statically determine which module will actually be loaded and thus breaks things.
-					if strings.HasSuffix(*absPath, "node_modules/safefs/es6guardian.js") {
-						packageJSON.absMainFields[field] = strings.Replace(*absPath, "es6guardian.js", "es5/lib/safefs.js", 1)
-					} else if strings.Contains(*absPath, "node_modules/istextorbinary/index.cjs") {
-						// This one breaks require overwrites as it works via a custom loader like the above.
-						// In order to do so it also has to query a `package.json` file which is an I/O operation we prefer
-						// to avoid.
-						packageJSON.absMainFields[field] = strings.Replace(*absPath, "istextorbinary/index.cjs", "istextorbinary/edition-esnext/index.js", 1)
-					} else {
-						packageJSON.absMainFields[field] = *absPath
-					}
+					packageJSON.absMainFields[field] = r.redirectPackageEntry(json, path, *absPath, toAbsPath)
 				}
 			}
 		}
@@ -275,13 +266,21 @@ func (r resolverQuery) parsePackageJSON(path string) *packageJSON {
 	if !r.options.CreateSnapshot {
 		// Read the "exports" map
 		if exportsJSON, exportsRange, ok := getProperty(json, "exports"); ok {
-			if exportsMap := parseExportsMap(jsonSource, r.log, exportsJSON); exportsMap != nil {
+			if exportsMap := parseImportsExportsMap(jsonSource, r.log, exportsJSON); exportsMap != nil {
 				exportsMap.exportsRange = jsonSource.RangeOfString(exportsRange)
 				packageJSON.exportsMap = exportsMap
 			}
 		}
 	}
 
+	// Read the "imports" map. Unlike the "exports" map this is also done for
+	// snapshots since there is no other way to resolve "#" specifiers.
+	if importsJSON, _, ok := getProperty(json, "imports"); ok {
+		if importsMap := parseImportsExportsMap(jsonSource, r.log, importsJSON); importsMap != nil {
+			packageJSON.importsMap = importsMap
+		}
+	}
+
 	return packageJSON
 }
 
@@ -363,7 +362,7 @@ func (entry peEntry) valueForKey(key string) (peEntry, bool) {
 	return peEntry{}, false
 }
 
-func parseExportsMap(source logger.Source, log logger.Log, json js_ast.Expr) *peMap {
+func parseImportsExportsMap(source logger.Source, log logger.Log, json js_ast.Expr) *peMap {
 	var visit func(expr js_ast.Expr) peEntry
 
 	visit = func(expr js_ast.Expr) peEntry {
@@ -485,6 +484,10 @@ const (
 	peStatusExact
 	peStatusInexact // This means we may need to try CommonJS-style extension suffixes
 
+	// The "imports" map points to another package, i.e. "#dep": "dep". The
+	// result is a package path that needs to be resolved again.
+	peStatusPackageResolve
+
 	// Module specifier is an invalid URL, package name or package subpath specifier.
 	peStatusInvalidModuleSpecifier
 
@@ -497,6 +500,9 @@ const (
 	// Package exports do not define or permit a target subpath in the package for the given module.
 	peStatusPackagePathNotExported
 
+	// Package imports do not define the specifier in the package for the given import.
+	peStatusPackageImportNotDefined
+
 	// The package or module requested does not exist.
 	peStatusModuleNotFound
 
@@ -524,6 +530,23 @@ func esmPackageExportsResolveWithPostConditions(
 	conditions map[string]bool,
 ) (string, peStatus, peDebug) {
 	resolved, status, debug := esmPackageExportsResolve(packageURL, subpath, exports, conditions)
+	return esmHandlePostConditions(resolved, status, debug)
+}
+
+func esmPackageImportsResolveWithPostConditions(
+	specifier string,
+	imports peEntry,
+	conditions map[string]bool,
+) (string, peStatus, peDebug) {
+	resolved, status, debug := esmPackageImportsResolve(specifier, imports, conditions)
+	return esmHandlePostConditions(resolved, status, debug)
+}
+
+func esmHandlePostConditions(
+	resolved string,
+	status peStatus,
+	debug peDebug,
+) (string, peStatus, peDebug) {
 	if status != peStatusExact && status != peStatusInexact {
 		return resolved, status, debug
 	}
@@ -568,13 +591,13 @@ func esmPackageExportsResolve(
 			}
 		}
 		if mainExport.kind != peNull {
-			resolved, status, debug := esmPackageTargetResolve(packageURL, mainExport, "", false, conditions)
+			resolved, status, debug := esmPackageTargetResolve(packageURL, mainExport, "", false, false, conditions)
 			if status != peStatusNull && status != peStatusUndefined {
 				return resolved, status, debug
 			}
 		}
 	} else if exports.kind == peObject && exports.keysStartWithDot() {
-		resolved, status, debug := esmPackageImportsExportsResolve(subpath, exports, packageURL, conditions)
+		resolved, status, debug := esmPackageImportsExportsResolve(subpath, exports, packageURL, false, conditions)
 		if status != peStatusNull && status != peStatusUndefined {
 			return resolved, status, debug
 		}
@@ -582,15 +605,38 @@ func esmPackageExportsResolve(
 	return "", peStatusPackagePathNotExported, peDebug{token: exports.firstToken}
 }
 
+func esmPackageImportsResolve(
+	specifier string,
+	imports peEntry,
+	conditions map[string]bool,
+) (string, peStatus, peDebug) {
+	// If specifier is exactly equal to "#" or starts with "#/", then throw an
+	// Invalid Module Specifier error.
+	if specifier == "#" || strings.HasPrefix(specifier, "#/") {
+		return specifier, peStatusInvalidModuleSpecifier, peDebug{token: imports.firstToken}
+	}
+	if imports.kind == peInvalid {
+		return "", peStatusInvalidPackageConfiguration, peDebug{token: imports.firstToken}
+	}
+	if imports.kind == peObject {
+		resolved, status, debug := esmPackageImportsExportsResolve(specifier, imports, "/", true, conditions)
+		if status != peStatusNull && status != peStatusUndefined {
+			return resolved, status, debug
+		}
+	}
+	return specifier, peStatusPackageImportNotDefined, peDebug{token: imports.firstToken}
+}
+
 func esmPackageImportsExportsResolve(
 	matchKey string,
 	matchObj peEntry,
 	packageURL string,
+	internal bool,
 	conditions map[string]bool,
 ) (string, peStatus, peDebug) {
 	if !strings.HasSuffix(matchKey, "*") {
 		if target, ok := matchObj.valueForKey(matchKey); ok {
-			return esmPackageTargetResolve(packageURL, target, "", false, conditions)
+			return esmPackageTargetResolve(packageURL, target, "", false, internal, conditions)
 		}
 	}
 
@@ -601,14 +647,14 @@ func esmPackageImportsExportsResolve(
 			if substr := expansion.key[:len(expansion.key)-1]; strings.HasPrefix(matchKey, substr) && matchKey != substr {
 				target := expansion.value
 				subpath := matchKey[len(expansion.key)-1:]
-				return esmPackageTargetResolve(packageURL, target, subpath, true, conditions)
+				return esmPackageTargetResolve(packageURL, target, subpath, true, internal, conditions)
 			}
 		}
 
 		if strings.HasPrefix(matchKey, expansion.key) {
 			target := expansion.value
 			subpath := matchKey[len(expansion.key):]
-			result, status, debug := esmPackageTargetResolve(packageURL, target, subpath, false, conditions)
+			result, status, debug := esmPackageTargetResolve(packageURL, target, subpath, false, internal, conditions)
 			if status == peStatusExact {
 				// Return the object { resolved, exact: false }.
 				status = peStatusInexact
@@ -649,6 +695,7 @@ func esmPackageTargetResolve(
 	target peEntry,
 	subpath string,
 	pattern bool,
+	internal bool,
 	conditions map[string]bool,
 ) (string, peStatus, peDebug) {
 	switch target.kind {
@@ -660,6 +707,16 @@ func esmPackageTargetResolve(
 		}
 
 		if !strings.HasPrefix(target.strData, "./") {
+			// If internal is true and target does not start with "../" or "/" and
+			// is not a valid URL, then return PACKAGE_RESOLVE(target + subpath).
+			if internal && !strings.HasPrefix(target.strData, "../") && !strings.HasPrefix(target.strData, "/") &&
+				!strings.Contains(target.strData, ":") {
+				if pattern {
+					return strings.ReplaceAll(target.strData, "*", subpath), peStatusPackageResolve, peDebug{token: target.firstToken}
+				}
+				return target.strData + subpath, peStatusPackageResolve, peDebug{token: target.firstToken}
+			}
+
 			return target.strData, peStatusInvalidPackageTarget, peDebug{token: target.firstToken}
 		}
 
@@ -690,7 +747,7 @@ func esmPackageTargetResolve(
 		for _, p := range target.mapData {
 			if p.key == "default" || conditions[p.key] {
 				targetValue := p.value
-				resolved, status, debug := esmPackageTargetResolve(packageURL, targetValue, subpath, pattern, conditions)
+				resolved, status, debug := esmPackageTargetResolve(packageURL, targetValue, subpath, pattern, internal, conditions)
 				if status.isUndefined() {
 					continue
 				}
@@ -720,7 +777,7 @@ func esmPackageTargetResolve(
 		lastDebug := peDebug{token: target.firstToken}
 		for _, targetValue := range target.arrData {
 			// Let resolved be the result, continuing the loop on any Invalid Package Target error.
-			resolved, status, debug := esmPackageTargetResolve(packageURL, targetValue, subpath, pattern, conditions)
+			resolved, status, debug := esmPackageTargetResolve(packageURL, targetValue, subpath, pattern, internal, conditions)
 			if status == peStatusInvalidPackageTarget || status == peStatusNull {
 				lastException = status
 				lastDebug = debug
@@ -772,3 +829,43 @@ func esmParsePackageName(packageSpecifier string) (packageName string, packageSu
 	ok = true
 	return
 }
+
+// Returns the replacement for the entry point "absPath" of the package in the
+// directory "path" if one of the configured redirects matches it
+func (r resolverQuery) redirectPackageEntry(
+	json js_ast.Expr,
+	path string,
+	absPath string,
+	toAbsPath func(string, logger.Range) *string,
+) string {
+	if len(r.options.PackageEntryRedirects) == 0 {
+		return absPath
+	}
+
+	nameJSON, _, ok := getProperty(json, "name")
+	if !ok {
+		return absPath
+	}
+	name, ok := getString(nameJSON)
+	if !ok {
+		return absPath
+	}
+
+	for _, redirect := range r.options.PackageEntryRedirects {
+		if redirect.PackageName != name || r.fs.Join(path, redirect.Main) != absPath {
+			continue
+		}
+		if redirectPath := toAbsPath(r.fs.Join(path, redirect.Redirect), logger.Range{}); redirectPath != nil {
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Redirecting the entry point of package %q from %q to %q",
+					name, absPath, *redirectPath))
+			}
+			return *redirectPath
+		}
+		if r.debugLogs != nil {
+			r.debugLogs.addNote(fmt.Sprintf("Ignoring the redirect of package %q to %q because it doesn't exist",
+				name, r.fs.Join(path, redirect.Redirect)))
+		}
+	}
+	return absPath
+}
@@ -226,7 +226,7 @@ func NewResolver(fs fs.FS, log logger.Log, caches *cache.CacheSet, options confi
 	// Filter out non-CSS extensions for CSS "@import" imports
 	atImportExtensionOrder := make([]string, 0, len(options.ExtensionOrder))
 	for _, ext := range options.ExtensionOrder {
-		if loader, ok := options.ExtensionToLoader[ext]; ok && loader != config.LoaderCSS {
+		if loader, ok := options.ExtensionToLoader[ext]; ok && !loader.IsCSS() {
 			continue
 		}
 		atImportExtensionOrder = append(atImportExtensionOrder, ext)
@@ -275,6 +275,13 @@ func (rr *resolver) Resolve(sourceDir string, importPath string, kind ast.Import
 			importPath, sourceDir, kind.StringForMetafile())}
 	}
 
+	// Aliases are applied before anything else, even before external checks
+	if r.options.Alias != nil && IsPackagePath(importPath) {
+		if aliased, ok := r.applyAlias(importPath); ok {
+			importPath = aliased
+		}
+	}
+
 	// Certain types of URLs default to being external for convenience
 	if r.isExternalPattern(importPath) ||
 
@@ -368,6 +375,25 @@ func (rr *resolver) Resolve(sourceDir string, importPath string, kind ast.Import
 	return result, debug
 }
 
+// This rewrites "pkg" and "pkg/subpath" using the longest matching alias
+func (r resolverQuery) applyAlias(importPath string) (string, bool) {
+	longestName := ""
+	for name := range r.options.Alias {
+		if len(name) > len(longestName) && strings.HasPrefix(importPath, name) &&
+			(len(importPath) == len(name) || importPath[len(name)] == '/') {
+			longestName = name
+		}
+	}
+	if longestName == "" {
+		return "", false
+	}
+	aliased := r.options.Alias[longestName] + importPath[len(longestName):]
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Applying the alias %q to rewrite the import path to %q", longestName, aliased))
+	}
+	return aliased, true
+}
+
 func (r resolverQuery) isExternalPattern(path string) bool {
 	for _, pattern := range r.options.ExternalModules.Patterns {
 		if len(path) >= len(pattern.Prefix)+len(pattern.Suffix) &&
@@ -646,6 +672,21 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 		}
 	}
 
+	// Check for subpath imports, which are resolved using the "imports" map of
+	// the package containing the importing file. Paths starting with "#" are
+	// resolved like other package paths if there is no such map, since they
+	// may also be matched by the "paths" in "tsconfig.json".
+	if checkPackage && strings.HasPrefix(importPath, "#") {
+		if packageDirInfo := r.enclosingPackageImportsScope(sourceDir); packageDirInfo != nil {
+			absolute, ok, diffCase, debug := r.loadPackageImports(importPath, kind, packageDirInfo)
+			if !ok {
+				return nil, debug
+			}
+			result = ResolveResult{PathPair: absolute, DifferentCase: diffCase}
+			checkPackage = false
+		}
+	}
+
 	if checkPackage {
 		// Check for external packages first
 		if r.options.ExternalModules.NodeModules != nil {
@@ -798,6 +839,9 @@ type dirInfo struct {
 	// package.json. We need this to remap paths after they have been resolved.
 	enclosingBrowserScope *dirInfo
 
+	// A pointer to the nearest enclosing Yarn Plug'n'Play manifest, if any
+	pnpManifest *pnpData
+
 	// All relevant information about this directory
 	absPath        string
 	entries        fs.DirEntries
@@ -1013,9 +1057,10 @@ func (r resolverQuery) dirInfoUncached(path string) *dirInfo {
 		}
 	}
 
-	// Propagate the browser scope into child directories
+	// Propagate the browser scope and the Yarn PnP manifest into child directories
 	if parentInfo != nil {
 		info.enclosingBrowserScope = parentInfo.enclosingBrowserScope
+		info.pnpManifest = parentInfo.pnpManifest
 
 		// Make sure "absRealPath" is the real path of the directory (resolving any symlinks)
 		if !r.options.PreserveSymlinks {
@@ -1046,6 +1091,19 @@ func (r resolverQuery) dirInfoUncached(path string) *dirInfo {
 		}
 	}
 
+	// Record if this directory has a Yarn Plug'n'Play manifest
+	for _, name := range []string{".pnp.data.json", ".pnp.cjs", ".pnp.js"} {
+		if entry, _ := entries.Get(name); entry != nil && entry.Kind(r.fs) == fs.FileEntry {
+			if manifest := r.parsePnPManifest(path, name); manifest != nil {
+				info.pnpManifest = manifest
+
+				// Packages in the manifest can be stored in zip archives
+				fs.EnableZipArchives(r.fs)
+			}
+			break
+		}
+	}
+
 	// Record if this directory has a tsconfig.json or jsconfig.json file
 	{
 		var tsConfigPath string
@@ -1219,7 +1277,7 @@ func getBool(json js_ast.Expr) (bool, bool) {
 func (r resolverQuery) loadAsFileOrDirectory(path string, kind ast.ImportKind) (PathPair, bool, *fs.DifferentCase) {
 	// Use a special import order for CSS "@import" imports
 	extensionOrder := r.options.ExtensionOrder
-	if kind == ast.ImportAt || kind == ast.ImportAtConditional {
+	if kind == ast.ImportAt || kind == ast.ImportAtConditional || kind == ast.ImportComposesFrom {
 		extensionOrder = r.atImportExtensionOrder
 	}
 
@@ -1450,6 +1508,31 @@ func (r resolverQuery) loadNodeModules(path string, kind ast.ImportKind, dirInfo
 		r.debugLogs.addNote(fmt.Sprintf("Parsed package name %q and package subpath %q", esmPackageName, esmPackageSubpath))
 	}
 
+	// Then check the enclosing Yarn Plug'n'Play manifest, which replaces the
+	// "node_modules" directories if the importing path belongs to a package
+	if manifest := dirInfo.pnpManifest; manifest != nil && esmOK && !BuiltInNodeModules[path] {
+		absPkgPath, parentLocator, status := r.pnpResolveToUnqualified(manifest, esmPackageName, dirInfo.absPath)
+		switch status {
+		case pnpStatusSuccess:
+			if pkgJSON := r.packageJSONWithExportsMap(absPkgPath); pkgJSON != nil {
+				return r.loadPackageExports(absPkgPath, pkgJSON, esmPackageName, esmPackageSubpath, kind)
+			}
+			absPath := r.fs.Join(absPkgPath, esmPackageSubpath)
+			if absolute, ok, diffCase := r.loadAsFileOrDirectory(absPath, kind); ok {
+				return absolute, true, diffCase, DebugMeta{}
+			}
+			return PathPair{}, false, nil, DebugMeta{}
+
+		case pnpStatusNoLocator:
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("The directory %q isn't part of any package in the Yarn PnP manifest", dirInfo.absPath))
+			}
+
+		default:
+			return PathPair{}, false, nil, DebugMeta{notes: pnpFailureNotes(esmPackageName, parentLocator, status)}
+		}
+	}
+
 	// Then check for the package in any enclosing "node_modules" directories
 	for {
 		// Skip directories that are themselves called "node_modules", since we
@@ -1463,134 +1546,8 @@ func (r resolverQuery) loadNodeModules(path string, kind ast.ImportKind, dirInfo
 			// Check for an "exports" map in the package's package.json starting from ,dirInfo.absPath%q
 			if esmOK {
 				absPkgPath := r.fs.Join(dirInfo.absPath, "node_modules", esmPackageName)
-				if pkgDirInfo := r.dirInfoCached(absPkgPath); pkgDirInfo != nil {
-					if pkgJSON := pkgDirInfo.packageJSON; pkgJSON != nil && pkgJSON.exportsMap != nil {
-						if r.debugLogs != nil {
-							r.debugLogs.addNote(fmt.Sprintf("Checking \"exports\" map in %s", pkgJSON.source.KeyPath.Text))
-						}
-
-						// The condition set is determined by the kind of import
-						conditions := r.esmConditionsDefault
-						switch kind {
-						case ast.ImportStmt, ast.ImportDynamic:
-							conditions = r.esmConditionsImport
-						case ast.ImportRequire, ast.ImportRequireResolve:
-							conditions = r.esmConditionsRequire
-						}
-
-						// Resolve against the path "/", then join it with the absolute
-						// directory path. This is done because ESM package resolution uses
-						// URLs while our path resolution uses file system paths. We don't
-						// want problems due to Windows paths, which are very unlike URL
-						// paths. We also want to avoid any "%" characters in the absolute
-						// directory path accidentally being interpreted as URL escapes.
-						resolvedPath, status, debug := esmPackageExportsResolveWithPostConditions("/", esmPackageSubpath, pkgJSON.exportsMap.root, conditions)
-						if (status == peStatusExact || status == peStatusInexact) && strings.HasPrefix(resolvedPath, "/") {
-							absResolvedPath := r.fs.Join(absPkgPath, resolvedPath[1:])
-
-							switch status {
-							case peStatusExact:
-								resolvedDirInfo := r.dirInfoCached(r.fs.Dir(absResolvedPath))
-								if resolvedDirInfo == nil {
-									status = peStatusModuleNotFound
-								} else if entry, diffCase := resolvedDirInfo.entries.Get(r.fs.Base(absResolvedPath)); entry == nil {
-									status = peStatusModuleNotFound
-								} else if kind := entry.Kind(r.fs); kind == fs.DirEntry {
-									status = peStatusUnsupportedDirectoryImport
-								} else if kind != fs.FileEntry {
-									status = peStatusModuleNotFound
-								} else {
-									if r.debugLogs != nil {
-										r.debugLogs.addNote(fmt.Sprintf("Resolved to %q", absResolvedPath))
-									}
-									return PathPair{Primary: logger.Path{Text: absResolvedPath, Namespace: "file"}}, true, diffCase, DebugMeta{}
-								}
-
-							case peStatusInexact:
-								// If this was resolved against an expansion key ending in a "/"
-								// instead of a "*", we need to try CommonJS-style implicit
-								// extension and/or directory detection.
-								if absolute, ok, diffCase := r.loadAsFileOrDirectory(absResolvedPath, kind); ok {
-									return absolute, true, diffCase, DebugMeta{}
-								}
-								status = peStatusModuleNotFound
-							}
-						}
-
-						var notes []logger.MsgData
-						var approach AlternativeApproach
-						if strings.HasPrefix(resolvedPath, "/") {
-							resolvedPath = "." + resolvedPath
-						}
-
-						// Provide additional details about the failure to help with debugging
-						switch status {
-						case peStatusInvalidModuleSpecifier:
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token,
-								fmt.Sprintf("The module specifier %q is invalid", resolvedPath))}
-
-						case peStatusInvalidPackageConfiguration:
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token,
-								"The package configuration has an invalid value here")}
-
-						case peStatusInvalidPackageTarget:
-							why := fmt.Sprintf("The package target %q is invalid", resolvedPath)
-							if resolvedPath == "" {
-								// "PACKAGE_TARGET_RESOLVE" is specified to throw an "Invalid
-								// Package Target" error for what is actually an invalid package
-								// configuration error
-								why = "The package configuration has an invalid value here"
-							}
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token, why)}
-
-						case peStatusPackagePathNotExported:
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token,
-								fmt.Sprintf("The path %q is not exported by package %q", esmPackageSubpath, esmPackageName))}
-
-						case peStatusModuleNotFound:
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token,
-								fmt.Sprintf("The module %q was not found on the file system", resolvedPath))}
-
-						case peStatusUnsupportedDirectoryImport:
-							notes = []logger.MsgData{logger.RangeData(&pkgJSON.source, debug.token,
-								fmt.Sprintf("Importing the directory %q is not supported", resolvedPath))}
-
-						case peStatusUndefinedNoConditionsMatch:
-							prettyPrintConditions := func(conditions []string) string {
-								quoted := make([]string, len(conditions))
-								for i, condition := range conditions {
-									quoted[i] = fmt.Sprintf("%q", condition)
-								}
-								return strings.Join(quoted, ", ")
-							}
-							keys := make([]string, 0, len(conditions))
-							for key := range conditions {
-								keys = append(keys, key)
-							}
-							sort.Strings(keys)
-							notes = []logger.MsgData{
-								logger.RangeData(&pkgJSON.source, pkgJSON.exportsMap.root.firstToken,
-									fmt.Sprintf("The path %q is not currently exported by package %q",
-										esmPackageSubpath, esmPackageName)),
-								logger.RangeData(&pkgJSON.source, debug.token,
-									fmt.Sprintf("None of the conditions provided (%s) match any of the currently active conditions (%s)",
-										prettyPrintConditions(debug.unmatchedConditions),
-										prettyPrintConditions(keys),
-									))}
-							for _, key := range debug.unmatchedConditions {
-								if key == "import" && (kind == ast.ImportRequire || kind == ast.ImportRequireResolve) {
-									approach = AlternativeApproachImport
-								} else if key == "require" && (kind == ast.ImportStmt || kind == ast.ImportDynamic) {
-									approach = AlternativeApproachRequire
-								}
-							}
-						}
-
-						return PathPair{}, false, nil, DebugMeta{
-							notes:    notes,
-							approach: approach,
-						}
-					}
+				if pkgJSON := r.packageJSONWithExportsMap(absPkgPath); pkgJSON != nil {
+					return r.loadPackageExports(absPkgPath, pkgJSON, esmPackageName, esmPackageSubpath, kind)
 				}
 			}
 
@@ -1623,6 +1580,225 @@ func (r resolverQuery) loadNodeModules(path string, kind ast.ImportKind, dirInfo
 	return PathPair{}, false, nil, DebugMeta{}
 }
 
+func (r resolverQuery) packageJSONWithExportsMap(absPkgPath string) *packageJSON {
+	if pkgDirInfo := r.dirInfoCached(absPkgPath); pkgDirInfo != nil {
+		if pkgJSON := pkgDirInfo.packageJSON; pkgJSON != nil && pkgJSON.exportsMap != nil {
+			return pkgJSON
+		}
+	}
+	return nil
+}
+
+func (r resolverQuery) loadPackageExports(
+	absPkgPath string,
+	pkgJSON *packageJSON,
+	esmPackageName string,
+	esmPackageSubpath string,
+	kind ast.ImportKind,
+) (PathPair, bool, *fs.DifferentCase, DebugMeta) {
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Checking \"exports\" map in %s", pkgJSON.source.KeyPath.Text))
+	}
+
+	// Resolve against the path "/", then join it with the absolute
+	// directory path. This is done because ESM package resolution uses
+	// URLs while our path resolution uses file system paths. We don't
+	// want problems due to Windows paths, which are very unlike URL
+	// paths. We also want to avoid any "%" characters in the absolute
+	// directory path accidentally being interpreted as URL escapes.
+	conditions := r.esmConditionsForKind(kind)
+	resolvedPath, status, debug := esmPackageExportsResolveWithPostConditions("/", esmPackageSubpath, pkgJSON.exportsMap.root, conditions)
+	return r.finalizeImportsExportsResult(
+		absPkgPath, conditions, *pkgJSON.exportsMap, pkgJSON,
+		resolvedPath, status, debug,
+		esmPackageName, esmPackageSubpath, kind,
+	)
+}
+
+// Returns the directory of the nearest enclosing "package.json" file if it has
+// an "imports" map. This doesn't leave the package, which ends at the enclosing
+// "node_modules" directory.
+func (r resolverQuery) enclosingPackageImportsScope(sourceDir string) *dirInfo {
+	dirInfo := r.dirInfoCached(sourceDir)
+	for dirInfo != nil && dirInfo.packageJSON == nil {
+		if r.fs.Base(dirInfo.absPath) == "node_modules" {
+			return nil
+		}
+		dirInfo = dirInfo.parent
+	}
+	if dirInfo == nil || dirInfo.packageJSON.importsMap == nil {
+		return nil
+	}
+	return dirInfo
+}
+
+// Reference: https://nodejs.org/api/esm.html#esm_resolver_algorithm_specification
+func (r resolverQuery) loadPackageImports(importPath string, kind ast.ImportKind, dirInfo *dirInfo) (PathPair, bool, *fs.DifferentCase, DebugMeta) {
+	packageJSON := dirInfo.packageJSON
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Checking \"imports\" map in %s", packageJSON.source.KeyPath.Text))
+	}
+
+	// Like "exports", this is resolved against the path "/"
+	conditions := r.esmConditionsForKind(kind)
+	resolvedPath, status, debug := esmPackageImportsResolveWithPostConditions(importPath, packageJSON.importsMap.root, conditions)
+
+	// The "imports" map can also point to other packages: "#dep": "dep"
+	if status == peStatusPackageResolve {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote(fmt.Sprintf("The import %q is mapped to the package path %q", importPath, resolvedPath))
+		}
+		return r.loadNodeModules(resolvedPath, kind, dirInfo)
+	}
+
+	return r.finalizeImportsExportsResult(
+		dirInfo.absPath, conditions, *packageJSON.importsMap, packageJSON,
+		resolvedPath, status, debug,
+		"", importPath, kind,
+	)
+}
+
+func (r resolverQuery) esmConditionsForKind(kind ast.ImportKind) map[string]bool {
+	// The condition set is determined by the kind of import
+	switch kind {
+	case ast.ImportStmt, ast.ImportDynamic:
+		return r.esmConditionsImport
+	case ast.ImportRequire, ast.ImportRequireResolve:
+		return r.esmConditionsRequire
+	}
+	return r.esmConditionsDefault
+}
+
+// This turns the result of resolving against an "exports" or "imports" map
+// into a path on the file system. The resolved path is relative to "/" which
+// stands for the directory of the "package.json" file. For "imports" maps,
+// "esmPackageName" is empty and "esmPackageSubpath" is the "#" specifier.
+func (r resolverQuery) finalizeImportsExportsResult(
+	absDirPath string,
+	conditions map[string]bool,
+	importExportMap peMap,
+	packageJSON *packageJSON,
+	resolvedPath string,
+	status peStatus,
+	debug peDebug,
+	esmPackageName string,
+	esmPackageSubpath string,
+	kind ast.ImportKind,
+) (PathPair, bool, *fs.DifferentCase, DebugMeta) {
+	if (status == peStatusExact || status == peStatusInexact) && strings.HasPrefix(resolvedPath, "/") {
+		absResolvedPath := r.fs.Join(absDirPath, resolvedPath[1:])
+
+		switch status {
+		case peStatusExact:
+			resolvedDirInfo := r.dirInfoCached(r.fs.Dir(absResolvedPath))
+			if resolvedDirInfo == nil {
+				status = peStatusModuleNotFound
+			} else if entry, diffCase := resolvedDirInfo.entries.Get(r.fs.Base(absResolvedPath)); entry == nil {
+				status = peStatusModuleNotFound
+			} else if kind := entry.Kind(r.fs); kind == fs.DirEntry {
+				status = peStatusUnsupportedDirectoryImport
+			} else if kind != fs.FileEntry {
+				status = peStatusModuleNotFound
+			} else {
+				if r.debugLogs != nil {
+					r.debugLogs.addNote(fmt.Sprintf("Resolved to %q", absResolvedPath))
+				}
+				return PathPair{Primary: logger.Path{Text: absResolvedPath, Namespace: "file"}}, true, diffCase, DebugMeta{}
+			}
+
+		case peStatusInexact:
+			// If this was resolved against an expansion key ending in a "/"
+			// instead of a "*", we need to try CommonJS-style implicit
+			// extension and/or directory detection.
+			if absolute, ok, diffCase := r.loadAsFileOrDirectory(absResolvedPath, kind); ok {
+				return absolute, true, diffCase, DebugMeta{}
+			}
+			status = peStatusModuleNotFound
+		}
+	}
+
+	var notes []logger.MsgData
+	var approach AlternativeApproach
+	if strings.HasPrefix(resolvedPath, "/") {
+		resolvedPath = "." + resolvedPath
+	}
+
+	// Provide additional details about the failure to help with debugging
+	switch status {
+	case peStatusInvalidModuleSpecifier:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			fmt.Sprintf("The module specifier %q is invalid", resolvedPath))}
+
+	case peStatusInvalidPackageConfiguration:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			"The package configuration has an invalid value here")}
+
+	case peStatusInvalidPackageTarget:
+		why := fmt.Sprintf("The package target %q is invalid", resolvedPath)
+		if resolvedPath == "" {
+			// "PACKAGE_TARGET_RESOLVE" is specified to throw an "Invalid
+			// Package Target" error for what is actually an invalid package
+			// configuration error
+			why = "The package configuration has an invalid value here"
+		}
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token, why)}
+
+	case peStatusPackagePathNotExported:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			fmt.Sprintf("The path %q is not exported by package %q", esmPackageSubpath, esmPackageName))}
+
+	case peStatusPackageImportNotDefined:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			fmt.Sprintf("The package import %q is not defined in this \"imports\" map", resolvedPath))}
+
+	case peStatusModuleNotFound:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			fmt.Sprintf("The module %q was not found on the file system", resolvedPath))}
+
+	case peStatusUnsupportedDirectoryImport:
+		notes = []logger.MsgData{logger.RangeData(&packageJSON.source, debug.token,
+			fmt.Sprintf("Importing the directory %q is not supported", resolvedPath))}
+
+	case peStatusUndefinedNoConditionsMatch:
+		prettyPrintConditions := func(conditions []string) string {
+			quoted := make([]string, len(conditions))
+			for i, condition := range conditions {
+				quoted[i] = fmt.Sprintf("%q", condition)
+			}
+			return strings.Join(quoted, ", ")
+		}
+		keys := make([]string, 0, len(conditions))
+		for key := range conditions {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		why := fmt.Sprintf("The path %q is not currently exported by package %q", esmPackageSubpath, esmPackageName)
+		if strings.HasPrefix(esmPackageSubpath, "#") {
+			why = fmt.Sprintf("The package import %q is not currently defined in this \"imports\" map", esmPackageSubpath)
+		}
+		notes = []logger.MsgData{
+			logger.RangeData(&packageJSON.source, importExportMap.root.firstToken, why),
+			logger.RangeData(&packageJSON.source, debug.token,
+				fmt.Sprintf("None of the conditions provided (%s) match any of the currently active conditions (%s)",
+					prettyPrintConditions(debug.unmatchedConditions),
+					prettyPrintConditions(keys),
+				))}
+		for _, key := range debug.unmatchedConditions {
+			if key == "import" && (kind == ast.ImportRequire || kind == ast.ImportRequireResolve) {
+				approach = AlternativeApproachImport
+			} else if key == "require" && (kind == ast.ImportStmt || kind == ast.ImportDynamic) {
+				approach = AlternativeApproachRequire
+			}
+		}
+	}
+
+	return PathPair{}, false, nil, DebugMeta{
+		notes:    notes,
+		approach: approach,
+	}
+
+}
+
 // Package paths are loaded from a "node_modules" directory. Non-package paths
 // are relative or absolute paths.
 func IsPackagePath(path string) bool {
@@ -0,0 +1,414 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_parser"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This file implements the Yarn Plug'n'Play specification, which replaces the
+// "node_modules" directory tree with a manifest that maps each package to the
+// location of each of its dependencies:
+//
+//   https://yarnpkg.com/advanced/pnp-spec/
+//
+// The manifest is either stored in a ".pnp.data.json" file or inlined into the
+// ".pnp.cjs" file as a JSON string. The packages themselves are usually stored
+// inside zip archives, which are handled by the file system layer.
+
+type pnpData struct {
+	// The directory containing the manifest. All package locations in the
+	// manifest are relative to this directory.
+	absDirPath string
+
+	// If true, packages are allowed to use the dependencies of the top-level
+	// package and of the fallback pool even if they don't declare them
+	enableTopLevelFallback bool
+
+	// Packages in this list aren't allowed to use the top-level fallback. This
+	// maps package names to a set of package references.
+	fallbackExclusionList map[string]map[string]bool
+
+	// Dependencies in this map are available to all packages when the top-level
+	// fallback is enabled
+	fallbackPool map[string]pnpIdentAndReference
+
+	// Paths matching this pattern are not considered to be part of any package
+	ignorePatternData *regexp.Regexp
+
+	// This maps package names to package references to package information
+	packageRegistryData map[string]map[string]pnpPackage
+
+	// This maps package locations back to package locators so that the package
+	// containing a given path can be found
+	packageLocatorsByLocations map[string]pnpPackageLocatorByLocation
+}
+
+// A locator uniquely identifies a package. The top-level package has both an
+// empty ident and an empty reference.
+type pnpIdentAndReference struct {
+	ident     string
+	reference string
+}
+
+type pnpPackage struct {
+	// Each dependency is either a reference to a package with the same name or
+	// an alias to a package with a different name. A dependency with an empty
+	// ident is a peer dependency that wasn't provided by any ancestor.
+	packageDependencies map[string]pnpIdentAndReference
+
+	packageLocation   string
+	discardFromLookup bool
+}
+
+type pnpPackageLocatorByLocation struct {
+	locator           pnpIdentAndReference
+	discardFromLookup bool
+}
+
+type pnpStatus uint8
+
+const (
+	pnpStatusSuccess pnpStatus = iota
+
+	// The importing path isn't inside of any package in the manifest, so the
+	// resolver should fall back to using "node_modules" directories
+	pnpStatusNoLocator
+
+	// The package was found in the manifest but it doesn't declare the import
+	pnpStatusUndeclaredDependency
+
+	// The package has a peer dependency that no ancestor provides
+	pnpStatusMissingPeerDependency
+)
+
+// Reference: https://yarnpkg.com/advanced/pnp-spec/#resolve_to_unqualified
+func (r resolverQuery) pnpResolveToUnqualified(manifest *pnpData, ident string, parentURL string) (string, pnpIdentAndReference, pnpStatus) {
+	parentLocator, ok := r.pnpFindLocator(manifest, parentURL)
+	if !ok {
+		return "", pnpIdentAndReference{}, pnpStatusNoLocator
+	}
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Found Yarn PnP locator %s for %q", parentLocator.pretty(), parentURL))
+	}
+
+	parentPkg, ok := manifest.getPackage(parentLocator)
+	if !ok {
+		return "", parentLocator, pnpStatusNoLocator
+	}
+
+	referenceOrAlias, ok := parentPkg.packageDependencies[ident]
+
+	// If the dependency isn't declared, try the top-level fallback
+	if !ok && manifest.enableTopLevelFallback && !manifest.fallbackExclusionList[parentLocator.ident][parentLocator.reference] {
+		if fallback, ok2 := manifest.resolveViaFallback(ident); ok2 {
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Found %q in the Yarn PnP top-level fallback", ident))
+			}
+			referenceOrAlias, ok = fallback, true
+		}
+	}
+
+	if !ok {
+		return "", parentLocator, pnpStatusUndeclaredDependency
+	}
+	if referenceOrAlias.ident == "" {
+		return "", parentLocator, pnpStatusMissingPeerDependency
+	}
+
+	dependencyPkg, ok := manifest.getPackage(referenceOrAlias)
+	if !ok {
+		return "", parentLocator, pnpStatusUndeclaredDependency
+	}
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Resolved %q through Yarn PnP to %s at %q", ident, referenceOrAlias.pretty(), dependencyPkg.packageLocation))
+	}
+	return r.fs.Join(manifest.absDirPath, dependencyPkg.packageLocation), parentLocator, pnpStatusSuccess
+}
+
+// Reference: https://yarnpkg.com/advanced/pnp-spec/#find_locator
+func (r resolverQuery) pnpFindLocator(manifest *pnpData, moduleURL string) (pnpIdentAndReference, bool) {
+	relativeURL, ok := r.fs.Rel(manifest.absDirPath, moduleURL)
+	if !ok {
+		return pnpIdentAndReference{}, false
+	}
+	relativeURL = strings.ReplaceAll(relativeURL, "\\", "/")
+
+	if manifest.ignorePatternData != nil && manifest.ignorePatternData.MatchString(relativeURL) {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote(fmt.Sprintf("The path %q is ignored by the Yarn PnP manifest", relativeURL))
+		}
+		return pnpIdentAndReference{}, false
+	}
+
+	// Package locations are stored with a leading "./" and a trailing "/"
+	if relativeURL == "." {
+		relativeURL = "./"
+	} else if !strings.HasPrefix(relativeURL, "../") {
+		relativeURL = "./" + relativeURL + "/"
+	} else {
+		relativeURL += "/"
+	}
+
+	// The longest package location that's a prefix of the path wins, so walk
+	// up the directory tree one directory at a time
+	for {
+		if locator, ok := manifest.packageLocatorsByLocations[relativeURL]; ok && !locator.discardFromLookup {
+			return locator.locator, true
+		}
+		relativeURL = relativeURL[:len(relativeURL)-1]
+		slash := strings.LastIndexByte(relativeURL, '/')
+		if slash == -1 {
+			break
+		}
+		relativeURL = relativeURL[:slash+1]
+	}
+	return pnpIdentAndReference{}, false
+}
+
+// Reference: https://yarnpkg.com/advanced/pnp-spec/#resolve_via_fallback
+func (manifest *pnpData) resolveViaFallback(ident string) (pnpIdentAndReference, bool) {
+	if topLevelPkg, ok := manifest.getPackage(pnpIdentAndReference{}); ok {
+		if referenceOrAlias, ok := topLevelPkg.packageDependencies[ident]; ok {
+			return referenceOrAlias, true
+		}
+	}
+	referenceOrAlias, ok := manifest.fallbackPool[ident]
+	return referenceOrAlias, ok
+}
+
+// Reference: https://yarnpkg.com/advanced/pnp-spec/#get_package
+func (manifest *pnpData) getPackage(locator pnpIdentAndReference) (pnpPackage, bool) {
+	pkg, ok := manifest.packageRegistryData[locator.ident][locator.reference]
+	return pkg, ok
+}
+
+func (locator pnpIdentAndReference) pretty() string {
+	if locator.ident == "" {
+		return "the top-level package"
+	}
+	return fmt.Sprintf("%q (%s)", locator.ident, locator.reference)
+}
+
+func pnpFailureNotes(ident string, parentLocator pnpIdentAndReference, status pnpStatus) []logger.MsgData {
+	switch status {
+	case pnpStatusUndeclaredDependency:
+		return []logger.MsgData{{Text: fmt.Sprintf(
+			"The Yarn Plug'n'Play manifest doesn't list %q as a dependency of %s", ident, parentLocator.pretty())}}
+
+	case pnpStatusMissingPeerDependency:
+		return []logger.MsgData{{Text: fmt.Sprintf(
+			"The package %q is a peer dependency of %s but isn't provided by any of its ancestors", ident, parentLocator.pretty())}}
+	}
+	return nil
+}
+
+func (r resolverQuery) parsePnPManifest(absDirPath string, fileName string) *pnpData {
+	absPath := r.fs.Join(absDirPath, fileName)
+	contents, err, originalError := r.caches.FSCache.ReadFile(r.fs, absPath)
+	if r.debugLogs != nil && originalError != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Failed to read file %q: %s", absPath, originalError.Error()))
+	}
+	if err != nil {
+		r.log.AddError(nil, logger.Loc{},
+			fmt.Sprintf("Cannot read file %q: %s",
+				r.PrettyPath(logger.Path{Text: absPath, Namespace: "file"}), err.Error()))
+		return nil
+	}
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("The file %q exists", absPath))
+	}
+
+	keyPath := logger.Path{Text: absPath, Namespace: "file"}
+	source := logger.Source{
+		KeyPath:    keyPath,
+		PrettyPath: r.PrettyPath(keyPath),
+		Contents:   contents,
+	}
+
+	// The ".pnp.cjs" file stores the manifest in a JavaScript string
+	if !strings.HasSuffix(fileName, ".json") {
+		data, ok := extractPnPDataFromJS(source)
+		if !ok {
+			r.log.AddError(&source, logger.Loc{},
+				"Cannot find the Yarn Plug'n'Play manifest data in this file")
+			return nil
+		}
+		source.Contents = data
+	}
+
+	json, ok := r.caches.JSONCache.Parse(r.log, source, js_parser.JSONOptions{})
+	if !ok {
+		return nil
+	}
+	return r.compileYarnPnPData(absDirPath, json)
+}
+
+// Yarn stores the manifest as "const RAW_RUNTIME_STATE = '...';" at the top
+// level of the ".pnp.cjs" file
+func extractPnPDataFromJS(source logger.Source) (string, bool) {
+	ast, ok := js_parser.Parse(logger.NewDeferLog(), source, js_parser.OptionsFromConfig(&config.Options{}))
+	if !ok {
+		return "", false
+	}
+	for _, part := range ast.Parts {
+		for _, stmt := range part.Stmts {
+			local, ok := stmt.Data.(*js_ast.SLocal)
+			if !ok {
+				continue
+			}
+			for _, decl := range local.Decls {
+				if id, ok := decl.Binding.Data.(*js_ast.BIdentifier); ok && decl.Value != nil &&
+					ast.Symbols[id.Ref.InnerIndex].OriginalName == "RAW_RUNTIME_STATE" {
+					if str, ok := decl.Value.Data.(*js_ast.EString); ok {
+						return js_lexer.UTF16ToString(str.Value), true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func (r resolverQuery) compileYarnPnPData(absDirPath string, json js_ast.Expr) *pnpData {
+	data := pnpData{
+		absDirPath:                 absDirPath,
+		fallbackExclusionList:      make(map[string]map[string]bool),
+		fallbackPool:               make(map[string]pnpIdentAndReference),
+		packageRegistryData:        make(map[string]map[string]pnpPackage),
+		packageLocatorsByLocations: make(map[string]pnpPackageLocatorByLocation),
+	}
+
+	if value, _, ok := getProperty(json, "enableTopLevelFallback"); ok {
+		data.enableTopLevelFallback, _ = getBool(value)
+	}
+
+	// Format: [["name", ["reference", ...]], ...]
+	if value, _, ok := getProperty(json, "fallbackExclusionList"); ok {
+		for _, item := range getArray(value) {
+			if tuple := getArray(item); len(tuple) == 2 {
+				ident, _ := getStringOrNull(tuple[0])
+				references := make(map[string]bool)
+				for _, reference := range getArray(tuple[1]) {
+					if text, ok := getStringOrNull(reference); ok {
+						references[text] = true
+					}
+				}
+				data.fallbackExclusionList[ident] = references
+			}
+		}
+	}
+
+	// Format: [["name", "reference" | ["alias", "reference"] | null], ...]
+	if value, _, ok := getProperty(json, "fallbackPool"); ok {
+		for _, item := range getArray(value) {
+			if tuple := getArray(item); len(tuple) == 2 {
+				if ident, ok := getString(tuple[0]); ok {
+					data.fallbackPool[ident] = getDependencyTarget(ident, tuple[1])
+				}
+			}
+		}
+	}
+
+	// This is a JavaScript regular expression, which is usually close enough
+	// to Go's regular expression syntax to be compiled directly
+	if value, _, ok := getProperty(json, "ignorePatternData"); ok {
+		if pattern, ok := getString(value); ok {
+			if regex, err := regexp.Compile(pattern); err == nil {
+				data.ignorePatternData = regex
+			} else if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Ignoring the Yarn PnP ignore pattern %q: %s", pattern, err.Error()))
+			}
+		}
+	}
+
+	// Format: [["name" | null, [["reference" | null, {...}], ...]], ...]
+	if value, _, ok := getProperty(json, "packageRegistryData"); ok {
+		for _, item := range getArray(value) {
+			tuple := getArray(item)
+			if len(tuple) != 2 {
+				continue
+			}
+			ident, _ := getStringOrNull(tuple[0])
+			references := data.packageRegistryData[ident]
+			if references == nil {
+				references = make(map[string]pnpPackage)
+				data.packageRegistryData[ident] = references
+			}
+
+			for _, refItem := range getArray(tuple[1]) {
+				refTuple := getArray(refItem)
+				if len(refTuple) != 2 {
+					continue
+				}
+				reference, _ := getStringOrNull(refTuple[0])
+				pkg := pnpPackage{packageDependencies: make(map[string]pnpIdentAndReference)}
+
+				if location, _, ok := getProperty(refTuple[1], "packageLocation"); ok {
+					pkg.packageLocation, _ = getString(location)
+				}
+				if discard, _, ok := getProperty(refTuple[1], "discardFromLookup"); ok {
+					pkg.discardFromLookup, _ = getBool(discard)
+				}
+				if deps, _, ok := getProperty(refTuple[1], "packageDependencies"); ok {
+					for _, dep := range getArray(deps) {
+						if depTuple := getArray(dep); len(depTuple) == 2 {
+							if depIdent, ok := getString(depTuple[0]); ok {
+								pkg.packageDependencies[depIdent] = getDependencyTarget(depIdent, depTuple[1])
+							}
+						}
+					}
+				}
+
+				references[reference] = pkg
+				locator := pnpIdentAndReference{ident: ident, reference: reference}
+
+				// Packages that are discarded from lookup can still be found if no
+				// other package claims their location
+				if existing, ok := data.packageLocatorsByLocations[pkg.packageLocation]; !ok || existing.discardFromLookup {
+					data.packageLocatorsByLocations[pkg.packageLocation] = pnpPackageLocatorByLocation{
+						locator:           locator,
+						discardFromLookup: pkg.discardFromLookup,
+					}
+				}
+			}
+		}
+	}
+
+	return &data
+}
+
+// A dependency target is either a reference, an alias to a different package
+// in the form ["name", "reference"], or null for a missing peer dependency
+func getDependencyTarget(ident string, json js_ast.Expr) pnpIdentAndReference {
+	if reference, ok := getString(json); ok {
+		return pnpIdentAndReference{ident: ident, reference: reference}
+	}
+	if alias := getArray(json); len(alias) == 2 {
+		aliasIdent, _ := getString(alias[0])
+		aliasReference, _ := getString(alias[1])
+		return pnpIdentAndReference{ident: aliasIdent, reference: aliasReference}
+	}
+	return pnpIdentAndReference{}
+}
+
+func getArray(json js_ast.Expr) []js_ast.Expr {
+	if value, ok := json.Data.(*js_ast.EArray); ok {
+		return value.Items
+	}
+	return nil
+}
+
+// The manifest uses null for the name and reference of the top-level package
+func getStringOrNull(json js_ast.Expr) (string, bool) {
+	if _, ok := json.Data.(*js_ast.ENull); ok {
+		return "", true
+	}
+	return getString(json)
+}
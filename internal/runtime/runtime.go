@@ -62,8 +62,8 @@ func code(isES6 bool, createSnapshot bool) string {
 	//   __spreadArrays
 	//   __values
 	//
-	// Note: The "__objRest" function has a for-of loop which requires ES6, but
-	// transforming destructuring to ES5 isn't even supported so it's ok.
+	// Note: The "__objRest" function has a for-of loop which requires ES6, so
+	// an ES5 variant is used when destructuring is lowered to ES5.
 	text := `
 		var __create = Object.create
 		var __defProp = Object.defineProperty
@@ -73,6 +73,8 @@ func code(isES6 bool, createSnapshot bool) string {
 		var __getOwnPropDesc = Object.getOwnPropertyDescriptor // Note: can return "undefined" due to a Safari bug
 		var __getOwnPropSymbols = Object.getOwnPropertySymbols
 		var __propIsEnum = Object.prototype.propertyIsEnumerable
+		var __freeze = Object.freeze
+		var __setProtoOf = Object.setPrototypeOf || ((obj, proto) => (obj.__proto__ = proto, obj))
 
 		export var __pow = Math.pow
 
@@ -140,6 +142,43 @@ func code(isES6 bool, createSnapshot bool) string {
 				}
 			return target
 		}
+
+		// For lowering tagged template literals
+		export var __template = (cooked, raw) => __freeze(__defProp(cooked, 'raw', { value: __freeze(raw || cooked.slice()) }))
+
+		// For lowering classes
+		export var __inherits = (child, parent) => {
+			if (typeof parent !== 'function' && parent !== null)
+				throw TypeError('Class extends value ' + parent + ' is not a constructor or null')
+			child.prototype = __create(parent && parent.prototype, { constructor: { value: child, writable: true, configurable: true } })
+			if (parent) __setProtoOf(child, parent)
+		}
+		export var __defineProperty = (target, key, descriptor) => {
+			// Class members are not enumerable, unlike properties of object literals
+			descriptor.configurable = true
+			if ('value' in descriptor) descriptor.writable = true
+			return __defProp(target, key, descriptor)
+		}
+
+		// For lowering "super" property accesses outside of calls. Getters and
+		// setters found on the prototype chain must run with the instance as "this".
+		export var __superGet = (proto, key, receiver) => {
+			for (var desc; proto; proto = __getProtoOf(proto))
+				if (desc = __getOwnPropDesc(proto, key))
+					return desc.get ? desc.get.call(receiver) : desc.value
+		}
+		export var __superSet = (proto, key, value, receiver) => {
+			for (var desc; proto; proto = __getProtoOf(proto))
+				if (desc = __getOwnPropDesc(proto, key)) {
+					if (desc.set) return desc.set.call(receiver, value), value
+					if (!desc.writable) throw TypeError('Cannot assign to read only property ' + String(key))
+					break
+				}
+			if ((desc = __getOwnPropDesc(receiver, key)) && !desc.writable)
+				throw TypeError('Cannot assign to read only property ' + String(key))
+			__defProp(receiver, key, desc ? { value } : { value, writable: true, enumerable: true, configurable: true })
+			return value
+		}
    `
 
 	if createSnapshot {
@@ -244,6 +283,10 @@ func code(isES6 bool, createSnapshot bool) string {
 			__accessCheck(obj, member, 'access private method')
 			return method
 		}
+		export var __privateIn = (member, obj) => {
+			if (Object(obj) !== obj) throw TypeError('Cannot use the "in" operator on this value')
+			return member.has(obj)
+		}
 
 		// This helps for lowering async functions
 		export var __async = (__this, __arguments, generator) => {
@@ -267,6 +310,177 @@ func code(isES6 bool, createSnapshot bool) string {
 			})
 		}
 
+		// This helps for lowering async generator functions. Each "await" in the
+		// body becomes a "yield" of a "__awaitValue" object, and "yield*" becomes a
+		// "yield" of the values produced by "__yieldStar" (with "isYieldStar" set).
+		var __knownSymbol = (name, symbol) => typeof Symbol !== 'function' ? '@@' + name : (symbol = Symbol[name]) ? symbol : Symbol.for('Symbol.' + name)
+		export var __awaitValue = function (promise, isYieldStar) {
+			this[0] = promise
+			this[1] = isYieldStar
+		}
+		export var __asyncGen = (__this, __arguments, generator) => {
+			var queue = [], it = {}
+			var settle = (index, value) => {
+				queue.shift()[index](value)
+				if (queue.length) step(queue[0][0], queue[0][1])
+			}
+			var step = (key, value) => {
+				try {
+					var result = generator[key](value), isAwait = (value = result.value) instanceof __awaitValue
+					Promise.resolve(isAwait ? value[0] : value).then(
+						x => isAwait
+							// Only "yield*" forwards "return" to the inner iterator after an "await"
+							? step(value[1] && key === 'return' ? key : 'next', value[1] ? { done: x.done, value: x.value } : x)
+							: settle(2, { value: x, done: result.done }),
+						e => step('throw', e))
+				} catch (e) {
+					settle(3, e)
+				}
+			}
+			var method = key => it[key] = value => new Promise((resolve, reject) => {
+				if (queue.push([key, value, resolve, reject]) < 2) step(key, value)
+			})
+			generator = generator.apply(__this, __arguments)
+			it[__knownSymbol('asyncIterator')] = () => it
+			method('next')
+			method('throw')
+			method('return')
+			return it
+		}
+		export var __yieldStar = value => {
+			var obj = value[__knownSymbol('asyncIterator')], isAwait = false, method, it = {}
+			if (obj == null) {
+				obj = __iterator(value)
+				method = key => it[key] = x => obj[key](x)
+			} else {
+				obj = obj.call(value)
+				method = key => it[key] = x => {
+					if (isAwait) {
+						isAwait = false
+						if (key === 'throw') throw x
+						return x
+					}
+					isAwait = true
+					return {
+						done: false,
+						value: new __awaitValue(new Promise(resolve => {
+							var result = obj[key](x)
+							if (!(result instanceof Object)) throw TypeError('Object expected')
+							resolve(result)
+						}), 1),
+					}
+				}
+			}
+			it[__knownSymbol('iterator')] = () => it
+			method('next')
+			if ('throw' in obj) method('throw')
+			else it.throw = x => { throw x }
+			if ('return' in obj) method('return')
+			return it
+		}
+
+		// This is for lowering "for await" loops
+		export var __forAwait = (obj, it, method) => (it = obj[__knownSymbol('asyncIterator')])
+			? it.call(obj)
+			: (obj = __iterator(obj), it = {}, method = (key, fn) => (fn = obj[key]) && (it[key] = arg => new Promise((resolve, reject, done) => (
+				arg = fn.call(obj, arg),
+				done = arg.done,
+				Promise.resolve(arg.value).then(value => resolve({ value, done }), reject)
+			))), method('next'), method('return'), it)
+
+		// This is for lowering generator functions. The generator body is turned
+		// into a state machine function that is called with the current state and
+		// that returns one of these instructions:
+		//
+		//   [0, value]         Return "value"
+		//   [1, value, label]  Yield "value" and then resume at "label"
+		//   [2, value, label]  Yield each value from "value" and then resume at "label"
+		//   [3, label]         Jump to "label"
+		//   [4]                End of a "finally" clause
+		//
+		// Each try statement in the body pushes "[tryLabel, catchLabel, finallyLabel,
+		// endLabel]" onto "state.trys" (using 0 for a missing clause). That's used
+		// to run the "catch" and "finally" clauses for any abrupt completion.
+		var __iterator = (value, method, i) => (method = value[__knownSymbol('iterator')])
+			? method.call(value)
+			: (i = 0, { next: () => ({ value: value[i], done: i++ >= value.length }) })
+		export var __stateMachine = (__this, body) => {
+			var state = { label: 0, sent: void 0, trys: [] }, running, done, delegate, it
+
+			// Internally "5" means throw and "6" means continue running the body
+			var unwind = x => {
+				for (var trys = state.trys, entry; entry = trys[trys.length - 1]; trys.pop()) {
+					if (x[0] === 3 && x[1] >= entry[0] && x[1] < entry[3]) break
+					if (x[0] === 5 && entry[1] && state.label < entry[1]) return state.label = entry[1], [6, x[1]]
+					if (state.label < entry[2]) return entry[4] = x, state.label = entry[2], [6]
+				}
+				if (x[0] === 3) return state.label = x[1], [6]
+				done = 1
+				if (x[0] === 5) throw x[1]
+				return x
+			}
+
+			var resume = x => {
+				if (running) throw TypeError('Generator is already running')
+				if (done) {
+					if (x[0] === 5) throw x[1]
+					return { value: x[0] ? void 0 : x[1], done: true }
+				}
+				running = 1
+				try {
+					for (;;) {
+						if (delegate) {
+							var iter = delegate, method = iter[x[0] === 6 ? 'next' : x[0] === 5 ? 'throw' : 'return'], result
+							delegate = 0
+							if (!method) {
+								if (x[0] === 5) {
+									if (iter.return) iter.return()
+									x = [5, TypeError('The iterator does not provide a "throw" method')]
+								}
+								continue
+							}
+							try {
+								result = method.call(iter, x[1])
+							} catch (e) {
+								x = [5, e]
+								continue
+							}
+							if (!result.done) return delegate = iter, result
+							x = [x[0] ? 6 : 0, result.value]
+						} else if (x[0] === 6) {
+							state.sent = x[1]
+							try {
+								x = body.call(__this, state) || [0]
+							} catch (e) {
+								x = [5, e]
+							}
+						} else if (x[0] === 1) {
+							state.label = x[2]
+							return { value: x[1], done: false }
+						} else if (x[0] === 2) {
+							state.label = x[2]
+							delegate = __iterator(x[1])
+							x = [6]
+						} else if (x[0] === 4) {
+							x = state.trys.pop()[4]
+						} else if ((x = unwind(x))[0] === 0) {
+							return { value: x[1], done: true }
+						}
+					}
+				} finally {
+					running = 0
+				}
+			}
+
+			it = {
+				next: value => resume([6, value]),
+				throw: value => resume([5, value]),
+				return: value => resume([0, value]),
+			}
+			it[__knownSymbol('iterator')] = () => it
+			return it
+		}
+
 		// This is for the "binary" loader (custom code is ~2x faster than "atob")
 		export var __toBinary = __platform === 'node'
 			? base64 => new Uint8Array(Buffer.from(base64, 'base64'))
@@ -1,10 +1,12 @@
 package snap_api
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/evanw/esbuild/internal/resolver"
 	"github.com/evanw/esbuild/pkg/api"
+	"github.com/evanw/esbuild/pkg/cli"
 )
 
 func IsExternalModule(platform api.Platform, external []string) api.ShouldReplaceRequirePredicate {
@@ -48,6 +50,12 @@ func trimPrefix(mdl string, prefix string) string {
 
 func CreateShouldRewriteModule(
 	args *SnapCmdArgs,
+) api.ShouldRewriteModulePredicate {
+	return CreateShouldRewriteModuleFromNorewrite(args.Norewrite)
+}
+
+func CreateShouldRewriteModuleFromNorewrite(
+	norewrite []string,
 ) api.ShouldRewriteModulePredicate {
 	return func(mdl string) bool {
 		if len(mdl) == 0 {
@@ -57,8 +65,8 @@ func CreateShouldRewriteModule(
 		// to make the logic below simpler.
 		mdl = trimPrefix(mdl, "./")
 
-		if args.Norewrite != nil {
-			for _, m := range args.Norewrite {
+		if norewrite != nil {
+			for _, m := range norewrite {
 				// The force no rewrite file follows a convention where we try
 				// and match all possible paths if the force no
 				// rewrite entry starts with "*". If it does not
@@ -76,3 +84,78 @@ func CreateShouldRewriteModule(
 		return true
 	}
 }
+
+func CreateProtectedGlobals(args *SnapCmdArgs) (map[string]api.ProtectedGlobalKind, error) {
+	return ParseProtectedGlobals(args.Protected)
+}
+
+func ParseProtectedGlobals(kinds map[string]string) (map[string]api.ProtectedGlobalKind, error) {
+	protected := make(map[string]api.ProtectedGlobalKind, len(kinds))
+	for path, kind := range kinds {
+		switch strings.ToLower(kind) {
+		case "defer":
+			protected[path] = api.ProtectedGlobalDefer
+		case "norewrite":
+			protected[path] = api.ProtectedGlobalNoRewrite
+		case "ignore":
+			protected[path] = api.ProtectedGlobalIgnore
+		default:
+			return nil, fmt.Errorf("Invalid kind %q for protected global %q, expected \"defer\", \"norewrite\" or \"ignore\"", kind, path)
+		}
+	}
+	return protected, nil
+}
+
+func CreateShouldReplaceRequireFromDeferred(deferred []string) api.ShouldReplaceRequirePredicate {
+	return func(mdl string) bool {
+		for _, m := range deferred {
+			if m == mdl {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type Target struct {
+	// nil when the default target should be used
+	Target  *api.Target
+	Engines []api.Engine
+	// nil when the default platform should be used
+	Platform *api.Platform
+}
+
+// Parses the "target" and "platform" config entries the same way the esbuild CLI parses them
+// and validates the result with the checks the build applies.
+func CreateTarget(args *SnapCmdArgs) (Target, error) {
+	target := Target{}
+	if len(args.Target) == 0 && args.Platform == "" {
+		return target, nil
+	}
+
+	var cliArgs []string
+	if len(args.Target) > 0 {
+		cliArgs = append(cliArgs, "--target="+strings.Join(args.Target, ","))
+	}
+	if args.Platform != "" {
+		cliArgs = append(cliArgs, "--platform="+args.Platform)
+	}
+	options, err := cli.ParseBuildOptions(cliArgs)
+	if err != nil {
+		return target, err
+	}
+
+	if len(args.Target) > 0 {
+		// An explicit target replaces the default engines even if it doesn't include any
+		target.Target = &options.Target
+		target.Engines = append([]api.Engine{}, options.Engines...)
+	}
+	if args.Platform != "" {
+		target.Platform = &options.Platform
+	}
+
+	if errors := api.ValidateSnapshotTarget(options.Target, options.Engines, options.Platform); len(errors) > 0 {
+		return target, fmt.Errorf("%s", errors[0].Text)
+	}
+	return target, nil
+}
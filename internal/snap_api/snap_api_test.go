@@ -1,9 +1,11 @@
 package snap_api
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/evanw/esbuild/internal/snap_printer"
+	"github.com/evanw/esbuild/pkg/api"
 )
 
 var snapApiSuite = suite{
@@ -29,7 +31,7 @@ func TestEntryRequiringLocalModule(t *testing.T) {
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
 let oneTwoThree;
 function __get_oneTwoThree__() {
-  return oneTwoThree = oneTwoThree || (require("./foo", "./foo.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)).oneTwoThree)
+  return oneTwoThree = oneTwoThree || (require("./foo", "./foo.js", __filename, __dirname).oneTwoThree)
 }
   module2.exports = function() {
     get_console().log((__get_oneTwoThree__()));
@@ -66,7 +68,7 @@ __commonJS["./debug.js"] = function(exports, module2, __filename, __dirname, req
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
 let import_debug;
 function __get_import_debug__() {
-  return import_debug = import_debug || (__toModule(require("./debug", "./debug.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname))))
+  return import_debug = import_debug || (__toModule(require("./debug", "./debug.js", __filename, __dirname)))
 }
 let debug;
 function __get_debug__() {
@@ -100,7 +102,7 @@ __commonJS["./debug.js"] = function(exports, module2, __filename, __dirname, req
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
 let Debug;
 function __get_Debug__() {
-  return Debug = Debug || (require("./debug", "./debug.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)))
+  return Debug = Debug || (require("./debug", "./debug.js", __filename, __dirname))
 }
 let debug;
 function __get_debug__() {
@@ -135,7 +137,7 @@ __commonJS["./foo.js"] = function(exports, module2, __filename, __dirname, requi
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
 let import_foo;
 function __get_import_foo__() {
-  return import_foo = import_foo || (__toModule(require("./foo", "./foo.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname))))
+  return import_foo = import_foo || (__toModule(require("./foo", "./foo.js", __filename, __dirname)))
 }
   module2.exports = function() {
     get_console().log((__get_import_foo__()).oneTwoThree);
@@ -163,7 +165,7 @@ module.exports = function () { deprecate() }
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
 let deprecate;
 function __get_deprecate__() {
-  return deprecate = deprecate || (require("./depd", "./depd.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname))("http-errors"))
+  return deprecate = deprecate || (require("./depd", "./depd.js", __filename, __dirname)("http-errors"))
 }
   module2.exports = function() {
     (__get_deprecate__())();
@@ -191,7 +193,7 @@ __commonJS["./body-parser.js"] = function(exports, module2, __filename, __dirnam
 };`,
 				ProjectBaseDir + "/entry.js": `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  require("./body-parser", "./body-parser.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  require("./body-parser", "./body-parser.js", __filename, __dirname);
 };`,
 			},
 		},
@@ -238,7 +240,7 @@ require('non-existent')
 			files: map[string]string{
 				ProjectBaseDir + `/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  require("non-existent", "non-existent", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  require("non-existent", "non-existent", __filename, __dirname);
 };`,
 			},
 		})
@@ -289,19 +291,19 @@ exports.bar = require('./bar')
 			files: map[string]string{
 				`dev/foo.js`: `
 __commonJS["./foo.js"] = function(exports, module, __filename, __dirname, require) {
-  var fs = require("fs", "fs", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  var fs = require("fs", "fs", __filename, __dirname);
 };`,
 				`dev/bar.js`: `
 __commonJS["./bar.js"] = function(exports, module2, __filename, __dirname, require) {
 let path;
 function __get_path__() {
-  return path = path || (require("path", "path", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)))
+  return path = path || (require("path", "path", __filename, __dirname))
 }
 };`,
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  Object.defineProperty(exports, "foo", { get: () => require("./foo", "./foo.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)) });
-  Object.defineProperty(exports, "bar", { get: () => require("./bar", "./bar.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)) });
+  Object.defineProperty(exports, "foo", { get: () => require("./foo", "./foo.js", __filename, __dirname) });
+  Object.defineProperty(exports, "bar", { get: () => require("./bar", "./bar.js", __filename, __dirname) });
 };`,
 			},
 		},
@@ -328,12 +330,12 @@ exports.fsevents = require('` + ProjectBaseDir + `/node_modules/fsevents/fsevent
 			files: map[string]string{
 				`dev/node_modules/fsevents/fsevents.js`: `
 __commonJS["./node_modules/fsevents/fsevents.js"] = function(exports, module, __filename, __dirname, require) {
-  var Native = require("./node_modules/fsevents/fsevents.node", "./node_modules/fsevents/fsevents.node", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  var Native = require("./node_modules/fsevents/fsevents.node", "./node_modules/fsevents/fsevents.node", __filename, __dirname);
   var events = Native.constants;
 };`,
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module, __filename, __dirname, require) {
-  exports.fsevents = require("/dev/node_modules/fsevents/fsevents.js", "./node_modules/fsevents/fsevents.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  exports.fsevents = require("/dev/node_modules/fsevents/fsevents.js", "./node_modules/fsevents/fsevents.js", __filename, __dirname);
 };`,
 			},
 		},
@@ -360,11 +362,11 @@ exports.fsevents = require('` + ProjectBaseDir + `/node_modules/fsevents/fsevent
 			files: map[string]string{
 				`dev/node_modules/fsevents/fsevents.js`: `
 __commonJS["./node_modules/fsevents/fsevents.js"] = function(exports, module2, __filename, __dirname, require) {
-  module2.exports = __resolve_path(typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname);
+  module2.exports = __resolve_path(__dirname);
 };`,
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  exports.fsevents = require("/dev/node_modules/fsevents/fsevents.js", "./node_modules/fsevents/fsevents.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  exports.fsevents = require("/dev/node_modules/fsevents/fsevents.js", "./node_modules/fsevents/fsevents.js", __filename, __dirname);
 };`,
 			},
 		},
@@ -391,12 +393,44 @@ exports.fileUrl = require('` + ProjectBaseDir + `/node_modules/file-url.js')
 				`dev/node_modules/file-url.js`: `
 __commonJS["./node_modules/file-url.js"] = function(exports, module2, __filename, __dirname, require) {
   module2.exports = function foo() {
-    return "file://" + __resolve_path(typeof __filename2 !== 'undefined' ? __filename2 : __filename);
+    return "file://" + __resolve_path(__filename);
   };
 };`,
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  exports.fileUrl = require("/dev/node_modules/file-url.js", "./node_modules/file-url.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  exports.fileUrl = require("/dev/node_modules/file-url.js", "./node_modules/file-url.js", __filename, __dirname);
+};`,
+			},
+		},
+	)
+}
+
+func TestInlinesRelativePathsForDirnameAndFilename(t *testing.T) {
+	snapApiSuite.expectBuild(t, built{
+		shouldReplaceRequire: snap_printer.ReplaceNone,
+		resolvePathFn:        "__snapshot_path",
+		inlineRelativePaths:  true,
+		files: map[string]string{
+			ProjectBaseDir + "/node_modules/file-url/index.js": `
+module.exports = { dir: __dirname, file: __filename }
+`,
+			ProjectBaseDir + "/entry.js": `
+exports.fileUrl = require('` + ProjectBaseDir + `/node_modules/file-url/index.js')
+exports.dir = __dirname
+`,
+		},
+		entryPoints: []string{ProjectBaseDir + "/entry.js"},
+	},
+		buildResult{
+			files: map[string]string{
+				`dev/node_modules/file-url/index.js`: `
+__commonJS["./node_modules/file-url/index.js"] = function(exports, module2, __filename, __dirname, require) {
+  module2.exports = {dir: __snapshot_path("./node_modules/file-url"), file: __snapshot_path("./node_modules/file-url/index.js")};
+};`,
+				`dev/entry.js`: `
+__commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
+  exports.fileUrl = require("/dev/node_modules/file-url/index.js", "./node_modules/file-url/index.js", __filename, __dirname);
+  exports.dir = __snapshot_path(".");
 };`,
 			},
 		},
@@ -434,8 +468,8 @@ __commonJS["./reassigns-console.js"] = function(exports, module2, __filename, __
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
   module2.exports = function() {
-    require("./fine", "./fine.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
-    require("./reassigns-console", "./reassigns-console.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+    require("./fine", "./fine.js", __filename, __dirname);
+    require("./reassigns-console", "./reassigns-console.js", __filename, __dirname);
   };
 };`,
 			},
@@ -479,13 +513,13 @@ require.resolve(toBeResolved('./'))
 			files: map[string]string{
 				`dev/entry.js`: `
 __commonJS["./entry.js"] = function(exports, module2, __filename, __dirname, require) {
-  var fooPath = require.resolve("./foo", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
-  require.resolve("./foo", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
-  delete require.cache[require.resolve("./fixtures/sync-deps.js", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname))];
+  var fooPath = require.resolve("./foo", __filename, __dirname);
+  require.resolve("./foo", __filename, __dirname);
+  delete require.cache[require.resolve("./fixtures/sync-deps.js", __filename, __dirname)];
   function toBeResolved(prefix) {
     return prefix + "foo";
   }
-  require.resolve(toBeResolved("./"), (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname));
+  require.resolve(toBeResolved("./"), __filename, __dirname);
 };`,
 			},
 		},
@@ -604,3 +638,71 @@ func TestCreateShouldRewriteModule(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     *SnapCmdArgs
+		target   *api.Target
+		engines  []api.Engine
+		platform *api.Platform
+		err      string
+	}{
+		{
+			name: "defaults",
+			args: &SnapCmdArgs{},
+		},
+		{
+			name:    "es version and engine",
+			args:    &SnapCmdArgs{Target: []string{"es2019", "node14"}},
+			target:  targetPtr(api.ES2019),
+			engines: []api.Engine{{Name: api.EngineNode, Version: "14"}},
+		},
+		{
+			name:     "browser platform",
+			args:     &SnapCmdArgs{Target: []string{"chrome91"}, Platform: "browser"},
+			target:   targetPtr(api.ESNext),
+			engines:  []api.Engine{{Name: api.EngineChrome, Version: "91"}},
+			platform: platformPtr(api.PlatformBrowser),
+		},
+		{
+			name: "invalid platform",
+			args: &SnapCmdArgs{Platform: "deno"},
+			err:  `Invalid platform: "deno" (valid: browser, node, neutral)`,
+		},
+		{
+			name: "invalid target",
+			args: &SnapCmdArgs{Target: []string{"es1999"}},
+			err:  `Invalid target: "es1999" (valid: esN, chromeN, edgeN, firefoxN, iosN, nodeN, safariN)`,
+		},
+		{
+			name: "invalid engine version",
+			args: &SnapCmdArgs{Target: []string{"node12.x"}},
+			err:  `Invalid version: "12.x"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := CreateTarget(tt.args)
+			if tt.err != "" || err != nil {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("CreateTarget() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(target.Target, tt.target) {
+				t.Errorf("CreateTarget() target = %v, want %v", target.Target, tt.target)
+			}
+			if len(target.Engines) != len(tt.engines) || (len(tt.engines) > 0 && !reflect.DeepEqual(target.Engines, tt.engines)) {
+				t.Errorf("CreateTarget() engines = %v, want %v", target.Engines, tt.engines)
+			}
+			if !reflect.DeepEqual(target.Platform, tt.platform) {
+				t.Errorf("CreateTarget() platform = %v, want %v", target.Platform, tt.platform)
+			}
+		})
+	}
+}
+
+func targetPtr(target api.Target) *api.Target         { return &target }
+func platformPtr(platform api.Platform) *api.Platform { return &platform }
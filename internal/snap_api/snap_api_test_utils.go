@@ -18,6 +18,8 @@ type built struct {
 	entryPoints          []string
 	shouldReplaceRequire api.ShouldReplaceRequirePredicate
 	shouldRewriteModule  api.ShouldRewriteModulePredicate
+	resolvePathFn        string
+	inlineRelativePaths  bool
 }
 
 type buildResult struct {
@@ -140,6 +142,8 @@ func (s *suite) build(args built) buildResult {
 			ShouldRewriteModule:  args.shouldRewriteModule,
 			AbsBasedir:           ProjectBaseDir,
 			Doctor:               true,
+			ResolvePathFn:        args.resolvePathFn,
+			InlineRelativePaths:  args.inlineRelativePaths,
 		},
 		FS: fs,
 	})
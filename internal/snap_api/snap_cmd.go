@@ -19,15 +19,36 @@ Usage:
 
 Config is a JSON file with the following properties:
 
-  entryfile  (string)    The snapshot entry file
-  outfile    (string)    The snapshot bundle output file
-  basedir    (string)    The full path project root relative to which modules are resolved 
-  deferred   (string[])  List of relative paths to defer
-  norewrite  (string[])  List of relative paths to files we should not rewrite
-                         which are also automatically deferred
-  metafile   (bool)      When true metadata about the build is written to a JSON file
-  doctor     (bool)      When true stricter validations are performed to detect problematic code
-  sourcemap  (string)    When provided sourcemaps will be generated and output to that file 
+  entryfile            (string)    The snapshot entry file
+  outfile              (string)    The snapshot bundle output file
+  basedir              (string)    The full path project root relative to which modules are resolved 
+  deferred             (string[])  List of relative paths to defer
+  norewrite            (string[])  List of relative paths to files we should not rewrite
+                                   which are also automatically deferred
+  metafile             (bool)      When true metadata about the build is written to a JSON file
+  doctor               (bool)      When true stricter validations are performed to detect problematic code
+  protected            (object)    Maps globals the doctor should protect from mutation, i.e. "Error.prepareStackTrace",
+                                   to "defer", "norewrite" or "ignore" in order to add, reclassify or remove entries
+  resolvePathFn        (string)    Function invoked at runtime to resolve __dirname and __filename,
+                                   defaults to "__resolve_path"
+  inlineRelativePaths  (bool)      When true paths relative to the basedir are passed to resolvePathFn
+                                   instead of __dirname and __filename
+  sourcemap            (string)    When provided sourcemaps will be generated and output to that file 
+  cacheDir             (string)    Directory where parse results are stored to speed up later snapshots
+  cacheMaxSize         (number)    Size limit of the cache directory in bytes, defaults to 512mb
+  chromeTraceFile      (string)    When provided the time spent in each phase of the build is written to
+                                   that file as Chrome trace events
+  target               (string[])  Environments the snapshot is created for, i.e. ["es2020", "node12.4"] which
+                                   is the default, or ["chrome91"]
+  platform             (string)    "node" (default), "browser" or "neutral", use "browser" for renderer snapshots
+  mainFields           (string[])  The "package.json" fields tried when resolving a package
+  conditions           (string[])  Additional conditions for the "exports" field in "package.json"
+  define               (object)    Maps global identifiers to the constant expressions replacing them
+  inject               (string[])  Files whose exports replace the global variables of the same name
+  external             (string[])  Modules excluded from the bundle in addition to "electron" and "bluebird"
+  packageEntryRedirects (object[]) Replace the entry point of packages that pick their implementation at
+                                   runtime, i.e. [{ "packageName": "safefs", "main": "es6guardian.js",
+                                   "redirect": "es5/lib/safefs.js" }], in addition to the built-in ones
 
 Examples:
   snapshot snapshot_config.json 
@@ -42,7 +63,25 @@ type SnapCmdArgs struct {
 	Deferred  []string
 	Norewrite []string
 	Doctor    bool
+	Protected map[string]string
 	Sourcemap string
+
+	CacheDir        string
+	CacheMaxSize    int64
+	ChromeTraceFile string
+
+	ResolvePathFn       string
+	InlineRelativePaths bool
+
+	Target     []string
+	Platform   string
+	MainFields []string
+	Conditions []string
+	Define     map[string]string
+	Inject     []string
+	External   []string
+
+	PackageEntryRedirects []api.PackageEntryRedirect
 }
 
 func (args *SnapCmdArgs) toString() string {
@@ -54,7 +93,21 @@ func (args *SnapCmdArgs) toString() string {
 	Norewrite:  '%s'
 	Metafile:   '%t',
 	Doctor:     '%t',
+	Protected:  '%v',
 	Sourcemap:  '%s',
+	CacheDir:   '%s',
+	CacheMaxSize: '%d',
+	ChromeTraceFile: '%s',
+	ResolvePathFn:        '%s',
+	InlineRelativePaths:  '%t',
+	Target:      '%s',
+	Platform:    '%s',
+	MainFields:  '%s',
+	Conditions:  '%s',
+	Define:      '%v',
+	Inject:      '%s',
+	External:    '%s',
+	PackageEntryRedirects: '%v',
 }`,
 		args.Entryfile,
 		args.Outfile,
@@ -63,7 +116,21 @@ func (args *SnapCmdArgs) toString() string {
 		strings.Join(args.Norewrite, ", "),
 		args.Metafile,
 		args.Doctor,
+		args.Protected,
 		args.Sourcemap,
+		args.CacheDir,
+		args.CacheMaxSize,
+		args.ChromeTraceFile,
+		args.ResolvePathFn,
+		args.InlineRelativePaths,
+		strings.Join(args.Target, ", "),
+		args.Platform,
+		strings.Join(args.MainFields, ", "),
+		strings.Join(args.Conditions, ", "),
+		args.Define,
+		strings.Join(args.Inject, ", "),
+		strings.Join(args.External, ", "),
+		args.PackageEntryRedirects,
 	)
 }
 
@@ -133,6 +200,14 @@ func SnapCmd(processArgs ProcessCmdArgs) {
 	if cmdArgs.Deferred == nil {
 		cmdArgs.Deferred = []string{}
 	}
+	if _, err := CreateProtectedGlobals(&cmdArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n%s\n", err.Error(), helpText)
+		os.Exit(1)
+	}
+	if _, err := CreateTarget(&cmdArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n%s\n", err.Error(), helpText)
+		os.Exit(1)
+	}
 
 	result := processArgs(&cmdArgs)
 	_, prettyPrint := os.LookupEnv("SNAPSHOT_PRETTY_PRINT_CONTENTS")
@@ -3,6 +3,7 @@ package snap_printer
 import (
 	"fmt"
 
+	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/snap_renamer"
 )
@@ -11,13 +12,16 @@ const SNAPSHOT_REWRITE_FAILURE = "[SNAPSHOT_REWRITE_FAILURE]"
 const SNAPSHOT_CACHE_FAILURE = "[SNAPSHOT_CACHE_FAILURE]"
 
 type SnapAstValiator struct {
-	renamer        *snap_renamer.SnapRenamer
-	validateStrict bool
+	renamer          *snap_renamer.SnapRenamer
+	importRecords    []ast.ImportRecord
+	validateStrict   bool
+	protectedGlobals ProtectedGlobals
+	requireBindings  map[js_ast.Ref]string
 }
 
-func (v *SnapAstValiator) verifySExpr(expr *js_ast.SExpr) (string, bool) {
+func (v *SnapAstValiator) verifySExpr(expr *js_ast.SExpr, isTopLevel bool) (string, ValidatioErrorKind, bool) {
 	if !v.validateStrict {
-		return "", true
+		return "", 0, true
 	}
 
 	// Detect monkey patches on `process`, i.e. `process.emitWarning = function () { ... }`
@@ -42,11 +46,11 @@ func (v *SnapAstValiator) verifySExpr(expr *js_ast.SExpr) (string, bool) {
 					// Now we look at the assigned value determine if it is a function declared inline
 					switch right := binary.Right.Data.(type) {
 					case *js_ast.EFunction, *js_ast.EArrow:
-						return fmt.Sprintf("Cannot override 'process.%s'", left.Name), false
+						return fmt.Sprintf("Cannot override 'process.%s'", left.Name), NoRewrite, false
 					case *js_ast.EIdentifier:
 						// Or if it is an identifier of a function
 						if v.renamer.IsFunctionRef(right.Ref) {
-							return fmt.Sprintf("Cannot override 'process.%s'", left.Name), false
+							return fmt.Sprintf("Cannot override 'process.%s'", left.Name), NoRewrite, false
 						}
 					}
 
@@ -54,7 +58,14 @@ func (v *SnapAstValiator) verifySExpr(expr *js_ast.SExpr) (string, bool) {
 			}
 		}
 	}
-	return "", true
+
+	// Detect mutations of shared globals, i.e. `Error.prepareStackTrace = ...` or `Array.prototype.flat = ...`
+	// Performed while the snapshot is created they would affect every module that is loaded after.
+	// Mutations inside functions only take effect once the function is invoked and thus are not considered.
+	if isTopLevel {
+		return v.verifyNoProtectedGlobalMutation(&expr.Value)
+	}
+	return "", 0, true
 }
 
 func (v *SnapAstValiator) verifyEIfBranchTarget(expr *js_ast.Expr) (string, bool) {
@@ -100,6 +100,9 @@ func (p *printer) handleEBinary(e *js_ast.EBinary) (handled bool) {
 		return false
 	}
 
+	if handled := p.handleEBinaryExportsObject(e); handled {
+		return true
+	}
 	if handled := p.handleEBinaryRequireCall(e); handled {
 		return true
 	}
@@ -37,9 +37,14 @@ func (p *printer) handleECall(ecall *js_ast.ECall) (handled bool) {
 func (p *printer) _printRequireResolve(request *js_ast.Expr) {
 	p.print("require.resolve(")
 	p.printExpr(*request, js_ast.LComma, 0)
-	// NOTE: more info about __dirname2/__dirname inside
-	// internal/snap_renamer/snap_renamer.go (functionWrapperForAbsPath)
-	p.print(", (typeof __filename2 !== 'undefined' ? __filename2 : __filename)")
-	p.print(", (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)")
+	p.printRequireContextArgs()
 	p.print(")")
 }
+
+// Passes the `__filename` and `__dirname` of the requiring module, i.e. `require("./foo", ..., __filename, __dirname)`
+func (p *printer) printRequireContextArgs() {
+	p.print(", ")
+	p.print(p.renamer.FilenameIdentifier())
+	p.print(", ")
+	p.print(p.renamer.DirnameIdentifier())
+}
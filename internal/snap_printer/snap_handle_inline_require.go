@@ -0,0 +1,282 @@
+package snap_printer
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+)
+
+//
+// Extractors
+//
+
+// Finds the first `require` call inside the expression that needs to be replaced, i.e. the
+// `require('a')` of `{ a: require('a') }` or `new (require('x').Y)()`.
+// Function and class bodies are not searched since they don't run when the module loads.
+func (p *printer) findInlineRequire(expr *js_ast.Expr) (*js_ast.Expr, bool) {
+	if expr == nil {
+		return nil, false
+	}
+
+	switch e := expr.Data.(type) {
+	case *js_ast.ERequire:
+		if _, ok := p.extractRequireExpression(*expr, 0, 0, 0); ok {
+			return expr, true
+		}
+	case *js_ast.ECall:
+		if _, ok := p.extractRequireExpression(*expr, 0, 0, 0); ok {
+			return expr, true
+		}
+		if require, ok := p.findInlineRequire(&e.Target); ok {
+			return require, true
+		}
+		return p.findInlineRequireInExprs(e.Args)
+	case *js_ast.ENew:
+		if require, ok := p.findInlineRequire(&e.Target); ok {
+			return require, true
+		}
+		return p.findInlineRequireInExprs(e.Args)
+	case *js_ast.EDot:
+		return p.findInlineRequire(&e.Target)
+	case *js_ast.EIndex:
+		if require, ok := p.findInlineRequire(&e.Target); ok {
+			return require, true
+		}
+		return p.findInlineRequire(&e.Index)
+	case *js_ast.EObject:
+		for _, property := range e.Properties {
+			if property.IsMethod {
+				continue
+			}
+			if property.IsComputed {
+				if require, ok := p.findInlineRequire(&property.Key); ok {
+					return require, true
+				}
+			}
+			if require, ok := p.findInlineRequire(property.Value); ok {
+				return require, true
+			}
+		}
+	case *js_ast.EArray:
+		return p.findInlineRequireInExprs(e.Items)
+	case *js_ast.ESpread:
+		return p.findInlineRequire(&e.Value)
+	case *js_ast.EUnary:
+		return p.findInlineRequire(&e.Value)
+	case *js_ast.EBinary:
+		if require, ok := p.findInlineRequire(&e.Left); ok {
+			return require, true
+		}
+		return p.findInlineRequire(&e.Right)
+	case *js_ast.EIf:
+		if require, ok := p.findInlineRequire(&e.Test); ok {
+			return require, true
+		}
+		if require, ok := p.findInlineRequire(&e.Yes); ok {
+			return require, true
+		}
+		return p.findInlineRequire(&e.No)
+	case *js_ast.EClass:
+		return p.findInlineRequire(e.Class.Extends)
+	}
+	return nil, false
+}
+
+func (p *printer) findInlineRequireInExprs(exprs []js_ast.Expr) (*js_ast.Expr, bool) {
+	for i := range exprs {
+		if require, ok := p.findInlineRequire(&exprs[i]); ok {
+			return require, true
+		}
+	}
+	return nil, false
+}
+
+func (p *printer) hasInlineRequire(expr *js_ast.Expr) bool {
+	_, ok := p.findInlineRequire(expr)
+	return ok
+}
+
+// Returns the argument of the require call for use in validation messages.
+func (p *printer) requireSpecifierForMsg(require *js_ast.Expr) string {
+	if specifier, ok := p.validator.requireSpecifier(require); ok {
+		return specifier
+	}
+	return "<unknown>"
+}
+
+//
+// Class declarations
+//
+
+// class Foo extends require('events') {}
+//
+// The `extends` clause is evaluated when the class is declared, so we declare it lazily instead:
+//
+// let Foo;
+// function __get_Foo__() {
+//   return Foo = Foo || (class Foo extends require("events") {
+//   })
+// }
+func (p *printer) handleSClass(s *js_ast.SClass) (handled bool) {
+	if !p.renamer.IsEnabled {
+		return false
+	}
+	if p.uninvokedFunctionDepth > 0 {
+		return false
+	}
+	if s.IsExport || s.Class.Name == nil {
+		return false
+	}
+	extends := s.Class.Extends
+	if !p.hasInlineRequire(extends) && !p.expressionHasRequireOrGlobalReference(extends) {
+		return false
+	}
+
+	ref := s.Class.Name.Ref
+	id := p.nameForSymbol(ref)
+	fnName := functionNameForId(id)
+
+	p.printNewline()
+	p.print(fmt.Sprintf("let %s;", id))
+	p.printNewline()
+	p.print(fmt.Sprintf("function %s() {", fnName))
+	p.printNewline()
+	p.print(fmt.Sprintf("  return %s = %s || (class %s", id, id, id))
+
+	// References to the class from inside its body bind to the class name of the expression and
+	// must not be replaced with calls to the getter we are declaring here.
+	currentPrinterIndex := p.renamer.CurrentPrinterIndex
+	p.renamer.CurrentPrinterIndex = nil
+	p.options.Indent++
+	p.printClass(s.Class)
+	p.options.Indent--
+	p.renamer.CurrentPrinterIndex = currentPrinterIndex
+
+	p.print(")")
+	p.printNewline()
+	p.print("}")
+	p.printNewline()
+
+	p.renamer.Replace(ref, functionCallForId(id))
+	return true
+}
+
+//
+// Exports objects
+//
+
+// module.exports = { a: require('a'), b: 1 }
+//
+// Properties that require modules are turned into getters. Their setters replace the getter with
+// the assigned value so that the exports can still be patched, i.e. `module.exports.a = mock`:
+//
+// module.exports = {
+//   get a() {
+//     return require("a");
+//   },
+//   set a(value) {
+//     Object.defineProperty(this, "a", { value, writable: true, enumerable: true, configurable: true });
+//   },
+//   b: 1
+// };
+func (p *printer) handleEBinaryExportsObject(e *js_ast.EBinary) (handled bool) {
+	if !p.assignsToExports(e) {
+		return false
+	}
+	object, ok := e.Right.Data.(*js_ast.EObject)
+	if !ok || !p.hasInlineRequire(&e.Right) {
+		return false
+	}
+
+	p.printExpr(e.Left, js_ast.LAssign, 0)
+	p.printSpace()
+	p.print("=")
+	p.printSpace()
+	p.print("{")
+	p.options.Indent++
+	for i, property := range object.Properties {
+		if i != 0 {
+			p.print(",")
+		}
+		p.printNewline()
+		p.printIndent()
+		if property.Kind != js_ast.PropertyNormal || property.IsMethod || property.IsComputed ||
+			!p.hasInlineRequire(property.Value) {
+			p.printProperty(property)
+			continue
+		}
+
+		value := *property.Value
+		p.printProperty(js_ast.Property{
+			Key:      property.Key,
+			Kind:     js_ast.PropertyGet,
+			IsMethod: true,
+			Value: &js_ast.Expr{Loc: value.Loc, Data: &js_ast.EFunction{Fn: js_ast.Fn{
+				Body: js_ast.FnBody{Loc: value.Loc, Stmts: []js_ast.Stmt{
+					{Loc: value.Loc, Data: &js_ast.SReturn{Value: &value}},
+				}},
+			}}},
+		})
+		p.print(",")
+		p.printNewline()
+		p.printIndent()
+		p.print("set ")
+		p.printProperty(js_ast.Property{Key: property.Key})
+		p.print("(value) {")
+		p.printNewline()
+		p.options.Indent++
+		p.printIndent()
+		p.print("Object.defineProperty(this, ")
+		p.printExpr(property.Key, js_ast.LComma, 0)
+		p.print(", { value, writable: true, enumerable: true, configurable: true });")
+		p.printNewline()
+		p.options.Indent--
+		p.printIndent()
+		p.print("}")
+	}
+	p.options.Indent--
+	p.printNewline()
+	p.printIndent()
+	p.print("}")
+	return true
+}
+
+//
+// Validation
+//
+
+// Requires that are passed to functions or spread into objects at the module level, i.e.
+// `Object.assign(exports, require('b'))`, load the module immediately and we cannot know which
+// properties of it are used. Thus the module containing the statement needs to be deferred.
+func (p *printer) verifyNoEagerInlineRequire(s *js_ast.SExpr) (string, bool) {
+	if !p.validator.validateStrict || !p.renamer.IsEnabled || p.uninvokedFunctionDepth > 0 {
+		return "", true
+	}
+
+	switch e := s.Value.Data.(type) {
+	case *js_ast.ECall:
+		if require, ok := p.findInlineRequireInExprs(e.Args); ok {
+			return fmt.Sprintf("Cannot defer require('%s') passed as an argument", p.requireSpecifierForMsg(require)), false
+		}
+	case *js_ast.ENew:
+		if require, ok := p.findInlineRequireInExprs(e.Args); ok {
+			return fmt.Sprintf("Cannot defer require('%s') passed as an argument", p.requireSpecifierForMsg(require)), false
+		}
+	case *js_ast.EBinary:
+		if !p.assignsToExports(e) {
+			break
+		}
+		object, ok := e.Right.Data.(*js_ast.EObject)
+		if !ok {
+			break
+		}
+		for _, property := range object.Properties {
+			if property.Kind == js_ast.PropertySpread {
+				if require, ok := p.findInlineRequire(property.Value); ok {
+					return fmt.Sprintf("Cannot defer require('%s') spread into the exports", p.requireSpecifierForMsg(require)), false
+				}
+			}
+		}
+	}
+	return "", true
+}
@@ -57,7 +57,7 @@ func (p *printer) extractRequireDeclaration(decl js_ast.Decl) (RequireDecl, bool
 }
 
 func (p *printer) extractRequireReferenceDeclaration(decl js_ast.Decl) (RequireReference, bool) {
-	if !p.expressionHasRequireOrGlobalReference(decl.Value) {
+	if !p.expressionHasRequireOrGlobalReference(decl.Value) && !p.hasInlineRequire(decl.Value) {
 		return RequireReference{}, false
 	}
 
@@ -28,6 +28,7 @@ type PrintOptions = js_printer.Options
 type SourceMapChunk = js_printer.SourceMapChunk
 type PrintResult = js_printer.PrintResult
 type ValidationError = js_printer.ValidationError
+type ValidatioErrorKind = js_printer.ValidatioErrorKind
 
 var Defer = js_printer.Defer
 var NoRewrite = js_printer.NoRewrite
@@ -281,6 +282,43 @@ func (p *printer) printQuotedUTF8(text string, allowBacktick bool) {
 	p.print(c)
 }
 
+func (p *printer) printImportRecordPath(record *ast.ImportRecord) {
+	p.printQuotedUTF8(record.Path.Text, false /* allowBacktick */)
+
+	// Import assertions are stripped if the target doesn't support them
+	if record.Assertions != nil && !p.options.UnsupportedFeatures.Has(compat.ImportAssertions) {
+		p.printSpace()
+		p.print("assert")
+		p.printSpace()
+		p.print("{")
+		for i, entry := range *record.Assertions {
+			if i > 0 {
+				p.print(",")
+			}
+			p.printSpace()
+			if !entry.PreferQuotedKey && p.canPrintIdentifierUTF16(entry.Key) {
+				p.printSpaceBeforeIdentifier()
+				p.printIdentifierUTF16(entry.Key)
+			} else {
+				c := p.bestQuoteCharForString(entry.Key, false /* allowBacktick */)
+				p.print(c)
+				p.printQuotedUTF16(entry.Key, rune(c[0]))
+				p.print(c)
+			}
+			p.print(":")
+			p.printSpace()
+			c := p.bestQuoteCharForString(entry.Value, false /* allowBacktick */)
+			p.print(c)
+			p.printQuotedUTF16(entry.Value, rune(c[0]))
+			p.print(c)
+		}
+		if len(*record.Assertions) > 0 {
+			p.printSpace()
+		}
+		p.print("}")
+	}
+}
+
 func (p *printer) addSourceMapping(loc logger.Loc) {
 	if !p.options.AddSourceMappings || loc == p.prevLoc || p.lastGeneratedUpdate == len(p.js) || p.options.Indent == 0 {
 		return
@@ -721,6 +759,15 @@ func (p *printer) printClass(class js_ast.Class) {
 	for _, item := range class.Properties {
 		p.printSemicolonIfNeeded()
 		p.printIndent()
+
+		if item.Kind == js_ast.PropertyClassStaticBlock {
+			p.print("static")
+			p.printSpace()
+			p.printBlock(item.ClassStaticBlock.Loc, item.ClassStaticBlock.Stmts)
+			p.printNewline()
+			continue
+		}
+
 		p.printProperty(item)
 
 		// Need semicolons after class fields
@@ -953,8 +1000,7 @@ func (p *printer) printRequireOrImportExpr(importRecordIndex uint32, leadingInte
 			p.printQuotedUTF8(record.Path.Text, true)
 			p.print(", ")
 			p.printQuotedUTF8(p.resolveRequireName(record), true /* allowBacktick */)
-			p.print(", (typeof __filename2 !== 'undefined' ? __filename2 : __filename)")
-			p.print(", (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)")
+			p.printRequireContextArgs()
 			p.print(")")
 			return
 		}
@@ -993,8 +1039,7 @@ func (p *printer) printRequireOrImportExpr(importRecordIndex uint32, leadingInte
 		p.printQuotedUTF8(record.Path.Text, true /* allowBacktick */)
 		p.print(", ")
 		p.printQuotedUTF8(p.resolveRequireName(record), true /* allowBacktick */)
-		p.print(", (typeof __filename2 !== 'undefined' ? __filename2 : __filename)")
-		p.print(", (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)")
+		p.printRequireContextArgs()
 		if len(leadingInteriorComments) > 0 {
 			p.printNewline()
 			p.options.Indent--
@@ -1255,8 +1300,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 		p.printSpaceBeforeIdentifier()
 		p.print("require.resolve(")
 		p.printQuotedUTF8(p.importRecords[e.ImportRecordIndex].Path.Text, true /* allowBacktick */)
-		p.print(", (typeof __filename2 !== 'undefined' ? __filename2 : __filename)")
-		p.print(", (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)")
+		p.printRequireContextArgs()
 		p.print(")")
 		if wrap {
 			p.print(")")
@@ -1288,6 +1332,11 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 					p.printIndent()
 				}
 				p.printExpr(e.Expr, js_ast.LComma, 0)
+				if e.OptionsOrNil.Data != nil {
+					p.print(",")
+					p.printSpace()
+					p.printExpr(e.OptionsOrNil, js_ast.LComma, 0)
+				}
 				if len(leadingInteriorComments) > 0 {
 					p.printNewline()
 					p.options.Indent--
@@ -1674,6 +1723,10 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags int) {
 			}
 		}
 
+	case *js_ast.EPrivateIdentifier:
+		// This is only valid before the "in" operator: "#x in obj"
+		p.printSymbol(e.Ref)
+
 	case *js_ast.EIdentifier:
 		name := p.renamer.NameForSymbol(e.Ref)
 		wrap := len(p.js) == p.forOfInitStart && name == "let"
@@ -2301,6 +2354,9 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		p.printNewline()
 
 	case *js_ast.SClass:
+		if handled := p.handleSClass(s); handled {
+			return
+		}
 		p.printIndent()
 		p.printSpaceBeforeIdentifier()
 		if s.IsExport {
@@ -2378,7 +2434,7 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		}
 		p.print("from")
 		p.printSpace()
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SExportClause:
@@ -2461,10 +2517,13 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		p.printSpace()
 		p.print("from")
 		p.printSpace()
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SLocal:
+		if p.uninvokedFunctionDepth <= 0 {
+			p.validator.trackRequireBindings(s)
+		}
 		if handled := p.handleSLocal(s); handled {
 			return
 		}
@@ -2738,7 +2797,7 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 			p.printSpace()
 		}
 
-		p.printQuotedUTF8(p.importRecords[s.ImportRecordIndex].Path.Text, false /* allowBacktick */)
+		p.printImportRecordPath(&p.importRecords[s.ImportRecordIndex])
 		p.printSemicolonAfterStatement()
 
 	case *js_ast.SBlock:
@@ -2803,9 +2862,12 @@ func (p *printer) printStmt(stmt js_ast.Stmt) {
 		p.printIndent()
 		p.stmtStart = len(p.js)
 
-		msg, ok := p.validator.verifySExpr(s)
+		msg, kind, ok := p.validator.verifySExpr(s, p.uninvokedFunctionDepth <= 0)
 		if !ok {
-			p.validationErrors = append(p.validationErrors, ValidationError{Kind: NoRewrite, Msg: msg, Idx: p.stmtStart})
+			p.validationErrors = append(p.validationErrors, ValidationError{Kind: kind, Msg: msg, Idx: p.stmtStart})
+		}
+		if msg, ok := p.verifyNoEagerInlineRequire(s); !ok {
+			p.validationErrors = append(p.validationErrors, ValidationError{Kind: Defer, Msg: msg, Idx: p.stmtStart})
 		}
 
 		p.printExpr(s.Value, js_ast.LLowest, exprResultIsUnused)
@@ -2835,6 +2897,7 @@ func Print(
 	r renamer.Renamer,
 	options PrintOptions,
 	validateStrict bool,
+	protectedGlobals ProtectedGlobals,
 	isWrapped bool,
 	shouldReplaceRequire func(string) bool,
 ) PrintResult {
@@ -2850,9 +2913,15 @@ func Print(
 			uninvokedFunctionDepth = -1
 		}
 
+		if protectedGlobals == nil {
+			protectedGlobals = DefaultProtectedGlobals
+		}
 		validator := SnapAstValiator{
-			renamer:        snapRenamer,
-			validateStrict: validateStrict,
+			renamer:          snapRenamer,
+			importRecords:    tree.ImportRecords,
+			validateStrict:   validateStrict,
+			protectedGlobals: protectedGlobals,
+			requireBindings:  make(map[js_ast.Ref]string),
 		}
 
 		p = &printer{
@@ -1,6 +1,12 @@
 package snap_printer
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/internal/compat"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/renamer"
+)
 
 // Note that formatting on some of the generated code isn't perfect. This is known: https://github.com/cypress-io/esbuild/issues/11
 
@@ -603,6 +609,149 @@ function __get_chain__() {
 `, ReplaceAll)
 }
 
+func TestRequireWithCallchainInsideNew(t *testing.T) {
+	expectPrinted(t, `
+ var emitter = new (require('events').EventEmitter)()
+`, `
+let emitter;
+function __get_emitter__() {
+  return emitter = emitter || (new (require("events")).EventEmitter())
+}
+`, ReplaceAll)
+}
+
+func TestRequireInsideObjectLiteralDeclaration(t *testing.T) {
+	expectPrinted(t, `
+ var deps = { a: require('a'), b: 1 }
+`, `
+let deps;
+function __get_deps__() {
+  return deps = deps || ({a: require("a"), b: 1})
+}
+`, ReplaceAll)
+}
+
+func TestClassExtendingRequire(t *testing.T) {
+	expectPrinted(t, `
+class Foo extends require('events') {
+  static create() { return new Foo() }
+}
+module.exports = Foo
+`, `
+let Foo;
+function __get_Foo__() {
+  return Foo = Foo || (class Foo extends require("events") {
+    static create() {
+      return new Foo();
+    }
+  })
+}
+module.exports = (__get_Foo__());
+`, ReplaceAll)
+
+	expectPrinted(t, `
+const { EventEmitter } = require('events')
+function create() { return new Foo() }
+class Foo extends EventEmitter {}
+`, `
+let EventEmitter;
+function __get_EventEmitter__() {
+  return EventEmitter = EventEmitter || (require("events").EventEmitter)
+}
+function create() {
+  return new (__get_Foo__())();
+}
+
+let Foo;
+function __get_Foo__() {
+  return Foo = Foo || (class Foo extends (__get_EventEmitter__()) {
+  })
+}
+`, ReplaceAll)
+}
+
+func TestClassExtendingRequireNotReplaced(t *testing.T) {
+	expectPrinted(t, `
+class Foo extends require('events') {}
+`, `
+class Foo extends require("events") {
+}
+`, ReplaceNone)
+}
+
+func TestClassStaticBlock(t *testing.T) {
+	expectPrinted(t, `
+class Foo {
+  #x;
+  static { this.events = require('events') }
+  static is(y) { return #x in y }
+}
+`, `
+class Foo {
+  #x;
+  static {
+    this.events = require("events");
+  }
+  static is(y) {
+    return #x in y;
+  }
+}
+`, ReplaceAll)
+}
+
+func TestModuleExportsObjectWithRequires(t *testing.T) {
+	expectPrinted(t, `
+module.exports = { a: require('a'), b: 1, c: require('c').c }
+`, `
+module.exports = {
+  get a() {
+    return require("a");
+  },
+  set a(value) {
+    Object.defineProperty(this, "a", { value, writable: true, enumerable: true, configurable: true });
+  },
+  b: 1,
+  get c() {
+    return require("c").c;
+  },
+  set c(value) {
+    Object.defineProperty(this, "c", { value, writable: true, enumerable: true, configurable: true });
+  }
+};
+`, ReplaceAll)
+	expectPrinted(t, `
+module.exports = { 'a-b': require('a') }
+`, `
+module.exports = {
+  get "a-b"() {
+    return require("a");
+  },
+  set "a-b"(value) {
+    Object.defineProperty(this, "a-b", { value, writable: true, enumerable: true, configurable: true });
+  }
+};
+`, ReplaceAll)
+}
+
+func TestModuleExportsObjectWithRequiresReassigned(t *testing.T) {
+	// Assigning to the exports later replaces the getter via its setter
+	expectPrinted(t, `
+module.exports = { a: require('a') }
+module.exports.a = function patched() {}
+`, `
+module.exports = {
+  get a() {
+    return require("a");
+  },
+  set a(value) {
+    Object.defineProperty(this, "a", { value, writable: true, enumerable: true, configurable: true });
+  }
+};
+module.exports.a = function patched() {
+};
+`, ReplaceAll)
+}
+
 func TestDeclarationReferencingGlobal(t *testing.T) {
 	expectPrinted(t, `
 const { relative } = require('path')
@@ -1136,6 +1285,114 @@ process.cwd = () => {}
 	})
 }
 
+func TestInvalidateProtectedGlobalMutations(t *testing.T) {
+	expectValidationErrors(t, `
+Error.prepareStackTrace = function (err, stack) { return stack }
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'Error.prepareStackTrace'"},
+	})
+	expectValidationErrors(t, `
+Array.prototype.flat = function flat() {}
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'Array.prototype.flat'"},
+	})
+	expectValidationErrors(t, `
+Object.prototype['toJSON'] = null
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'Object.prototype.toJSON'"},
+	})
+	expectValidationErrors(t, `
+global.fetch = require('node-fetch')
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'global.fetch'"},
+	})
+	expectValidationErrors(t, `
+Object.defineProperty(Error, 'prepareStackTrace', { value: prepare })
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'Error.prepareStackTrace' via 'Object.defineProperty'"},
+	})
+}
+
+func TestInvalidateModuleLoaderMutations(t *testing.T) {
+	expectValidationErrors(t, `
+require.extensions['.ts'] = compile
+  `, []ValidationError{
+		{Kind: NoRewrite, Msg: "Cannot override 'require.extensions['.ts']'"},
+	})
+	expectValidationErrors(t, `
+delete require.extensions[ext]
+  `, []ValidationError{
+		{Kind: NoRewrite, Msg: "Cannot delete 'require.extensions[...]'"},
+	})
+	expectValidationErrors(t, `
+var Module = require('module')
+const originalLoad = Module._load
+Module._load = function (request) { return originalLoad.apply(this, arguments) }
+  `, []ValidationError{
+		{Kind: NoRewrite, Msg: "Cannot override 'Module._load'"},
+	})
+	expectValidationErrors(t, `
+require('module').prototype.require = function () {}
+  `, []ValidationError{
+		{Kind: NoRewrite, Msg: "Cannot override 'require('module').prototype.require'"},
+	})
+}
+
+func TestValidateProtectedGlobalMutationsAllowed(t *testing.T) {
+	// Reading protected globals or mutating locals of the same name is fine
+	expectValidationErrors(t, `
+const prepare = Error.prepareStackTrace
+const Error = {}
+Error.prepareStackTrace = prepare
+  `, []ValidationError{})
+	// Mutations inside functions are only performed when the function is invoked
+	expectValidationErrors(t, `
+function install() {
+  Error.prepareStackTrace = function () {}
+}
+  `, []ValidationError{})
+}
+
+func TestValidateIgnoredProtectedGlobals(t *testing.T) {
+	protected := CopyDefaultProtectedGlobals()
+	protected["process.env"] = Defer
+	protected["process.env.DEBUG"] = Ignore
+	protected["Object.prototype"] = Ignore
+
+	// Ignoring a path below a protected one only unprotects that path
+	expectValidationErrorsWithProtectedGlobals(t, `
+process.env.DEBUG = 'app:*'
+  `, protected, []ValidationError{})
+	expectValidationErrorsWithProtectedGlobals(t, `
+process.env.NODE_ENV = 'production'
+  `, protected, []ValidationError{
+		{Kind: Defer, Msg: "Cannot override 'process.env.NODE_ENV'"},
+	})
+	// Ignoring a default entry removes it
+	expectValidationErrorsWithProtectedGlobals(t, `
+Object.prototype.toJSON = null
+  `, protected, []ValidationError{})
+}
+
+func TestInvalidateEagerInlineRequires(t *testing.T) {
+	expectValidationErrors(t, `
+Object.assign(exports, require('b'))
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot defer require('b') passed as an argument"},
+	})
+	expectValidationErrors(t, `
+module.exports = { a: 1, ...require('b') }
+  `, []ValidationError{
+		{Kind: Defer, Msg: "Cannot defer require('b') spread into the exports"},
+	})
+	// Inside functions requires only run when the function is invoked
+	expectValidationErrors(t, `
+function init() {
+  Object.assign(exports, require('b'))
+}
+  `, []ValidationError{})
+}
+
 func TestInvalidateBufferPropertyProbingScriptLevel(t *testing.T) {
 	expectPrinted(t, `
 var nativeIsBuffer = Buffer ? Buffer.isBuffer : undefined
@@ -1308,6 +1565,40 @@ func TestDynamicImport(t *testing.T) {
 	expectPrinted(t, `
 let first = await import('./base')
 `, `
-let first = await Promise.resolve().then(() => require("./base", "./base", (typeof __filename2 !== 'undefined' ? __filename2 : __filename), (typeof __dirname2 !== 'undefined' ? __dirname2 : __dirname)));
+let first = await Promise.resolve().then(() => require("./base", "./base", __filename, __dirname));
 `, ReplaceAll)
 }
+
+func TestRenamedDirname(t *testing.T) {
+	// A local `__dirname` binding claims the name first so the one of the module is renamed to
+	// `__dirname2` by the linker, which is what the wrapper function then receives.
+	renameDirname := func(tree js_ast.AST, symbols js_ast.SymbolMap) renamer.Renamer {
+		r := renamer.NewNumberRenamer(symbols, make(map[string]uint32))
+		local := tree.ModuleScope.Children[0].Members["__dirname"]
+		r.AddTopLevelSymbol(local.Ref)
+		r.AddTopLevelSymbol(tree.DirnameRef)
+		r.AddTopLevelSymbol(tree.FilenameRef)
+		return r
+	}
+	contents := `
+function local(__dirname) { return __dirname }
+const dir = __dirname
+let first = await import('./base')
+`
+	expectPrintedCommon(t, contents, contents, `
+function local(__dirname) {
+  return __dirname;
+}
+const dir = __resolve_path(__dirname2);
+let first = await Promise.resolve().then(() => require("./base", "./base", __filename, __dirname2));
+`,
+		PrintOptions{UnsupportedFeatures: compat.DynamicImport},
+		testOpts{
+			shouldReplaceRequire: ReplaceAll,
+			shouldRewrite:        true,
+			validateStrict:       true,
+			createSnapshot:       true,
+			wrappedRenamer:       renameDirname,
+		},
+	)
+}
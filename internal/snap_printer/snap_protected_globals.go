@@ -0,0 +1,249 @@
+package snap_printer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+)
+
+// Maps the path of a shared global, i.e. `Error.prepareStackTrace` or `Object.prototype`, to the kind
+// of validation error that is reported when a module mutates it at the top level.
+// A path protects itself and everything below it, thus `Object.prototype` also covers
+// `Object.prototype.foo`.
+// Paths are rooted either at a global identifier or at a required module, i.e. `require('module')._load`.
+// Mapping a path to `Ignore` unprotects it even when one of its parents is protected.
+type ProtectedGlobals map[string]ValidatioErrorKind
+
+// Validation error kinds are flags, thus the zero value is free to mark ignored paths.
+const Ignore ValidatioErrorKind = 0
+
+// Builtins and prototypes which libraries commonly patch while they initialize.
+// Mutating them while the snapshot is created affects every module that is loaded afterwards.
+//
+// Patches of the module loader conflict with our rewritten requires and thus prevent a rewrite,
+// all other mutations cause the module to be deferred so that it runs after the snapshot was loaded.
+var DefaultProtectedGlobals = ProtectedGlobals{
+	"Error.prepareStackTrace":            Defer,
+	"Error.stackTraceLimit":              Defer,
+	"Object.prototype":                   Defer,
+	"Array.prototype":                    Defer,
+	"Function.prototype":                 Defer,
+	"String.prototype":                   Defer,
+	"Number.prototype":                   Defer,
+	"RegExp.prototype":                   Defer,
+	"Promise":                            Defer,
+	"global.fetch":                       Defer,
+	"globalThis.fetch":                   Defer,
+	"window.fetch":                       Defer,
+	"require.extensions":                 NoRewrite,
+	"require('module')._load":            NoRewrite,
+	"require('module')._resolveFilename": NoRewrite,
+	"require('module')._extensions":      NoRewrite,
+	"require('module').prototype":        NoRewrite,
+}
+
+// Returns a copy of the default protected globals which callers may modify.
+func CopyDefaultProtectedGlobals() ProtectedGlobals {
+	protected := make(ProtectedGlobals, len(DefaultProtectedGlobals))
+	for path, kind := range DefaultProtectedGlobals {
+		protected[path] = kind
+	}
+	return protected
+}
+
+func requirePath(specifier string) string {
+	return fmt.Sprintf("require('%s')", specifier)
+}
+
+// Finds the protected path that covers the given path, i.e. `Object.prototype` for
+// `Object.prototype.foo.bar`. The closest entry wins, starting with the path itself, so that
+// ignoring `Object.prototype.foo` is honored even though `Object.prototype` is protected.
+func (protected ProtectedGlobals) lookup(path string) (string, ValidatioErrorKind, bool) {
+	for {
+		if kind, ok := protected[path]; ok {
+			if kind == Ignore {
+				return "", 0, false
+			}
+			return path, kind, true
+		}
+		idx := strings.LastIndex(path, ".")
+		if idx < 0 {
+			return "", 0, false
+		}
+		path = path[:idx]
+	}
+}
+
+//
+// Path resolution
+//
+
+// A path to a mutated target. The `canonical` path is matched against the protected globals
+// while the `display` path is how it was spelled in the source and is used for messages.
+type mutationPath struct {
+	canonical string
+	display   string
+}
+
+func (m mutationPath) append(name string) mutationPath {
+	display := m.display + "." + name
+	if !js_lexer.IsIdentifier(name) {
+		display = fmt.Sprintf("%s['%s']", m.display, name)
+	}
+	return mutationPath{
+		canonical: m.canonical + "." + name,
+		display:   display,
+	}
+}
+
+// Resolves the path of the given target expression, i.e. `Object.prototype.foo` for
+// `Object.prototype.foo = ...`. Only paths rooted at a global or at a required module
+// resolve, i.e. for locally declared objects `false` is returned.
+func (v *SnapAstValiator) resolveMutationPath(expr *js_ast.Expr) (mutationPath, bool) {
+	switch e := expr.Data.(type) {
+	case *js_ast.EIdentifier:
+		if specifier, ok := v.requireBindings[e.Ref]; ok {
+			return mutationPath{canonical: requirePath(specifier), display: v.renamer.OriginalName(e.Ref)}, true
+		}
+		if v.renamer.IsUnbound(e.Ref) {
+			name := v.renamer.OriginalName(e.Ref)
+			return mutationPath{canonical: name, display: name}, true
+		}
+
+	case *js_ast.ERequire, *js_ast.ECall:
+		if specifier, ok := v.requireSpecifier(expr); ok {
+			path := requirePath(specifier)
+			return mutationPath{canonical: path, display: path}, true
+		}
+
+	case *js_ast.EDot:
+		if parent, ok := v.resolveMutationPath(&e.Target); ok {
+			return parent.append(e.Name), true
+		}
+
+	case *js_ast.EIndex:
+		if parent, ok := v.resolveMutationPath(&e.Target); ok {
+			if str, ok := e.Index.Data.(*js_ast.EString); ok {
+				return parent.append(stringifyEString(str)), true
+			}
+			// A computed property still mutates the object it is indexing into
+			return mutationPath{canonical: parent.canonical, display: parent.display + "[...]"}, true
+		}
+	}
+	return mutationPath{}, false
+}
+
+// Returns the specifier of `require('<specifier>')` calls.
+func (v *SnapAstValiator) requireSpecifier(expr *js_ast.Expr) (string, bool) {
+	switch e := expr.Data.(type) {
+	case *js_ast.ERequire:
+		record := &v.importRecords[e.ImportRecordIndex]
+		if record.Kind != ast.ImportDynamic {
+			return record.Path.Text, true
+		}
+	case *js_ast.ECall:
+		if target, ok := e.Target.Data.(*js_ast.EIdentifier); ok && len(e.Args) == 1 {
+			if !v.renamer.IsUnbound(target.Ref) || v.renamer.OriginalName(target.Ref) != "require" {
+				break
+			}
+			if arg, ok := e.Args[0].Data.(*js_ast.EString); ok {
+				return stringifyEString(arg), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Tracks top-level bindings to required modules, i.e. `var Module = require('module')`, so that
+// mutations via those bindings can be matched against paths like `require('module')._load`.
+func (v *SnapAstValiator) trackRequireBindings(local *js_ast.SLocal) {
+	if !v.validateStrict {
+		return
+	}
+	for _, decl := range local.Decls {
+		if decl.Value == nil {
+			continue
+		}
+		binding, ok := decl.Binding.Data.(*js_ast.BIdentifier)
+		if !ok {
+			continue
+		}
+		if specifier, ok := v.requireSpecifier(decl.Value); ok {
+			v.requireBindings[binding.Ref] = specifier
+		}
+	}
+}
+
+//
+// Mutation detection
+//
+
+var objectMutators = []string{"defineProperty", "defineProperties", "assign", "setPrototypeOf"}
+
+func isObjectMutator(name string) bool {
+	for _, m := range objectMutators {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Detects mutations of protected globals, i.e. `Object.prototype.foo = ...`, `delete require.extensions['.ts']`
+// or `Object.defineProperty(Error, 'prepareStackTrace', ...)`.
+func (v *SnapAstValiator) verifyNoProtectedGlobalMutation(expr *js_ast.Expr) (string, ValidatioErrorKind, bool) {
+	switch e := expr.Data.(type) {
+	case *js_ast.EBinary:
+		if e.Op == js_ast.BinOpComma {
+			if msg, kind, ok := v.verifyNoProtectedGlobalMutation(&e.Left); !ok {
+				return msg, kind, ok
+			}
+			return v.verifyNoProtectedGlobalMutation(&e.Right)
+		}
+		if e.Op.BinaryAssignTarget() != js_ast.AssignTargetNone {
+			if path, ok := v.resolveMutationPath(&e.Left); ok {
+				if _, kind, isProtected := v.protectedGlobals.lookup(path.canonical); isProtected {
+					return fmt.Sprintf("Cannot override '%s'", path.display), kind, false
+				}
+			}
+			// Handle chained assignments, i.e. `a = Error.prepareStackTrace = fn`
+			return v.verifyNoProtectedGlobalMutation(&e.Right)
+		}
+
+	case *js_ast.EUnary:
+		if e.Op == js_ast.UnOpDelete {
+			if path, ok := v.resolveMutationPath(&e.Value); ok {
+				if _, kind, isProtected := v.protectedGlobals.lookup(path.canonical); isProtected {
+					return fmt.Sprintf("Cannot delete '%s'", path.display), kind, false
+				}
+			}
+		}
+
+	case *js_ast.ECall:
+		// Object.defineProperty(<target>, <key>, ...)
+		dot, ok := e.Target.Data.(*js_ast.EDot)
+		if !ok || !isObjectMutator(dot.Name) || len(e.Args) == 0 {
+			break
+		}
+		object, ok := dot.Target.Data.(*js_ast.EIdentifier)
+		if !ok || !v.renamer.IsUnbound(object.Ref) || v.renamer.OriginalName(object.Ref) != "Object" {
+			break
+		}
+		path, ok := v.resolveMutationPath(&e.Args[0])
+		if !ok {
+			break
+		}
+		if dot.Name == "defineProperty" && len(e.Args) > 1 {
+			if key, ok := e.Args[1].Data.(*js_ast.EString); ok {
+				path = path.append(stringifyEString(key))
+			}
+		}
+		if _, kind, isProtected := v.protectedGlobals.lookup(path.canonical); isProtected {
+			return fmt.Sprintf("Cannot override '%s' via 'Object.%s'", path.display, dot.Name), kind, false
+		}
+	}
+	return "", 0, true
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_parser"
 	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/renamer"
 	"github.com/evanw/esbuild/internal/snap_renamer"
 	"github.com/evanw/esbuild/internal/test"
 )
@@ -29,6 +30,9 @@ type testOpts struct {
 	shouldRewrite        bool
 	validateStrict       bool
 	snapFilePath         string
+	createSnapshot       bool
+	// When set the snap renamer wraps the returned renamer, like it does the one prepared by the linker
+	wrappedRenamer func(tree js_ast.AST, symbols js_ast.SymbolMap) renamer.Renamer
 }
 
 func showSpaces(s string) string {
@@ -47,7 +51,7 @@ func expectPrintedCommon(
 	t.Run(name, func(t *testing.T) {
 		t.Helper()
 		log := logger.NewDeferLog()
-		tree, ok := js_parser.Parse(log, test.SourceForTest(contents), js_parser.Options{})
+		tree, ok := js_parser.Parse(log, test.SourceForTest(contents), js_parser.Options{CreateSnapshot: testOpts.createSnapshot})
 		msgs := log.Done()
 		text := ""
 		for _, msg := range msgs {
@@ -59,12 +63,24 @@ func expectPrintedCommon(
 		}
 		symbols := js_ast.NewSymbolMap(1)
 		symbols.SymbolsForSource[0] = tree.Symbols
-		r := snap_renamer.NewSnapRenamer(
-			symbols,
-			name,
-			tree.DirnameRef,
-			tree.FilenameRef,
-			testOpts.shouldRewrite)
+		var r snap_renamer.SnapRenamer
+		if testOpts.wrappedRenamer != nil {
+			wrapped := testOpts.wrappedRenamer(tree, symbols)
+			r = snap_renamer.WrapRenamer(
+				&wrapped,
+				symbols,
+				name,
+				tree.DirnameRef,
+				tree.FilenameRef,
+				testOpts.shouldRewrite)
+		} else {
+			r = snap_renamer.NewSnapRenamer(
+				symbols,
+				name,
+				tree.DirnameRef,
+				tree.FilenameRef,
+				testOpts.shouldRewrite)
+		}
 
 		js := Print(
 			tree,
@@ -72,6 +88,7 @@ func expectPrintedCommon(
 			&r,
 			options,
 			testOpts.validateStrict,
+			DefaultProtectedGlobals,
 			testOpts.isWrapped,
 			testOpts.shouldReplaceRequire,
 		).JS
@@ -230,6 +247,16 @@ func expectValidationErrors(
 	t *testing.T,
 	contents string,
 	expectedErrors []ValidationError,
+) {
+	t.Helper()
+	expectValidationErrorsWithProtectedGlobals(t, contents, DefaultProtectedGlobals, expectedErrors)
+}
+
+func expectValidationErrorsWithProtectedGlobals(
+	t *testing.T,
+	contents string,
+	protectedGlobals ProtectedGlobals,
+	expectedErrors []ValidationError,
 ) {
 	name := contents
 	options := PrintOptions{}
@@ -264,6 +291,7 @@ func expectValidationErrors(
 			&r,
 			options,
 			testOpts.validateStrict,
+			protectedGlobals,
 			testOpts.isWrapped,
 			testOpts.shouldReplaceRequire,
 		).ValidationErrors
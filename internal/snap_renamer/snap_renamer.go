@@ -2,8 +2,11 @@ package snap_renamer
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_printer"
 	"github.com/evanw/esbuild/internal/renamer"
 )
 
@@ -17,6 +20,23 @@ type NamedReference struct {
 	Replace        *Replacement
 }
 
+// Configures how accesses to `__dirname` and `__filename` are rewritten.
+type PathResolution struct {
+	// The function invoked at runtime to resolve the path, i.e. `__resolve_path(__dirname)`
+	FnName string
+
+	// When set the path of the module relative to the snapshot base dir is inlined instead of
+	// passing the `__dirname`/`__filename` argument, i.e. `__resolve_path("./node_modules/foo")`
+	InlineRelative bool
+
+	// The path of the module relative to the snapshot base dir, i.e. "./node_modules/foo/index.js"
+	RelPath string
+}
+
+const DefaultResolvePathFnName = "__resolve_path"
+
+var DefaultPathResolution = PathResolution{FnName: DefaultResolvePathFnName}
+
 type SnapRenamer struct {
 	symbols             js_ast.SymbolMap
 	globalSymbols       GlobalSymbols
@@ -28,6 +48,7 @@ type SnapRenamer struct {
 	wrappedRenamer      *renamer.Renamer
 	NamedReferences     map[js_ast.Ref]*NamedReference
 	CurrentPrinterIndex func() int
+	PathResolution      PathResolution
 	// Used when logging, i.e. fmt.Printf("[%10s]: %v\n", r.filePath, symbol)
 	filePath string
 }
@@ -75,6 +96,7 @@ func NewSnapRenamer(symbols js_ast.SymbolMap,
 		IsEnabled:           isEnabled,
 		deferredIdentifiers: make(map[js_ast.Ref]Replacement),
 		NamedReferences:     make(map[js_ast.Ref]*NamedReference),
+		PathResolution:      DefaultPathResolution,
 	}
 }
 
@@ -100,6 +122,7 @@ func WrapRenamer(r *renamer.Renamer,
 		deferredIdentifiers: make(map[js_ast.Ref]Replacement),
 		wrappedRenamer:      r,
 		NamedReferences:     make(map[js_ast.Ref]*NamedReference),
+		PathResolution:      DefaultPathResolution,
 	}
 }
 
@@ -124,7 +147,7 @@ func (r *SnapRenamer) SnapNameForSymbol(
 	if r.isCommonJS && opts.allowReplaceWithDeferr {
 		// commonJS __dirname, __filename are always replaced
 		if ref == r.dirnameRef || ref == r.filenameRef {
-			return functionWrapperForAbsPath(symbol.OriginalName)
+			return r.resolvePathCall(ref)
 		}
 	}
 
@@ -259,13 +282,51 @@ func (r *SnapRenamer) IsRequire(ref js_ast.Ref) bool {
 	return r.isSymbolNamed(symbol, "require")
 }
 
-// NOTE: esbuild renames __dirname/__filename to __dirname2/__filename2 in some cases and
-// I see no obvious way to retrieve that final name here. This is a workaround which is very stable
-// as I have not seen anything but that one variation inside a very large bundle.
-// Also it seems like that when those args are actually used they are renamed, otherwise not, which
-// is why we look for the __x2 version first.
-func functionWrapperForAbsPath(id string) string {
-	return fmt.Sprintf("__resolve_path(typeof %s2 !== 'undefined' ? %s2 : %s)", id, id, id)
+// Returns the name the `__dirname`/`__filename` wrapper argument was given by the wrapped renamer
+// which may differ from the original, i.e. `__dirname2`, when it collides with another symbol.
+func (r *SnapRenamer) nameForWrapperArg(ref js_ast.Ref, fallback string) string {
+	if !r.isCommonJS {
+		return fallback
+	}
+	if r.wrappedRenamer != nil {
+		return (*r.wrappedRenamer).NameForSymbol(ref)
+	}
+	return r.symbols.Get(ref).OriginalName
+}
+
+// The identifier holding the `__dirname` of the module inside its wrapper function.
+func (r *SnapRenamer) DirnameIdentifier() string {
+	return r.nameForWrapperArg(r.dirnameRef, "__dirname")
+}
+
+// The identifier holding the `__filename` of the module inside its wrapper function.
+func (r *SnapRenamer) FilenameIdentifier() string {
+	return r.nameForWrapperArg(r.filenameRef, "__filename")
+}
+
+// Accesses to `__dirname` and `__filename` are resolved at runtime since the paths at the time
+// the snapshot is created differ from the ones where it is used.
+//   __resolve_path(__dirname)
+// or when inlining relative paths
+//   __resolve_path("./node_modules/foo")
+func (r *SnapRenamer) resolvePathCall(ref js_ast.Ref) string {
+	opts := r.PathResolution
+	isDirname := ref == r.dirnameRef
+	if opts.InlineRelative && opts.RelPath != "" {
+		relPath := opts.RelPath
+		if isDirname {
+			relPath = path.Dir(relPath)
+			// path.Dir cleans the path and thus removes the leading "./"
+			if relPath != "." && !strings.HasPrefix(relPath, "../") {
+				relPath = "./" + relPath
+			}
+		}
+		return fmt.Sprintf("%s(%s)", opts.FnName, js_printer.QuoteForJSON(relPath, false))
+	}
+	if isDirname {
+		return fmt.Sprintf("%s(%s)", opts.FnName, r.DirnameIdentifier())
+	}
+	return fmt.Sprintf("%s(%s)", opts.FnName, r.FilenameIdentifier())
 }
 
 // TODO(thlorenz): Include more from
@@ -311,6 +372,11 @@ func (r *SnapRenamer) IsProcessRef(ref js_ast.Ref) bool {
 	return symbol.OriginalName == "process" && symbol.Link == r.globalSymbols.process.Link
 }
 
+func (r *SnapRenamer) OriginalName(ref js_ast.Ref) string {
+	ref = r.resolveRefFromSymbols(ref)
+	return r.symbols.Get(ref).OriginalName
+}
+
 func (r *SnapRenamer) IsFunctionRef(ref js_ast.Ref) bool {
 	ref = r.resolveRefFromSymbols(ref)
 	symbol := r.symbols.Get(ref)
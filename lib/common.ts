@@ -698,6 +698,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 resolveDir: request.resolveDir,
                 kind: request.kind,
                 pluginData: stash.load(request.pluginData),
+                assertions: request.assertions,
               });
 
               if (result != null) {
@@ -707,6 +708,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 let path = getFlag(result, keys, 'path', mustBeString);
                 let namespace = getFlag(result, keys, 'namespace', mustBeString);
                 let external = getFlag(result, keys, 'external', mustBeBoolean);
+                let suffix = getFlag(result, keys, 'suffix', mustBeString);
                 let pluginData = getFlag(result, keys, 'pluginData', canBeAnything);
                 let errors = getFlag(result, keys, 'errors', mustBeArray);
                 let warnings = getFlag(result, keys, 'warnings', mustBeArray);
@@ -719,6 +721,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 if (path != null) response.path = path;
                 if (namespace != null) response.namespace = namespace;
                 if (external != null) response.external = external;
+                if (suffix != null) response.suffix = suffix;
                 if (pluginData != null) response.pluginData = stash.store(pluginData);
                 if (errors != null) response.errors = sanitizeMessages(errors, 'errors', stash);
                 if (warnings != null) response.warnings = sanitizeMessages(warnings, 'warnings', stash);
@@ -741,7 +744,9 @@ export function createChannel(streamIn: StreamIn): StreamOut {
               let result = await callback({
                 path: request.path,
                 namespace: request.namespace,
+                suffix: request.suffix,
                 pluginData: stash.load(request.pluginData),
+                assertions: request.assertions,
               });
 
               if (result != null) {
@@ -137,6 +137,7 @@ export interface OnResolveRequest {
   resolveDir: string;
   kind: types.ImportKind;
   pluginData: number;
+  assertions: Record<string, string>;
 }
 
 export interface OnResolveResponse {
@@ -149,6 +150,7 @@ export interface OnResolveResponse {
   path?: string;
   external?: boolean;
   namespace?: string;
+  suffix?: string;
   pluginData?: number;
 
   watchFiles?: string[];
@@ -161,7 +163,9 @@ export interface OnLoadRequest {
   ids: number[];
   path: string;
   namespace: string;
+  suffix: string;
   pluginData: number;
+  assertions: Record<string, string>;
 }
 
 export interface OnLoadResponse {
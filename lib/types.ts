@@ -1,6 +1,6 @@
 export type Platform = 'browser' | 'node' | 'neutral';
 export type Format = 'iife' | 'cjs' | 'esm';
-export type Loader = 'js' | 'jsx' | 'ts' | 'tsx' | 'css' | 'json' | 'text' | 'base64' | 'file' | 'dataurl' | 'binary' | 'default';
+export type Loader = 'js' | 'jsx' | 'ts' | 'tsx' | 'css' | 'local-css' | 'json' | 'text' | 'base64' | 'file' | 'dataurl' | 'binary' | 'default';
 export type LogLevel = 'debug' | 'info' | 'warning' | 'error' | 'silent';
 export type Charset = 'ascii' | 'utf8';
 export type TreeShaking = true | 'ignore-annotations';
@@ -203,6 +203,7 @@ export interface OnResolveArgs {
   resolveDir: string;
   kind: ImportKind;
   pluginData: any;
+  assertions: Record<string, string>;
 }
 
 export type ImportKind =
@@ -217,6 +218,7 @@ export type ImportKind =
   // CSS
   | 'import-rule'
   | 'url-token'
+  | 'composes-from'
 
 export interface OnResolveResult {
   pluginName?: string;
@@ -227,6 +229,7 @@ export interface OnResolveResult {
   path?: string;
   external?: boolean;
   namespace?: string;
+  suffix?: string;
   pluginData?: any;
 
   watchFiles?: string[];
@@ -241,7 +244,9 @@ export interface OnLoadOptions {
 export interface OnLoadArgs {
   path: string;
   namespace: string;
+  suffix: string;
   pluginData: any;
+  assertions: Record<string, string>;
 }
 
 export interface OnLoadResult {
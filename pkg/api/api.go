@@ -126,6 +126,7 @@ const (
 	LoaderFile
 	LoaderBinary
 	LoaderCSS
+	LoaderLocalCSS
 	LoaderDefault
 )
 
@@ -272,6 +273,9 @@ type BuildOptions struct {
 	Footer            map[string]string
 	NodePaths         []string // The "NODE_PATH" variable from Node.js
 
+	PackageEntryRedirects []PackageEntryRedirect
+	Alias                 map[string]string
+
 	EntryNames string
 	ChunkNames string
 	AssetNames string
@@ -284,10 +288,18 @@ type BuildOptions struct {
 	Incremental bool
 	Plugins     []Plugin
 
+	CacheDir     string // Parse results are stored here to speed up later builds
+	CacheMaxSize int64  // In bytes, defaults to 512mb
+
+	// Spans for each phase of the build are written to this file as Chrome
+	// trace events, which can be viewed in "chrome://tracing" or Perfetto
+	ChromeTraceFile string
+
 	Watch *WatchMode
 
 	Snapshot *SnapshotOptions
 	FS       fs.FS
+	Overlay  *Overlay // In-memory files layered on top of "FS"
 }
 
 type EntryPoint struct {
@@ -295,10 +307,41 @@ type EntryPoint struct {
 	OutputPath string
 }
 
+// Replaces the file that a "main" field of the package with the given "name"
+// resolves to. This is for packages that pick their implementation at run-time,
+// which can't be bundled. Both paths are relative to the package directory.
+type PackageEntryRedirect struct {
+	PackageName string
+	Main        string
+	Redirect    string
+}
+
 type WatchMode struct {
 	OnRebuild func(BuildResult)
 }
 
+// Files in an overlay take precedence over the files on the file system, and
+// directories that contain them are merged with the directories on the file
+// system. An overlay can be changed at any time. Each build uses the files
+// that were in the overlay when it started, and both watch mode and "Rebuild"
+// pick up any changes made since then. Relative paths are relative to the
+// working directory of the build.
+type Overlay struct {
+	overlay *fs.Overlay
+}
+
+func NewOverlay() *Overlay {
+	return &Overlay{overlay: fs.NewOverlay()}
+}
+
+func (o *Overlay) WriteFile(path string, contents string) {
+	o.overlay.WriteFile(path, contents)
+}
+
+func (o *Overlay) RemoveFile(path string) {
+	o.overlay.RemoveFile(path)
+}
+
 type StdinOptions struct {
 	Contents   string
 	ResolveDir string
@@ -309,6 +352,14 @@ type StdinOptions struct {
 type ShouldReplaceRequirePredicate func(string) bool
 type ShouldRewriteModulePredicate func(string) bool
 
+type ProtectedGlobalKind uint8
+
+const (
+	ProtectedGlobalDefer ProtectedGlobalKind = iota
+	ProtectedGlobalNoRewrite
+	ProtectedGlobalIgnore
+)
+
 type SnapshotOptions struct {
 	CreateSnapshot       bool
 	ShouldReplaceRequire ShouldReplaceRequirePredicate
@@ -317,6 +368,19 @@ type SnapshotOptions struct {
 	VerifyPrint          bool
 	PanicOnError         bool
 	Doctor               bool
+
+	// The function invoked at runtime to resolve "__dirname" and "__filename",
+	// defaults to "__resolve_path"
+	ResolvePathFn string
+	// When true the paths of modules relative to "AbsBasedir" are passed to the
+	// "ResolvePathFn" instead of their "__dirname" and "__filename"
+	InlineRelativePaths bool
+
+	// Globals whose mutation at the top level of a module is reported by the doctor,
+	// i.e. "Error.prepareStackTrace" or "require('module')._load". These are merged
+	// into the default set, use "ProtectedGlobalIgnore" to remove a default entry or to
+	// exclude a path below a protected one, i.e. "Object.prototype.toJSON".
+	ProtectedGlobals map[string]ProtectedGlobalKind
 }
 
 type BuildResult struct {
@@ -429,10 +493,58 @@ type Plugin struct {
 
 type PluginBuild struct {
 	InitialOptions *BuildOptions
+	Resolve        func(path string, options ResolveOptions) ResolveResult
+	OnStart        func(callback func() (OnStartResult, error))
+	OnEnd          func(callback func(result *BuildResult) (OnEndResult, error))
 	OnResolve      func(options OnResolveOptions, callback func(OnResolveArgs) (OnResolveResult, error))
 	OnLoad         func(options OnLoadOptions, callback func(OnLoadArgs) (OnLoadResult, error))
 }
 
+// "OnStart" callbacks run at the start of every build, including rebuilds
+// triggered by "Rebuild" and by watch mode. They all run in parallel and
+// must finish before any "OnResolve" or "OnLoad" callback is called.
+// They also run when the build options are invalid, so every "OnEnd" callback
+// has a matching "OnStart" callback.
+type OnStartResult struct {
+	Errors   []Message
+	Warnings []Message
+}
+
+// "OnEnd" callbacks run in order at the end of every build, including builds
+// that failed. The result contains the errors and warnings so far as well as
+// the output files and the metafile. Changes to "OutputFiles" and "Metafile"
+// are kept, but output files have already been written when "Write" is true.
+// Any errors and warnings returned here are added to the build result.
+type OnEndResult struct {
+	Errors   []Message
+	Warnings []Message
+}
+
+// "Resolve" resolves a path the same way an import statement would. It runs
+// the "OnResolve" callbacks of all other plugins and then the built-in
+// resolver. The callbacks of the plugin calling "Resolve" are skipped, so it's
+// safe to call from inside that plugin's own "OnResolve" callbacks. Plugins
+// that keep calling each other get an error once the calls are nested too
+// deeply. This can only be called once the build has started.
+type ResolveOptions struct {
+	Importer   string
+	Namespace  string
+	ResolveDir string
+	Kind       ResolveKind
+	PluginData interface{}
+}
+
+type ResolveResult struct {
+	Errors   []Message
+	Warnings []Message
+
+	Path       string
+	External   bool
+	Namespace  string
+	Suffix     string
+	PluginData interface{}
+}
+
 type OnResolveOptions struct {
 	Filter    string
 	Namespace string
@@ -445,6 +557,7 @@ type OnResolveArgs struct {
 	ResolveDir string
 	Kind       ResolveKind
 	PluginData interface{}
+	Assertions map[string]string
 }
 
 type OnResolveResult struct {
@@ -458,6 +571,11 @@ type OnResolveResult struct {
 	Namespace  string
 	PluginData interface{}
 
+	// An optional query string and/or hash such as "?inline" or "#module" that
+	// isn't part of the path itself. It's passed to "OnLoad" callbacks and is
+	// kept in the URLs generated for the "file" loader.
+	Suffix string
+
 	WatchFiles []string
 	WatchDirs  []string
 }
@@ -470,7 +588,9 @@ type OnLoadOptions struct {
 type OnLoadArgs struct {
 	Path       string
 	Namespace  string
+	Suffix     string
 	PluginData interface{}
+	Assertions map[string]string
 }
 
 type OnLoadResult struct {
@@ -498,6 +618,7 @@ const (
 	ResolveJSRequireResolve
 	ResolveCSSImportRule
 	ResolveCSSURLToken
+	ResolveCSSComposesFrom
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -24,6 +24,7 @@ func createPrintAST(snapshot *SnapshotOptions, log *logger.Log) bundler.PrintAST
 		if shouldRewriteModule == nil {
 			shouldRewriteModule = rewriteAll
 		}
+		protectedGlobals := protectedGlobalsFromOpts(snapshot.ProtectedGlobals)
 
 		return func(
 			tree js_ast.AST,
@@ -37,6 +38,7 @@ func createPrintAST(snapshot *SnapshotOptions, log *logger.Log) bundler.PrintAST
 				tree.DirnameRef,
 				tree.FilenameRef,
 				shouldRewriteModule(options.FilePath))
+			r.PathResolution = pathResolutionFromOpts(snapshot, options.SnapshotRelPath)
 
 			if options.IsRuntime {
 				return js_printer.Print(tree, symbols, &r, options)
@@ -47,6 +49,7 @@ func createPrintAST(snapshot *SnapshotOptions, log *logger.Log) bundler.PrintAST
 					&r,
 					options,
 					snapshot.Doctor,
+					protectedGlobals,
 					true,
 					shouldReplaceRequire)
 
@@ -66,6 +69,36 @@ func createPrintAST(snapshot *SnapshotOptions, log *logger.Log) bundler.PrintAST
 	}
 }
 
+func pathResolutionFromOpts(snapshot *SnapshotOptions, relPath string) snap_renamer.PathResolution {
+	fnName := snapshot.ResolvePathFn
+	if fnName == "" {
+		fnName = snap_renamer.DefaultResolvePathFnName
+	}
+	return snap_renamer.PathResolution{
+		FnName:         fnName,
+		InlineRelative: snapshot.InlineRelativePaths,
+		RelPath:        relPath,
+	}
+}
+
+func protectedGlobalsFromOpts(opts map[string]ProtectedGlobalKind) snap_printer.ProtectedGlobals {
+	if len(opts) == 0 {
+		return snap_printer.DefaultProtectedGlobals
+	}
+	protectedGlobals := snap_printer.CopyDefaultProtectedGlobals()
+	for path, kind := range opts {
+		switch kind {
+		case ProtectedGlobalDefer:
+			protectedGlobals[path] = snap_printer.Defer
+		case ProtectedGlobalNoRewrite:
+			protectedGlobals[path] = snap_printer.NoRewrite
+		case ProtectedGlobalIgnore:
+			protectedGlobals[path] = snap_printer.Ignore
+		}
+	}
+	return protectedGlobals
+}
+
 func addSnapshotOpts(buildOpts *BuildOptions, configOpts *config.Options) {
 	if buildOpts.Snapshot == nil || !buildOpts.Snapshot.CreateSnapshot {
 		return
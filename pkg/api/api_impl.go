@@ -19,6 +19,7 @@ import (
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
 	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/helpers"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_lexer"
 	"github.com/evanw/esbuild/internal/js_parser"
@@ -88,7 +89,7 @@ func validatePathTemplate(template string) []config.PathTemplate {
 	return parts
 }
 
-func validatePlatform(value Platform) config.Platform {
+func validatePlatform(log logger.Log, value Platform) config.Platform {
 	switch value {
 	case PlatformBrowser:
 		return config.PlatformBrowser
@@ -97,7 +98,8 @@ func validatePlatform(value Platform) config.Platform {
 	case PlatformNeutral:
 		return config.PlatformNeutral
 	default:
-		panic("Invalid platform")
+		log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid platform: %d", value))
+		return config.PlatformBrowser
 	}
 }
 
@@ -211,6 +213,8 @@ func validateLoader(value Loader) config.Loader {
 		return config.LoaderBinary
 	case LoaderCSS:
 		return config.LoaderCSS
+	case LoaderLocalCSS:
+		return config.LoaderLocalCSS
 	case LoaderDefault:
 		return config.LoaderDefault
 	default:
@@ -239,7 +243,7 @@ func validateEngine(value EngineName) compat.Engine {
 
 var versionRegex = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?$`)
 
-func validateFeatures(log logger.Log, target Target, engines []Engine) (compat.JSFeature, compat.CSSFeature, string) {
+func validateFeatures(log logger.Log, target Target, engines []Engine) (compat.JSFeature, compat.CSSFeature, compat.CSSPrefixData, string) {
 	constraints := make(map[compat.Engine][]int)
 	targets := make([]string, 0, 1+len(engines))
 
@@ -260,7 +264,7 @@ func validateFeatures(log logger.Log, target Target, engines []Engine) (compat.J
 		constraints[compat.ES] = []int{2020}
 	case ESNext:
 	default:
-		panic("Invalid target")
+		log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid target: %d", target))
 	}
 
 	for _, engine := range engines {
@@ -287,7 +291,7 @@ func validateFeatures(log logger.Log, target Target, engines []Engine) (compat.J
 				case EngineSafari:
 					constraints[compat.Safari] = version
 				default:
-					panic("Invalid engine name")
+					log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid engine name: %d", engine.Name))
 				}
 				continue
 			}
@@ -312,7 +316,7 @@ func validateFeatures(log logger.Log, target Target, engines []Engine) (compat.J
 	sort.Strings(targets)
 	targetEnv := strings.Join(targets, ", ")
 
-	return compat.UnsupportedJSFeatures(constraints), compat.UnsupportedCSSFeatures(constraints), targetEnv
+	return compat.UnsupportedJSFeatures(constraints), compat.UnsupportedCSSFeatures(constraints), compat.CSSPrefixDataForConstraints(constraints), targetEnv
 }
 
 func validateGlobalName(log logger.Log, text string) []string {
@@ -355,6 +359,68 @@ func validateExternals(log logger.Log, fs fs.FS, paths []string) config.External
 	return result
 }
 
+func validateAlias(log logger.Log, fs fs.FS, alias map[string]string) map[string]string {
+	if len(alias) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for name, substitute := range alias {
+		// Only package paths can be aliased
+		if !resolver.IsPackagePath(name) || strings.HasSuffix(name, "/") || strings.ContainsRune(name, '*') {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid alias name: %q", name))
+			continue
+		}
+
+		// Relative substitutions are relative to the working directory
+		if substitute == "" {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid alias substitution for %q: the substitution must not be empty", name))
+			continue
+		} else if strings.HasPrefix(substitute, "./") || strings.HasPrefix(substitute, "../") {
+			substitute = validatePath(log, fs, substitute, "alias path")
+		}
+		result[name] = substitute
+	}
+	return result
+}
+
+func validateCacheDir(log logger.Log, fs fs.FS, buildOpts *BuildOptions, minify bool) *cache.DiskCache {
+	if buildOpts.CacheDir == "" {
+		return nil
+	}
+	if buildOpts.CacheMaxSize < 0 {
+		log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid cache max size: %d", buildOpts.CacheMaxSize))
+		return nil
+	}
+	dir := validatePath(log, fs, buildOpts.CacheDir, "cache directory path")
+
+	// The processed defines contain functions and can't be compared, so the
+	// options that they are generated from are part of the cache key instead
+	fingerprint := fmt.Sprintf("%q %q %v %v", buildOpts.Define, buildOpts.Pure, buildOpts.Platform, minify)
+	return cache.NewDiskCache(dir, buildOpts.CacheMaxSize, fingerprint)
+}
+
+func validatePackageEntryRedirects(log logger.Log, fs fs.FS, redirects []PackageEntryRedirect) []config.PackageEntryRedirect {
+	var result []config.PackageEntryRedirect
+	for _, redirect := range redirects {
+		if redirect.PackageName == "" || redirect.Main == "" || redirect.Redirect == "" {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid package entry redirect for package %q: "+
+				"the package name, main and redirect must all be present", redirect.PackageName))
+			continue
+		}
+		if fs.IsAbs(redirect.Main) || fs.IsAbs(redirect.Redirect) {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf("Invalid package entry redirect for package %q: "+
+				"the main and redirect must be relative to the package directory", redirect.PackageName))
+			continue
+		}
+		result = append(result, config.PackageEntryRedirect{
+			PackageName: redirect.PackageName,
+			Main:        redirect.Main,
+			Redirect:    redirect.Redirect,
+		})
+	}
+	return result
+}
+
 func isValidExtension(ext string) bool {
 	return len(ext) >= 2 && ext[0] == '.' && ext[len(ext)-1] != '.'
 }
@@ -745,7 +811,7 @@ func printSummary(logOptions logger.OutputOptions, outputFiles []OutputFile, sta
 func rebuildImpl(
 	buildOpts BuildOptions,
 	caches *cache.CacheSet,
-	plugins []config.Plugin,
+	plugins *loadedPlugins,
 	logOptions logger.OutputOptions,
 	log logger.Log,
 	isRebuild bool,
@@ -765,7 +831,17 @@ func rebuildImpl(
 			panic(err.Error())
 		}
 	}
-	jsFeatures, cssFeatures, targetEnv := validateFeatures(log, buildOpts.Target, buildOpts.Engines)
+
+	// In-memory files take precedence over the files on the file system
+	if buildOpts.Overlay != nil {
+		realFS = fs.WrapWithOverlay(realFS, buildOpts.Overlay.overlay)
+	}
+
+	// Packages installed by Yarn's Plug'n'Play mode are stored in zip archives,
+	// which are only read once the resolver finds a Plug'n'Play manifest
+	realFS = fs.WrapWithZip(realFS)
+
+	jsFeatures, cssFeatures, cssPrefixData, targetEnv := validateFeatures(log, buildOpts.Target, buildOpts.Engines)
 	outJS, outCSS := validateOutputExtensions(log, buildOpts.OutExtensions)
 	bannerJS, bannerCSS := validateBannerOrFooter(log, "banner", buildOpts.Banner)
 	footerJS, footerCSS := validateBannerOrFooter(log, "footer", buildOpts.Footer)
@@ -774,6 +850,7 @@ func rebuildImpl(
 	options := config.Options{
 		UnsupportedJSFeatures:  jsFeatures,
 		UnsupportedCSSFeatures: cssFeatures,
+		CSSPrefixData:          cssPrefixData,
 		OriginalTargetEnv:      targetEnv,
 		JSX: config.JSXOptions{
 			Factory:  validateJSX(log, buildOpts.JSXFactory, "factory"),
@@ -781,7 +858,7 @@ func rebuildImpl(
 		},
 		Defines:               defines,
 		InjectedDefines:       injectedDefines,
-		Platform:              validatePlatform(buildOpts.Platform),
+		Platform:              validatePlatform(log, buildOpts.Platform),
 		SourceMap:             validateSourceMap(buildOpts.Sourcemap),
 		SourceRoot:            buildOpts.SourceRoot,
 		ExcludeSourcesContent: buildOpts.SourcesContent == SourcesContentExclude,
@@ -808,6 +885,8 @@ func rebuildImpl(
 		TsConfigOverride:      validatePath(log, realFS, buildOpts.Tsconfig, "tsconfig path"),
 		MainFields:            buildOpts.MainFields,
 		Conditions:            append([]string{}, buildOpts.Conditions...),
+		PackageEntryRedirects: validatePackageEntryRedirects(log, realFS, buildOpts.PackageEntryRedirects),
+		Alias:                 validateAlias(log, realFS, buildOpts.Alias),
 		PublicPath:            buildOpts.PublicPath,
 		KeepNames:             buildOpts.KeepNames,
 		InjectAbsPaths:        make([]string, len(buildOpts.Inject)),
@@ -818,7 +897,7 @@ func rebuildImpl(
 		CSSFooter:             footerCSS,
 		PreserveSymlinks:      buildOpts.PreserveSymlinks,
 		WatchMode:             buildOpts.Watch != nil,
-		Plugins:               plugins,
+		Plugins:               plugins.plugins,
 	}
 	if options.MainFields != nil {
 		options.MainFields = append([]string{}, options.MainFields...)
@@ -917,8 +996,29 @@ func rebuildImpl(
 	var metafileJSON string
 	var watchData fs.WatchData
 
+	// Parse results from other processes can be reused if there's a cache directory
+	diskCache := validateCacheDir(log, realFS, &buildOpts, minify)
+	caches.SetDiskCache(diskCache)
+
+	// Record the time spent in each phase of the build if requested
+	traceFile := validatePath(log, realFS, buildOpts.ChromeTraceFile, "trace file path")
+	if traceFile != "" {
+		options.Tracer = helpers.NewTracer()
+	}
+
 	// Stop now if there were errors
 	resolver := resolver.NewResolver(realFS, log, caches, options)
+
+	// Plugins that call "Resolve" during this build should use this resolver
+	plugins.resolveContext.mutex.Lock()
+	plugins.resolveContext.res = resolver
+	plugins.resolveContext.fs = realFS
+	plugins.resolveContext.caches = caches
+	plugins.resolveContext.mutex.Unlock()
+
+	// Every "onEnd" callback below has a matching "onStart" callback
+	bundler.RunOnStartCallbacks(log, resolver, options)
+
 	if !log.HasErrors() {
 		// Scan over the bundle
 		bundle := bundler.ScanBundle(log, realFS, resolver, caches, entryPoints, options)
@@ -988,6 +1088,24 @@ func rebuildImpl(
 		}
 	}
 
+	// Let plugins inspect the result before the log ends
+	if len(plugins.onEndCallbacks) > 0 {
+		outputFiles, metafileJSON = runOnEndCallbacks(log, plugins.onEndCallbacks, outputFiles, metafileJSON)
+	}
+
+	// The trace is also written for failed builds since those can be slow too
+	if options.Tracer != nil {
+		if err := ioutil.WriteFile(traceFile, options.Tracer.JSON(), 0644); err != nil {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf(
+				"Failed to write to trace file: %s", err.Error()))
+		}
+	}
+
+	// Keep the cache directory under its size limit
+	if diskCache != nil {
+		diskCache.Evict()
+	}
+
 	// End the log now, which may print a message
 	msgs := log.Done()
 
@@ -1241,11 +1359,12 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 	}
 
 	// Convert and validate the transformOpts
-	jsFeatures, cssFeatures, targetEnv := validateFeatures(log, transformOpts.Target, transformOpts.Engines)
+	jsFeatures, cssFeatures, cssPrefixData, targetEnv := validateFeatures(log, transformOpts.Target, transformOpts.Engines)
 	defines, injectedDefines := validateDefines(log, transformOpts.Define, transformOpts.Pure, PlatformNeutral, false /* minify */)
 	options := config.Options{
 		UnsupportedJSFeatures:   jsFeatures,
 		UnsupportedCSSFeatures:  cssFeatures,
+		CSSPrefixData:           cssPrefixData,
 		OriginalTargetEnv:       targetEnv,
 		JSX:                     jsx,
 		Defines:                 defines,
@@ -1270,7 +1389,7 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 			SourceFile: transformOpts.Sourcefile,
 		},
 	}
-	if options.Stdin.Loader == config.LoaderCSS {
+	if options.Stdin.Loader.IsCSS() {
 		options.CSSBanner = transformOpts.Banner
 		options.CSSFooter = transformOpts.Footer
 	} else {
@@ -1339,6 +1458,190 @@ type pluginImpl struct {
 	log    logger.Log
 	fs     fs.FS
 	plugin config.Plugin
+	onEnd  []onEndCallback
+
+	// The index of this plugin in the list of loaded plugins and the state
+	// needed to run the resolver from within a plugin
+	index          int
+	resolveContext *pluginResolveContext
+}
+
+// This is shared by all plugins. The resolver and the caches are set at the
+// start of each build so that "Resolve" uses the same state as the build.
+type pluginResolveContext struct {
+	mutex   sync.Mutex
+	plugins []config.Plugin
+	res     resolver.Resolver
+	fs      fs.FS
+	caches  *cache.CacheSet
+
+	// The number of calls to "Resolve" that haven't returned yet. Calls made
+	// from "OnResolve" callbacks that run inside of another call add up, so
+	// plugins that keep calling each other run into the limit below.
+	depth int
+}
+
+// This is well above the number of calls that run at once in a normal build
+// but stops plugins that resolve through each other before the stack overflows.
+const maxResolveDepth = 1000
+
+func (impl *pluginImpl) Resolve(path string, options ResolveOptions) (result ResolveResult) {
+	context := impl.resolveContext
+	context.mutex.Lock()
+	plugins, res, realFS, caches := context.plugins, context.res, context.fs, context.caches
+	context.mutex.Unlock()
+
+	if res == nil {
+		result.Errors = []Message{{Text: fmt.Sprintf("[%s] Cannot call \"Resolve\" before the build has started", impl.plugin.Name)}}
+		return
+	}
+
+	var kind ast.ImportKind
+	switch options.Kind {
+	case ResolveEntryPoint:
+		kind = ast.ImportEntryPoint
+	case ResolveJSImportStatement:
+		kind = ast.ImportStmt
+	case ResolveJSRequireCall:
+		kind = ast.ImportRequire
+	case ResolveJSDynamicImport:
+		kind = ast.ImportDynamic
+	case ResolveJSRequireResolve:
+		kind = ast.ImportRequireResolve
+	case ResolveCSSImportRule:
+		kind = ast.ImportAt
+	case ResolveCSSURLToken:
+		kind = ast.ImportURL
+	case ResolveCSSComposesFrom:
+		kind = ast.ImportComposesFrom
+	default:
+		result.Errors = []Message{{Text: fmt.Sprintf("[%s] Invalid resolve kind: %d", impl.plugin.Name, options.Kind)}}
+		return
+	}
+
+	context.mutex.Lock()
+	if context.depth >= maxResolveDepth {
+		context.mutex.Unlock()
+		result.Errors = []Message{{Text: fmt.Sprintf("[%s] Too many nested calls to \"Resolve\"", impl.plugin.Name)}}
+		return
+	}
+	context.depth++
+	context.mutex.Unlock()
+	defer func() {
+		context.mutex.Lock()
+		context.depth--
+		context.mutex.Unlock()
+	}()
+
+	// Skip the callbacks of the plugin doing the resolving. Otherwise calling
+	// "Resolve" from an "OnResolve" callback would recurse forever.
+	otherPlugins := make([]config.Plugin, 0, len(plugins))
+	for i, plugin := range plugins {
+		if i != impl.index {
+			otherPlugins = append(otherPlugins, plugin)
+		}
+	}
+
+	// Messages are returned to the plugin instead of being added to the build
+	log := logger.NewDeferLog()
+	absResolveDir := validatePath(log, realFS, options.ResolveDir, "resolve directory path")
+	if !log.HasErrors() {
+		importer := logger.Path{Text: options.Importer, Namespace: options.Namespace}
+		resolveResult, didLogError, debug := bundler.RunOnResolvePlugins(
+			otherPlugins,
+			res,
+			log,
+			realFS,
+			&caches.FSCache,
+			importer,
+			path,
+			kind,
+			absResolveDir,
+			options.PluginData,
+		)
+
+		if resolveResult == nil {
+			if !didLogError {
+				log.AddErrorWithNotes(nil, logger.Loc{}, fmt.Sprintf("Could not resolve %q", path), debug.Notes(nil, logger.Range{}))
+			}
+		} else {
+			result.Path = resolveResult.PathPair.Primary.Text
+			result.Namespace = resolveResult.PathPair.Primary.Namespace
+			result.Suffix = resolveResult.PathPair.Primary.IgnoredSuffix
+			result.External = resolveResult.IsExternal
+			result.PluginData = resolveResult.PluginData
+		}
+	}
+
+	msgs := log.Done()
+	result.Errors = convertMessagesToPublic(logger.Error, msgs)
+	result.Warnings = convertMessagesToPublic(logger.Warning, msgs)
+	return
+}
+
+type onEndCallback struct {
+	pluginName string
+	fn         func(*BuildResult) (OnEndResult, error)
+}
+
+func (impl *pluginImpl) OnStart(callback func() (OnStartResult, error)) {
+	impl.plugin.OnStart = append(impl.plugin.OnStart, config.OnStart{
+		Name: impl.plugin.Name,
+		Callback: func() (result config.OnStartResult) {
+			response, err := callback()
+			if err != nil {
+				result.ThrownError = err
+				return
+			}
+
+			// Convert log messages
+			if len(response.Errors)+len(response.Warnings) > 0 {
+				msgs := make(logger.SortableMsgs, 0, len(response.Errors)+len(response.Warnings))
+				msgs = convertMessagesToInternal(msgs, logger.Error, response.Errors)
+				msgs = convertMessagesToInternal(msgs, logger.Warning, response.Warnings)
+				sort.Stable(msgs)
+				result.Msgs = msgs
+			}
+			return
+		},
+	})
+}
+
+func (impl *pluginImpl) OnEnd(callback func(*BuildResult) (OnEndResult, error)) {
+	impl.onEnd = append(impl.onEnd, onEndCallback{
+		pluginName: impl.plugin.Name,
+		fn:         callback,
+	})
+}
+
+func runOnEndCallbacks(log logger.Log, onEndCallbacks []onEndCallback, outputFiles []OutputFile, metafile string) ([]OutputFile, string) {
+	result := BuildResult{
+		OutputFiles: outputFiles,
+		Metafile:    metafile,
+	}
+
+	for _, onEnd := range onEndCallbacks {
+		msgs := log.Peek()
+		result.Errors = convertMessagesToPublic(logger.Error, msgs)
+		result.Warnings = convertMessagesToPublic(logger.Warning, msgs)
+
+		response, err := onEnd.fn(&result)
+		if err != nil {
+			log.AddError(nil, logger.Loc{}, fmt.Sprintf("[%s] %s", onEnd.pluginName, err.Error()))
+			continue
+		}
+
+		// Add the returned messages to the log so they are printed
+		var added []logger.Msg
+		added = convertMessagesToInternal(added, logger.Error, response.Errors)
+		added = convertMessagesToInternal(added, logger.Warning, response.Warnings)
+		for _, msg := range added {
+			msg.Data.Text = fmt.Sprintf("[%s] %s", onEnd.pluginName, msg.Data.Text)
+			log.AddMsg(msg)
+		}
+	}
+
+	return result.OutputFiles, result.Metafile
 }
 
 func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnResolveArgs) (OnResolveResult, error)) {
@@ -1369,6 +1672,8 @@ func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnReso
 				kind = ResolveCSSImportRule
 			case ast.ImportURL:
 				kind = ResolveCSSURLToken
+			case ast.ImportComposesFrom:
+				kind = ResolveCSSComposesFrom
 			default:
 				panic("Internal error")
 			}
@@ -1380,6 +1685,7 @@ func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnReso
 				ResolveDir: args.ResolveDir,
 				Kind:       kind,
 				PluginData: args.PluginData,
+				Assertions: args.Assertions,
 			})
 			result.PluginName = response.PluginName
 			result.AbsWatchFiles = impl.validatePathsArray(response.WatchFiles, "watch file")
@@ -1390,7 +1696,13 @@ func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnReso
 				return
 			}
 
-			result.Path = logger.Path{Text: response.Path, Namespace: response.Namespace}
+			// The suffix must look like a query string or a hash
+			if response.Suffix != "" && response.Suffix[0] != '?' && response.Suffix[0] != '#' {
+				result.ThrownError = fmt.Errorf("Invalid path suffix %q (it must start with \"?\" or \"#\")", response.Suffix)
+				return
+			}
+
+			result.Path = logger.Path{Text: response.Path, Namespace: response.Namespace, IgnoredSuffix: response.Suffix}
 			result.External = response.External
 			result.PluginData = response.PluginData
 
@@ -1421,7 +1733,9 @@ func (impl *pluginImpl) OnLoad(options OnLoadOptions, callback func(OnLoadArgs)
 			response, err := callback(OnLoadArgs{
 				Path:       args.Path.Text,
 				Namespace:  args.Path.Namespace,
+				Suffix:     args.Path.IgnoredSuffix,
 				PluginData: args.PluginData,
+				Assertions: args.Assertions,
 			})
 			result.PluginName = response.PluginName
 			result.AbsWatchFiles = impl.validatePathsArray(response.WatchFiles, "watch file")
@@ -1465,7 +1779,17 @@ func (impl *pluginImpl) validatePathsArray(pathsIn []string, name string) (paths
 	return
 }
 
-func loadPlugins(initialOptions *BuildOptions, fs fs.FS, log logger.Log) (results []config.Plugin) {
+type loadedPlugins struct {
+	plugins        []config.Plugin
+	onEndCallbacks []onEndCallback
+
+	// This points "Resolve" calls from plugins at the current build
+	resolveContext *pluginResolveContext
+}
+
+func loadPlugins(initialOptions *BuildOptions, fs fs.FS, log logger.Log) *loadedPlugins {
+	result := &loadedPlugins{resolveContext: &pluginResolveContext{}}
+
 	// Clone the plugin array to guard against mutation during iteration
 	clone := append(make([]Plugin, 0, len(initialOptions.Plugins)), initialOptions.Plugins...)
 
@@ -1476,20 +1800,28 @@ func loadPlugins(initialOptions *BuildOptions, fs fs.FS, log logger.Log) (result
 		}
 
 		impl := &pluginImpl{
-			fs:     fs,
-			log:    log,
-			plugin: config.Plugin{Name: item.Name},
+			fs:             fs,
+			log:            log,
+			plugin:         config.Plugin{Name: item.Name},
+			index:          len(result.plugins),
+			resolveContext: result.resolveContext,
 		}
 
 		item.Setup(PluginBuild{
 			InitialOptions: initialOptions,
+			Resolve:        impl.Resolve,
+			OnStart:        impl.OnStart,
+			OnEnd:          impl.OnEnd,
 			OnResolve:      impl.OnResolve,
 			OnLoad:         impl.OnLoad,
 		})
 
-		results = append(results, impl.plugin)
+		result.plugins = append(result.plugins, impl.plugin)
+		result.onEndCallbacks = append(result.onEndCallbacks, impl.onEnd...)
 	}
-	return
+
+	result.resolveContext.plugins = result.plugins
+	return result
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/fs"
+)
+
+func buildWithPlugins(files map[string]string, plugins ...Plugin) BuildResult {
+	return Build(BuildOptions{
+		EntryPoints: []string{"/entry.js"},
+		Outfile:     "/out.js",
+		Bundle:      true,
+		LogLevel:    LogLevelSilent,
+		Plugins:     plugins,
+		FS:          fs.MockFS(files),
+	})
+}
+
+var filesForResolveTest = map[string]string{
+	"/entry.js":                  `import value from 'dep'; console.log(value)`,
+	"/node_modules/dep/index.js": `export default 123`,
+}
+
+// This plugin calls "Resolve" with the incoming arguments and then tweaks the
+// result, which is the main use case for "Resolve"
+func resolveAndTweakPlugin(name string, resolved *[]string) Plugin {
+	return Plugin{
+		Name: name,
+		Setup: func(build PluginBuild) {
+			build.OnResolve(OnResolveOptions{Filter: `^dep$`}, func(args OnResolveArgs) (OnResolveResult, error) {
+				result := build.Resolve(args.Path, ResolveOptions{
+					Importer:   args.Importer,
+					Namespace:  args.Namespace,
+					ResolveDir: args.ResolveDir,
+					Kind:       args.Kind,
+					PluginData: args.PluginData,
+				})
+				*resolved = append(*resolved, name+": "+result.Path)
+				return OnResolveResult{
+					Path:     result.Path,
+					Errors:   result.Errors,
+					Warnings: result.Warnings,
+				}, nil
+			})
+		},
+	}
+}
+
+func TestPluginResolveSelfRecursion(t *testing.T) {
+	var resolved []string
+	result := buildWithPlugins(filesForResolveTest, resolveAndTweakPlugin("a", &resolved))
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if len(resolved) != 1 || resolved[0] != "a: /node_modules/dep/index.js" {
+		t.Fatalf("Unexpected resolve results: %v", resolved)
+	}
+}
+
+func TestPluginResolveMutualRecursion(t *testing.T) {
+	var resolved []string
+	result := buildWithPlugins(filesForResolveTest,
+		resolveAndTweakPlugin("a", &resolved),
+		resolveAndTweakPlugin("b", &resolved))
+
+	// "a" and "b" keep running inside each other's calls to "Resolve" until
+	// the calls are nested too deeply
+	if len(result.Errors) != 1 || !strings.HasSuffix(result.Errors[0].Text, "Too many nested calls to \"Resolve\"") {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	// The last call is the one that was rejected
+	if len(resolved) != maxResolveDepth+1 {
+		t.Fatalf("Expected %d calls to \"Resolve\", got %d", maxResolveDepth+1, len(resolved))
+	}
+}
+
+func TestPluginResolveBeforeBuild(t *testing.T) {
+	var resolveResult ResolveResult
+	buildWithPlugins(filesForResolveTest, Plugin{
+		Name: "early",
+		Setup: func(build PluginBuild) {
+			resolveResult = build.Resolve("dep", ResolveOptions{ResolveDir: "/", Kind: ResolveJSImportStatement})
+		},
+	})
+	if len(resolveResult.Errors) != 1 || resolveResult.Errors[0].Text != "[early] Cannot call \"Resolve\" before the build has started" {
+		t.Fatalf("Unexpected errors: %v", resolveResult.Errors)
+	}
+	if resolveResult.Path != "" {
+		t.Fatalf("Unexpected path: %q", resolveResult.Path)
+	}
+}
+
+func TestPluginResolveInvalidKind(t *testing.T) {
+	var resolveResult ResolveResult
+	buildWithPlugins(filesForResolveTest, Plugin{
+		Name: "kind",
+		Setup: func(build PluginBuild) {
+			build.OnStart(func() (OnStartResult, error) {
+				resolveResult = build.Resolve("dep", ResolveOptions{ResolveDir: "/", Kind: ResolveKind(255)})
+				return OnStartResult{}, nil
+			})
+		},
+	})
+	if len(resolveResult.Errors) != 1 || resolveResult.Errors[0].Text != "[kind] Invalid resolve kind: 255" {
+		t.Fatalf("Unexpected errors: %v", resolveResult.Errors)
+	}
+}
+
+func TestPluginOnStart(t *testing.T) {
+	loaded := "not started"
+	result := buildWithPlugins(map[string]string{
+		"/entry.js": `import value from 'virtual'; console.log(value)`,
+	}, Plugin{
+		Name: "state",
+		Setup: func(build PluginBuild) {
+			build.OnStart(func() (OnStartResult, error) {
+				loaded = "started"
+				return OnStartResult{Warnings: []Message{{Text: "Starting a new build"}}}, nil
+			})
+			build.OnResolve(OnResolveOptions{Filter: `^virtual$`}, func(args OnResolveArgs) (OnResolveResult, error) {
+				return OnResolveResult{Path: "virtual", Namespace: "virtual"}, nil
+			})
+			build.OnLoad(OnLoadOptions{Filter: `^virtual$`}, func(args OnLoadArgs) (OnLoadResult, error) {
+				contents := "export default " + strconv.Quote(loaded)
+				return OnLoadResult{Contents: &contents}, nil
+			})
+		},
+	})
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Text != "[state] Starting a new build" {
+		t.Fatalf("Unexpected warnings: %v", result.Warnings)
+	}
+	if len(result.OutputFiles) != 1 || !strings.Contains(string(result.OutputFiles[0].Contents), `"started"`) {
+		t.Fatalf("Unexpected output files: %v", result.OutputFiles)
+	}
+}
+
+func TestPluginOnStartInvalidOptions(t *testing.T) {
+	var calls []string
+	result := Build(BuildOptions{
+		EntryPoints: []string{"/entry.js"},
+		Outfile:     "/out.js",
+		Outdir:      "/out",
+		Bundle:      true,
+		LogLevel:    LogLevelSilent,
+		Plugins: []Plugin{{
+			Name: "calls",
+			Setup: func(build PluginBuild) {
+				build.OnStart(func() (OnStartResult, error) {
+					calls = append(calls, "start")
+					return OnStartResult{}, nil
+				})
+				build.OnEnd(func(result *BuildResult) (OnEndResult, error) {
+					calls = append(calls, "end")
+					return OnEndResult{}, nil
+				})
+			},
+		}},
+		FS: fs.MockFS(filesForResolveTest),
+	})
+	if len(result.Errors) != 1 || result.Errors[0].Text != `Cannot use both "outfile" and "outdir"` {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if strings.Join(calls, ",") != "start,end" {
+		t.Fatalf("Unexpected callbacks: %v", calls)
+	}
+}
+
+func onEndPlugin(name string, callback func(result *BuildResult) (OnEndResult, error)) Plugin {
+	return Plugin{
+		Name: name,
+		Setup: func(build PluginBuild) {
+			build.OnEnd(callback)
+		},
+	}
+}
+
+func TestPluginOnEndFailedBuild(t *testing.T) {
+	var endErrors []Message
+	result := buildWithPlugins(map[string]string{
+		"/entry.js": `import './missing'`,
+	}, onEndPlugin("end", func(result *BuildResult) (OnEndResult, error) {
+		endErrors = result.Errors
+		return OnEndResult{}, nil
+	}))
+	if len(endErrors) != 1 || !strings.Contains(endErrors[0].Text, "./missing") {
+		t.Fatalf("Unexpected errors passed to OnEnd: %v", endErrors)
+	}
+	if len(result.Errors) != 1 || len(result.OutputFiles) != 0 {
+		t.Fatalf("Unexpected result: %v", result)
+	}
+}
+
+func TestPluginOnEndRebuild(t *testing.T) {
+	count := 0
+	result := Build(BuildOptions{
+		EntryPoints: []string{"/entry.js"},
+		Outfile:     "/out.js",
+		Bundle:      true,
+		Incremental: true,
+		LogLevel:    LogLevelSilent,
+		Plugins: []Plugin{onEndPlugin("end", func(result *BuildResult) (OnEndResult, error) {
+			count++
+			return OnEndResult{}, nil
+		})},
+		FS: fs.MockFS(filesForResolveTest),
+	})
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	result = result.Rebuild()
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if count != 2 {
+		t.Fatalf("Expected OnEnd to run twice, ran %d times", count)
+	}
+}
+
+func TestPluginOnEndChangesResult(t *testing.T) {
+	var warnings []Message
+	result := buildWithPlugins(filesForResolveTest,
+		onEndPlugin("a", func(result *BuildResult) (OnEndResult, error) {
+			result.OutputFiles = append(result.OutputFiles, OutputFile{Path: "/extra.txt", Contents: []byte("extra")})
+			return OnEndResult{Warnings: []Message{{Text: "first"}}}, nil
+		}),
+		onEndPlugin("b", func(result *BuildResult) (OnEndResult, error) {
+			// Later callbacks see the changes made by earlier ones
+			warnings = result.Warnings
+			return OnEndResult{}, nil
+		}))
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if len(result.OutputFiles) != 2 || result.OutputFiles[1].Path != "/extra.txt" || string(result.OutputFiles[1].Contents) != "extra" {
+		t.Fatalf("Unexpected output files: %v", result.OutputFiles)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Text != "[a] first" {
+		t.Fatalf("Unexpected warnings: %v", result.Warnings)
+	}
+	if len(warnings) != 1 || warnings[0].Text != "[a] first" {
+		t.Fatalf("Unexpected warnings passed to OnEnd: %v", warnings)
+	}
+}
+
+func TestPluginOnEndError(t *testing.T) {
+	ranAfterError := false
+	result := buildWithPlugins(filesForResolveTest,
+		onEndPlugin("a", func(result *BuildResult) (OnEndResult, error) {
+			return OnEndResult{Warnings: []Message{{Text: "ignored"}}}, errors.New("failure")
+		}),
+		onEndPlugin("b", func(result *BuildResult) (OnEndResult, error) {
+			ranAfterError = true
+			return OnEndResult{}, nil
+		}))
+	if len(result.Errors) != 1 || result.Errors[0].Text != "[a] failure" {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", result.Warnings)
+	}
+	if !ranAfterError {
+		t.Fatal("Expected the OnEnd callback after the error to run")
+	}
+}
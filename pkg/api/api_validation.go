@@ -33,6 +33,9 @@ func ErrorToWarningLogger(log *logger.Log, failureType string) logger.Log {
 		Done: func() []logger.Msg {
 			return log.Done()
 		},
+		Peek: func() []logger.Msg {
+			return log.Peek()
+		},
 	}
 	return forgivingLog
 }
@@ -116,3 +119,12 @@ func tryFindLocInside(js *[]byte, needle string, skip int) int32 {
 	}
 	return int32(offset + loc - needleLen)
 }
+
+// Validates the target, engines and platform of a snapshot build with the same helpers that are
+// used when building, so that invalid snapshot configurations are reported before the build runs.
+func ValidateSnapshotTarget(target Target, engines []Engine, platform Platform) []Message {
+	log := logger.NewDeferLog()
+	validatePlatform(log, platform)
+	validateFeatures(log, target, engines)
+	return convertMessagesToPublic(logger.Error, log.Done())
+}
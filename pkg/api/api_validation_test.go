@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/internal/fs"
+)
+
+func TestValidateSnapshotTarget(t *testing.T) {
+	expect := func(target Target, engines []Engine, platform Platform, expected ...string) {
+		t.Helper()
+		errors := ValidateSnapshotTarget(target, engines, platform)
+		if len(errors) != len(expected) {
+			t.Fatalf("Expected %d errors, got %v", len(expected), errors)
+		}
+		for i, text := range expected {
+			if errors[i].Text != text {
+				t.Fatalf("Expected error %q, got %q", text, errors[i].Text)
+			}
+		}
+	}
+
+	expect(ES2020, nil, PlatformNode)
+	expect(ESNext, []Engine{{Name: EngineChrome, Version: "91"}, {Name: EngineNode, Version: "12.4"}}, PlatformBrowser)
+	expect(ES2019, []Engine{{Name: EngineNode, Version: "12.x"}}, PlatformNode, `Invalid version: "12.x"`)
+	expect(Target(100), nil, PlatformNode, "Invalid target: 100")
+	expect(ES2020, []Engine{{Name: EngineName(100), Version: "1"}}, PlatformNode, "Invalid engine name: 100")
+	expect(ES2020, nil, Platform(100), "Invalid platform: 100")
+}
+
+func TestBuildInvalidTargetAndPlatform(t *testing.T) {
+	result := Build(BuildOptions{
+		EntryPoints: []string{"/entry.js"},
+		Outfile:     "/out.js",
+		LogLevel:    LogLevelSilent,
+		Target:      Target(100),
+		Platform:    Platform(100),
+		FS:          fs.MockFS(map[string]string{"/entry.js": `console.log(1)`}),
+	})
+	if len(result.Errors) != 2 || result.Errors[0].Text != "Invalid target: 100" || result.Errors[1].Text != "Invalid platform: 100" {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+}
@@ -230,6 +230,20 @@ func parseOptionsImpl(
 		case strings.HasPrefix(arg, "--outbase=") && buildOpts != nil:
 			buildOpts.Outbase = arg[len("--outbase="):]
 
+		case strings.HasPrefix(arg, "--chrome-trace=") && buildOpts != nil:
+			buildOpts.ChromeTraceFile = arg[len("--chrome-trace="):]
+
+		case strings.HasPrefix(arg, "--cache-dir=") && buildOpts != nil:
+			buildOpts.CacheDir = arg[len("--cache-dir="):]
+
+		case strings.HasPrefix(arg, "--cache-max-size=") && buildOpts != nil:
+			value := arg[len("--cache-max-size="):]
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return fmt.Errorf("Invalid cache max size: %q", value), nil
+			}
+			buildOpts.CacheMaxSize = size
+
 		case strings.HasPrefix(arg, "--tsconfig=") && buildOpts != nil:
 			buildOpts.Tsconfig = arg[len("--tsconfig="):]
 
@@ -309,6 +323,17 @@ func parseOptionsImpl(
 				transformOpts.Engines = engines
 			}
 
+		case strings.HasPrefix(arg, "--alias:") && buildOpts != nil:
+			value := arg[len("--alias:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return fmt.Errorf("Missing \"=\": %q", value), nil
+			}
+			if buildOpts.Alias == nil {
+				buildOpts.Alias = make(map[string]string)
+			}
+			buildOpts.Alias[value[:equals]] = value[equals+1:]
+
 		case strings.HasPrefix(arg, "--out-extension:") && buildOpts != nil:
 			value := arg[len("--out-extension:"):]
 			equals := strings.IndexByte(value, '=')
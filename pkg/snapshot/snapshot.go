@@ -0,0 +1,350 @@
+// This package builds snapshot bundles, i.e. bundles in which modules are rewritten so that they
+// can be loaded into a V8 snapshot. It provides the same defaults as the `snapshot` command but
+// returns typed results so that Go tools can embed snapshot generation directly.
+package snapshot
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/snap_api"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// Modules which are always excluded from the snapshot.
+var DefaultExternals = []string{
+	// should always be excluded
+	"electron",
+	// Causes numerous problems including FATAL:v8_context_snapshot_impl.cc(229)] Unknown WrapperTypeInfo
+	// when running mksnapshot
+	"bluebird",
+}
+
+// Entry points of packages which pick their implementation at runtime, which prevents statically
+// determining which module is actually loaded.
+var DefaultPackageEntryRedirects = []api.PackageEntryRedirect{
+	// https://github.com/bevry/safefs/blob/11c7818dc3b3968e080003e0e960ae13e487dd1a/es6guardian.js
+	{PackageName: "safefs", Main: "es6guardian.js", Redirect: "es5/lib/safefs.js"},
+	// Loads its edition via a custom loader like the above which also queries a `package.json` file,
+	// an I/O operation we prefer to avoid.
+	{PackageName: "istextorbinary", Main: "index.cjs", Redirect: "edition-esnext/index.js"},
+}
+
+// The target and node version the snapshot is created for unless configured otherwise.
+const DefaultTarget = api.ES2020
+const DefaultNodeVersion = "12.4"
+
+type SnapshotBuildOptions struct {
+	// The snapshot entry file
+	Entryfile string
+	// The full path project root relative to which modules are resolved
+	Basedir string
+	// The bundle is written to this file when `Write` is `true`
+	Outfile string
+	Write   bool
+
+	// Relative paths of modules to defer
+	Deferred []string
+	// Relative paths of modules which should not be rewritten and are also deferred.
+	// Entries starting with "*/" match any path ending with the remainder.
+	Norewrite []string
+	// Modules that are not included in the bundle in addition to `DefaultExternals`
+	External []string
+
+	// Defaults to `DefaultTarget`
+	Target *api.Target
+	// Defaults to node `DefaultNodeVersion` when nil and building for node
+	Engines []api.Engine
+	// Defaults to node, renderer snapshots use the browser platform in order to honor the
+	// "browser" field in "package.json"
+	Platform *api.Platform
+
+	MainFields []string
+	// For the "exports" field in "package.json"
+	Conditions []string
+	Define     map[string]string
+	Inject     []string
+	// Entry points of packages to replace in addition to `DefaultPackageEntryRedirects`
+	PackageEntryRedirects []api.PackageEntryRedirect
+
+	// When true stricter validations are performed to detect problematic code
+	Doctor bool
+	// Adds, reclassifies or removes (via `api.ProtectedGlobalIgnore`) the globals the doctor
+	// protects from mutation
+	ProtectedGlobals map[string]api.ProtectedGlobalKind
+	// Function invoked at runtime to resolve __dirname and __filename
+	ResolvePathFn string
+	// When true paths relative to the basedir are passed to `ResolvePathFn`
+	InlineRelativePaths bool
+
+	Metafile  bool
+	Sourcemap bool
+
+	// Parse results are stored in this directory to speed up later builds of
+	// unchanged files, and it's kept under `CacheMaxSize` bytes
+	CacheDir     string
+	CacheMaxSize int64
+
+	// Spans for each phase of the build, including the snapshot printer for
+	// each file, are written to this file as Chrome trace events
+	ChromeTraceFile string
+
+	// Defaults to `api.LogLevelInfo`
+	LogLevel *api.LogLevel
+
+	// Overrides the file system, mainly used for testing
+	FS fs.FS
+}
+
+// The verdict for a module is derived from the validation errors reported while it was rewritten.
+type Verdict uint8
+
+const (
+	// The module was rewritten and can be included in the snapshot
+	VerdictRewrite Verdict = iota
+	// The module needs to be deferred, i.e. it should only run once the snapshot was loaded
+	VerdictDefer
+	// The module cannot be rewritten and is thus also deferred
+	VerdictNoRewrite
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictDefer:
+		return "defer"
+	case VerdictNoRewrite:
+		return "norewrite"
+	default:
+		return "rewrite"
+	}
+}
+
+type ModuleVerdict struct {
+	Verdict Verdict
+	// The messages of all validation errors that were reported for the module
+	Messages []string
+}
+
+type MetafileImport struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+type MetafileInput struct {
+	Bytes    int `json:"bytes"`
+	FileInfo struct {
+		FullPath string `json:"fullPath"`
+	} `json:"fileInfo"`
+	Imports []MetafileImport `json:"imports"`
+}
+
+type MetafileOutputInput struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+type MetafileOutput struct {
+	Bytes      int                            `json:"bytes"`
+	Imports    []MetafileImport               `json:"imports"`
+	Exports    []string                       `json:"exports"`
+	EntryPoint string                         `json:"entryPoint,omitempty"`
+	Inputs     map[string]MetafileOutputInput `json:"inputs"`
+}
+
+type Metafile struct {
+	Inputs  map[string]MetafileInput  `json:"inputs"`
+	Outputs map[string]MetafileOutput `json:"outputs"`
+	// Maps "<resolve dir>***<import path>" to the path the import resolved to
+	ResolverMap map[string]string `json:"resolverMap"`
+}
+
+type SnapshotResult struct {
+	Errors   []api.Message
+	Warnings []api.Message
+
+	// The generated bundle and source map, only set when `Write` is `false`
+	Bundle    []byte
+	SourceMap []byte
+
+	// Only set when `Metafile` is `true`
+	Metafile *Metafile
+	// Maps "<resolve dir>***<import path>" to the path the import resolved to,
+	// only set when `Metafile` is `true`
+	ResolverMap map[string]string
+
+	// Verdicts for all modules for which validation errors were reported, keyed by the same
+	// paths as the metafile inputs
+	Verdicts map[string]ModuleVerdict
+
+	// The result of the underlying build
+	BuildResult api.BuildResult
+}
+
+// Returns the verdict for the module at the given path, modules without validation errors are
+// rewritten.
+func (result *SnapshotResult) Verdict(path string) Verdict {
+	if verdict, ok := result.Verdicts[path]; ok {
+		return verdict.Verdict
+	}
+	return VerdictRewrite
+}
+
+// Invalid targets, engines and platforms are returned as errors without building.
+func Build(options SnapshotBuildOptions) SnapshotResult {
+	platform := api.PlatformNode
+	if options.Platform != nil {
+		platform = *options.Platform
+	}
+
+	target := DefaultTarget
+	if options.Target != nil {
+		target = *options.Target
+	}
+
+	engines := options.Engines
+	if engines == nil && platform == api.PlatformNode {
+		engines = []api.Engine{
+			{Name: api.EngineNode, Version: DefaultNodeVersion},
+		}
+	}
+
+	if errors := api.ValidateSnapshotTarget(target, engines, platform); len(errors) > 0 {
+		return SnapshotResult{Errors: errors}
+	}
+
+	external := append(append([]string{}, DefaultExternals...), options.External...)
+	redirects := append(append([]api.PackageEntryRedirect{}, DefaultPackageEntryRedirects...), options.PackageEntryRedirects...)
+
+	logLevel := api.LogLevelInfo
+	if options.LogLevel != nil {
+		logLevel = *options.LogLevel
+	}
+
+	sourcemap := api.SourceMapNone
+	if options.Sourcemap {
+		sourcemap = api.SourceMapExternal
+	}
+
+	outfile := options.Outfile
+	if !options.Write && outfile == "" {
+		outfile = virtualOutfile(options.Entryfile)
+	}
+
+	shouldRewriteModule := snap_api.CreateShouldRewriteModuleFromNorewrite(options.Norewrite)
+	shouldReplaceRequire := snap_api.CreateShouldReplaceRequire(
+		platform,
+		external,
+		snap_api.CreateShouldReplaceRequireFromDeferred(options.Deferred),
+		shouldRewriteModule)
+
+	buildResult := api.Build(api.BuildOptions{
+		LogLevel: logLevel,
+		Target:   target,
+		Bundle:   true,
+		Metafile: options.Metafile,
+
+		Outfile:     outfile,
+		EntryPoints: []string{options.Entryfile},
+
+		// Setting to Node results in:
+		// - the default output format is set to cjs
+		// - built-in node modules such as fs are automatically marked as external
+		// - disables the interpretation of the browser field in package.json
+		Platform:   platform,
+		Engines:    engines,
+		MainFields: options.MainFields,
+		Conditions: options.Conditions,
+		Define:     options.Define,
+		Inject:     options.Inject,
+		Format:     api.FormatCommonJS,
+		External:   external,
+		Write:      options.Write,
+		Sourcemap:  sourcemap,
+
+		PackageEntryRedirects: redirects,
+		CacheDir:              options.CacheDir,
+		CacheMaxSize:          options.CacheMaxSize,
+		ChromeTraceFile:       options.ChromeTraceFile,
+
+		Snapshot: &api.SnapshotOptions{
+			CreateSnapshot:       true,
+			ShouldReplaceRequire: shouldReplaceRequire,
+			ShouldRewriteModule:  shouldRewriteModule,
+			AbsBasedir:           options.Basedir,
+			Doctor:               options.Doctor,
+			ProtectedGlobals:     options.ProtectedGlobals,
+			ResolvePathFn:        options.ResolvePathFn,
+			InlineRelativePaths:  options.InlineRelativePaths,
+			VerifyPrint:          true,
+			PanicOnError:         false,
+		},
+		FS: options.FS,
+	})
+
+	result := SnapshotResult{
+		Errors:      buildResult.Errors,
+		Warnings:    buildResult.Warnings,
+		Verdicts:    verdictsFromWarnings(buildResult.Warnings),
+		BuildResult: buildResult,
+	}
+
+	for _, file := range buildResult.OutputFiles {
+		if strings.HasSuffix(file.Path, ".map") {
+			result.SourceMap = file.Contents
+		} else {
+			result.Bundle = file.Contents
+		}
+	}
+
+	if options.Metafile && buildResult.Metafile != "" {
+		metafile := Metafile{}
+		if err := json.Unmarshal([]byte(buildResult.Metafile), &metafile); err != nil {
+			result.Errors = append(result.Errors, api.Message{Text: "Failed to parse metafile: " + err.Error()})
+		} else {
+			result.Metafile = &metafile
+			result.ResolverMap = metafile.ResolverMap
+		}
+	}
+
+	return result
+}
+
+// When the bundle isn't written esbuild still needs an output path in order to include the
+// metafile and source map with the output files. This is the same path that is used when the
+// entry file is bundled into an output directory of "/", thus the paths in the results don't
+// change depending on how the bundle is produced.
+func virtualOutfile(entryfile string) string {
+	base := filepath.Base(entryfile)
+	return "/" + strings.TrimSuffix(base, filepath.Ext(base)) + ".js"
+}
+
+func verdictsFromWarnings(warnings []api.Message) map[string]ModuleVerdict {
+	verdicts := make(map[string]ModuleVerdict)
+	for _, warning := range warnings {
+		if warning.Location == nil {
+			continue
+		}
+		var verdict Verdict
+		var msg string
+		if strings.HasPrefix(warning.Text, api.SNAPSHOT_REWRITE_FAILURE) {
+			verdict = VerdictNoRewrite
+			msg = strings.TrimPrefix(warning.Text, api.SNAPSHOT_REWRITE_FAILURE)
+		} else if strings.HasPrefix(warning.Text, api.SNAPSHOT_CACHE_FAILURE) {
+			verdict = VerdictDefer
+			msg = strings.TrimPrefix(warning.Text, api.SNAPSHOT_CACHE_FAILURE)
+		} else {
+			continue
+		}
+
+		path := warning.Location.File
+		moduleVerdict := verdicts[path]
+		// Modules that cannot be rewritten are deferred as well
+		if verdict > moduleVerdict.Verdict {
+			moduleVerdict.Verdict = verdict
+		}
+		moduleVerdict.Messages = append(moduleVerdict.Messages, strings.TrimSpace(msg))
+		verdicts[path] = moduleVerdict
+	}
+	return verdicts
+}
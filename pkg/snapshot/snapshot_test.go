@@ -0,0 +1,214 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+const projectBaseDir = "/dev"
+
+func buildFiles(files map[string]string, options SnapshotBuildOptions) SnapshotResult {
+	options.Entryfile = projectBaseDir + "/entry.js"
+	options.Basedir = projectBaseDir
+	options.Metafile = true
+	if options.LogLevel == nil {
+		silent := api.LogLevelSilent
+		options.LogLevel = &silent
+	}
+	options.FS = fs.MockFS(files)
+	return Build(options)
+}
+
+func TestBuildReturnsBundleAndMetafile(t *testing.T) {
+	result := buildFiles(map[string]string{
+		projectBaseDir + "/entry.js": `
+			const { oneTwoThree } = require('./foo')
+			module.exports = function () { return oneTwoThree }
+		`,
+		projectBaseDir + "/foo.js": `exports.oneTwoThree = 123`,
+	}, SnapshotBuildOptions{})
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if !strings.Contains(string(result.Bundle), `__commonJS["./foo.js"]`) {
+		t.Fatalf("bundle should include foo.js\n%s", result.Bundle)
+	}
+	if result.Metafile == nil {
+		t.Fatal("metafile should have been parsed")
+	}
+	if len(result.Metafile.Inputs) != 2 {
+		t.Fatalf("expected 2 inputs, got %v", result.Metafile.Inputs)
+	}
+	for path, input := range result.Metafile.Inputs {
+		if input.FileInfo.FullPath == "" {
+			t.Fatalf("missing full path for %s", path)
+		}
+	}
+	if len(result.Metafile.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %v", result.Metafile.Outputs)
+	}
+	if len(result.ResolverMap) != 1 {
+		t.Fatalf("expected 1 resolved import, got %v", result.ResolverMap)
+	}
+	if len(result.Verdicts) != 0 {
+		t.Fatalf("expected no verdicts, got %v", result.Verdicts)
+	}
+}
+
+func TestBuildReportsModuleVerdicts(t *testing.T) {
+	result := buildFiles(map[string]string{
+		projectBaseDir + "/entry.js": `
+			require('./deferred')
+			require('./healthy')
+		`,
+		projectBaseDir + "/deferred.js": `Error.prepareStackTrace = function () {}`,
+		projectBaseDir + "/healthy.js":  `exports.a = 1`,
+	}, SnapshotBuildOptions{Doctor: true})
+
+	var deferredPath, healthyPath string
+	for path := range result.Metafile.Inputs {
+		if strings.HasSuffix(path, "deferred.js") {
+			deferredPath = path
+		} else if strings.HasSuffix(path, "healthy.js") {
+			healthyPath = path
+		}
+	}
+
+	verdict, ok := result.Verdicts[deferredPath]
+	if !ok || verdict.Verdict != VerdictDefer {
+		t.Fatalf("expected %s to be deferred, got %v", deferredPath, result.Verdicts)
+	}
+	if len(verdict.Messages) != 1 || verdict.Messages[0] != "Cannot override 'Error.prepareStackTrace'" {
+		t.Fatalf("unexpected messages %v", verdict.Messages)
+	}
+	if result.Verdict(healthyPath) != VerdictRewrite {
+		t.Fatalf("expected %s to be rewritten", healthyPath)
+	}
+}
+
+func TestBuildForBrowserPlatformHonorsBrowserField(t *testing.T) {
+	files := map[string]string{
+		projectBaseDir + "/entry.js": `module.exports = require('pkg')`,
+		projectBaseDir + "/node_modules/pkg/package.json": `{
+			"main": "./node.js",
+			"browser": "./browser.js"
+		}`,
+		projectBaseDir + "/node_modules/pkg/node.js":    `exports.env = 'node'`,
+		projectBaseDir + "/node_modules/pkg/browser.js": `exports.env = 'browser'`,
+	}
+
+	result := buildFiles(files, SnapshotBuildOptions{})
+	if !strings.Contains(string(result.Bundle), `"node"`) {
+		t.Fatalf("node build should use the main field\n%s", result.Bundle)
+	}
+
+	browser := api.PlatformBrowser
+	target := api.ES2020
+	result = buildFiles(files, SnapshotBuildOptions{
+		Platform: &browser,
+		Target:   &target,
+		Engines:  []api.Engine{{Name: api.EngineChrome, Version: "91"}},
+	})
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if !strings.Contains(string(result.Bundle), `"browser"`) {
+		t.Fatalf("browser build should use the browser field\n%s", result.Bundle)
+	}
+}
+
+func TestBuildRedirectsPackageEntries(t *testing.T) {
+	files := map[string]string{
+		projectBaseDir + "/entry.js": `module.exports = [require('safefs'), require('pkg')]`,
+		projectBaseDir + "/node_modules/safefs/package.json": `{
+			"name": "safefs",
+			"main": "es6guardian.js"
+		}`,
+		projectBaseDir + "/node_modules/safefs/es6guardian.js":    `module.exports = require('editions').requirePackage(__dirname, require)`,
+		projectBaseDir + "/node_modules/safefs/es5/lib/safefs.js": `exports.edition = 'safefs-es5'`,
+		projectBaseDir + "/node_modules/pkg/package.json": `{
+			"name": "pkg",
+			"main": "./loader.js"
+		}`,
+		projectBaseDir + "/node_modules/pkg/loader.js":  `module.exports = require(process.env.PKG_EDITION)`,
+		projectBaseDir + "/node_modules/pkg/edition.js": `exports.edition = 'pkg-edition'`,
+	}
+
+	result := buildFiles(files, SnapshotBuildOptions{
+		PackageEntryRedirects: []api.PackageEntryRedirect{
+			{PackageName: "pkg", Main: "loader.js", Redirect: "edition.js"},
+		},
+	})
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	for _, edition := range []string{"safefs-es5", "pkg-edition"} {
+		if !strings.Contains(string(result.Bundle), edition) {
+			t.Fatalf("bundle should include the redirected %s entry\n%s", edition, result.Bundle)
+		}
+	}
+	if strings.Contains(string(result.Bundle), "requirePackage") || strings.Contains(string(result.Bundle), "PKG_EDITION") {
+		t.Fatalf("bundle should not include the original entries\n%s", result.Bundle)
+	}
+}
+
+// Log messages are written directly to stderr, so capture it while building
+func buildFilesAndCaptureStderr(t *testing.T, files map[string]string, options SnapshotBuildOptions) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = writer
+	buildFiles(files, options)
+	os.Stderr = stderr
+	writer.Close()
+	output, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestBuildInvalidTarget(t *testing.T) {
+	files := map[string]string{
+		projectBaseDir + "/entry.js": `module.exports = 1`,
+	}
+	target := api.Target(100)
+	result := buildFiles(files, SnapshotBuildOptions{Target: &target})
+	if len(result.Errors) != 1 || result.Errors[0].Text != "Invalid target: 100" {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if result.Bundle != nil {
+		t.Fatalf("expected no bundle, got\n%s", result.Bundle)
+	}
+
+	platform := api.Platform(100)
+	result = buildFiles(files, SnapshotBuildOptions{Platform: &platform})
+	if len(result.Errors) != 1 || result.Errors[0].Text != "Invalid platform: 100" {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestBuildLogLevel(t *testing.T) {
+	files := map[string]string{
+		projectBaseDir + "/entry.js": `module.exports = require('./missing')`,
+	}
+
+	silent := api.LogLevelSilent
+	if output := buildFilesAndCaptureStderr(t, files, SnapshotBuildOptions{LogLevel: &silent}); output != "" {
+		t.Fatalf("silent build should not log anything\n%s", output)
+	}
+
+	info := api.LogLevelInfo
+	if output := buildFilesAndCaptureStderr(t, files, SnapshotBuildOptions{LogLevel: &info}); !strings.Contains(output, "./missing") {
+		t.Fatalf("info build should log the error\n%s", output)
+	}
+}
@@ -177,6 +177,33 @@ mergeVersions('TopLevelAwait', {
   node14_8: true,
 })
 
+// Manually copied from https://developer.mozilla.org/en-US/docs/Web/JavaScript/Reference/Classes/Class_static_initialization_blocks
+mergeVersions('ClassStaticBlocks', {
+  chrome91: true,
+  edge94: true,
+  es2022: true,
+  firefox93: true,
+  node16_11: true,
+})
+
+// Manually copied from https://developer.mozilla.org/en-US/docs/Web/JavaScript/Reference/Operators/in
+mergeVersions('ClassPrivateBrandCheck', {
+  chrome91: true,
+  edge91: true,
+  es2022: true,
+  firefox90: true,
+  ios15: true,
+  node16_9: true,
+  safari15: true,
+})
+
+// Manually copied from https://v8.dev/features/import-assertions
+mergeVersions('ImportAssertions', {
+  chrome91: true,
+  edge91: true,
+  node16_14: true,
+})
+
 // Manually copied from https://caniuse.com/es6-module-dynamic-import
 mergeVersions('DynamicImport', {
   chrome63: true,
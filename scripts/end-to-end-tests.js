@@ -273,6 +273,40 @@
     }),
   )
 
+  // Test that lowered "super" property accesses run getters and setters with the instance as "this"
+  let superPropertySemantics = `
+    class A {
+      constructor(v) { this.v = v }
+      get g() { return this.v }
+      get n() { return this._n || 10 }
+      set n(value) { this._n = value }
+      static get s() { return this.name }
+    }
+    class B extends A {
+      get() { return super.g }
+      getArrow() { return (() => super.g)() }
+      set() { super.z = 3; super.n = 7 }
+      update(key) { return [super.n += 2, super.n++, ++super[key], super[key]--, this._n] }
+      static get() { return super.s }
+    }
+    let b = new B(5)
+    if (b.get() !== 5) throw 'fail: 1'
+    if (b.getArrow() !== 5) throw 'fail: 2'
+    b.set()
+    if (b.z !== 3 || A.prototype.hasOwnProperty('z')) throw 'fail: 3'
+    if (b._n !== 7 || A.prototype.hasOwnProperty('_n')) throw 'fail: 4'
+    if (new B(0).update('n').join() !== '12,12,14,14,13') throw 'fail: 5'
+    if (B.get() !== 'B') throw 'fail: 6'
+  `
+  tests.push(
+    test(['in.js', '--outfile=node.js', '--target=es6'], {
+      'in.js': superPropertySemantics,
+    }),
+    test(['in.js', '--outfile=node.js', '--target=es5'], {
+      'in.js': superPropertySemantics,
+    }),
+  )
+
   let simpleCyclicImportTestCase542 = {
     'in.js': `
       import {Test} from './lib';
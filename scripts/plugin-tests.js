@@ -1918,6 +1918,41 @@ let pluginTests = {
       }],
     })
   },
+
+  async pathSuffix({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const icon = path.join(testDir, 'icon.svg')
+    const output = path.join(testDir, 'out.js')
+    await writeFileAsync(input, `
+      import x from './icon.svg?inline'
+      export default x
+    `)
+    await writeFileAsync(icon, `<svg></svg>`)
+    let loadArgs
+    await esbuild.build({
+      entryPoints: [input],
+      bundle: true,
+      outfile: output,
+      format: 'cjs',
+      plugins: [{
+        name: 'name',
+        setup(build) {
+          build.onResolve({ filter: /\?inline$/ }, args => {
+            return { path: path.join(args.resolveDir, args.path.slice(0, -'?inline'.length)), suffix: '?inline' }
+          })
+          build.onLoad({ filter: /\.svg$/ }, async (args) => {
+            loadArgs = args
+            const contents = await readFileAsync(args.path, 'utf8')
+            return { contents, loader: args.suffix === '?inline' ? 'text' : 'file' }
+          })
+        },
+      }],
+    })
+    assert.strictEqual(loadArgs.path, icon)
+    assert.strictEqual(loadArgs.suffix, '?inline')
+    const result = require(output)
+    assert.strictEqual(result.default, '<svg></svg>')
+  },
 }
 
 // These tests have to run synchronously